@@ -0,0 +1,7 @@
+package main
+
+import "santoshkal/mcp-godocker/pkg/cmd"
+
+func main() {
+	cmd.Execute()
+}
@@ -1,43 +1,55 @@
+// Command client is a minimal example of driving the MCP server from Go: it asks for a plan
+// and executes it.
+//
+// Deprecated: use the typed client in pkg/rpcclient directly, or the mcp CLI's plan and
+// apply commands.
 package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
 
-	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/config"
 	"santoshkal/mcp-godocker/pkg/rpcclient"
 )
 
 func main() {
-	client := rpcclient.NewRPCClient("http://localhost:1234/rpc")
-	ctx := context.Background()
+	verbose := flag.Bool("v", false, "print the raw JSON-RPC requests and responses")
+	flag.Parse()
+	timeouts, err := config.TimeoutsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := rpcclient.NewRPCClient("http://localhost:1234/rpc", timeouts.HTTPClient).WithToken(os.Getenv(config.EnvAPIToken))
+	if *verbose {
+		client.WithVerbose(os.Stderr)
+	}
+	if err := run(context.Background(), client, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
 
+// run asks the server for a plan and executes it, printing both to out.
+func run(ctx context.Context, client *rpcclient.RPCClient, out io.Writer) error {
 	// Example: Call LLM to generate a plan.
 	userInstructions := "Pull postgres:latest image"
 
 	// userInstructions := "Generate a plan to create a MySQL container"
-	var planJSON string
-	if err := client.CallAndParse(ctx, "Server.CallLLM", &planJSON, userInstructions); err != nil {
-		log.Fatalf("Error calling Server.CallLLM: %v", err)
-	}
-	fmt.Printf("Plan JSON: %s\n", planJSON)
-
-	// Validate plan JSON.
-	if !json.Valid([]byte(planJSON)) {
-		log.Fatalf("Invalid JSON received from Server.CallLLM")
+	planJSON, err := client.CallLLM(ctx, userInstructions)
+	if err != nil {
+		return fmt.Errorf("error calling Server.CallLLM: %w", err)
 	}
+	fmt.Fprintf(out, "Plan JSON: %s\n", planJSON)
 
 	// Execute the plan.
-	var execResp mcp.RPCResponse
-	if err := client.CallAndParse(ctx, "Server.ExecutePlan", &execResp, planJSON); err != nil {
-		log.Fatalf("Error calling Server.ExecutePlan: %v", err)
-	}
-
-	var result map[string]string
-	if err := json.Unmarshal(execResp.Result, &result); err != nil {
-		log.Fatalf("Error unmarshalling result: %v", err)
+	result, err := client.ExecutePlan(ctx, planJSON)
+	if err != nil {
+		return fmt.Errorf("error calling Server.ExecutePlan: %w", err)
 	}
-	fmt.Printf("Plan execution result: Status=%s, Message=%s\n", result["status"], result["message"])
+	fmt.Fprintf(out, "Plan execution result: Status=%s, Message=%s\n", result.Status, result.Message)
+	return nil
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/rpcclient"
+)
+
+func TestRun(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		methods = append(methods, req.Method)
+		switch req.Method {
+		case "Server.CallLLM":
+			w.Write([]byte(`{"result": "[{\"action\":\"pull_image\",\"parameters\":{\"image\":\"postgres:latest\"}}]", "id": 1}`))
+		case "Server.ExecutePlan":
+			w.Write([]byte(`{"result": {"jsonrpc": "2.0", "result": {"status": "success", "message": "Plan executed"}}, "id": 2}`))
+		default:
+			http.Error(w, "unexpected method", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var out, log bytes.Buffer
+	client := rpcclient.NewRPCClient(srv.URL, time.Minute).WithVerbose(&log)
+	if err := run(context.Background(), client, &out); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if strings.Join(methods, ",") != "Server.CallLLM,Server.ExecutePlan" {
+		t.Errorf("methods called = %v", methods)
+	}
+	if !strings.Contains(out.String(), "Status=success, Message=Plan executed") {
+		t.Errorf("output = %q, want the plan result", out.String())
+	}
+	if strings.Count(log.String(), "--> ") != 2 || strings.Count(log.String(), "<-- 200 ") != 2 {
+		t.Errorf("verbose log = %q, want each request and response", log.String())
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/utils"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "generate a plan for an instruction and apply it",
+	Long:  `Send a natural-language instruction to the MCP Server, show the generated plan for confirmation and then execute it, reporting the result of every action.`,
+	RunE:  runapplyCmd,
+}
+
+type applyFlags struct {
+	input    string
+	endpoint string
+	project  string
+	yes      bool
+}
+
+var applyArgs applyFlags
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyArgs.input, "input", "i", "", "Natural-language instruction to plan and apply")
+	applyCmd.Flags().StringVarP(&applyArgs.endpoint, "endpoint", "e", "", "Specify the endpoint for the MCP Server (defaults to the configured endpoint)")
+	applyCmd.Flags().StringVarP(&applyArgs.project, "project", "p", "", "Project the plan is applied to (defaults to the configured default project)")
+	applyCmd.Flags().BoolVarP(&applyArgs.yes, "yes", "y", false, "Apply the plan without asking for confirmation")
+	_ = applyCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(applyCmd)
+}
+
+func runapplyCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if applyArgs.endpoint != "" {
+		cfg.Endpoint = applyArgs.endpoint
+	}
+	if applyArgs.project != "" {
+		cfg.DefaultProject = applyArgs.project
+	}
+	client, err := newRPCClient(cfg.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	spin := utils.StartSpinner("Generating plan, please hold-on for a moment...")
+	planJSON, err := client.CallLLM(cmd.Context(), applyArgs.input)
+	spin.Stop()
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
+	var plan []map[string]interface{}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return fmt.Errorf("server returned an invalid JSON plan: %w", err)
+	}
+
+	if !applyArgs.yes {
+		if err := printJSON(cmd.OutOrStdout(), []byte(planJSON)); err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), "Apply this plan? [y/N]: ")
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(cmd.OutOrStdout(), "Plan not applied.")
+			return nil
+		}
+	}
+
+	doc, err := json.Marshal(mcp.PlanDocument{Project: cfg.DefaultProject, Plan: plan})
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	spin = utils.StartSpinner("Applying plan...")
+	result, err := client.ExecutePlan(cmd.Context(), string(doc))
+	spin.Stop()
+	var rpcErr *mcp.RPCError
+	if errors.As(err, &rpcErr) {
+		color.New(color.FgRed).Fprintf(cmd.ErrOrStderr(), "✗ %s\n", rpcErr.Message)
+		return fmt.Errorf("plan execution failed")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute plan: %w", err)
+	}
+	printApplyResult(cmd, result)
+	return nil
+}
+
+// printApplyResult renders the status of each executed action followed by the summary.
+func printApplyResult(cmd *cobra.Command, result mcp.PlanResult) {
+	out := cmd.OutOrStdout()
+	for i, action := range result.Actions {
+		if action.Skipped {
+			color.New(color.FgYellow).Fprintf(out, "- %d. %s (skipped, already applied)\n", i+1, action.Action)
+			continue
+		}
+		color.New(color.FgGreen).Fprintf(out, "✓ %d. %s", i+1, action.Action)
+		if len(action.Result) > 0 {
+			details, _ := json.Marshal(action.Result)
+			fmt.Fprintf(out, " %s", details)
+		}
+		fmt.Fprintln(out)
+	}
+	for _, ref := range result.CreatedResources {
+		fmt.Fprintf(out, "+ created %s %s\n", ref.Type, ref.Name)
+	}
+	fmt.Fprintf(out, "%s: %s\n", result.Status, result.Message)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// applyServer stands in for the MCP server: Server.CallLLM returns plan as a mock LLM would,
+// and Server.ExecutePlan records the plan document it receives and answers with execResult
+// or execErr.
+func applyServer(t *testing.T, plan string, execResult string, execErr *mcp.RPCError, executed *[]mcp.PlanDocument) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req mcp.RPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		var result interface{}
+		switch req.Method {
+		case "Server.CallLLM":
+			result = plan
+		case "Server.ExecutePlan":
+			doc, err := mcp.ParsePlan([]byte(req.Params[0].(string)))
+			if err != nil {
+				t.Error(err)
+			}
+			*executed = append(*executed, doc)
+			result = mcp.RPCResponse{Version: mcp.JSONRPCVersion, Result: json.RawMessage(execResult), Error: execErr}
+		default:
+			t.Errorf("unexpected call to %s", req.Method)
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(mcp.RPCResponse{Version: mcp.JSONRPCVersion, Result: data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestApplyCommand(t *testing.T) {
+	plan := `[{"action": "create_network", "parameters": {"name": "shop"}}, {"action": "create_volume", "parameters": {"name": "data"}}]`
+	success := `{"status": "success", "message": "Plan executed successfully", "actions": [
+		{"action": "create_network", "result": {"id": "n1"}},
+		{"action": "create_volume", "skipped": true}
+	], "created_resources": [{"type": "network", "name": "shop", "id": "n1"}]}`
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		execErr      *mcp.RPCError
+		wantExecuted bool
+		wantOut      []string
+		wantErr      string
+	}{
+		{
+			name:         "confirmed",
+			args:         []string{"-p", "shop"},
+			stdin:        "y\n",
+			wantExecuted: true,
+			wantOut:      []string{`"action": "create_network"`, "Apply this plan? [y/N]: ", `✓ 1. create_network {"id":"n1"}`, "- 2. create_volume (skipped, already applied)", "+ created network shop", "success: Plan executed successfully"},
+		},
+		{
+			name:    "declined",
+			stdin:   "n\n",
+			wantOut: []string{"Plan not applied."},
+		},
+		{
+			name:         "without confirmation",
+			args:         []string{"--yes"},
+			wantExecuted: true,
+			wantOut:      []string{"✓ 1. create_network"},
+		},
+		{
+			name:         "execution fails",
+			args:         []string{"--yes"},
+			execErr:      mcp.NewError(-32000, "failed to execute action create_volume: disk full"),
+			wantExecuted: true,
+			wantErr:      "plan execution failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyArgs.yes, applyArgs.project = false, ""
+			var executed []mcp.PlanDocument
+			srv := applyServer(t, plan, success, tt.execErr, &executed)
+			rootCmd.SetIn(strings.NewReader(tt.stdin))
+			t.Cleanup(func() { rootCmd.SetIn(nil) })
+			out, err := runCLI(t, append([]string{"apply", "-i", "create a network and a volume", "-e", srv.URL + "/rpc"}, tt.args...)...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("apply error = %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if got := len(executed) == 1; got != tt.wantExecuted {
+				t.Fatalf("plan executed %d times, want executed = %v", len(executed), tt.wantExecuted)
+			}
+			if tt.wantExecuted && (len(executed[0].Plan) != 2 || executed[0].Project != applyArgs.project) {
+				t.Errorf("executed %+v, want the generated plan for project %q", executed[0], applyArgs.project)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+
+	"santoshkal/mcp-godocker/pkg/config"
+	"santoshkal/mcp-godocker/pkg/rpcclient"
+)
+
+// loadConfig loads the configuration from the --config file (defaults to ./mcp.yaml) with
+// environment overrides applied. Commands apply their own flags on top, so the precedence is
+// flag > env > file > default.
+func loadConfig() (*config.Config, error) {
+	return config.Load(configFile)
+}
+
+// verbose is set by --verbose.
+var verbose bool
+
+// newRPCClient returns a client for the server at endpoint, with the HTTP timeout taken from
+// MCP_HTTP_CLIENT_TIMEOUT and the API token from MCP_API_TOKEN when they are set. With
+// --verbose the raw requests and responses are printed to stderr.
+func newRPCClient(endpoint string) (*rpcclient.RPCClient, error) {
+	timeouts, err := config.TimeoutsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	client := rpcclient.NewRPCClient(endpoint, timeouts.HTTPClient).WithToken(os.Getenv(config.EnvAPIToken))
+	if verbose {
+		client.WithVerbose(os.Stderr)
+	}
+	return client, nil
+}
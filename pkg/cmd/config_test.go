@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/config"
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+func TestPlanEndpointPrecedence(t *testing.T) {
+	var fileReq, envReq, flagReq mcp.RPCRequest
+	plan := `[{"action": "create_network", "parameters": {"name": "shop"}}]`
+	fileSrv := rpcServer(t, plan, nil, &fileReq)
+	envSrv := rpcServer(t, plan, nil, &envReq)
+	flagSrv := rpcServer(t, plan, nil, &flagReq)
+	cfgPath := filepath.Join(t.TempDir(), "mcp.yaml")
+	if err := os.WriteFile(cfgPath, []byte("endpoint: "+fileSrv.URL+"/rpc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  string
+		flag string
+		want *mcp.RPCRequest
+	}{
+		{name: "file", want: &fileReq},
+		{name: "env over file", env: envSrv.URL + "/rpc", want: &envReq},
+		{name: "flag over env", env: envSrv.URL + "/rpc", flag: flagSrv.URL + "/rpc", want: &flagReq},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileReq, envReq, flagReq = mcp.RPCRequest{}, mcp.RPCRequest{}, mcp.RPCRequest{}
+			planArgs.endpoint = ""
+			t.Setenv(config.EnvEndpoint, tt.env)
+			args := []string{"plan", "-i", "create a network", "-c", cfgPath}
+			if tt.flag != "" {
+				args = append(args, "-e", tt.flag)
+			}
+			if _, err := runCLI(t, args...); err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			for _, req := range []*mcp.RPCRequest{&fileReq, &envReq, &flagReq} {
+				if called := req.Method != ""; called != (req == tt.want) {
+					t.Errorf("server called = %v, want only the %s endpoint called", called, tt.name)
+				}
+			}
+		})
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		existing string
+		env      map[string]string
+		wantErr  string
+		wantFile []string
+	}{
+		{
+			name:     "writes a starter config",
+			args:     []string{"--service", "docker", "--endpoint", "http://mcp.internal:1234/rpc"},
+			wantFile: []string{"service: docker", "endpoint: http://mcp.internal:1234/rpc", "model: gpt-4o"},
+		},
+		{
+			name:    "missing service",
+			wantErr: "--service is required",
+		},
+		{
+			name:     "missing service reported before a broken config",
+			existing: "llm: [not a map\n",
+			wantErr:  "--service is required",
+		},
+		{
+			name:    "unsupported service",
+			args:    []string{"--service", "kubernetes"},
+			wantErr: `unsupported service "kubernetes"`,
+		},
+		{
+			name:    "invalid endpoint",
+			args:    []string{"--service", "docker", "--endpoint", "mcp.internal"},
+			wantErr: `invalid endpoint "mcp.internal"`,
+		},
+		{
+			name:     "existing file",
+			args:     []string{"--service", "docker"},
+			existing: "llm:\n  model: gpt-4o-mini\n",
+			wantErr:  "already exists; use --force to overwrite it",
+		},
+		{
+			name:     "existing file with force",
+			args:     []string{"--service", "docker", "--force"},
+			existing: "llm:\n  model: gpt-4o-mini\n",
+			wantFile: []string{"service: docker", "model: gpt-4o-mini"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initArgs = initFlags{}
+			t.Setenv(config.EnvEndpoint, "")
+			t.Setenv(config.EnvLLMModel, "")
+			cfgPath := filepath.Join(t.TempDir(), "mcp.yaml")
+			if tt.existing != "" {
+				if err := os.WriteFile(cfgPath, []byte(tt.existing), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := runCLI(t, append([]string{"init", "-c", cfgPath}, tt.args...)...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("init error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("init: %v", err)
+			}
+			data, err := os.ReadFile(cfgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantFile {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("config file does not contain %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+
+	"santoshkal/mcp-godocker/utils"
 )
 
 var initCmd = &cobra.Command{
@@ -16,6 +20,7 @@ var initCmd = &cobra.Command{
 type initFlags struct {
 	service  string
 	endpoint string
+	force    bool
 }
 
 var (
@@ -26,6 +31,7 @@ var (
 func init() {
 	initCmd.Flags().StringVarP(&initArgs.service, "service", "s", "", "Service to initialize")
 	initCmd.Flags().StringVarP(&initArgs.endpoint, "endpoint", "e", "", "Specify the endpoint for the MCp Server")
+	initCmd.Flags().BoolVarP(&initArgs.force, "force", "f", false, "Overwrite an existing configuration file")
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -33,12 +39,29 @@ func runinitCmd(cmd *cobra.Command, args []string) error {
 	spin := utils.StartSpinner("Processing your request, please hold-on for a moment...")
 	defer spin.Stop()
 
+	if initArgs.service == "" {
+		return errors.New("--service is required, e.g. --service docker")
+	}
 	// Load configuration
 	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	cfg.Service = initArgs.service
+	if initArgs.endpoint != "" {
+		cfg.Endpoint = initArgs.endpoint
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(configFile); err == nil && !initArgs.force {
+		return fmt.Errorf("configuration file %s already exists; use --force to overwrite it", configFile)
+	}
+	if err := cfg.Write(configFile); err != nil {
+		return err
+	}
 
 	spin.Stop()
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote starter configuration for %s to %s\n", cfg.Service, configFile)
 	return nil
 }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+)
+
+var (
+	keyColor     = color.New(color.FgCyan)
+	stringColor  = color.New(color.FgGreen)
+	numberColor  = color.New(color.FgYellow)
+	literalColor = color.New(color.FgMagenta)
+)
+
+// printJSON writes data indented and syntax-colored. Colors are dropped automatically when
+// the output is not a terminal.
+func printJSON(w io.Writer, data []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	_, err := io.WriteString(w, colorizeJSON(indented.Bytes())+"\n")
+	return err
+}
+
+// colorizeJSON colors the keys, strings, numbers and literals of valid JSON text.
+func colorizeJSON(data []byte) string {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			token := string(data[i:end])
+			// A string followed by a colon is an object key.
+			next := end
+			for next < len(data) && (data[next] == ' ' || data[next] == '\n') {
+				next++
+			}
+			if next < len(data) && data[next] == ':' {
+				out.WriteString(keyColor.Sprint(token))
+			} else {
+				out.WriteString(stringColor.Sprint(token))
+			}
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(data) && bytes.IndexByte([]byte("+-.0123456789eE"), data[end]) >= 0 {
+				end++
+			}
+			out.WriteString(numberColor.Sprint(string(data[i:end])))
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(data) && data[end] >= 'a' && data[end] <= 'z' {
+				end++
+			}
+			out.WriteString(literalColor.Sprint(string(data[i:end])))
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "generate a plan for an instruction without applying it",
+	Long:  `Send a natural-language instruction to the MCP Server and print the plan the LLM generates for it. The plan is only displayed; nothing is executed.`,
+	RunE:  runplanCmd,
+}
+
+type planFlags struct {
+	input    string
+	endpoint string
+}
+
+var planArgs planFlags
+
+func init() {
+	planCmd.Flags().StringVarP(&planArgs.input, "input", "i", "", "Natural-language instruction to plan for")
+	planCmd.Flags().StringVarP(&planArgs.endpoint, "endpoint", "e", "", "Specify the endpoint for the MCP Server (defaults to the configured endpoint)")
+	_ = planCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(planCmd)
+}
+
+func runplanCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if planArgs.endpoint != "" {
+		cfg.Endpoint = planArgs.endpoint
+	}
+	client, err := newRPCClient(cfg.Endpoint)
+	if err != nil {
+		return err
+	}
+	planJSON, err := client.CallLLM(cmd.Context(), planArgs.input)
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
+	if !json.Valid([]byte(planJSON)) {
+		return fmt.Errorf("server returned an invalid JSON plan: %s", planJSON)
+	}
+	return printJSON(cmd.OutOrStdout(), []byte(planJSON))
+}
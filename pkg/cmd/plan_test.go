@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// rpcServer answers every JSON-RPC call with result, or with rpcErr when it is set,
+// recording the method and params of the last request.
+func rpcServer(t *testing.T, result interface{}, rpcErr *mcp.RPCError, got *mcp.RPCRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Error(err)
+		}
+		resp := mcp.RPCResponse{Version: mcp.JSONRPCVersion, Error: rpcErr}
+		if rpcErr == nil {
+			resp.Result, _ = json.Marshal(result)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// runCLI executes the root command with args, returning what it printed.
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(color.Output) })
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestPlanCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  interface{}
+		rpcErr  *mcp.RPCError
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "prints the plan",
+			result: `[{"action":"create_network","parameters":{"name":"shop"}}]`,
+			want: `[
+  {
+    "action": "create_network",
+    "parameters": {
+      "name": "shop"
+    }
+  }
+]
+`,
+		},
+		{
+			name:    "invalid plan",
+			result:  `create a network`,
+			wantErr: "server returned an invalid JSON plan: create a network",
+		},
+		{
+			name:    "server error",
+			rpcErr:  mcp.NewError(-32000, "LLM unavailable"),
+			wantErr: "failed to generate plan",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req mcp.RPCRequest
+			srv := rpcServer(t, tt.result, tt.rpcErr, &req)
+			out, err := runCLI(t, "plan", "-i", "create a network named shop", "-e", srv.URL+"/rpc")
+			if req.Method != "Server.CallLLM" {
+				t.Errorf("called %q, want Server.CallLLM", req.Method)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("plan error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if out != tt.want {
+				t.Errorf("plan printed\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+
+	"santoshkal/mcp-godocker/pkg/config"
 )
 
 // rootCommand returns a cobra command for mcpserver CLI tool
@@ -20,6 +22,8 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.SetOut(color.Output)
 	rootCmd.SetErr(color.Error)
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", config.DefaultPath, "Path of the configuration file (YAML, or JSON with a .json extension)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Print the raw JSON-RPC requests and responses exchanged with the server")
 }
 
 func Execute() {
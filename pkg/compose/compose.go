@@ -0,0 +1,196 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project is the subset of a docker-compose file that compose_up understands.
+type Project struct {
+	Services map[string]Service  `yaml:"services"`
+	Networks map[string]Resource `yaml:"networks"`
+	Volumes  map[string]Resource `yaml:"volumes"`
+}
+
+// Service describes one compose service.
+type Service struct {
+	Image         string     `yaml:"image"`
+	ContainerName string     `yaml:"container_name"`
+	Command       StringList `yaml:"command"`
+	Entrypoint    StringList `yaml:"entrypoint"`
+	WorkingDir    string     `yaml:"working_dir"`
+	Environment   Mapping    `yaml:"environment"`
+	Ports         []string   `yaml:"ports"`
+	Volumes       []string   `yaml:"volumes"`
+	Networks      NameList   `yaml:"networks"`
+	DependsOn     NameList   `yaml:"depends_on"`
+	Restart       string     `yaml:"restart"`
+	Labels        Mapping    `yaml:"labels"`
+}
+
+// Resource is a top-level network or volume declaration.
+type Resource struct {
+	Driver     string            `yaml:"driver"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	External   bool              `yaml:"external"`
+}
+
+// StringList accepts either a single string (split on whitespace) or a list of strings.
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*l = strings.Fields(node.Value)
+		return nil
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		*l = items
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a string or a list of strings", node.Line)
+}
+
+// Mapping accepts either a map or a list of KEY=VALUE strings.
+type Mapping map[string]string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *Mapping) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		var values map[string]string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*m = values
+		return nil
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		values := make(map[string]string, len(items))
+		for _, item := range items {
+			key, value, _ := strings.Cut(item, "=")
+			values[key] = value
+		}
+		*m = values
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a map or a list of KEY=VALUE strings", node.Line)
+}
+
+// NameList accepts either a list of names or a map keyed by name (whose values are ignored),
+// as used by networks and depends_on.
+type NameList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *NameList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		*l = items
+		return nil
+	case yaml.MappingNode:
+		names := make([]string, 0, len(node.Content)/2)
+		for i := 0; i < len(node.Content); i += 2 {
+			names = append(names, node.Content[i].Value)
+		}
+		sort.Strings(names)
+		*l = names
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a list or a map of names", node.Line)
+}
+
+// Parse decodes a compose file and checks that every service has an image and only
+// references declared networks and existing services.
+func Parse(data []byte) (*Project, error) {
+	var p Project
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid compose file: %w", err)
+	}
+	if len(p.Services) == 0 {
+		return nil, fmt.Errorf("compose file defines no services")
+	}
+	for name, svc := range p.Services {
+		if svc.Image == "" {
+			return nil, fmt.Errorf("service %q has no image (build is not supported)", name)
+		}
+		for _, dep := range svc.DependsOn {
+			if _, ok := p.Services[dep]; !ok {
+				return nil, fmt.Errorf("service %q depends on unknown service %q", name, dep)
+			}
+		}
+		for _, n := range svc.Networks {
+			if _, ok := p.Networks[n]; !ok && n != "default" {
+				return nil, fmt.Errorf("service %q uses undeclared network %q", name, n)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// ParseFile reads and parses the compose file at path.
+func ParseFile(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	return Parse(data)
+}
+
+// StartOrder returns the service names ordered so that every service comes after the
+// services it depends on. Ties are broken alphabetically to keep the order stable.
+func (p *Project) StartOrder() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(p.Services))
+	order := make([]string, 0, len(p.Services))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		deps := append([]string(nil), p.Services[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
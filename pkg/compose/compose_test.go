@@ -0,0 +1,118 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		check   func(t *testing.T, p *Project)
+		wantErr string
+	}{
+		{
+			name: "short and long forms",
+			yaml: `
+services:
+  web:
+    image: nginx
+    command: nginx -g daemon
+    environment:
+      - MODE=prod
+      - EMPTY=
+    networks:
+      front: {}
+      back: {}
+    depends_on: [db]
+  db:
+    image: postgres:16
+    entrypoint: [docker-entrypoint.sh, postgres]
+    environment:
+      POSTGRES_DB: shop
+networks:
+  front: {}
+  back: {}
+`,
+			check: func(t *testing.T, p *Project) {
+				web, db := p.Services["web"], p.Services["db"]
+				if !reflect.DeepEqual([]string(web.Command), []string{"nginx", "-g", "daemon"}) {
+					t.Errorf("web command = %q", web.Command)
+				}
+				if !reflect.DeepEqual(map[string]string(web.Environment), map[string]string{"MODE": "prod", "EMPTY": ""}) {
+					t.Errorf("web environment = %v", web.Environment)
+				}
+				if !reflect.DeepEqual([]string(web.Networks), []string{"back", "front"}) {
+					t.Errorf("web networks = %v, want sorted names", web.Networks)
+				}
+				if !reflect.DeepEqual([]string(db.Entrypoint), []string{"docker-entrypoint.sh", "postgres"}) {
+					t.Errorf("db entrypoint = %q", db.Entrypoint)
+				}
+				if db.Environment["POSTGRES_DB"] != "shop" {
+					t.Errorf("db environment = %v", db.Environment)
+				}
+			},
+		},
+		{name: "not YAML", yaml: "services: [", wantErr: "invalid compose file"},
+		{name: "no services", yaml: "networks: {}\n", wantErr: "compose file defines no services"},
+		{name: "build only", yaml: "services:\n  app:\n    build: .\n", wantErr: `service "app" has no image`},
+		{name: "unknown dependency", yaml: "services:\n  web:\n    image: nginx\n    depends_on: [db]\n", wantErr: `service "web" depends on unknown service "db"`},
+		{name: "undeclared network", yaml: "services:\n  web:\n    image: nginx\n    networks: [front]\n", wantErr: `service "web" uses undeclared network "front"`},
+		{name: "default network needs no declaration", yaml: "services:\n  web:\n    image: nginx\n    networks: [default]\n"},
+		{name: "bad environment", yaml: "services:\n  web:\n    image: nginx\n    environment: prod\n", wantErr: "expected a map or a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse([]byte(tt.yaml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, p)
+			}
+		})
+	}
+}
+
+func TestStartOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string
+		want    []string
+		wantErr string
+	}{
+		{name: "independent services sorted", deps: map[string][]string{"web": nil, "cache": nil, "db": nil}, want: []string{"cache", "db", "web"}},
+		{name: "dependencies first", deps: map[string][]string{"web": {"db", "cache"}, "cache": nil, "db": nil}, want: []string{"cache", "db", "web"}},
+		{name: "chain", deps: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil}, want: []string{"c", "b", "a"}},
+		{name: "cycle", deps: map[string][]string{"a": {"b"}, "b": {"a"}}, wantErr: "dependency cycle: a -> b -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Project{Services: map[string]Service{}}
+			for name, deps := range tt.deps {
+				p.Services[name] = Service{Image: "x", DependsOn: deps}
+			}
+			got, err := p.StartOrder()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("StartOrder() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StartOrder() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StartOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// ServiceLabel records which compose service a container was created for.
+const ServiceLabel = "mcp-server-docker.service"
+
+// defaultNetwork is the network services are attached to when they declare none.
+const defaultNetwork = "default"
+
+// ServiceStatus reports the container started for a service.
+type ServiceStatus struct {
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	Status    string `json:"status"`
+}
+
+// Up creates the project's networks, volumes and containers, labelled and prefixed with the
+// project name, and starts the containers in dependency order. Relative bind mounts are
+// resolved against baseDir. Each image pull is bounded by pullTimeout.
+func Up(ctx context.Context, cli *client.Client, projectName string, p *Project, baseDir string, pullTimeout time.Duration) ([]ServiceStatus, error) {
+	if projectName == "" {
+		return nil, fmt.Errorf("missing project name")
+	}
+	order, err := p.StartOrder()
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{docker.ProjectLabel: projectName}
+
+	networkNames := make(map[string]string, len(p.Networks)+1)
+	for _, name := range sortedKeys(p.Networks) {
+		res := p.Networks[name]
+		fullName := resourceName(projectName, name, res)
+		networkNames[name] = fullName
+		if res.External {
+			continue
+		}
+		if _, err := cli.NetworkCreate(ctx, fullName, network.CreateOptions{
+			Driver:  res.Driver,
+			Options: res.DriverOpts,
+			Labels:  labels,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to create network %s: %w", fullName, err)
+		}
+	}
+	if _, declared := networkNames[defaultNetwork]; !declared && needsDefaultNetwork(p) {
+		fullName := projectName + "-" + defaultNetwork
+		if _, err := cli.NetworkCreate(ctx, fullName, network.CreateOptions{Labels: labels}); err != nil {
+			return nil, fmt.Errorf("failed to create network %s: %w", fullName, err)
+		}
+		networkNames[defaultNetwork] = fullName
+	}
+
+	volumeNames := make(map[string]string, len(p.Volumes))
+	for _, name := range sortedKeys(p.Volumes) {
+		res := p.Volumes[name]
+		fullName := resourceName(projectName, name, res)
+		volumeNames[name] = fullName
+		if res.External {
+			continue
+		}
+		if _, err := cli.VolumeCreate(ctx, volume.CreateOptions{
+			Name:       fullName,
+			Driver:     res.Driver,
+			DriverOpts: res.DriverOpts,
+			Labels:     labels,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to create volume %s: %w", fullName, err)
+		}
+	}
+
+	containerNames := make(map[string]string, len(order))
+	for _, name := range order {
+		containerName, err := createService(ctx, cli, projectName, name, p.Services[name], networkNames, volumeNames, baseDir, pullTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		containerNames[name] = containerName
+	}
+
+	statuses := make([]ServiceStatus, 0, len(order))
+	for _, name := range order {
+		state, err := docker.RunContainer(ctx, cli, containerNames[name], docker.DefaultStartAttempts, 1)
+		if err != nil {
+			return statuses, fmt.Errorf("failed to start service %s: %w", name, err)
+		}
+		statuses = append(statuses, ServiceStatus{Service: name, Container: containerNames[name], Status: state.Status})
+	}
+	return statuses, nil
+}
+
+// HostPaths returns the host paths the project's services bind-mount, with relative paths
+// resolved against baseDir as Up does.
+func (p *Project) HostPaths(baseDir string) []string {
+	var paths []string
+	for _, svc := range p.Services {
+		for _, spec := range svc.Volumes {
+			source, _, found := strings.Cut(spec, ":")
+			if !found {
+				continue
+			}
+			if _, named := p.Volumes[source]; named {
+				continue
+			}
+			if strings.HasPrefix(source, ".") {
+				source = filepath.Join(baseDir, source)
+			}
+			if filepath.IsAbs(source) {
+				paths = append(paths, source)
+			}
+		}
+	}
+	return paths
+}
+
+// Images returns the images the project's services run, in service name order.
+func (p *Project) Images() []string {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	images := make([]string, 0, len(names))
+	for _, name := range names {
+		if image := p.Services[name].Image; image != "" {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// createService pulls the service image if needed, creates its container and attaches it to
+// its networks, returning the container name.
+func createService(ctx context.Context, cli *client.Client, projectName, name string, svc Service, networkNames, volumeNames map[string]string, baseDir string, pullTimeout time.Duration) (string, error) {
+	containerName := svc.ContainerName
+	if containerName == "" {
+		containerName = projectName + "-" + name
+	}
+	if _, _, err := cli.ImageInspectWithRaw(ctx, svc.Image); errdefs.IsNotFound(err) {
+		if err := docker.PullImage(ctx, cli, svc.Image, pullTimeout, 1, nil); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	exposed, bindings, err := nat.ParsePortSpecs(svc.Ports)
+	if err != nil {
+		return "", fmt.Errorf("invalid ports: %w", err)
+	}
+	labels := map[string]string{}
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	labels[docker.ProjectLabel] = projectName
+	labels[ServiceLabel] = name
+
+	config := &container.Config{
+		Image:        svc.Image,
+		Cmd:          []string(svc.Command),
+		Entrypoint:   []string(svc.Entrypoint),
+		WorkingDir:   svc.WorkingDir,
+		Env:          envList(svc.Environment),
+		Labels:       labels,
+		ExposedPorts: exposed,
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings:  bindings,
+		RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyMode(svc.Restart)},
+	}
+	for _, spec := range svc.Volumes {
+		source, target, found := strings.Cut(spec, ":")
+		if !found {
+			// An anonymous volume only names the target path.
+			if config.Volumes == nil {
+				config.Volumes = map[string]struct{}{}
+			}
+			config.Volumes[spec] = struct{}{}
+			continue
+		}
+		if full, ok := volumeNames[source]; ok {
+			source = full
+		} else if !filepath.IsAbs(source) && strings.HasPrefix(source, ".") {
+			source = filepath.Join(baseDir, source)
+		}
+		hostConfig.Binds = append(hostConfig.Binds, source+":"+target)
+	}
+
+	serviceNetworks := []string(svc.Networks)
+	if len(serviceNetworks) == 0 {
+		serviceNetworks = []string{defaultNetwork}
+	}
+	var networkingConfig *network.NetworkingConfig
+	if first, ok := networkNames[serviceNetworks[0]]; ok {
+		networkingConfig = &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{
+			first: {Aliases: []string{name}},
+		}}
+	}
+	if _, err := cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, containerName); err != nil {
+		return "", err
+	}
+	for _, n := range serviceNetworks[1:] {
+		if err := cli.NetworkConnect(ctx, networkNames[n], containerName, &network.EndpointSettings{Aliases: []string{name}}); err != nil {
+			return "", fmt.Errorf("failed to connect to network %s: %w", n, err)
+		}
+	}
+	return containerName, nil
+}
+
+// needsDefaultNetwork reports whether any service relies on the implicit default network.
+func needsDefaultNetwork(p *Project) bool {
+	for _, svc := range p.Services {
+		if len(svc.Networks) == 0 {
+			return true
+		}
+		for _, n := range svc.Networks {
+			if n == defaultNetwork {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resourceName prefixes a network or volume with the project name unless it is external.
+func resourceName(projectName, name string, res Resource) string {
+	if res.External {
+		return name
+	}
+	return projectName + "-" + name
+}
+
+// envList converts an environment mapping into Docker's sorted KEY=VALUE form.
+func envList(env Mapping) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func sortedKeys(m map[string]Resource) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// fakeDaemon records the Docker API requests it receives and accepts them all, reporting
+// every image as present and every started container as running.
+type fakeDaemon struct {
+	mu       sync.Mutex
+	requests []string
+	created  map[string]container.CreateRequest
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1.47")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case path == "/containers/create":
+		name := r.URL.Query().Get("name")
+		var req container.CreateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		d.created[name] = req
+		d.requests = append(d.requests, "create container "+name)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "`+name+`"}`)
+	case path == "/networks/create":
+		var req struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&req)
+		d.requests = append(d.requests, "create network "+req.Name)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "`+req.Name+`"}`)
+	case path == "/volumes/create":
+		var req struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&req)
+		d.requests = append(d.requests, "create volume "+req.Name)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Name": "`+req.Name+`"}`)
+	case strings.HasPrefix(path, "/networks/") && strings.HasSuffix(path, "/connect"):
+		var req struct{ Container string }
+		json.NewDecoder(r.Body).Decode(&req)
+		d.requests = append(d.requests, "connect "+req.Container+" to "+strings.TrimSuffix(strings.TrimPrefix(path, "/networks/"), "/connect"))
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		io.WriteString(w, `{"Id": "sha256:0123"}`)
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/start"):
+		d.requests = append(d.requests, "start "+strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/start"))
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/json"):
+		io.WriteString(w, `{"Id": "x", "State": {"Status": "running", "Running": true}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message": "not found: `+path+`"}`)
+	}
+}
+
+func newFakeDaemon(t *testing.T) (*fakeDaemon, *client.Client) {
+	t.Helper()
+	d := &fakeDaemon{created: map[string]container.CreateRequest{}}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return d, cli
+}
+
+func TestUpTwoServices(t *testing.T) {
+	p, err := Parse([]byte(`
+services:
+  web:
+    image: nginx:latest
+    ports: ["8080:80"]
+    depends_on: [db]
+    networks: [front, back]
+    volumes: ["./conf:/etc/nginx/conf.d:ro"]
+    restart: unless-stopped
+  db:
+    image: postgres:16
+    networks: [back]
+    volumes: ["data:/var/lib/postgresql/data"]
+    environment:
+      POSTGRES_DB: shop
+networks:
+  front: {}
+  back: {}
+volumes:
+  data: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, cli := newFakeDaemon(t)
+	statuses, err := Up(context.Background(), cli, "shop", p, "/srv/shop", time.Minute)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	wantRequests := []string{
+		"create network shop-back",
+		"create network shop-front",
+		"create volume shop-data",
+		"create container shop-db",
+		"create container shop-web",
+		"connect shop-web to shop-back",
+		"start shop-db",
+		"start shop-web",
+	}
+	if !reflect.DeepEqual(d.requests, wantRequests) {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(d.requests, "\n"), strings.Join(wantRequests, "\n"))
+	}
+	wantStatuses := []ServiceStatus{
+		{Service: "db", Container: "shop-db", Status: "running"},
+		{Service: "web", Container: "shop-web", Status: "running"},
+	}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("statuses = %+v, want %+v", statuses, wantStatuses)
+	}
+
+	db, web := d.created["shop-db"], d.created["shop-web"]
+	if db.Labels[docker.ProjectLabel] != "shop" || db.Labels[ServiceLabel] != "db" {
+		t.Errorf("db labels = %v, want the project and service", db.Labels)
+	}
+	if !reflect.DeepEqual(db.Env, []string{"POSTGRES_DB=shop"}) {
+		t.Errorf("db env = %v", db.Env)
+	}
+	if !reflect.DeepEqual(db.HostConfig.Binds, []string{"shop-data:/var/lib/postgresql/data"}) {
+		t.Errorf("db binds = %v, want the project volume", db.HostConfig.Binds)
+	}
+	if !reflect.DeepEqual(web.HostConfig.Binds, []string{"/srv/shop/conf:/etc/nginx/conf.d:ro"}) {
+		t.Errorf("web binds = %v, want the relative path resolved", web.HostConfig.Binds)
+	}
+	if web.HostConfig.RestartPolicy.Name != container.RestartPolicyUnlessStopped {
+		t.Errorf("web restart policy = %v", web.HostConfig.RestartPolicy)
+	}
+	if b := web.HostConfig.PortBindings["80/tcp"]; len(b) != 1 || b[0].HostPort != "8080" {
+		t.Errorf("web port bindings = %v, want 8080 -> 80", web.HostConfig.PortBindings)
+	}
+	endpoint := web.NetworkingConfig.EndpointsConfig["shop-front"]
+	if endpoint == nil || !reflect.DeepEqual(endpoint.Aliases, []string{"web"}) {
+		t.Errorf("web endpoints = %v, want shop-front with the service alias", web.NetworkingConfig.EndpointsConfig)
+	}
+}
+
+func TestUpDefaultNetwork(t *testing.T) {
+	p, err := Parse([]byte("services:\n  web:\n    image: nginx\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, cli := newFakeDaemon(t)
+	if _, err := Up(context.Background(), cli, "blog", p, ".", time.Minute); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if d.requests[0] != "create network blog-default" {
+		t.Errorf("first request = %q, want the default network created", d.requests[0])
+	}
+	if _, ok := d.created["blog-web"].NetworkingConfig.EndpointsConfig["blog-default"]; !ok {
+		t.Errorf("web endpoints = %v, want the default network", d.created["blog-web"].NetworkingConfig.EndpointsConfig)
+	}
+}
+
+func TestHostPaths(t *testing.T) {
+	p, err := Parse([]byte(`
+services:
+  web:
+    image: nginx
+    volumes: ["./conf:/etc/nginx/conf.d:ro", "/var/run/docker.sock:/var/run/docker.sock", "data:/data"]
+volumes:
+  data: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.HostPaths("/srv/shop")
+	want := []string{"/srv/shop/conf", "/var/run/docker.sock"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HostPaths() = %q, want %q", got, want)
+	}
+}
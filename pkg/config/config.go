@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/utils"
+)
+
+// DefaultPath is the configuration file used when --config is not given.
+const DefaultPath = "mcp.yaml"
+
+// EnvConfigFile names the configuration file the server loads at startup and on reload. The
+// server falls back to DefaultPath when it is unset.
+const EnvConfigFile = "MCP_CONFIG"
+
+// Environment variables that override values from the configuration file.
+const (
+	EnvLLMProvider    = "MCP_LLM_PROVIDER"
+	EnvLLMModel       = "MCP_LLM_MODEL"
+	EnvLLMAPIKeyEnv   = "MCP_LLM_API_KEY_ENV"
+	EnvDockerHost     = "DOCKER_HOST"
+	EnvDefaultProject = "MCP_DEFAULT_PROJECT"
+	EnvEndpoint       = "MCP_ENDPOINT"
+	// EnvSystemPromptFile overrides llm.system_prompt_file.
+	EnvSystemPromptFile = "MCP_SYSTEM_PROMPT_FILE"
+)
+
+// EnvAPIToken holds the bearer token the server requires on its HTTP endpoints and the CLI
+// sends. Authentication is off when it is unset.
+const EnvAPIToken = "MCP_API_TOKEN"
+
+// supportedProviders lists the LLM providers the server can talk to.
+var supportedProviders = []string{"openai"}
+
+// restartPolicies lists the restart policies a project may default to.
+var restartPolicies = []string{"no", "on-failure", "always", "unless-stopped"}
+
+// planFormats lists the plan shapes the system prompt can ask the model for.
+var planFormats = []string{"array", "document"}
+
+// supportedServices lists the services `init` can write a starter configuration for.
+var supportedServices = []string{"docker"}
+
+// Config is the MCP server and CLI configuration.
+type Config struct {
+	Service        string    `json:"service,omitempty" yaml:"service,omitempty"`
+	LLM            LLMConfig `json:"llm" yaml:"llm"`
+	DockerHost     string    `json:"docker_host,omitempty" yaml:"docker_host,omitempty"`
+	DockerTLS      *TLSFiles `json:"docker_tls,omitempty" yaml:"docker_tls,omitempty"`
+	DefaultProject string    `json:"default_project,omitempty" yaml:"default_project,omitempty"`
+	Endpoint       string    `json:"endpoint" yaml:"endpoint"`
+	// TraceToolCalls makes the server record the model's tool calls for every plan it
+	// generates, for debugging prompts.
+	TraceToolCalls bool `json:"trace_tool_calls,omitempty" yaml:"trace_tool_calls,omitempty"`
+	// Swarm makes create_container create Swarm services instead of plain containers. The
+	// Docker host must be a swarm manager.
+	Swarm bool `json:"swarm,omitempty" yaml:"swarm,omitempty"`
+	// ReadyCheckLLM makes /readyz also send a one-token request to the LLM. It is off by
+	// default because every probe then uses API quota.
+	ReadyCheckLLM bool `json:"ready_check_llm,omitempty" yaml:"ready_check_llm,omitempty"`
+	// ImageCacheTTLSeconds is how long pull_image trusts that an image it found locally is
+	// still there before inspecting it again (default 60).
+	ImageCacheTTLSeconds int `json:"image_cache_ttl_seconds,omitempty" yaml:"image_cache_ttl_seconds,omitempty"`
+	// DockerRetryAttempts is how many times run_container and pull_image try a Docker call
+	// that fails with a transient error, such as the daemon being briefly busy (default 3).
+	DockerRetryAttempts int `json:"docker_retry_attempts,omitempty" yaml:"docker_retry_attempts,omitempty"`
+	// Policy restricts which actions, images and bind mounts plans and tool calls may use.
+	Policy policy.Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// Projects holds per-project defaults, keyed by project name.
+	Projects map[string]ProjectConfig `json:"projects,omitempty" yaml:"projects,omitempty"`
+}
+
+// ProjectConfig holds defaults applied to the resources of one project. Values given
+// explicitly in a plan take precedence.
+type ProjectConfig struct {
+	// RestartPolicy is used for containers created without a restart_policy.
+	RestartPolicy string `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+}
+
+// LLMConfig selects the model used to generate plans. The API key itself is never stored in
+// the file; APIKeyEnv names the environment variable holding it.
+type LLMConfig struct {
+	Provider  string `json:"provider" yaml:"provider"`
+	Model     string `json:"model" yaml:"model"`
+	APIKeyEnv string `json:"api_key_env" yaml:"api_key_env"`
+	// PlanFormat selects whether the system prompt asks the model for a bare array of
+	// actions ("array", the default) or for {"plan": [...]} ("document").
+	PlanFormat string `json:"plan_format,omitempty" yaml:"plan_format,omitempty"`
+	// MaxTokens limits the completion tokens of each plan request; zero means no limit.
+	MaxTokens int `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	// TokenBudget caps the total tokens (prompt and completion) the server may spend on
+	// plans before CallLLM starts refusing; zero means no cap. The count resets on restart.
+	TokenBudget int `json:"token_budget,omitempty" yaml:"token_budget,omitempty"`
+	// SystemPrompt replaces the built-in system prompt. It is a Go text/template that may use
+	// {{.AllowedActions}} (the actions the server offers and the policy allows) and
+	// {{.PlanShape}} (the sentence asking for plan_format), and must still ask for JSON.
+	SystemPrompt string `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+	// SystemPromptFile names a file holding the system prompt template instead. It is read
+	// when the configuration is loaded or reloaded.
+	SystemPromptFile string `json:"system_prompt_file,omitempty" yaml:"system_prompt_file,omitempty"`
+
+	// systemPromptText is the custom prompt template, from SystemPromptFile or SystemPrompt.
+	systemPromptText string
+}
+
+// SystemPromptTemplate returns the custom system prompt template, or "" to use the
+// built-in one.
+func (l *LLMConfig) SystemPromptTemplate() string {
+	return l.systemPromptText
+}
+
+// TLSFiles names the PEM files used to authenticate to a Docker daemon over TLS.
+type TLSFiles struct {
+	CACert string `json:"ca_cert" yaml:"ca_cert"`
+	Cert   string `json:"cert" yaml:"cert"`
+	Key    string `json:"key" yaml:"key"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		LLM: LLMConfig{
+			Provider:  "openai",
+			Model:     "gpt-4o",
+			APIKeyEnv: "OPENAI_API_KEY",
+		},
+		Endpoint: "http://localhost:1234/rpc",
+	}
+}
+
+// Load builds the configuration from the defaults, then the file at path (JSON when it has a
+// .json extension, YAML otherwise), then environment variables, each overriding the last.
+// A missing file is treated as empty. The result is validated.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if err := cfg.mergeFile(path); err != nil {
+		return nil, err
+	}
+	cfg.mergeEnv()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.LLM.loadSystemPrompt(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadSystemPrompt reads the custom system prompt template and checks that it renders to a
+// prompt that asks for JSON.
+func (l *LLMConfig) loadSystemPrompt() error {
+	l.systemPromptText = l.SystemPrompt
+	if l.SystemPromptFile != "" {
+		data, err := os.ReadFile(l.SystemPromptFile)
+		if err != nil {
+			return fmt.Errorf("failed to read llm.system_prompt_file: %w", err)
+		}
+		l.systemPromptText = string(data)
+	}
+	if l.systemPromptText == "" {
+		return nil
+	}
+	sample := utils.PromptData{PlanShape: utils.PlanShape(l.PlanFormat), AllowedActions: "create_container"}
+	if _, err := utils.RenderSystemPrompt(l.systemPromptText, sample); err != nil {
+		return fmt.Errorf("invalid custom system prompt: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) mergeFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) mergeEnv() {
+	for env, field := range map[string]*string{
+		EnvLLMProvider:      &c.LLM.Provider,
+		EnvLLMModel:         &c.LLM.Model,
+		EnvLLMAPIKeyEnv:     &c.LLM.APIKeyEnv,
+		EnvDockerHost:       &c.DockerHost,
+		EnvDefaultProject:   &c.DefaultProject,
+		EnvEndpoint:         &c.Endpoint,
+		EnvSystemPromptFile: &c.LLM.SystemPromptFile,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+}
+
+// Validate checks the configuration and returns an error describing how to fix the first
+// problem found.
+func (c *Config) Validate() error {
+	if c.Service != "" && !contains(supportedServices, c.Service) {
+		return fmt.Errorf("unsupported service %q: set service to one of %s", c.Service, strings.Join(supportedServices, ", "))
+	}
+	if !contains(supportedProviders, c.LLM.Provider) {
+		return fmt.Errorf("unsupported llm.provider %q: set it to one of %s (or %s)", c.LLM.Provider, strings.Join(supportedProviders, ", "), EnvLLMProvider)
+	}
+	if c.LLM.Model == "" {
+		return fmt.Errorf("llm.model is empty: set it in the config file or via %s", EnvLLMModel)
+	}
+	if c.LLM.APIKeyEnv == "" {
+		return fmt.Errorf("llm.api_key_env is empty: name the environment variable that holds the API key (e.g. OPENAI_API_KEY)")
+	}
+	if c.LLM.PlanFormat != "" && !contains(planFormats, c.LLM.PlanFormat) {
+		return fmt.Errorf("invalid llm.plan_format %q: use one of %s", c.LLM.PlanFormat, strings.Join(planFormats, ", "))
+	}
+	if c.LLM.SystemPrompt != "" && c.LLM.SystemPromptFile != "" {
+		return fmt.Errorf("llm.system_prompt and llm.system_prompt_file (or %s) are both set: use one", EnvSystemPromptFile)
+	}
+	if c.LLM.MaxTokens < 0 || c.LLM.TokenBudget < 0 {
+		return fmt.Errorf("llm.max_tokens and llm.token_budget must not be negative")
+	}
+	if c.DockerHost != "" {
+		u, err := url.Parse(c.DockerHost)
+		if err != nil || !contains([]string{"unix", "tcp", "npipe", "ssh", "http", "https"}, u.Scheme) {
+			return fmt.Errorf("invalid docker_host %q: use a URL such as unix:///var/run/docker.sock or tcp://host:2376", c.DockerHost)
+		}
+	}
+	if t := c.DockerTLS; t != nil && (t.Cert == "") != (t.Key == "") {
+		return fmt.Errorf("docker_tls needs both cert and key (or neither, to verify the daemon with ca_cert only)")
+	}
+	if c.ImageCacheTTLSeconds < 0 {
+		return fmt.Errorf("image_cache_ttl_seconds must not be negative, got %d", c.ImageCacheTTLSeconds)
+	}
+	if c.DockerRetryAttempts < 0 {
+		return fmt.Errorf("docker_retry_attempts must not be negative, got %d", c.DockerRetryAttempts)
+	}
+	if err := c.Policy.Validate(); err != nil {
+		return err
+	}
+	for name, p := range c.Projects {
+		if p.RestartPolicy != "" && !contains(restartPolicies, p.RestartPolicy) {
+			return fmt.Errorf("invalid projects.%s.restart_policy %q: use one of %s", name, p.RestartPolicy, strings.Join(restartPolicies, ", "))
+		}
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: use an http(s) URL such as http://localhost:1234/rpc (or set %s)", c.Endpoint, EnvEndpoint)
+	}
+	return nil
+}
+
+// Write saves the configuration to path in the format implied by its extension.
+func (c *Config) Write(path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		data, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
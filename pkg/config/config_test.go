@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets the environment overrides for the duration of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{EnvLLMProvider, EnvLLMModel, EnvLLMAPIKeyEnv, EnvDockerHost, EnvDefaultProject, EnvEndpoint, EnvSystemPromptFile} {
+		t.Setenv(env, "")
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, "mcp.yaml", "llm:\n  model: gpt-4o-mini\nendpoint: http://file:1234/rpc\ndefault_project: from-file\n")
+
+	got, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, Default()) {
+		t.Errorf("Load() without a file = %+v, want the defaults", got)
+	}
+
+	got, err = Load(file)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.LLM.Model != "gpt-4o-mini" || got.Endpoint != "http://file:1234/rpc" || got.DefaultProject != "from-file" {
+		t.Errorf("Load() = %+v, want the file's values", got)
+	}
+	if got.LLM.Provider != "openai" || got.LLM.APIKeyEnv != "OPENAI_API_KEY" {
+		t.Errorf("Load() = %+v, want defaults for values the file leaves out", got)
+	}
+
+	t.Setenv(EnvEndpoint, "http://env:1234/rpc")
+	t.Setenv(EnvLLMModel, "gpt-4.1")
+	got, err = Load(file)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Endpoint != "http://env:1234/rpc" || got.LLM.Model != "gpt-4.1" || got.DefaultProject != "from-file" {
+		t.Errorf("Load() = %+v, want the environment to override the file", got)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	clearEnv(t)
+	got, err := Load(writeFile(t, "mcp.json", `{"service": "docker", "docker_host": "unix:///var/run/docker.sock"}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Service != "docker" || got.DockerHost != "unix:///var/run/docker.sock" {
+		t.Errorf("Load() = %+v, want the JSON file's values", got)
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "malformed yaml", file: "mcp.yaml", content: "llm: [", wantErr: "failed to parse config file"},
+		{name: "malformed json", file: "mcp.json", content: "{", wantErr: "failed to parse config file"},
+		{name: "unsupported service", file: "mcp.yaml", content: "service: kubernetes\n", wantErr: `unsupported service "kubernetes"`},
+		{name: "unsupported provider", file: "mcp.yaml", content: "llm:\n  provider: acme\n", wantErr: `unsupported llm.provider "acme"`},
+		{name: "provider from env", file: "mcp.yaml", env: map[string]string{EnvLLMProvider: "acme"}, wantErr: `unsupported llm.provider "acme"`},
+		{name: "empty model", file: "mcp.yaml", content: "llm:\n  model: \"\"\n", wantErr: "llm.model is empty"},
+		{name: "empty api key env", file: "mcp.yaml", content: "llm:\n  api_key_env: \"\"\n", wantErr: "llm.api_key_env is empty"},
+		{name: "bad docker host", file: "mcp.yaml", content: "docker_host: ftp://host\n", wantErr: `invalid docker_host "ftp://host"`},
+		{name: "tls cert without key", file: "mcp.yaml", content: "docker_tls:\n  ca_cert: ca.pem\n  cert: cert.pem\n", wantErr: "docker_tls needs both cert and key"},
+		{name: "bad project restart policy", file: "mcp.yaml", content: "projects:\n  shop:\n    restart_policy: sometimes\n", wantErr: `invalid projects.shop.restart_policy "sometimes"`},
+		{name: "negative image cache ttl", file: "mcp.yaml", content: "image_cache_ttl_seconds: -1\n", wantErr: "image_cache_ttl_seconds must not be negative, got -1"},
+		{name: "negative token budget", file: "mcp.yaml", content: "llm:\n  token_budget: -5\n", wantErr: "llm.max_tokens and llm.token_budget must not be negative"},
+		{name: "bad plan format", file: "mcp.yaml", content: "llm:\n  plan_format: yaml\n", wantErr: `invalid llm.plan_format "yaml"`},
+		{name: "bad policy pattern", file: "mcp.yaml", content: "policy:\n  denied_images: [\"nginx[\"]\n", wantErr: `invalid policy.denied_images pattern "nginx["`},
+		{name: "system prompt and file", file: "mcp.yaml", content: "llm:\n  system_prompt: Reply in JSON\n  system_prompt_file: prompt.tmpl\n", wantErr: "llm.system_prompt and llm.system_prompt_file (or MCP_SYSTEM_PROMPT_FILE) are both set"},
+		{name: "missing system prompt file", file: "mcp.yaml", env: map[string]string{EnvSystemPromptFile: "/nonexistent/prompt.tmpl"}, wantErr: "failed to read llm.system_prompt_file"},
+		{name: "bad system prompt template", file: "mcp.yaml", content: "llm:\n  system_prompt: \"Reply in JSON {{.Actions\"\n", wantErr: "invalid custom system prompt: invalid system prompt template"},
+		{name: "unknown system prompt field", file: "mcp.yaml", content: "llm:\n  system_prompt: \"Reply in JSON {{.Tools}}\"\n", wantErr: "invalid custom system prompt"},
+		{name: "system prompt without JSON", file: "mcp.yaml", content: "llm:\n  system_prompt: Reply with a list of steps\n", wantErr: "system prompt does not ask for JSON output"},
+		{name: "negative docker retry attempts", file: "mcp.yaml", content: "docker_retry_attempts: -1\n", wantErr: "docker_retry_attempts must not be negative, got -1"},
+		{name: "bad endpoint", file: "mcp.yaml", content: "endpoint: localhost:1234\n", wantErr: `invalid endpoint "localhost:1234"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(writeFile(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	clearEnv(t)
+	for _, name := range []string{"mcp.yaml", "mcp.json"} {
+		t.Run(name, func(t *testing.T) {
+			want := Default()
+			want.Service = "docker"
+			want.DefaultProject = "shop"
+			path := filepath.Join(t.TempDir(), name)
+			if err := want.Write(path); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			got, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Environment variables overriding the default timeouts. Values are Go durations such as
+// "90s" or "10m".
+const (
+	EnvPlanTimeout       = "MCP_PLAN_TIMEOUT"
+	EnvPullTimeout       = "MCP_PULL_TIMEOUT"
+	EnvBuildTimeout      = "MCP_BUILD_TIMEOUT"
+	EnvHTTPClientTimeout = "MCP_HTTP_CLIENT_TIMEOUT"
+	EnvDockerWait        = "MCP_DOCKER_WAIT"
+)
+
+// Timeouts bounds how long the server and its clients wait on slow operations.
+type Timeouts struct {
+	// Plan bounds a whole ExecutePlan call, and a single CallTool call, but see PlanDeadline.
+	Plan time.Duration
+	// Pull bounds each image pull.
+	Pull time.Duration
+	// Build bounds each image build.
+	Build time.Duration
+	// HTTPClient bounds each request the RPC client makes to the server.
+	HTTPClient time.Duration
+	// DockerWait is how long the server waits at startup for the Docker daemon to answer.
+	// Zero, the default, starts the server at once and keeps retrying in the background.
+	DockerWait time.Duration
+}
+
+// DefaultTimeouts returns the timeouts used when no override is set.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Plan:       30 * time.Second,
+		Pull:       2 * time.Minute,
+		Build:      10 * time.Minute,
+		HTTPClient: 5 * time.Minute,
+	}
+}
+
+// PlanDeadline returns how long a plan or tool call may run: Plan, raised to Pull and, when
+// it builds images, to Build, so that a pull or build is bounded by its own timeout rather
+// than cut short by a shorter plan deadline.
+func (t Timeouts) PlanDeadline(builds bool) time.Duration {
+	d := max(t.Plan, t.Pull)
+	if builds {
+		d = max(d, t.Build)
+	}
+	return d
+}
+
+// TimeoutsFromEnv returns DefaultTimeouts with any values overridden from the environment.
+func TimeoutsFromEnv() (Timeouts, error) {
+	t := DefaultTimeouts()
+	for env, field := range map[string]*time.Duration{
+		EnvPlanTimeout:       &t.Plan,
+		EnvPullTimeout:       &t.Pull,
+		EnvBuildTimeout:      &t.Build,
+		EnvHTTPClientTimeout: &t.HTTPClient,
+		EnvDockerWait:        &t.DockerWait,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Timeouts{}, fmt.Errorf("invalid %s %q: use a positive duration such as 90s or 10m", env, v)
+		}
+		*field = d
+	}
+	return t, nil
+}
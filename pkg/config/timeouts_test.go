@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(*Timeouts)
+		wantErr bool
+	}{
+		{name: "defaults", want: func(*Timeouts) {}},
+		{
+			name: "overrides",
+			env:  map[string]string{EnvPlanTimeout: "90s", EnvPullTimeout: "5m", EnvDockerWait: "10s"},
+			want: func(t *Timeouts) { t.Plan, t.Pull, t.DockerWait = 90*time.Second, 5*time.Minute, 10*time.Second },
+		},
+		{name: "not a duration", env: map[string]string{EnvBuildTimeout: "10"}, wantErr: true},
+		{name: "zero", env: map[string]string{EnvPlanTimeout: "0s"}, wantErr: true},
+		{name: "negative", env: map[string]string{EnvHTTPClientTimeout: "-1m"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{EnvPlanTimeout, EnvPullTimeout, EnvBuildTimeout, EnvHTTPClientTimeout, EnvDockerWait} {
+				t.Setenv(env, tt.env[env])
+			}
+			got, err := TimeoutsFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TimeoutsFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := DefaultTimeouts()
+			tt.want(&want)
+			if got != want {
+				t.Errorf("TimeoutsFromEnv() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts Timeouts
+		builds   bool
+		want     time.Duration
+	}{
+		{"defaults without builds", DefaultTimeouts(), false, 2 * time.Minute},
+		{"defaults with builds", DefaultTimeouts(), true, 10 * time.Minute},
+		{"plan longer than steps", Timeouts{Plan: time.Hour, Pull: time.Minute, Build: time.Minute}, true, time.Hour},
+		{"pull longer than build", Timeouts{Plan: time.Second, Pull: 3 * time.Minute, Build: time.Minute}, true, 3 * time.Minute},
+		{"build ignored without builds", Timeouts{Plan: time.Second, Pull: time.Second, Build: time.Hour}, false, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timeouts.PlanDeadline(tt.builds); got != tt.want {
+				t.Errorf("PlanDeadline(%v) = %v, want %v", tt.builds, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/client"
+)
+
+// TLSConfig names the PEM files used to authenticate to a Docker daemon over TLS.
+type TLSConfig struct {
+	CACert string
+	Cert   string
+	Key    string
+}
+
+// NewClient creates a Docker API client. Settings are first taken from the environment
+// (DOCKER_HOST, DOCKER_CERT_PATH, DOCKER_TLS_VERIFY, DOCKER_API_VERSION), then host and
+// tlsConfig override them when set, so one process can manage a remote daemon without
+// changing its environment.
+func NewClient(host string, tlsConfig *TLSConfig) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, client.WithTLSClientConfig(tlsConfig.CACert, tlsConfig.Cert, tlsConfig.Key))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	return cli, nil
+}
+
+// Ping checks that the daemon cli talks to answers. When it cannot be reached the error says
+// so in terms an operator can act on, while still matching client.IsErrConnectionFailed.
+func Ping(ctx context.Context, cli *client.Client) error {
+	_, err := cli.Ping(ctx)
+	if err != nil && (client.IsErrConnectionFailed(err) || errors.Is(err, context.DeadlineExceeded)) {
+		return fmt.Errorf("Docker daemon unreachable at %s; is Docker running? (%w)", cli.DaemonHost(), err)
+	}
+	return err
+}
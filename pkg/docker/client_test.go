@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestPing(t *testing.T) {
+	t.Run("daemon error is not a connection failure", func(t *testing.T) {
+		cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "daemon is starting"})
+		})
+		err := Ping(context.Background(), cli)
+		if err == nil {
+			t.Fatal("Ping succeeded against a failing daemon")
+		}
+		if strings.Contains(err.Error(), "unreachable") || client.IsErrConnectionFailed(err) {
+			t.Errorf("Ping = %v, want a daemon that answered not reported unreachable", err)
+		}
+	})
+	t.Run("unreachable daemon", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		host := "tcp://" + down.Listener.Addr().String()
+		cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithVersion("1.47"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		err = Ping(context.Background(), cli)
+		want := "Docker daemon unreachable at " + host + "; is Docker running?"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Ping = %v, want one containing %q", err, want)
+		}
+		if !client.IsErrConnectionFailed(err) {
+			t.Errorf("error %v no longer matches client.IsErrConnectionFailed", err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// DefaultCrashLoopWindow is how long restart counts are observed when looking for
+// crash-looping containers.
+const DefaultCrashLoopWindow = 3 * time.Second
+
+// crashLoopMinRestarts is the restart count above which a container caught in the
+// restarting state is treated as crash-looping even if no restart happened in the window.
+const crashLoopMinRestarts = 3
+
+// ContainerHealth summarises the runtime state of a project container.
+type ContainerHealth struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	State        string `json:"state"`
+	Status       string `json:"status"`
+	RestartCount int    `json:"restart_count"`
+	CrashLooping bool   `json:"crash_looping"`
+}
+
+// ProjectContainerHealth reports the state of every container labelled with project, sorted
+// by name. A container is flagged as crash-looping when its restart count grows within
+// window, or when it is restarting after more than crashLoopMinRestarts restarts. The
+// second inspection is skipped when window is zero or no container has a restart policy.
+func ProjectContainerHealth(ctx context.Context, cli *client.Client, project string, window time.Duration) ([]ContainerHealth, error) {
+	f := filters.NewArgs()
+	f.Add("label", fmt.Sprintf("%s=%s", ProjectLabel, project))
+	list, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers for project %s: %w", project, err)
+	}
+
+	health := make([]ContainerHealth, 0, len(list))
+	watch := map[int]bool{}
+	for _, c := range list {
+		info, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", c.ID, err)
+		}
+		h := ContainerHealth{
+			ID:           c.ID,
+			Name:         strings.TrimPrefix(info.Name, "/"),
+			Image:        c.Image,
+			State:        c.State,
+			Status:       c.Status,
+			RestartCount: info.RestartCount,
+		}
+		if restarting(info) && info.RestartCount > crashLoopMinRestarts {
+			h.CrashLooping = true
+		}
+		if info.HostConfig != nil && !info.HostConfig.RestartPolicy.IsNone() && info.HostConfig.RestartPolicy.Name != "" {
+			watch[len(health)] = true
+		}
+		health = append(health, h)
+	}
+
+	if window > 0 && len(watch) > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(window):
+		}
+		for i := range watch {
+			info, err := cli.ContainerInspect(ctx, health[i].ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to inspect container %s: %w", health[i].Name, err)
+			}
+			if info.RestartCount > health[i].RestartCount {
+				health[i].CrashLooping = true
+			}
+			health[i].RestartCount = info.RestartCount
+		}
+	}
+
+	sort.Slice(health, func(i, j int) bool { return health[i].Name < health[j].Name })
+	return health, nil
+}
+
+func restarting(info types.ContainerJSON) bool {
+	return info.State != nil && info.State.Restarting
+}
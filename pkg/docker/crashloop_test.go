@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// crashContainer is a container served by crashDaemon. Successive inspections report the
+// restart counts in counts, repeating the last one.
+type crashContainer struct {
+	name       string
+	policy     string
+	restarting bool
+	counts     []int
+}
+
+// crashDaemon lists containers as the project's and answers their inspections, counting
+// them per container in inspects.
+func crashDaemon(t *testing.T, containers []crashContainer, inspects map[string]int) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			if got := r.URL.Query().Get("filters"); !strings.Contains(got, ProjectLabel+"=shop") {
+				t.Errorf("filters = %s, want the project label", got)
+			}
+			list := []map[string]interface{}{}
+			for _, c := range containers {
+				list = append(list, map[string]interface{}{"Id": c.name, "Names": []string{"/" + c.name}, "Image": "app", "State": "running", "Status": "Up"})
+			}
+			writeJSON(w, http.StatusOK, list)
+			return
+		}
+		for _, c := range containers {
+			if r.URL.Path != "/containers/"+c.name+"/json" {
+				continue
+			}
+			mu.Lock()
+			n := inspects[c.name]
+			inspects[c.name]++
+			mu.Unlock()
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"Id":           c.name,
+				"Name":         "/" + c.name,
+				"RestartCount": c.counts[min(n, len(c.counts)-1)],
+				"State":        map[string]interface{}{"Restarting": c.restarting},
+				"HostConfig":   map[string]interface{}{"RestartPolicy": map[string]interface{}{"Name": c.policy}},
+			})
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found: " + r.URL.Path})
+	}
+}
+
+func TestProjectContainerHealth(t *testing.T) {
+	containers := []crashContainer{
+		{name: "web", policy: "always", counts: []int{2, 4}},
+		{name: "db", policy: "unless-stopped", counts: []int{1}},
+		{name: "job", policy: "no", restarting: true, counts: []int{5}},
+		{name: "cache", policy: "no", counts: []int{9}},
+	}
+	tests := []struct {
+		name         string
+		window       time.Duration
+		wantLooping  map[string]bool
+		wantCounts   map[string]int
+		wantInspects map[string]int
+	}{
+		{
+			name:         "restart count grows within the window",
+			window:       10 * time.Millisecond,
+			wantLooping:  map[string]bool{"web": true, "job": true},
+			wantCounts:   map[string]int{"web": 4, "db": 1, "job": 5, "cache": 9},
+			wantInspects: map[string]int{"web": 2, "db": 2, "job": 1, "cache": 1},
+		},
+		{
+			name:         "no window",
+			wantLooping:  map[string]bool{"job": true},
+			wantCounts:   map[string]int{"web": 2, "db": 1, "job": 5, "cache": 9},
+			wantInspects: map[string]int{"web": 1, "db": 1, "job": 1, "cache": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspects := map[string]int{}
+			cli := newFakeClient(t, crashDaemon(t, containers, inspects))
+			health, err := ProjectContainerHealth(context.Background(), cli, "shop", tt.window)
+			if err != nil {
+				t.Fatalf("ProjectContainerHealth() error = %v", err)
+			}
+			var names []string
+			for _, h := range health {
+				names = append(names, h.Name)
+				if h.CrashLooping != tt.wantLooping[h.Name] {
+					t.Errorf("%s crash looping = %v, want %v", h.Name, h.CrashLooping, tt.wantLooping[h.Name])
+				}
+				if h.RestartCount != tt.wantCounts[h.Name] {
+					t.Errorf("%s restart count = %d, want %d", h.Name, h.RestartCount, tt.wantCounts[h.Name])
+				}
+			}
+			if strings.Join(names, ",") != "cache,db,job,web" {
+				t.Errorf("containers = %v, want them sorted by name", names)
+			}
+			for name, want := range tt.wantInspects {
+				if inspects[name] != want {
+					t.Errorf("%s inspected %d times, want %d", name, inspects[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestProjectContainerHealthStopsWhenCancelled(t *testing.T) {
+	cli := newFakeClient(t, crashDaemon(t, []crashContainer{{name: "web", policy: "always", counts: []int{1}}}, map[string]int{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := ProjectContainerHealth(ctx, cli, "shop", time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("ProjectContainerHealth() error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want it to stop waiting when the context ends", elapsed)
+	}
+}
@@ -1,79 +1,884 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	img "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
-// CreateNetwork creates a Docker network with the given name.
-func CreateNetwork(ctx context.Context, cli *client.Client, name string) error {
+// ProjectLabel is the label key marking Docker resources as belonging to a project.
+const ProjectLabel = "mcp-server-docker.project"
+
+// ServiceLabel is the label key naming the service a scaled replica container belongs to.
+const ServiceLabel = "mcp-server-docker.service"
+
+// CreateNetwork creates a Docker network with the given name and labels, returning its ID.
+func CreateNetwork(ctx context.Context, cli *client.Client, name string, labels map[string]string) (string, error) {
+	return CreateNetworkWithOptions(ctx, cli, name, network.CreateOptions{Labels: labels})
+}
+
+// CreateNetworkWithOptions creates a Docker network with the given name and options, such as
+// its driver and IPAM configuration, returning its ID.
+func CreateNetworkWithOptions(ctx context.Context, cli *client.Client, name string, opts network.CreateOptions) (string, error) {
 	if name == "" {
-		return fmt.Errorf("missing network name")
+		return "", fmt.Errorf("missing network name")
 	}
-	_, err := cli.NetworkCreate(ctx, name, network.CreateOptions{})
-	return err
+	resp, err := cli.NetworkCreate(ctx, name, opts)
+	return resp.ID, err
 }
 
-// CreateContainer creates a Docker container with the given name and image.
-func CreateContainer(ctx context.Context, cli *client.Client, name, image string) error {
-	if name == "" || image == "" {
-		return fmt.Errorf("missing container name or image")
+// FindNetwork returns the network with exactly the given name, or nil if there is none.
+func FindNetwork(ctx context.Context, cli *client.Client, name string) (*network.Inspect, error) {
+	n, err := cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if errdefs.IsNotFound(err) {
+		return nil, nil
 	}
-	_, err := cli.ContainerCreate(ctx, &container.Config{Image: image}, nil, nil, nil, name)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	// NetworkInspect also matches ID prefixes; only an exact name match counts.
+	if n.Name != name {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+// ConnectNetwork attaches an existing container to an existing network, reachable there under
+// the given aliases in addition to its name. Both must exist; a missing one is reported by
+// name rather than with the daemon's generic not-found error.
+func ConnectNetwork(ctx context.Context, cli *client.Client, networkName, containerName string, aliases []string) error {
+	if err := requireNetworkAndContainer(ctx, cli, networkName, containerName); err != nil {
+		return err
+	}
+	return cli.NetworkConnect(ctx, networkName, containerName, &network.EndpointSettings{Aliases: aliases})
+}
+
+// DisconnectNetwork detaches a container from a network.
+func DisconnectNetwork(ctx context.Context, cli *client.Client, networkName, containerName string) error {
+	if err := requireNetworkAndContainer(ctx, cli, networkName, containerName); err != nil {
+		return err
+	}
+	return cli.NetworkDisconnect(ctx, networkName, containerName, false)
+}
+
+func requireNetworkAndContainer(ctx context.Context, cli *client.Client, networkName, containerName string) error {
+	if networkName == "" || containerName == "" {
+		return fmt.Errorf("missing network or container name")
+	}
+	n, err := FindNetwork(ctx, cli, networkName)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return fmt.Errorf("network %s does not exist", networkName)
+	}
+	c, err := FindContainer(ctx, cli, containerName)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("container %s does not exist", containerName)
+	}
+	return nil
 }
 
-// CreateVolume creates a Docker volume with the given name.
-func CreateVolume(ctx context.Context, cli *client.Client, name string) error {
+// CreateContainer creates a Docker container with the given name, config and host config,
+// returning its ID.
+func CreateContainer(ctx context.Context, cli *client.Client, name string, config *container.Config, hostConfig *container.HostConfig) (string, error) {
+	if name == "" || config == nil || config.Image == "" {
+		return "", fmt.Errorf("missing container name or image")
+	}
+	resp, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, name)
+	return resp.ID, err
+}
+
+// UpdateContainer changes the restart policy and resource limits of an existing container
+// in place, returning any warnings from the daemon.
+func UpdateContainer(ctx context.Context, cli *client.Client, name string, update container.UpdateConfig) ([]string, error) {
 	if name == "" {
+		return nil, fmt.Errorf("missing container name")
+	}
+	resp, err := cli.ContainerUpdate(ctx, name, update)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Warnings, nil
+}
+
+// FindContainer returns the container with the given name, or nil if there is none.
+func FindContainer(ctx context.Context, cli *client.Client, name string) (*types.ContainerJSON, error) {
+	c, err := cli.ContainerInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateVolume creates a Docker volume with the given name and labels.
+func CreateVolume(ctx context.Context, cli *client.Client, name string, labels map[string]string) error {
+	return CreateVolumeWithOptions(ctx, cli, volume.CreateOptions{Name: name, Labels: labels})
+}
+
+// CreateVolumeWithOptions creates a Docker volume from opts, which name it and may set its
+// driver and driver options, such as the type, o and device of an NFS mount.
+func CreateVolumeWithOptions(ctx context.Context, cli *client.Client, opts volume.CreateOptions) error {
+	if opts.Name == "" {
 		return fmt.Errorf("invalid or missing volume name")
 	}
-	_, err := cli.VolumeCreate(ctx, volume.CreateOptions{Name: name})
+	_, err := cli.VolumeCreate(ctx, opts)
 	return err
 }
 
-// RunContainer starts the Docker container with the given name.
-func RunContainer(ctx context.Context, cli *client.Client, name string) error {
+// FindVolume returns the volume with the given name, or nil if there is none.
+func FindVolume(ctx context.Context, cli *client.Client, name string) (*volume.Volume, error) {
+	v, err := cli.VolumeInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DefaultStartAttempts is how many times RunContainer polls a started container before
+// giving up on it reaching a settled state.
+const DefaultStartAttempts = 10
+
+// startPollInterval is the delay between RunContainer's state polls.
+const startPollInterval = 200 * time.Millisecond
+
+// RemoveContainer force-removes the container with the given name or ID, stopping it first
+// if it is running.
+func RemoveContainer(ctx context.Context, cli *client.Client, nameOrID string) error {
+	return cli.ContainerRemove(ctx, nameOrID, container.RemoveOptions{Force: true})
+}
+
+// ListContainersByLabels lists the containers carrying every label in labels, with values
+// matched exactly (an empty value matches any value). Stopped containers are included only
+// when all is set.
+func ListContainersByLabels(ctx context.Context, cli *client.Client, labels map[string]string, all bool) ([]types.Container, error) {
+	f := filters.NewArgs()
+	for k, v := range labels {
+		if v == "" {
+			f.Add("label", k)
+		} else {
+			f.Add("label", fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return cli.ContainerList(ctx, container.ListOptions{All: all, Filters: f})
+}
+
+// StopContainer stops a container, killing it if it has not exited after timeout seconds
+// (nil uses the container's own stop timeout). A container that is already stopped or no
+// longer exists counts as stopped.
+func StopContainer(ctx context.Context, cli *client.Client, nameOrID string, timeout *int) error {
+	err := cli.ContainerStop(ctx, nameOrID, container.StopOptions{Timeout: timeout})
+	if errdefs.IsNotFound(err) || errdefs.IsNotModified(err) {
+		return nil
+	}
+	return err
+}
+
+// RemoveNetwork removes the network with the given name or ID.
+func RemoveNetwork(ctx context.Context, cli *client.Client, nameOrID string) error {
+	return cli.NetworkRemove(ctx, nameOrID)
+}
+
+// RemoveVolume removes the volume with the given name.
+func RemoveVolume(ctx context.Context, cli *client.Client, name string) error {
+	return cli.VolumeRemove(ctx, name, false)
+}
+
+// ContainerLogTail returns the last lines lines of the named container's combined stdout and
+// stderr, oldest first, and its exit code (meaningful only once it has stopped).
+func ContainerLogTail(ctx context.Context, cli *client.Client, name string, lines int) ([]string, int, error) {
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	exitCode := 0
+	if info.State != nil {
+		exitCode = info.State.ExitCode
+	}
+	rc, err := cli.ContainerLogs(ctx, name, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return nil, exitCode, err
+	}
+	defer rc.Close()
+	var output bytes.Buffer
+	// A container with a TTY has a single raw stream; otherwise the two are multiplexed.
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(&output, rc)
+	} else {
+		_, err = stdcopy.StdCopy(&output, &output, rc)
+	}
+	if err != nil {
+		return nil, exitCode, fmt.Errorf("failed to read logs of container %s: %w", name, err)
+	}
+	text := strings.TrimRight(output.String(), "\n")
+	if text == "" {
+		return []string{}, exitCode, nil
+	}
+	return strings.Split(text, "\n"), exitCode, nil
+}
+
+// ExecCommand runs cmd inside the named container and returns its exit code together with
+// its combined stdout and stderr.
+func ExecCommand(ctx context.Context, cli *client.Client, name string, cmd []string) (int, string, error) {
+	exec, err := cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Close()
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, resp.Reader); err != nil {
+		return 0, "", fmt.Errorf("failed to read exec output: %w", err)
+	}
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, "", err
+	}
+	return inspect.ExitCode, output.String(), nil
+}
+
+// RunContainer starts the Docker container with the given name and polls up to attempts
+// times until it reports running (or has already exited), returning the confirmed state.
+// A transient failure to start it is retried as by Retry with up to retries tries; the
+// polling itself is not retried.
+func RunContainer(ctx context.Context, cli *client.Client, name string, attempts, retries int) (*types.ContainerState, error) {
+	if name == "" {
+		return nil, fmt.Errorf("invalid container name")
+	}
+	err := Retry(ctx, retries, func() error {
+		return cli.ContainerStart(ctx, name, container.StartOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		info, err := cli.ContainerInspect(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if info.State != nil && (info.State.Running || info.State.Status == "exited" || info.State.Status == "dead") {
+			return info.State, nil
+		}
+		if attempt >= attempts {
+			status := "unknown"
+			if info.State != nil {
+				status = info.State.Status
+			}
+			return nil, fmt.Errorf("container %s did not reach running state after %d checks (status: %s)", name, attempts, status)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(startPollInterval):
+		}
+	}
+}
+
+// WaitContainer blocks until the named container stops running and returns its exit code,
+// together with the error message the daemon reports for the wait, if any. A context
+// deadline or cancellation is returned as ctx.Err().
+func WaitContainer(ctx context.Context, cli *client.Client, name string) (int64, string, error) {
 	if name == "" {
-		return fmt.Errorf("invalid container name")
+		return 0, "", fmt.Errorf("missing container name")
+	}
+	statusCh, errCh := cli.ContainerWait(ctx, name, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		msg := ""
+		if status.Error != nil {
+			msg = status.Error.Message
+		}
+		return status.StatusCode, msg, nil
+	case err := <-errCh:
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, "", ctxErr
+		}
+		return 0, "", err
+	case <-ctx.Done():
+		return 0, "", ctx.Err()
+	}
+}
+
+// CopyToContainer copies the local file or directory sourcePath into the named container so
+// that it ends up at destPath, like `docker cp source container:dest` with a destination
+// that does not exist yet. The parent directory of destPath must exist in the container.
+func CopyToContainer(ctx context.Context, cli *client.Client, name, sourcePath, destPath string) error {
+	if name == "" || sourcePath == "" || destPath == "" {
+		return fmt.Errorf("missing container name, source_path or dest_path")
+	}
+	if !path.IsAbs(destPath) {
+		return fmt.Errorf("dest_path %q must be an absolute path inside the container", destPath)
+	}
+	if err := requireContainer(ctx, cli, name); err != nil {
+		return err
+	}
+	destPath = path.Clean(destPath)
+	var buf bytes.Buffer
+	if err := tarPath(&buf, sourcePath, path.Base(destPath)); err != nil {
+		return err
+	}
+	return cli.CopyToContainer(ctx, name, path.Dir(destPath), &buf, container.CopyToContainerOptions{})
+}
+
+// CopyFromContainer copies sourcePath out of the named container into the local directory
+// destDir, creating it if needed, and returns the local paths written. Only regular files
+// and directories are extracted; entries that would land outside destDir are refused.
+func CopyFromContainer(ctx context.Context, cli *client.Client, name, sourcePath, destDir string) ([]string, error) {
+	if name == "" || sourcePath == "" || destDir == "" {
+		return nil, fmt.Errorf("missing container name, source_path or dest_path")
+	}
+	if err := requireContainer(ctx, cli, name); err != nil {
+		return nil, err
+	}
+	rc, _, err := cli.CopyFromContainer(ctx, name, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return untar(rc, destDir)
+}
+
+// PauseContainer freezes the processes of the named running container. A container that is
+// missing, not running or already paused is reported as such, classified as not found or a
+// conflict.
+func PauseContainer(ctx context.Context, cli *client.Client, name string) error {
+	c, err := FindContainer(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case c == nil:
+		return errdefs.NotFound(fmt.Errorf("container %s does not exist", name))
+	case c.State != nil && c.State.Paused:
+		return errdefs.Conflict(fmt.Errorf("container %s is already paused", name))
+	case c.State == nil || !c.State.Running:
+		return errdefs.Conflict(fmt.Errorf("container %s is not running (status %s); only a running container can be paused", name, containerStatus(c)))
+	}
+	return cli.ContainerPause(ctx, name)
+}
+
+// UnpauseContainer resumes the processes of the named paused container. A container that is
+// missing or not paused is reported as such, classified as not found or a conflict.
+func UnpauseContainer(ctx context.Context, cli *client.Client, name string) error {
+	c, err := FindContainer(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case c == nil:
+		return errdefs.NotFound(fmt.Errorf("container %s does not exist", name))
+	case c.State == nil || !c.State.Paused:
+		return errdefs.Conflict(fmt.Errorf("container %s is not paused (status %s)", name, containerStatus(c)))
+	}
+	return cli.ContainerUnpause(ctx, name)
+}
+
+// containerStatus returns a container's status, e.g. "exited", or "unknown".
+func containerStatus(c *types.ContainerJSON) string {
+	if c.State == nil || c.State.Status == "" {
+		return "unknown"
+	}
+	return c.State.Status
+}
+
+// requireContainer returns an error naming the container if it does not exist.
+func requireContainer(ctx context.Context, cli *client.Client, name string) error {
+	c, err := FindContainer(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("container %s does not exist", name)
+	}
+	return nil
+}
+
+// tarPath writes the file or directory at source to w as a tar archive whose top-level
+// entry is called name.
+func tarPath(w io.Writer, source, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(source, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, file)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", source, err)
 	}
-	return cli.ContainerStart(ctx, name, container.StartOptions{})
+	return tw.Close()
 }
 
-// PullImage pulls a Docker image. It accepts a parameters map so that if the image name is not directly provided,
-// it will combine "name" and "tag" (defaulting tag to "latest").
-func PullImage(ctx context.Context, cli *client.Client, parameters map[string]interface{}) error {
-	image, ok := parameters["image"].(string)
-	if !ok || image == "" {
-		// Try to combine "name" and "tag"
-		name, nameOk := parameters["name"].(string)
-		tag, tagOk := parameters["tag"].(string)
-		if !nameOk || name == "" {
-			return fmt.Errorf("missing image name for pull_image")
+// untar extracts the regular files and directories of a tar stream into destDir. Entries
+// that would land outside destDir, directly or through a symlink already in it, are refused.
+func untar(r io.Reader, destDir string) ([]string, error) {
+	root, err := filepath.Abs(destDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	var written []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return written, nil
 		}
-		if !tagOk || tag == "" {
-			tag = "latest"
+		if err != nil {
+			return written, err
 		}
-		image = fmt.Sprintf("%s:%s", name, tag)
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return written, fmt.Errorf("refusing to extract %q outside %s", hdr.Name, destDir)
+		}
+		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeReg {
+			if err := noSymlinks(root, target); err != nil {
+				return written, err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return written, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return written, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return written, err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return written, err
+			}
+			written = append(written, target)
+		}
+	}
+}
+
+// noSymlinks returns an error if target, or a directory between root and target, is an
+// existing symlink, so that links already in the destination cannot redirect extraction
+// outside it.
+func noSymlinks(root, target string) error {
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." {
+		return err
 	}
-	// Use a child context with a longer timeout for image pulling.
-	pullCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	p := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to extract through symlink %s", p)
+		}
+	}
+	return nil
+}
+
+// ErrRateLimited is returned when a registry refuses a pull because of its rate limit, as
+// Docker Hub does for anonymous and free-tier users.
+var ErrRateLimited = errors.New("registry rate limit reached")
+
+// pullRetryDelay is how long PullImage backs off before retrying a rate-limited pull once.
+const pullRetryDelay = 10 * time.Second
+
+// PullImage pulls the Docker image with the given reference, giving each attempt up to
+// timeout. Progress lines are passed to onLine (when non-nil) as described for
+// ReadPullOutput. Transient failures are retried as by Retry with up to attempts tries. A
+// rate-limited pull is retried once after pullRetryDelay; if it is refused again the error
+// wraps ErrRateLimited.
+func PullImage(ctx context.Context, cli *client.Client, image string, timeout time.Duration, attempts int, onLine func(string)) error {
+	if image == "" {
+		return fmt.Errorf("missing image name for pull_image")
+	}
+	pull := func() error { return pullImage(ctx, cli, image, timeout, onLine) }
+	err := Retry(ctx, attempts, pull)
+	if !errors.Is(err, ErrRateLimited) {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return err
+	case <-time.After(pullRetryDelay):
+	}
+	return Retry(ctx, attempts, pull)
+}
+
+func pullImage(ctx context.Context, cli *client.Client, image string, timeout time.Duration, onLine func(string)) error {
+	pullCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	out, err := cli.ImagePull(pullCtx, image, img.PullOptions{})
 	if err != nil {
-		return err
+		return classifyPullError(image, err)
 	}
 	defer out.Close()
-	// Consume the output stream so the pull completes.
-	_, err = io.Copy(io.Discard, out)
+	// Read the whole stream so the pull completes; failures are reported inside it.
+	if err := ReadPullOutput(out, onLine); err != nil {
+		return classifyPullError(image, err)
+	}
+	return nil
+}
+
+// classifyPullError wraps rate-limit failures in ErrRateLimited with advice on avoiding them.
+func classifyPullError(image string, err error) error {
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "toomanyrequests") || strings.Contains(msg, "429 too many requests") || strings.Contains(msg, "rate limit") {
+		return fmt.Errorf("%w while pulling %s: Docker Hub limits anonymous and free-tier pulls; run `docker login` on the daemon host (or use a mirror) and try again later: %v", ErrRateLimited, image, err)
+	}
 	return err
 }
+
+// ImageExists reports whether the image reference is present locally.
+func ImageExists(ctx context.Context, cli *client.Client, image string) (bool, error) {
+	_, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ImageTag tags the local image source as target. Unlike the raw API error, a missing source
+// image is reported in terms of the image the caller named.
+func ImageTag(ctx context.Context, cli *client.Client, source, target string) error {
+	if source == "" || target == "" {
+		return fmt.Errorf("missing source or target image for tag_image")
+	}
+	if _, _, err := cli.ImageInspectWithRaw(ctx, source); err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("source image %s does not exist locally; pull or build it first", source)
+		}
+		return err
+	}
+	return cli.ImageTag(ctx, source, target)
+}
+
+// ImageRemoval lists what removing an image did: the references that were untagged and the
+// image and layer IDs that were deleted.
+type ImageRemoval struct {
+	Untagged []string `json:"untagged"`
+	Deleted  []string `json:"deleted"`
+}
+
+// RemoveImage removes the local image ref. force removes it even when stopped containers use
+// it or it has several tags; pruneChildren also deletes untagged parent images. An image
+// used by a container is reported as a conflict saying so.
+func RemoveImage(ctx context.Context, cli *client.Client, ref string, force, pruneChildren bool) (ImageRemoval, error) {
+	removal := ImageRemoval{Untagged: []string{}, Deleted: []string{}}
+	resp, err := cli.ImageRemove(ctx, ref, img.RemoveOptions{Force: force, PruneChildren: pruneChildren})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return removal, fmt.Errorf("image %s does not exist locally: %w", ref, err)
+		}
+		if errdefs.IsConflict(err) && strings.Contains(err.Error(), "container") {
+			hint := "remove the container first, or set force if it is stopped"
+			if strings.Contains(err.Error(), "running container") {
+				hint = "stop and remove the container first"
+			}
+			return removal, fmt.Errorf("image %s is in use by a container; %s: %w", ref, hint, err)
+		}
+		return removal, err
+	}
+	for _, r := range resp {
+		if r.Untagged != "" {
+			removal.Untagged = append(removal.Untagged, r.Untagged)
+		}
+		if r.Deleted != "" {
+			removal.Deleted = append(removal.Deleted, r.Deleted)
+		}
+	}
+	return removal, nil
+}
+
+// ImageSummary is the subset of image metadata reported by ListImages.
+type ImageSummary struct {
+	ID       string   `json:"id"`
+	RepoTags []string `json:"repoTags"`
+	Size     int64    `json:"size"`
+	Created  int64    `json:"created"`
+}
+
+// ListImages lists local images, optionally restricted to those matching reference (e.g.
+// "nginx" or "nginx:1.*") and carrying every label in labels ("key" or "key=value").
+func ListImages(ctx context.Context, cli *client.Client, reference string, labels []string) ([]ImageSummary, error) {
+	f := filters.NewArgs()
+	if reference != "" {
+		f.Add("reference", reference)
+	}
+	for _, label := range labels {
+		f.Add("label", label)
+	}
+	list, err := cli.ImageList(ctx, img.ListOptions{Filters: f})
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]ImageSummary, 0, len(list))
+	for _, i := range list {
+		tags := i.RepoTags
+		if tags == nil {
+			tags = []string{}
+		}
+		summaries = append(summaries, ImageSummary{ID: i.ID, RepoTags: tags, Size: i.Size, Created: i.Created})
+	}
+	return summaries, nil
+}
+
+// BuildImage builds an image tagged with tag from a tarred build context. dockerfile is the
+// path of the Dockerfile inside the context and defaults to "Dockerfile". Each build log line
+// is passed to onLine (when non-nil) as it arrives, and the full log is returned.
+func BuildImage(ctx context.Context, cli *client.Client, buildContext io.Reader, tag, dockerfile string, onLine func(string)) ([]string, error) {
+	if tag == "" {
+		return nil, fmt.Errorf("missing image tag for build_image")
+	}
+	if buildContext == nil {
+		return nil, fmt.Errorf("missing build context for build_image")
+	}
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	resp, err := cli.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:       []string{tag},
+		Dockerfile: dockerfile,
+		Remove:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ReadBuildOutput(resp.Body, onLine)
+}
+
+// buildMessage is one entry of the JSON stream returned by the image build API.
+type buildMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// ReadBuildOutput turns a Docker build JSON stream into readable log lines. Lines emitted
+// while a Dockerfile step runs are prefixed with that step's number (e.g. "[2/5] ..."), so
+// the interleaved output stays attributable. Build failures are reported inside the stream
+// rather than as an API error; they are returned as an error along with the log so far.
+func ReadBuildOutput(r io.Reader, onLine func(string)) ([]string, error) {
+	var (
+		lines   []string
+		step    string
+		partial string
+	)
+	emit := func(line string) {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+		if m := buildStepPattern.FindStringSubmatch(line); m != nil {
+			step = m[1]
+		} else if step != "" {
+			line = "[" + step + "] " + line
+		}
+		lines = append(lines, line)
+		if onLine != nil {
+			onLine(line)
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg buildMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return lines, fmt.Errorf("failed to read build output: %w", err)
+		}
+		if msg.Error != "" || msg.ErrorDetail != nil {
+			errMsg := msg.Error
+			if errMsg == "" {
+				errMsg = msg.ErrorDetail.Message
+			}
+			if partial != "" {
+				emit(partial)
+			}
+			emit("ERROR: " + errMsg)
+			return lines, fmt.Errorf("build failed: %s", errMsg)
+		}
+		if msg.Status != "" {
+			// Base image pulls report per-layer status rather than log text.
+			if msg.ID != "" {
+				emit(msg.ID + ": " + msg.Status)
+			} else {
+				emit(msg.Status)
+			}
+			continue
+		}
+		// Stream chunks are not guaranteed to end on a line boundary.
+		text := partial + msg.Stream
+		parts := strings.Split(text, "\n")
+		partial = parts[len(parts)-1]
+		for _, line := range parts[:len(parts)-1] {
+			emit(line)
+		}
+	}
+	if partial != "" {
+		emit(partial)
+	}
+	return lines, nil
+}
+
+// buildStepPattern matches the classic builder's step header, e.g. "Step 2/5 : RUN make".
+var buildStepPattern = regexp.MustCompile(`^Step (\d+/\d+) :`)
+
+// ContainerUsage is a container's resource usage, computed as `docker stats` does.
+type ContainerUsage struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemUsageBytes uint64  `json:"mem_usage_bytes"`
+	MemLimitBytes uint64  `json:"mem_limit_bytes"`
+	MemPercent    float64 `json:"mem_percent"`
+	NetRxBytes    uint64  `json:"net_rx"`
+	NetTxBytes    uint64  `json:"net_tx"`
+}
+
+// ContainerStats takes a one-shot sample of the named container's resource usage.
+func ContainerStats(ctx context.Context, cli *client.Client, name string) (ContainerUsage, error) {
+	if name == "" {
+		return ContainerUsage{}, fmt.Errorf("missing container name for container_stats")
+	}
+	resp, err := cli.ContainerStats(ctx, name, false)
+	if err != nil {
+		return ContainerUsage{}, err
+	}
+	defer resp.Body.Close()
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ContainerUsage{}, fmt.Errorf("failed to decode stats of container %s: %w", name, err)
+	}
+	return Usage(stats), nil
+}
+
+// Usage computes resource usage from a stats sample. Memory excludes the page cache
+// ("inactive_file"), and network traffic is summed over all interfaces.
+func Usage(stats container.StatsResponse) ContainerUsage {
+	u := ContainerUsage{
+		CPUPercent:    CPUPercent(stats),
+		MemUsageBytes: stats.MemoryStats.Usage,
+		MemLimitBytes: stats.MemoryStats.Limit,
+	}
+	// cgroup v2 reports the page cache as inactive_file, v1 as total_inactive_file.
+	cache, ok := stats.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if cache < u.MemUsageBytes {
+		u.MemUsageBytes -= cache
+	}
+	if u.MemLimitBytes > 0 {
+		u.MemPercent = float64(u.MemUsageBytes) / float64(u.MemLimitBytes) * 100
+	}
+	for _, n := range stats.Networks {
+		u.NetRxBytes += n.RxBytes
+		u.NetTxBytes += n.TxBytes
+	}
+	return u
+}
+
+// CPUPercent computes CPU usage from the change in container and system CPU time between
+// the previous and current readings of a stats sample, scaled by the number of CPUs, so a
+// container saturating two CPUs reports 200%.
+func CPUPercent(stats container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
@@ -0,0 +1,639 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// newFakeClient returns a Docker client talking to a fake daemon that passes every request,
+// with the API version prefix stripped from its path, to daemon.
+func newFakeClient(t *testing.T, daemon http.HandlerFunc) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/v1.47")
+		daemon(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// writeJSON answers a fake daemon request with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestReadBuildOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "steps prefix their output",
+			stream: `{"stream": "Step 1/2 : FROM alpine\n"}
+{"stream": " ---> abc\n"}
+{"stream": "Step 2/2 : RUN make\n"}
+{"stream": "make: ok\n"}`,
+			want: []string{"Step 1/2 : FROM alpine", "[1/2]  ---> abc", "Step 2/2 : RUN make", "[2/2] make: ok"},
+		},
+		{
+			name:   "chunks split mid-line",
+			stream: `{"stream": "Step 1/1 : RUN ec"}{"stream": "ho hi\nhi"}{"stream": "\n"}`,
+			want:   []string{"Step 1/1 : RUN echo hi", "[1/1] hi"},
+		},
+		{
+			name:   "pull status",
+			stream: `{"status": "Pulling fs layer", "id": "a1"}{"status": "Digest: sha256:1"}`,
+			want:   []string{"a1: Pulling fs layer", "Digest: sha256:1"},
+		},
+		{
+			name:    "error detail",
+			stream:  `{"stream": "Step 1/1 : RUN false\n"}{"errorDetail": {"message": "exit code 1"}}`,
+			want:    []string{"Step 1/1 : RUN false", "[1/1] ERROR: exit code 1"},
+			wantErr: "build failed: exit code 1",
+		},
+		{
+			name:    "malformed stream",
+			stream:  `{"stream": "Step 1/1 : FROM alpine\n"}{`,
+			want:    []string{"Step 1/1 : FROM alpine"},
+			wantErr: "failed to read build output",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var streamed []string
+			got, err := ReadBuildOutput(strings.NewReader(tt.stream), func(line string) {
+				streamed = append(streamed, line)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ReadBuildOutput() error = %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("ReadBuildOutput() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadBuildOutput() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(streamed, tt.want) {
+				t.Errorf("streamed %q, want %q", streamed, tt.want)
+			}
+		})
+	}
+}
+
+func TestListImages(t *testing.T) {
+	var gotFilters map[string]map[string]bool
+	cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/json" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found: " + r.URL.Path})
+			return
+		}
+		gotFilters = nil
+		if f := r.URL.Query().Get("filters"); f != "" {
+			if err := json.Unmarshal([]byte(f), &gotFilters); err != nil {
+				t.Errorf("decoding filters: %v", err)
+			}
+		}
+		writeJSON(w, http.StatusOK, []map[string]interface{}{
+			{"Id": "sha256:1", "RepoTags": []string{"nginx:1.27"}, "RepoDigests": []string{"nginx@sha256:a"}, "Size": 1024, "Created": 100, "Labels": map[string]string{"a": "b"}, "Containers": 2},
+			{"Id": "sha256:2", "RepoTags": nil, "Size": 512, "Created": 50},
+		})
+	})
+
+	got, err := ListImages(context.Background(), cli, "nginx:1.*", []string{"tier=web", ProjectLabel + "=shop"})
+	if err != nil {
+		t.Fatalf("ListImages() error = %v", err)
+	}
+	wantFilters := map[string]map[string]bool{
+		"reference": {"nginx:1.*": true},
+		"label":     {"tier=web": true, ProjectLabel + "=shop": true},
+	}
+	if !reflect.DeepEqual(gotFilters, wantFilters) {
+		t.Errorf("filters = %v, want %v", gotFilters, wantFilters)
+	}
+	want := []ImageSummary{
+		{ID: "sha256:1", RepoTags: []string{"nginx:1.27"}, Size: 1024, Created: 100},
+		{ID: "sha256:2", RepoTags: []string{}, Size: 512, Created: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListImages() = %+v, want %+v", got, want)
+	}
+	out, _ := json.Marshal(got[1])
+	if string(out) != `{"id":"sha256:2","repoTags":[],"size":512,"created":50}` {
+		t.Errorf("untagged image encodes as %s, want an empty repoTags list", out)
+	}
+
+	if _, err := ListImages(context.Background(), cli, "", nil); err != nil {
+		t.Fatalf("ListImages() error = %v", err)
+	}
+	if len(gotFilters) != 0 {
+		t.Errorf("filters = %v, want none without a reference or labels", gotFilters)
+	}
+}
+
+func TestWaitContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		daemon   http.HandlerFunc
+		timeout  time.Duration
+		wantCode int64
+		wantMsg  string
+		wantErr  string
+	}{
+		{
+			name: "clean exit",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"StatusCode": 0})
+			},
+		},
+		{
+			name: "failed job",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"StatusCode": 3, "Error": map[string]string{"Message": "migration failed"}})
+			},
+			wantCode: 3,
+			wantMsg:  "migration failed",
+		},
+		{
+			name: "missing container",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"message": "No such container: job"})
+			},
+			wantErr: "No such container: job",
+		},
+		{
+			name: "still running at the deadline",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			timeout: 50 * time.Millisecond,
+			wantErr: context.DeadlineExceeded.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/containers/job/wait" || r.URL.Query().Get("condition") != "not-running" {
+					t.Errorf("waited on %s with condition %q, want /containers/job/wait until not-running", r.URL.Path, r.URL.Query().Get("condition"))
+				}
+				tt.daemon(w, r)
+			})
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			code, msg, err := WaitContainer(ctx, cli, "job")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("WaitContainer() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WaitContainer() error = %v", err)
+			}
+			if code != tt.wantCode || msg != tt.wantMsg {
+				t.Errorf("WaitContainer() = %d, %q; want %d, %q", code, msg, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestPullImageHonoursTimeout(t *testing.T) {
+	cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "Pulling from library/redis"}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	start := time.Now()
+	err := PullImage(context.Background(), cli, "redis:latest", 50*time.Millisecond, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("PullImage() error = %v, want the pull timeout to expire", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("PullImage() took %v, want it bounded by the 50ms timeout", elapsed)
+	}
+}
+
+func TestPullImageReportsRateLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		daemon      http.HandlerFunc
+		rateLimited bool
+		wantErr     string
+	}{
+		{
+			name: "refused request",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "toomanyrequests: You have reached your pull rate limit."})
+			},
+			rateLimited: true,
+		},
+		{
+			name: "error in the stream",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status": "Pulling from library/redis"}` + "\n" +
+					`{"errorDetail": {"message": "429 Too Many Requests"}, "error": "429 Too Many Requests"}` + "\n"))
+			},
+			rateLimited: true,
+		},
+		{
+			name: "other stream error",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"error": "manifest unknown"}` + "\n"))
+			},
+			wantErr: "manifest unknown",
+		},
+		{
+			name: "success",
+			daemon: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status": "Status: Downloaded newer image for redis:latest"}` + "\n"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newFakeClient(t, tt.daemon)
+			err := pullImage(context.Background(), cli, "redis:latest", time.Minute, nil)
+			if errors.Is(err, ErrRateLimited) != tt.rateLimited {
+				t.Fatalf("pullImage() error = %v, rate limited = %v", err, tt.rateLimited)
+			}
+			switch {
+			case tt.rateLimited:
+				for _, want := range []string{"registry rate limit reached while pulling redis:latest", "docker login"} {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("pullImage() error = %v, want one containing %q", err, want)
+					}
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("pullImage() error = %v, want one containing %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("pullImage() error = %v", err)
+			}
+		})
+	}
+}
+
+// tarEntry is one entry of a tar stream built by makeTar.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+func makeTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0o644, Size: int64(len(e.body)), Linkname: e.linkname}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		// setup prepares destDir and returns a directory outside it that must stay empty.
+		setup   func(t *testing.T, destDir string) string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "files and directories",
+			entries: []tarEntry{
+				{name: "out/", typeflag: tar.TypeDir},
+				{name: "out/a.txt", typeflag: tar.TypeReg, body: "a"},
+				{name: "out/sub/b.txt", typeflag: tar.TypeReg, body: "b"},
+			},
+			want: []string{"out/a.txt", "out/sub/b.txt"},
+		},
+		{
+			name: "links and devices are skipped",
+			entries: []tarEntry{
+				{name: "out/a.txt", typeflag: tar.TypeReg, body: "a"},
+				{name: "out/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+				{name: "out/hard", typeflag: tar.TypeLink, linkname: "out/a.txt"},
+			},
+			want: []string{"out/a.txt"},
+		},
+		{
+			name:    "parent traversal",
+			entries: []tarEntry{{name: "../escaped.txt", typeflag: tar.TypeReg, body: "x"}},
+			wantErr: "outside",
+		},
+		{
+			name:    "nested parent traversal",
+			entries: []tarEntry{{name: "out/../../escaped.txt", typeflag: tar.TypeReg, body: "x"}},
+			wantErr: "outside",
+		},
+		{
+			name:    "absolute name stays inside",
+			entries: []tarEntry{{name: "/abs.txt", typeflag: tar.TypeReg, body: "x"}},
+			want:    []string{"abs.txt"},
+		},
+		{
+			name:    "existing symlinked directory",
+			entries: []tarEntry{{name: "link/escaped.txt", typeflag: tar.TypeReg, body: "x"}},
+			setup: func(t *testing.T, destDir string) string {
+				outside := t.TempDir()
+				if err := os.Symlink(outside, filepath.Join(destDir, "link")); err != nil {
+					t.Fatal(err)
+				}
+				return outside
+			},
+			wantErr: "symlink",
+		},
+		{
+			name:    "existing symlinked file",
+			entries: []tarEntry{{name: "target.txt", typeflag: tar.TypeReg, body: "x"}},
+			setup: func(t *testing.T, destDir string) string {
+				outside := t.TempDir()
+				if err := os.Symlink(filepath.Join(outside, "victim.txt"), filepath.Join(destDir, "target.txt")); err != nil {
+					t.Fatal(err)
+				}
+				return outside
+			},
+			wantErr: "symlink",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destDir := filepath.Join(t.TempDir(), "dest")
+			if err := os.MkdirAll(destDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			var outside string
+			if tt.setup != nil {
+				outside = tt.setup(t, destDir)
+			}
+			written, err := untar(makeTar(t, tt.entries), destDir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("untar() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(filepath.Join(filepath.Dir(destDir), "escaped.txt")); err == nil {
+					t.Error("file was written outside destDir")
+				}
+				if outside != "" {
+					if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+						t.Errorf("files were written through the symlink: %v", entries)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("untar() error = %v", err)
+			}
+			var got []string
+			for _, file := range written {
+				rel, err := filepath.Rel(destDir, file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("untar() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTarPathRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"app.conf": "listen 80;\n", "conf.d/extra.conf": "gzip on;\n"}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := tarPath(&buf, src, "nginx"); err != nil {
+		t.Fatalf("tarPath() error = %v", err)
+	}
+	dest := t.TempDir()
+	if _, err := untar(&buf, dest); err != nil {
+		t.Fatalf("untar() error = %v", err)
+	}
+	for name, body := range files {
+		got, err := os.ReadFile(filepath.Join(dest, "nginx", name))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		if string(got) != body {
+			t.Errorf("%s = %q, want %q", name, got, body)
+		}
+	}
+}
+
+func TestCopyToContainer(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(src, []byte("listen 80;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var dir string
+	var names []string
+	cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id": "c1", "Name": "/web"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/containers/web/archive":
+			dir = r.URL.Query().Get("path")
+			tr := tar.NewReader(r.Body)
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					break
+				}
+				names = append(names, hdr.Name)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No such container: ` + r.URL.Path + `"}`))
+		}
+	})
+
+	if err := CopyToContainer(context.Background(), cli, "web", src, "/etc/nginx/conf.d/default.conf"); err != nil {
+		t.Fatalf("CopyToContainer() error = %v", err)
+	}
+	if dir != "/etc/nginx/conf.d" || len(names) != 1 || names[0] != "default.conf" {
+		t.Errorf("copied %v into %q, want default.conf into /etc/nginx/conf.d", names, dir)
+	}
+
+	tests := []struct {
+		name, container, dest, wantErr string
+	}{
+		{name: "relative destination", container: "web", dest: "conf/default.conf", wantErr: "must be an absolute path inside the container"},
+		{name: "missing container", container: "db", dest: "/etc/app.conf", wantErr: "container db does not exist"},
+		{name: "missing destination", container: "web", wantErr: "missing container name, source_path or dest_path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CopyToContainer(context.Background(), cli, tt.container, src, tt.dest)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CopyToContainer() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// cpuSample returns a stats sample with the given container and system CPU times, before
+// and after.
+func cpuSample(preTotal, total, preSystem, system uint64, online uint32, percpu int) container.StatsResponse {
+	var stats container.StatsResponse
+	stats.PreCPUStats.CPUUsage.TotalUsage = preTotal
+	stats.PreCPUStats.SystemUsage = preSystem
+	stats.CPUStats.CPUUsage.TotalUsage = total
+	stats.CPUStats.SystemUsage = system
+	stats.CPUStats.OnlineCPUs = online
+	stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, percpu)
+	return stats
+}
+
+func TestCPUPercent(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats container.StatsResponse
+		want  float64
+	}{
+		{name: "one of two CPUs busy", stats: cpuSample(100, 200, 1000, 1200, 2, 0), want: 100},
+		{name: "saturating two CPUs", stats: cpuSample(0, 200, 0, 200, 2, 0), want: 200},
+		{name: "zero CPU delta", stats: cpuSample(100, 100, 1000, 2000, 4, 0), want: 0},
+		{name: "zero system delta", stats: cpuSample(100, 200, 1000, 1000, 4, 0), want: 0},
+		{name: "counter went backwards", stats: cpuSample(200, 100, 1000, 2000, 4, 0), want: 0},
+		{name: "zero online CPUs falls back to per-CPU usage", stats: cpuSample(0, 100, 0, 400, 0, 4), want: 100},
+		{name: "zero online CPUs and no per-CPU usage counts one", stats: cpuSample(0, 100, 0, 400, 0, 0), want: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CPUPercent(tt.stats); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("CPUPercent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory container.MemoryStats
+		want   ContainerUsage
+	}{
+		{
+			name:   "cgroup v2 page cache excluded",
+			memory: container.MemoryStats{Usage: 300, Limit: 1000, Stats: map[string]uint64{"inactive_file": 100}},
+			want:   ContainerUsage{MemUsageBytes: 200, MemLimitBytes: 1000, MemPercent: 20},
+		},
+		{
+			name:   "cgroup v1 page cache excluded",
+			memory: container.MemoryStats{Usage: 300, Limit: 600, Stats: map[string]uint64{"total_inactive_file": 150}},
+			want:   ContainerUsage{MemUsageBytes: 150, MemLimitBytes: 600, MemPercent: 25},
+		},
+		{
+			name:   "cache larger than usage is ignored",
+			memory: container.MemoryStats{Usage: 100, Limit: 1000, Stats: map[string]uint64{"inactive_file": 500}},
+			want:   ContainerUsage{MemUsageBytes: 100, MemLimitBytes: 1000, MemPercent: 10},
+		},
+		{
+			name:   "no limit",
+			memory: container.MemoryStats{Usage: 100},
+			want:   ContainerUsage{MemUsageBytes: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats container.StatsResponse
+			stats.MemoryStats = tt.memory
+			stats.Networks = map[string]container.NetworkStats{
+				"eth0": {RxBytes: 10, TxBytes: 20},
+				"eth1": {RxBytes: 1, TxBytes: 2},
+			}
+			tt.want.NetRxBytes, tt.want.NetTxBytes = 11, 22
+			if got := Usage(stats); got != tt.want {
+				t.Errorf("Usage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerStats(t *testing.T) {
+	var stream string
+	cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/web/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No such container"}`))
+			return
+		}
+		stream = r.URL.Query().Get("stream")
+		w.Write([]byte(`{"memory_stats": {"usage": 512, "limit": 1024}, "cpu_stats": {"cpu_usage": {"total_usage": 50}, "system_cpu_usage": 100, "online_cpus": 1}, "precpu_stats": {"cpu_usage": {"total_usage": 0}, "system_cpu_usage": 0}}`))
+	})
+	got, err := ContainerStats(context.Background(), cli, "web")
+	if err != nil {
+		t.Fatalf("ContainerStats() error = %v", err)
+	}
+	if stream != "0" {
+		t.Errorf("stream = %q, want a one-shot sample", stream)
+	}
+	want := ContainerUsage{CPUPercent: 50, MemUsageBytes: 512, MemLimitBytes: 1024, MemPercent: 50}
+	if got != want {
+		t.Errorf("ContainerStats() = %+v, want %+v", got, want)
+	}
+	if _, err := ContainerStats(context.Background(), cli, ""); err == nil {
+		t.Error("ContainerStats() without a name succeeded")
+	}
+}
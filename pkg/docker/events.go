@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Event is a Docker event reduced to what operators need to follow a project.
+type Event struct {
+	Type      string    `json:"type"`
+	Action    string    `json:"action"`
+	ActorID   string    `json:"actor_id"`
+	ActorName string    `json:"actor_name,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// NormalizeEvent converts a daemon event message to an Event.
+func NormalizeEvent(m events.Message) Event {
+	at := time.Unix(0, m.TimeNano)
+	if m.TimeNano == 0 {
+		at = time.Unix(m.Time, 0)
+	}
+	return Event{
+		Type:      string(m.Type),
+		Action:    string(m.Action),
+		ActorID:   m.Actor.ID,
+		ActorName: m.Actor.Attributes["name"],
+		Time:      at.UTC(),
+	}
+}
+
+// StreamEvents follows the daemon's event stream, calling fn for every event about a
+// resource labelled with project (every event when project is empty), until ctx is
+// cancelled, fn returns an error or the stream fails. Cancellation returns ctx's error;
+// an error from fn is returned as is.
+func StreamEvents(ctx context.Context, cli *client.Client, project string, fn func(Event) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	f := filters.NewArgs()
+	if project != "" {
+		f.Add("label", fmt.Sprintf("%s=%s", ProjectLabel, project))
+	}
+	msgs, errs := cli.Events(ctx, events.ListOptions{Filters: f})
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				return errors.New("docker event stream ended")
+			}
+			return fmt.Errorf("docker event stream failed: %w", err)
+		case m := <-msgs:
+			if err := fn(NormalizeEvent(m)); err != nil {
+				return err
+			}
+		}
+	}
+}
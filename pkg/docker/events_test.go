@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestNormalizeEvent(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	tests := []struct {
+		name string
+		msg  events.Message
+		want Event
+	}{
+		{
+			name: "container event",
+			msg: events.Message{
+				Type:     events.ContainerEventType,
+				Action:   events.ActionDie,
+				Actor:    events.Actor{ID: "c1", Attributes: map[string]string{"name": "shop-web", "exitCode": "1"}},
+				Time:     at.Unix(),
+				TimeNano: at.UnixNano(),
+			},
+			want: Event{Type: "container", Action: "die", ActorID: "c1", ActorName: "shop-web", Time: at},
+		},
+		{
+			name: "seconds only",
+			msg:  events.Message{Type: events.NetworkEventType, Action: events.ActionConnect, Actor: events.Actor{ID: "n1"}, Time: at.Unix()},
+			want: Event{Type: "network", Action: "connect", ActorID: "n1", Time: at.Truncate(time.Second)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeEvent(tt.msg); got != tt.want {
+				t.Errorf("NormalizeEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package images
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// NormalizeImageRef parses an image reference and returns it in its canonical familiar form
+// (e.g. "mysql" becomes "mysql:latest"), so malformed references are rejected before they
+// reach the Docker API. References pinned by digest are kept as-is.
+func NormalizeImageRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("missing image reference")
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		if ref != strings.ToLower(ref) {
+			return "", fmt.Errorf("invalid image reference %q: repository names must be lowercase", ref)
+		}
+		return "", fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	return reference.FamiliarString(reference.TagNameOnly(named)), nil
+}
@@ -0,0 +1,45 @@
+package images
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeImageRef(t *testing.T) {
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "mysql", want: "mysql:latest"},
+		{ref: " nginx:1.27 ", want: "nginx:1.27"},
+		{ref: "docker.io/library/redis", want: "redis:latest"},
+		{ref: "docker.io/bitnami/redis:7", want: "bitnami/redis:7"},
+		{ref: "ghcr.io/org/app", want: "ghcr.io/org/app:latest"},
+		{ref: "localhost:5000/app:dev", want: "localhost:5000/app:dev"},
+		{ref: "alpine@" + digest, want: "alpine@" + digest},
+		{ref: "", wantErr: "missing image reference"},
+		{ref: "   ", wantErr: "missing image reference"},
+		{ref: "MySQL", wantErr: "repository names must be lowercase"},
+		{ref: "nginx:", wantErr: `invalid image reference "nginx:"`},
+		{ref: "nginx::1", wantErr: `invalid image reference "nginx::1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := NormalizeImageRef(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NormalizeImageRef(%q) = %q, %v; want an error containing %q", tt.ref, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeImageRef(%q) error = %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeImageRef(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// pullReportInterval is the minimum time between progress lines while layers are only
+// downloading or extracting, so a large pull does not flood the caller.
+const pullReportInterval = time.Second
+
+// pullMessage is one entry of the JSON stream returned by the image pull API.
+type pullMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// layerProgress is the last reported state of one image layer.
+type layerProgress struct {
+	status     string
+	downloaded int64
+	size       int64
+}
+
+// layerDone reports whether a layer status means the layer needs no more work.
+func layerDone(status string) bool {
+	return status == "Pull complete" || status == "Already exists"
+}
+
+// ReadPullOutput consumes a Docker image pull JSON stream, passing readable progress lines to
+// onLine (when non-nil). Progress is aggregated across layers: every line says how many
+// layers are done and how much has been downloaded, and lines are sent whenever a layer
+// changes state and at most once per pullReportInterval in between. Pull failures are
+// reported inside the stream rather than as an API error; they are returned as an error.
+func ReadPullOutput(r io.Reader, onLine func(string)) error {
+	layers := map[string]*layerProgress{}
+	var lastReport time.Time
+	report := func(line string) {
+		lastReport = time.Now()
+		if onLine != nil {
+			onLine(line)
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+			return errors.New(msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if msg.Status == "" {
+			continue
+		}
+		// Messages without a layer ID ("Digest: ...", "Status: Downloaded newer image ...")
+		// and the "Pulling from" header, whose ID is the tag, are passed on as they are.
+		if msg.ID == "" || !isLayerStatus(msg.Status) {
+			report(statusLine(msg))
+			continue
+		}
+		layer := layers[msg.ID]
+		if layer == nil {
+			layer = &layerProgress{}
+			layers[msg.ID] = layer
+		}
+		changed := layer.status != msg.Status
+		layer.status = msg.Status
+		if msg.Status == "Downloading" {
+			layer.downloaded = msg.ProgressDetail.Current
+			layer.size = msg.ProgressDetail.Total
+		} else if msg.Status == "Download complete" && layer.size > 0 {
+			layer.downloaded = layer.size
+		}
+		if changed || time.Since(lastReport) >= pullReportInterval {
+			report(fmt.Sprintf("%s: %s (%s)", msg.ID, msg.Status, summarizeLayers(layers)))
+		}
+	}
+}
+
+// isLayerStatus reports whether status is one Docker sends for individual layers.
+func isLayerStatus(status string) bool {
+	switch status {
+	case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum", "Download complete",
+		"Extracting", "Pull complete", "Already exists":
+		return true
+	}
+	return false
+}
+
+// statusLine renders a message that is not about a layer.
+func statusLine(msg pullMessage) string {
+	if msg.ID == "" {
+		return msg.Status
+	}
+	return msg.ID + ": " + msg.Status
+}
+
+// summarizeLayers describes the overall progress of a pull, e.g.
+// "2/5 layers done, 12.3MB of 40.1MB downloaded".
+func summarizeLayers(layers map[string]*layerProgress) string {
+	var done int
+	var downloaded, size int64
+	for _, l := range layers {
+		if layerDone(l.status) {
+			done++
+		}
+		downloaded += l.downloaded
+		size += l.size
+	}
+	summary := fmt.Sprintf("%d/%d layers done", done, len(layers))
+	if size > 0 {
+		summary += fmt.Sprintf(", %s of %s downloaded", units.HumanSize(float64(downloaded)), units.HumanSize(float64(size)))
+	}
+	return summary
+}
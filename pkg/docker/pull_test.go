@@ -0,0 +1,64 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadPullOutput(t *testing.T) {
+	stream := `{"status": "Pulling from library/redis", "id": "latest"}
+{"status": "Pulling fs layer", "id": "a"}
+{"status": "Pulling fs layer", "id": "b"}
+{"status": "Downloading", "id": "a", "progressDetail": {"current": 500, "total": 1000}}
+{"status": "Downloading", "id": "a", "progressDetail": {"current": 600, "total": 1000}}
+{"status": "Download complete", "id": "a"}
+{"status": "Pull complete", "id": "a"}
+{"status": "Already exists", "id": "b"}
+{"status": "Digest: sha256:abc"}
+{"status": "Status: Downloaded newer image for redis:latest"}
+`
+	var lines []string
+	if err := ReadPullOutput(strings.NewReader(stream), func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("ReadPullOutput() error = %v", err)
+	}
+	want := []string{
+		"latest: Pulling from library/redis",
+		"a: Pulling fs layer (0/1 layers done)",
+		"b: Pulling fs layer (0/2 layers done)",
+		"a: Downloading (0/2 layers done, 500B of 1kB downloaded)",
+		"a: Download complete (0/2 layers done, 1kB of 1kB downloaded)",
+		"a: Pull complete (1/2 layers done, 1kB of 1kB downloaded)",
+		"b: Already exists (2/2 layers done, 1kB of 1kB downloaded)",
+		"Digest: sha256:abc",
+		"Status: Downloaded newer image for redis:latest",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("progress lines:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReadPullOutputErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		wantErr string
+	}{
+		{
+			name: "error detail",
+			stream: `{"status": "Pulling fs layer", "id": "a"}
+{"errorDetail": {"message": "unauthorized: authentication required"}, "error": "unauthorized"}
+`,
+			wantErr: "unauthorized: authentication required",
+		},
+		{name: "error only", stream: `{"error": "manifest unknown"}` + "\n", wantErr: "manifest unknown"},
+		{name: "truncated stream", stream: `{"status": "Downloading", "id": "a"`, wantErr: "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadPullOutput(strings.NewReader(tt.stream), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadPullOutput() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// DefaultRetryAttempts is how many times Retry runs an operation when no other limit is
+// configured.
+const DefaultRetryAttempts = 3
+
+// retryBaseDelay is the wait before Retry's second attempt; it doubles for each further one.
+const retryBaseDelay = 250 * time.Millisecond
+
+// IsTransient reports whether err is a failure that may go away on its own, such as the
+// daemon being briefly unreachable or busy, as opposed to one that will recur until the
+// request changes: a missing or conflicting resource, an invalid parameter, a refused
+// credential, a rate limit or an expired context.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrRateLimited),
+		errdefs.IsNotFound(err),
+		errdefs.IsConflict(err),
+		errdefs.IsInvalidParameter(err),
+		errdefs.IsUnauthorized(err),
+		errdefs.IsForbidden(err),
+		errdefs.IsNotImplemented(err):
+		return false
+	}
+	return client.IsErrConnectionFailed(err) || errdefs.IsUnavailable(err) || errdefs.IsSystem(err) || errdefs.IsUnknown(err)
+}
+
+// Retry runs op up to attempts times (at least once), waiting retryBaseDelay, then twice as
+// long each time, between attempts. Only transient errors are retried; any other error, or
+// the last attempt's, is returned at once, with the attempt count added when retries were
+// spent. Cancelling ctx stops the waiting.
+func Retry(ctx context.Context, attempts int, op func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		if attempt >= attempts {
+			if attempt > 1 {
+				return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
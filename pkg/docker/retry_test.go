@@ -0,0 +1,260 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unavailable", errdefs.Unavailable(errors.New("daemon busy")), true},
+		{"system", errdefs.System(errors.New("internal error")), true},
+		{"unknown", errdefs.Unknown(errors.New("unexpected EOF")), true},
+		{"connection failed", client.ErrorConnectionFailed("tcp://127.0.0.1:2375"), true},
+		{"wrapped unavailable", fmt.Errorf("start: %w", errdefs.Unavailable(errors.New("busy"))), true},
+		{"not found", errdefs.NotFound(errors.New("no such container")), false},
+		{"conflict", errdefs.Conflict(errors.New("name in use")), false},
+		{"invalid parameter", errdefs.InvalidParameter(errors.New("bad port")), false},
+		{"unauthorized", errdefs.Unauthorized(errors.New("login required")), false},
+		{"forbidden", errdefs.Forbidden(errors.New("denied")), false},
+		{"not implemented", errdefs.NotImplemented(errors.New("swarm only")), false},
+		{"rate limited", fmt.Errorf("%w while pulling nginx", ErrRateLimited), false},
+		{"cancelled", context.Canceled, false},
+		{"deadline", fmt.Errorf("pull: %w", context.DeadlineExceeded), false},
+		{"plain error", errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	transient := errdefs.Unavailable(errors.New("daemon busy"))
+	permanent := errdefs.NotFound(errors.New("no such container"))
+	tests := []struct {
+		name      string
+		attempts  int
+		errs      []error
+		wantCalls int
+		wantErr   error
+		wantMsg   string
+	}{
+		{name: "success", attempts: 3, errs: []error{nil}, wantCalls: 1},
+		{name: "transient then success", attempts: 3, errs: []error{transient, nil}, wantCalls: 2},
+		{name: "permanent is not retried", attempts: 3, errs: []error{permanent}, wantCalls: 1, wantErr: permanent},
+		{name: "attempts exhausted", attempts: 2, errs: []error{transient, transient, nil}, wantCalls: 2, wantErr: transient, wantMsg: "failed after 2 attempts"},
+		{name: "single attempt", attempts: 1, errs: []error{transient, nil}, wantCalls: 1, wantErr: transient},
+		{name: "zero attempts still runs once", attempts: 0, errs: []error{nil}, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), tt.attempts, func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("op ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Retry() error = %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Retry() error = %q, want it to contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWaitingWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := Retry(ctx, 5, func() error {
+		calls++
+		cancel()
+		return errdefs.Unavailable(errors.New("daemon busy"))
+	})
+	if calls != 1 || !errdefs.IsUnavailable(err) {
+		t.Errorf("Retry() = %v after %d calls, want the first error after 1 call", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed >= retryBaseDelay {
+		t.Errorf("Retry() waited %v after cancellation", elapsed)
+	}
+}
+
+// fakeDaemon serves the Docker API paths in routes, keyed by method and path without the
+// version prefix, and counts the requests made to each.
+type fakeDaemon struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	routes map[string]func(w http.ResponseWriter, call int)
+}
+
+func newFakeDaemon(t *testing.T, routes map[string]func(w http.ResponseWriter, call int)) (*fakeDaemon, *client.Client) {
+	t.Helper()
+	d := &fakeDaemon{calls: map[string]int{}, routes: routes}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return d, cli
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/v1.47")
+	d.mu.Lock()
+	d.calls[key]++
+	call := d.calls[key]
+	d.mu.Unlock()
+	route, ok := d.routes[key]
+	if !ok {
+		daemonError(w, http.StatusNotFound, "no route for "+key)
+		return
+	}
+	route(w, call)
+}
+
+func (d *fakeDaemon) count(key string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[key]
+}
+
+func daemonError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// failFirst answers the first n calls with status and later ones with ok.
+func failFirst(n, status int, ok func(w http.ResponseWriter)) func(http.ResponseWriter, int) {
+	return func(w http.ResponseWriter, call int) {
+		if call <= n {
+			daemonError(w, status, "try again")
+			return
+		}
+		ok(w)
+	}
+}
+
+func runningContainer(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"Id": "abc", "Name": "/web", "State": {"Status": "running", "Running": true}}`)
+}
+
+func TestRunContainerRetriesOnlyTheStart(t *testing.T) {
+	const (
+		start   = "POST /containers/web/start"
+		inspect = "GET /containers/web/json"
+	)
+	noContent := func(w http.ResponseWriter) { w.WriteHeader(http.StatusNoContent) }
+	tests := []struct {
+		name         string
+		routes       map[string]func(http.ResponseWriter, int)
+		wantStarts   int
+		wantInspects int
+		wantErr      bool
+	}{
+		{
+			name:         "transient start failure is retried",
+			routes:       map[string]func(http.ResponseWriter, int){start: failFirst(1, http.StatusServiceUnavailable, noContent), inspect: failFirst(0, 0, runningContainer)},
+			wantStarts:   2,
+			wantInspects: 1,
+		},
+		{
+			name:       "permanent start failure is not retried",
+			routes:     map[string]func(http.ResponseWriter, int){start: failFirst(5, http.StatusNotFound, noContent), inspect: failFirst(0, 0, runningContainer)},
+			wantStarts: 1,
+			wantErr:    true,
+		},
+		{
+			name:         "readiness failure does not restart the container",
+			routes:       map[string]func(http.ResponseWriter, int){start: failFirst(0, 0, noContent), inspect: failFirst(5, http.StatusInternalServerError, runningContainer)},
+			wantStarts:   1,
+			wantInspects: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, cli := newFakeDaemon(t, tt.routes)
+			state, err := RunContainer(context.Background(), cli, "web", DefaultStartAttempts, 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunContainer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !state.Running {
+				t.Errorf("RunContainer() state = %+v, want running", state)
+			}
+			if got := d.count(start); got != tt.wantStarts {
+				t.Errorf("container started %d times, want %d", got, tt.wantStarts)
+			}
+			if got := d.count(inspect); got != tt.wantInspects {
+				t.Errorf("container inspected %d times, want %d", got, tt.wantInspects)
+			}
+		})
+	}
+}
+
+func TestPullImageRetriesTransientFailures(t *testing.T) {
+	const create = "POST /images/create"
+	pulled := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status": "Pulling from library/nginx"}`+"\n"+`{"status": "Status: Downloaded newer image for nginx:latest"}`+"\n")
+	}
+	tests := []struct {
+		name      string
+		route     func(http.ResponseWriter, int)
+		attempts  int
+		wantPulls int
+		wantErr   bool
+	}{
+		{name: "success", route: failFirst(0, 0, pulled), attempts: 3, wantPulls: 1},
+		{name: "transient failures are retried", route: failFirst(2, http.StatusServiceUnavailable, pulled), attempts: 3, wantPulls: 3},
+		{name: "attempts are bounded", route: failFirst(5, http.StatusServiceUnavailable, pulled), attempts: 2, wantPulls: 2, wantErr: true},
+		{name: "missing image is not retried", route: failFirst(5, http.StatusNotFound, pulled), attempts: 3, wantPulls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, cli := newFakeDaemon(t, map[string]func(http.ResponseWriter, int){create: tt.route})
+			err := PullImage(context.Background(), cli, "nginx:latest", time.Minute, tt.attempts, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PullImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := d.count(create); got != tt.wantPulls {
+				t.Errorf("image pulled %d times, want %d", got, tt.wantPulls)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// RequireSwarmManager returns an error unless the daemon is an active Swarm manager, the
+// only kind of node that can create services.
+func RequireSwarmManager(ctx context.Context, cli *client.Client) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query Docker for Swarm status: %w", err)
+	}
+	if info.Swarm.LocalNodeState != swarm.LocalNodeStateActive {
+		return fmt.Errorf("swarm mode is enabled but the Docker host is not part of a swarm (state %q); run `docker swarm init` or disable swarm in the configuration", info.Swarm.LocalNodeState)
+	}
+	if !info.Swarm.ControlAvailable {
+		return fmt.Errorf("swarm mode is enabled but the Docker host is a worker node; point the server at a swarm manager")
+	}
+	return nil
+}
+
+// ServiceSpec translates the settings of a container into the equivalent Swarm service:
+// image, entrypoint, command, working directory, user, environment, labels, published ports,
+// restart policy and resource limits, run as replicas tasks attached to networks.
+func ServiceSpec(name string, config *container.Config, hostConfig *container.HostConfig, replicas uint64, networks []string) swarm.ServiceSpec {
+	spec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{Name: name, Labels: config.Labels},
+		TaskTemplate: swarm.TaskSpec{
+			// A service's Command replaces the image's entrypoint and Args its command.
+			ContainerSpec: &swarm.ContainerSpec{
+				Image:   config.Image,
+				Command: config.Entrypoint,
+				Args:    config.Cmd,
+				Dir:     config.WorkingDir,
+				User:    config.User,
+				Env:     config.Env,
+				Labels:  config.Labels,
+			},
+		},
+		Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+	}
+	for _, n := range networks {
+		spec.TaskTemplate.Networks = append(spec.TaskTemplate.Networks, swarm.NetworkAttachmentConfig{Target: n})
+	}
+	if hostConfig == nil {
+		return spec
+	}
+	if hostConfig.Memory > 0 || hostConfig.NanoCPUs > 0 {
+		spec.TaskTemplate.Resources = &swarm.ResourceRequirements{
+			Limits: &swarm.Limit{NanoCPUs: hostConfig.NanoCPUs, MemoryBytes: hostConfig.Memory},
+		}
+	}
+	if policy := serviceRestartPolicy(hostConfig.RestartPolicy); policy != nil {
+		spec.TaskTemplate.RestartPolicy = policy
+	}
+	var ports []swarm.PortConfig
+	for port, bindings := range hostConfig.PortBindings {
+		for _, b := range bindings {
+			// An empty or unparsable host port lets Swarm pick one.
+			published, _ := strconv.Atoi(b.HostPort)
+			ports = append(ports, swarm.PortConfig{
+				Protocol:      swarm.PortConfigProtocol(port.Proto()),
+				TargetPort:    uint32(port.Int()),
+				PublishedPort: uint32(published),
+				PublishMode:   swarm.PortConfigPublishModeIngress,
+			})
+		}
+	}
+	if len(ports) > 0 {
+		spec.EndpointSpec = &swarm.EndpointSpec{Ports: ports}
+	}
+	return spec
+}
+
+func serviceRestartPolicy(p container.RestartPolicy) *swarm.RestartPolicy {
+	switch p.Name {
+	case container.RestartPolicyAlways, container.RestartPolicyUnlessStopped:
+		return &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionAny}
+	case container.RestartPolicyOnFailure:
+		policy := &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionOnFailure}
+		if p.MaximumRetryCount > 0 {
+			attempts := uint64(p.MaximumRetryCount)
+			policy.MaxAttempts = &attempts
+		}
+		return policy
+	case container.RestartPolicyDisabled:
+		return &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionNone}
+	}
+	return nil
+}
+
+// CreateService creates a Swarm service from spec, returning its ID.
+func CreateService(ctx context.Context, cli *client.Client, spec swarm.ServiceSpec) (string, error) {
+	resp, err := cli.ServiceCreate(ctx, spec, types.ServiceCreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// FindService returns the service with the given name, or nil if there is none.
+func FindService(ctx context.Context, cli *client.Client, name string) (*swarm.Service, error) {
+	svc, _, err := cli.ServiceInspectWithRaw(ctx, name, types.ServiceInspectOptions{})
+	if errdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ServiceInspect also matches IDs; only an exact name match counts.
+	if svc.Spec.Name != name {
+		return nil, nil
+	}
+	return &svc, nil
+}
+
+// RemoveService removes the service with the given name or ID.
+func RemoveService(ctx context.Context, cli *client.Client, nameOrID string) error {
+	return cli.ServiceRemove(ctx, nameOrID)
+}
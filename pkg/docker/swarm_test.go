@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestServiceSpec(t *testing.T) {
+	three := uint64(3)
+	retries := uint64(4)
+	config := &container.Config{Image: "nginx:latest", Env: []string{"A=1"}, Labels: map[string]string{ProjectLabel: "shop"}}
+	hostConfig := &container.HostConfig{
+		RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 4},
+		Resources:     container.Resources{Memory: 256 << 20, NanoCPUs: 500000000},
+		PortBindings:  nat.PortMap{"80/tcp": {{HostPort: "8080"}}},
+	}
+	want := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{Name: "web", Labels: config.Labels},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "nginx:latest", Env: []string{"A=1"}, Labels: config.Labels},
+			Resources:     &swarm.ResourceRequirements{Limits: &swarm.Limit{NanoCPUs: 500000000, MemoryBytes: 256 << 20}},
+			RestartPolicy: &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionOnFailure, MaxAttempts: &retries},
+			Networks:      []swarm.NetworkAttachmentConfig{{Target: "shop-front"}, {Target: "shop-back"}},
+		},
+		Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &three}},
+		EndpointSpec: &swarm.EndpointSpec{Ports: []swarm.PortConfig{
+			{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+		}},
+	}
+	got := ServiceSpec("web", config, hostConfig, 3, []string{"shop-front", "shop-back"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceSpec() = %+v, want %+v", got, want)
+	}
+
+	bare := ServiceSpec("job", &container.Config{Image: "busybox"}, nil, 1, nil)
+	if bare.TaskTemplate.Resources != nil || bare.TaskTemplate.RestartPolicy != nil || bare.EndpointSpec != nil || *bare.Mode.Replicated.Replicas != 1 {
+		t.Errorf("ServiceSpec() without host config = %+v, want only the image and one replica", bare)
+	}
+}
+
+func TestServiceRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy container.RestartPolicyMode
+		want   swarm.RestartPolicyCondition
+	}{
+		{container.RestartPolicyAlways, swarm.RestartPolicyConditionAny},
+		{container.RestartPolicyUnlessStopped, swarm.RestartPolicyConditionAny},
+		{container.RestartPolicyOnFailure, swarm.RestartPolicyConditionOnFailure},
+		{container.RestartPolicyDisabled, swarm.RestartPolicyConditionNone},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := serviceRestartPolicy(container.RestartPolicy{Name: tt.policy})
+		switch {
+		case tt.want == "" && got != nil:
+			t.Errorf("serviceRestartPolicy(%q) = %+v, want none", tt.policy, got)
+		case tt.want != "" && (got == nil || got.Condition != tt.want || got.MaxAttempts != nil):
+			t.Errorf("serviceRestartPolicy(%q) = %+v, want condition %s", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestRequireSwarmManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		swarm   map[string]interface{}
+		wantErr string
+	}{
+		{name: "manager", swarm: map[string]interface{}{"LocalNodeState": "active", "ControlAvailable": true}},
+		{name: "not in a swarm", swarm: map[string]interface{}{"LocalNodeState": "inactive"}, wantErr: "run `docker swarm init`"},
+		{name: "worker", swarm: map[string]interface{}{"LocalNodeState": "active"}, wantErr: "is a worker node"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newFakeClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"Swarm": tt.swarm})
+			})
+			err := RequireSwarmManager(context.Background(), cli)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("RequireSwarmManager() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RequireSwarmManager() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
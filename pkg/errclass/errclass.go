@@ -0,0 +1,82 @@
+// Package errclass sorts errors from the Docker SDK and the server's own helpers into
+// classes with distinct JSON-RPC error codes, so clients can tell failures that need a
+// different response apart (retry later, fix the request, pick another name, ...).
+package errclass
+
+import (
+	"context"
+	"errors"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/policy"
+)
+
+// Kinds of error, reported to clients in the error's data as {"kind": ...}.
+const (
+	KindUnknown          = "unknown"
+	KindRateLimited      = "rate_limited"
+	KindNotFound         = "not_found"
+	KindConflict         = "conflict"
+	KindUnauthorized     = "unauthorized"
+	KindForbidden        = "forbidden"
+	KindInvalidParameter = "invalid_parameter"
+	KindUnavailable      = "unavailable"
+	KindTimeout          = "timeout"
+	KindCancelled        = "cancelled"
+	KindPolicy           = "policy"
+)
+
+// JSON-RPC error codes for each kind. -32000 remains the code for unclassified failures.
+const (
+	CodeUnknown          = -32000
+	CodePolicy           = -32001
+	CodeRateLimited      = -32002
+	CodeNotFound         = -32003
+	CodeConflict         = -32004
+	CodeUnauthorized     = -32005
+	CodeForbidden        = -32006
+	CodeInvalidParameter = -32007
+	CodeUnavailable      = -32008
+	CodeTimeout          = -32009
+	CodeCancelled        = -32010
+)
+
+// Class is the classification of an error.
+type Class struct {
+	Kind string
+	Code int
+	// Retryable reports whether the same request may succeed if retried later.
+	Retryable bool
+}
+
+// Classify returns the class of err. Errors that match no class are KindUnknown.
+func Classify(err error) Class {
+	switch {
+	case err == nil:
+		return Class{Kind: KindUnknown, Code: CodeUnknown}
+	case errors.As(err, new(*policy.Violation)):
+		return Class{Kind: KindPolicy, Code: CodePolicy}
+	case errors.Is(err, docker.ErrRateLimited):
+		return Class{Kind: KindRateLimited, Code: CodeRateLimited, Retryable: true}
+	case errors.Is(err, context.DeadlineExceeded), errdefs.IsDeadline(err):
+		return Class{Kind: KindTimeout, Code: CodeTimeout, Retryable: true}
+	case errors.Is(err, context.Canceled), errdefs.IsCancelled(err):
+		return Class{Kind: KindCancelled, Code: CodeCancelled, Retryable: true}
+	case client.IsErrConnectionFailed(err), errdefs.IsUnavailable(err):
+		return Class{Kind: KindUnavailable, Code: CodeUnavailable, Retryable: true}
+	case errdefs.IsNotFound(err):
+		return Class{Kind: KindNotFound, Code: CodeNotFound}
+	case errdefs.IsConflict(err):
+		return Class{Kind: KindConflict, Code: CodeConflict}
+	case errdefs.IsUnauthorized(err):
+		return Class{Kind: KindUnauthorized, Code: CodeUnauthorized}
+	case errdefs.IsForbidden(err):
+		return Class{Kind: KindForbidden, Code: CodeForbidden}
+	case errdefs.IsInvalidParameter(err):
+		return Class{Kind: KindInvalidParameter, Code: CodeInvalidParameter}
+	}
+	return Class{Kind: KindUnknown, Code: CodeUnknown}
+}
@@ -0,0 +1,45 @@
+package errclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/policy"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{name: "nil", err: nil, want: Class{Kind: KindUnknown, Code: CodeUnknown}},
+		{name: "plain error", err: errors.New("boom"), want: Class{Kind: KindUnknown, Code: CodeUnknown}},
+		{name: "policy violation", err: fmt.Errorf("action 0: %w", &policy.Violation{Rule: "denied_images", Message: "image redis is denied"}), want: Class{Kind: KindPolicy, Code: CodePolicy}},
+		{name: "rate limited", err: fmt.Errorf("pulling redis: %w", docker.ErrRateLimited), want: Class{Kind: KindRateLimited, Code: CodeRateLimited, Retryable: true}},
+		{name: "deadline", err: fmt.Errorf("waiting: %w", context.DeadlineExceeded), want: Class{Kind: KindTimeout, Code: CodeTimeout, Retryable: true}},
+		{name: "errdefs deadline", err: errdefs.Deadline(errors.New("slow")), want: Class{Kind: KindTimeout, Code: CodeTimeout, Retryable: true}},
+		{name: "cancelled", err: context.Canceled, want: Class{Kind: KindCancelled, Code: CodeCancelled, Retryable: true}},
+		{name: "errdefs cancelled", err: errdefs.Cancelled(errors.New("stopped")), want: Class{Kind: KindCancelled, Code: CodeCancelled, Retryable: true}},
+		{name: "unavailable", err: errdefs.Unavailable(errors.New("daemon down")), want: Class{Kind: KindUnavailable, Code: CodeUnavailable, Retryable: true}},
+		{name: "not found", err: errdefs.NotFound(errors.New("No such container: web")), want: Class{Kind: KindNotFound, Code: CodeNotFound}},
+		{name: "wrapped not found", err: fmt.Errorf("inspect: %w", errdefs.NotFound(errors.New("No such image"))), want: Class{Kind: KindNotFound, Code: CodeNotFound}},
+		{name: "conflict", err: errdefs.Conflict(errors.New("name already in use")), want: Class{Kind: KindConflict, Code: CodeConflict}},
+		{name: "unauthorized", err: errdefs.Unauthorized(errors.New("login required")), want: Class{Kind: KindUnauthorized, Code: CodeUnauthorized}},
+		{name: "forbidden", err: errdefs.Forbidden(errors.New("denied")), want: Class{Kind: KindForbidden, Code: CodeForbidden}},
+		{name: "invalid parameter", err: errdefs.InvalidParameter(errors.New("bad port")), want: Class{Kind: KindInvalidParameter, Code: CodeInvalidParameter}},
+		{name: "system error", err: errdefs.System(errors.New("disk full")), want: Class{Kind: KindUnknown, Code: CodeUnknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify(%v) = %+v, want %+v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -11,10 +11,13 @@ import (
 // LLMClient wraps the underlying OpenAI LLM.
 type LLMClient struct {
 	client *openai.LLM
+	// maxTokens caps the completion length of each plan request; zero leaves it to the model.
+	maxTokens int
 }
 
-// NewLLMClient creates a new LLMClient given an API key and model name.
-func NewLLMClient(apiKey, model string) (*LLMClient, error) {
+// NewLLMClient creates a new LLMClient given an API key and model name. maxTokens, when
+// positive, limits the tokens generated for each plan.
+func NewLLMClient(apiKey, model string, maxTokens int) (*LLMClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
 	}
@@ -22,10 +25,61 @@ func NewLLMClient(apiKey, model string) (*LLMClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &LLMClient{client: l}, nil
+	return &LLMClient{client: l, maxTokens: maxTokens}, nil
 }
 
 // GeneratePlan sends a prompt and returns the LLM response.
 func (l *LLMClient) GeneratePlan(ctx context.Context, prompt []llms.MessageContent, tools []llms.Tool) (*llms.ContentResponse, error) {
-	return l.client.GenerateContent(ctx, prompt, llms.WithTools(tools))
+	return l.client.GenerateContent(ctx, prompt, l.planOptions(tools)...)
+}
+
+// GeneratePlanStream is like GeneratePlan but passes each chunk of generated text to onChunk
+// as it arrives. Returning an error from onChunk aborts generation.
+func (l *LLMClient) GeneratePlanStream(ctx context.Context, prompt []llms.MessageContent, tools []llms.Tool, onChunk func(ctx context.Context, chunk []byte) error) (*llms.ContentResponse, error) {
+	return l.client.GenerateContent(ctx, prompt, append(l.planOptions(tools), llms.WithStreamingFunc(onChunk))...)
+}
+
+// planOptions returns the call options shared by plan requests.
+func (l *LLMClient) planOptions(tools []llms.Tool) []llms.CallOption {
+	opts := []llms.CallOption{llms.WithTools(tools)}
+	if l.maxTokens > 0 {
+		opts = append(opts, llms.WithMaxTokens(l.maxTokens))
+	}
+	return opts
+}
+
+// Ping checks that the model is reachable and the API key is accepted, with a request
+// limited to a single output token.
+func (l *LLMClient) Ping(ctx context.Context) error {
+	_, err := l.client.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "ping"),
+	}, llms.WithMaxTokens(1))
+	return err
+}
+
+// Usage returns the prompt and completion token counts the provider reported for a
+// response, or zeros when it reported none.
+func Usage(response *llms.ContentResponse) (prompt, completion int) {
+	if response == nil {
+		return 0, 0
+	}
+	// Every choice of a response comes from the same request; the provider reports the
+	// usage on the first.
+	if len(response.Choices) == 0 {
+		return 0, 0
+	}
+	info := response.Choices[0].GenerationInfo
+	return intInfo(info, "PromptTokens"), intInfo(info, "CompletionTokens")
+}
+
+func intInfo(info map[string]any, key string) int {
+	switch v := info[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
 }
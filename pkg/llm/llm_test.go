@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       *llms.ContentResponse
+		wantPrompt     int
+		wantCompletion int
+	}{
+		{name: "nil response", response: nil},
+		{name: "no choices", response: &llms.ContentResponse{}},
+		{name: "no usage reported", response: &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "[]"}}}},
+		{
+			name:           "int counts",
+			response:       &llms.ContentResponse{Choices: []*llms.ContentChoice{{GenerationInfo: map[string]any{"PromptTokens": 12, "CompletionTokens": 5}}}},
+			wantPrompt:     12,
+			wantCompletion: 5,
+		},
+		{
+			name:           "float counts",
+			response:       &llms.ContentResponse{Choices: []*llms.ContentChoice{{GenerationInfo: map[string]any{"PromptTokens": float64(7), "CompletionTokens": int64(3)}}}},
+			wantPrompt:     7,
+			wantCompletion: 3,
+		},
+		{
+			name: "only the first choice counts",
+			response: &llms.ContentResponse{Choices: []*llms.ContentChoice{
+				{GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 2}},
+				{GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 4}},
+			}},
+			wantPrompt:     10,
+			wantCompletion: 2,
+		},
+		{
+			name:     "unexpected type",
+			response: &llms.ContentResponse{Choices: []*llms.ContentChoice{{GenerationInfo: map[string]any{"PromptTokens": "12"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, completion := Usage(tt.response)
+			if prompt != tt.wantPrompt || completion != tt.wantCompletion {
+				t.Errorf("Usage() = %d, %d; want %d, %d", prompt, completion, tt.wantPrompt, tt.wantCompletion)
+			}
+		})
+	}
+}
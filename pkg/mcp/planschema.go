@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// planActionsSchema describes a bare plan: a non-empty array of actions, each naming the
+// action and carrying its parameters as an object.
+const planActionsSchema = `{
+	"type": "array",
+	"minItems": 1,
+	"items": {
+		"type": "object",
+		"required": ["action", "parameters"],
+		"properties": {
+			"action": {"type": "string", "minLength": 1},
+			"parameters": {"type": "object"},
+			"idempotency_key": {"type": "string"}
+		}
+	}
+}`
+
+// planDocumentSchema describes the PlanDocument envelope around the actions.
+const planDocumentSchema = `{
+	"type": "object",
+	"required": ["plan"],
+	"properties": {
+		"project": {"type": "string"},
+		"resume": {"type": "boolean"},
+		"profile": {"type": "string"},
+		"idempotency_key": {"type": "string"},
+		"on_busy": {"type": "string", "enum": ["wait", "fail"]},
+		"plan": {"$ref": "actions.json"}
+	}
+}`
+
+var planActions, planDocument = compilePlanSchemas()
+
+func compilePlanSchemas() (*jsonschema.Schema, *jsonschema.Schema) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	if err := c.AddResource("actions.json", strings.NewReader(planActionsSchema)); err != nil {
+		panic(err)
+	}
+	if err := c.AddResource("document.json", strings.NewReader(planDocumentSchema)); err != nil {
+		panic(err)
+	}
+	return c.MustCompile("actions.json"), c.MustCompile("document.json")
+}
+
+// PlanSchemaError reports where a plan departs from the plan schema. Pointer is the JSON
+// pointer of the offending value ("" for the whole plan, "/0/parameters" for the first
+// action's parameters).
+type PlanSchemaError struct {
+	Pointer string
+	Message string
+}
+
+func (e *PlanSchemaError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("plan is malformed at %s: %s", pointer, e.Message)
+}
+
+// ValidatePlan checks plan JSON against the plan schema before it is parsed: either a bare
+// array of actions or a PlanDocument whose "plan" is one. Structural problems are returned
+// as a *PlanSchemaError naming the offending element; data that is not JSON at all is
+// returned as the decoder's error.
+func ValidatePlan(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &v); err != nil {
+		return err
+	}
+	schema := planActions
+	switch doc := v.(type) {
+	case []interface{}:
+	case map[string]interface{}:
+		_, hasPlan := doc["plan"]
+		if _, hasAction := doc["action"]; hasAction && !hasPlan {
+			return &PlanSchemaError{Message: `expected an array of actions, got a single action object (wrap it in [...])`}
+		}
+		schema = planDocument
+	default:
+		return &PlanSchemaError{Message: fmt.Sprintf(`expected an array of actions or {"plan": [...]}, got %s`, jsonKind(v))}
+	}
+	err := schema.Validate(v)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	return schemaError(verr)
+}
+
+// schemaError picks the most specific cause of verr: the deepest instance location, which
+// is the element the plan author needs to fix.
+func schemaError(verr *jsonschema.ValidationError) *PlanSchemaError {
+	best := verr
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			if len(e.InstanceLocation) > len(best.InstanceLocation) || len(best.Causes) > 0 {
+				best = e
+			}
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(verr)
+	return &PlanSchemaError{Pointer: best.InstanceLocation, Message: best.Message}
+}
+
+// jsonKind names the JSON type of a decoded value for error messages.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	}
+	return fmt.Sprintf("%T", v)
+}
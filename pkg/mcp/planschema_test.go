@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidatePlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    string
+		pointer string
+		message string
+		// notSchema marks errors that are not *PlanSchemaError, such as invalid JSON.
+		notSchema bool
+	}{
+		{name: "bare plan", plan: `[{"action": "pull_image", "parameters": {"image": "nginx"}}]`},
+		{name: "plan document", plan: `{"project": "shop", "on_busy": "fail", "plan": [{"action": "pull_image", "parameters": {}, "idempotency_key": "k"}]}`},
+		{name: "surrounding whitespace", plan: "\n  [{\"action\": \"list_containers\", \"parameters\": {}}]\n"},
+		{name: "not JSON", plan: `[{"action": `, notSchema: true},
+		{name: "string", plan: `"pull nginx"`, pointer: "", message: `got a string`},
+		{name: "single action object", plan: `{"action": "pull_image", "parameters": {}}`, pointer: "", message: "wrap it in [...]"},
+		{name: "empty plan", plan: `[]`, pointer: "", message: "minimum 1 items"},
+		{name: "missing parameters", plan: `[{"action": "pull_image"}]`, pointer: "/0", message: "parameters"},
+		{name: "empty action", plan: `[{"action": "", "parameters": {}}]`, pointer: "/0/action", message: "length must be >= 1"},
+		{name: "parameters not an object", plan: `[{"action": "pull_image", "parameters": {}}, {"action": "pull_image", "parameters": ["nginx"]}]`, pointer: "/1/parameters", message: "expected object"},
+		{name: "document without plan", plan: `{"project": "shop"}`, pointer: "", message: "plan"},
+		{name: "invalid on_busy", plan: `{"on_busy": "queue", "plan": [{"action": "pull_image", "parameters": {}}]}`, pointer: "/on_busy", message: "wait"},
+		{name: "invalid action in document", plan: `{"plan": [{"action": 3, "parameters": {}}]}`, pointer: "/plan/0/action", message: "expected string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePlan([]byte(tt.plan))
+			var schemaErr *PlanSchemaError
+			switch {
+			case tt.notSchema:
+				if err == nil || errors.As(err, &schemaErr) {
+					t.Fatalf("ValidatePlan() error = %v, want a JSON syntax error", err)
+				}
+			case tt.message == "":
+				if err != nil {
+					t.Fatalf("ValidatePlan() error = %v, want none", err)
+				}
+			case !errors.As(err, &schemaErr):
+				t.Fatalf("ValidatePlan() error = %v, want a *PlanSchemaError", err)
+			case schemaErr.Pointer != tt.pointer || !strings.Contains(schemaErr.Message, tt.message):
+				t.Errorf("ValidatePlan() = %q at %q, want a message containing %q at %q", schemaErr.Message, schemaErr.Pointer, tt.message, tt.pointer)
+			}
+		})
+	}
+}
+
+func TestPlanSchemaErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  PlanSchemaError
+		want string
+	}{
+		{PlanSchemaError{Message: "expected array"}, "plan is malformed at /: expected array"},
+		{PlanSchemaError{Pointer: "/0/parameters", Message: "expected object"}, "plan is malformed at /0/parameters: expected object"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
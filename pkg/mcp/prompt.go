@@ -6,12 +6,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+
+	"santoshkal/mcp-godocker/pkg/docker"
 )
 
 // GetPromptResult represents the result containing one or more prompt messages.
@@ -31,61 +38,226 @@ type TextContent struct {
 	Text string `json:"text"`
 }
 
+// DefaultMaxResources is how many resources of each type the docker_compose prompt lists
+// before summarizing the rest, so a large project does not overflow the model's context.
+const DefaultMaxResources = 50
+
 // DockerComposePromptInput is the expected input when generating a docker_compose prompt.
 type DockerComposePromptInput struct {
 	Name       string `json:"name"`
 	Containers string `json:"containers"`
 }
 
-// GetPrompt generates a system prompt for the given prompt name ("docker_compose")
-// using a Docker client to list existing resources. The arguments map should contain
-// at least "name" (and optionally "containers").
+// PromptArgument describes one argument a prompt template accepts.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// PromptRenderer produces the text of a prompt from its arguments. Required arguments have
+// already been checked to be non-empty.
+type PromptRenderer func(ctx context.Context, cli *client.Client, arguments map[string]string) (string, error)
+
+// PromptTemplate is a prompt that can be requested by name through GetPrompt.
+type PromptTemplate struct {
+	Description string
+	Arguments   []PromptArgument
+	Render      PromptRenderer
+}
+
+// PromptInfo describes a registered prompt, as returned by ListPrompts.
+type PromptInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments"`
+}
+
+var (
+	promptsMu sync.RWMutex
+	prompts   = map[string]PromptTemplate{
+		"docker_compose": {
+			Description: "Manage a Docker project through a plan+apply loop, akin to Docker Compose",
+			Arguments: []PromptArgument{
+				{Name: "name", Description: "Project name, used to label and prefix resources", Required: true},
+				{Name: "containers", Description: "Plain-language description of the desired resources"},
+				{Name: "max_resources", Description: fmt.Sprintf("Most containers, volumes and networks listed of each type (default %d)", DefaultMaxResources)},
+				{Name: "verbose", Description: "Set to true to keep image IDs, full port bindings and network members when a listing is truncated"},
+			},
+			Render: renderDockerComposePrompt,
+		},
+	}
+)
+
+// RegisterPrompt adds a prompt template, replacing any template already registered under
+// name.
+func RegisterPrompt(name string, template PromptTemplate) {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	prompts[name] = template
+}
+
+// ListPrompts returns the registered prompts sorted by name.
+func ListPrompts() []PromptInfo {
+	promptsMu.RLock()
+	defer promptsMu.RUnlock()
+	infos := make([]PromptInfo, 0, len(prompts))
+	for name, t := range prompts {
+		infos = append(infos, PromptInfo{Name: name, Description: t.Description, Arguments: t.Arguments})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// GetPrompt renders the registered prompt called name, using a Docker client to list
+// existing resources. Every required argument of the template must be present in arguments.
+//
+// Resources are listed from whichever daemon cli talks to. To describe a remote host,
+// build the client with docker.NewClient, passing the host URL and, for a TLS-protected
+// daemon, the CA, certificate and key files.
 func GetPrompt(ctx context.Context, cli *client.Client, name string, arguments map[string]string) (GetPromptResult, error) {
-	if name != "docker_compose" {
+	promptsMu.RLock()
+	template, ok := prompts[name]
+	promptsMu.RUnlock()
+	if !ok {
 		return GetPromptResult{}, fmt.Errorf("unknown prompt name: %s", name)
 	}
+	for _, arg := range template.Arguments {
+		if arg.Required && arguments[arg.Name] == "" {
+			return GetPromptResult{}, fmt.Errorf("missing required argument '%s'", arg.Name)
+		}
+	}
+	text, err := template.Render(ctx, cli, arguments)
+	if err != nil {
+		return GetPromptResult{}, err
+	}
 
-	// Validate input arguments.
+	// Create a prompt message with role "user" and the generated text.
+	message := PromptMessage{
+		Role: "user",
+		Content: TextContent{
+			Type: "text",
+			Text: text,
+		},
+	}
+
+	return GetPromptResult{
+		Messages: []PromptMessage{message},
+	}, nil
+}
+
+// renderDockerComposePrompt renders the built-in docker_compose prompt, listing the
+// resources currently labelled with the project name.
+func renderDockerComposePrompt(ctx context.Context, cli *client.Client, arguments map[string]string) (string, error) {
 	input := DockerComposePromptInput{
 		Name:       arguments["name"],
 		Containers: arguments["containers"],
 	}
-	if input.Name == "" {
-		return GetPromptResult{}, fmt.Errorf("missing required argument 'name'")
+	maxResources := DefaultMaxResources
+	if v := strings.TrimSpace(arguments["max_resources"]); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("argument 'max_resources' must be a positive integer, got %q", v)
+		}
+		maxResources = n
+	}
+	verbose := false
+	if v := strings.TrimSpace(arguments["verbose"]); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", fmt.Errorf("argument 'verbose' must be true or false, got %q", v)
+		}
+		verbose = b
 	}
 
-	projectLabel := fmt.Sprintf("mcp-server-docker.project=%s", input.Name)
+	projectLabel := fmt.Sprintf("%s=%s", docker.ProjectLabel, input.Name)
 
 	// List containers with the given label.
 	containerFilter := filters.NewArgs()
 	containerFilter.Add("label", projectLabel)
 	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: containerFilter})
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error listing containers: %w", err)
+		return "", fmt.Errorf("error listing containers: %w", err)
 	}
 
+	// Sort every listing by name so identical project state always renders the same prompt.
+	sort.Slice(containers, func(i, j int) bool {
+		return firstName(containers[i].Names) < firstName(containers[j].Names)
+	})
+
+	// Only the first maxResources containers are inspected and listed. When the listing is
+	// cut short, the bulky fields are also dropped unless verbose is set.
+	moreContainers := 0
+	if len(containers) > maxResources {
+		moreContainers = len(containers) - maxResources
+		containers = containers[:maxResources]
+	}
+	compact := moreContainers > 0 && !verbose
+
 	// Build container info similar to the Python version.
 	containerInfos := make([]map[string]interface{}, 0, len(containers))
 	for _, c := range containers {
-		var containerName string
-		if len(c.Names) > 0 {
-			containerName = c.Names[0]
-		}
+		containerName := firstName(c.Names)
+		sort.Slice(c.Ports, func(i, j int) bool {
+			pi, pj := c.Ports[i], c.Ports[j]
+			if pi.PrivatePort != pj.PrivatePort {
+				return pi.PrivatePort < pj.PrivatePort
+			}
+			if pi.Type != pj.Type {
+				return pi.Type < pj.Type
+			}
+			if pi.IP != pj.IP {
+				return pi.IP < pj.IP
+			}
+			return pi.PublicPort < pj.PublicPort
+		})
 		imageInfo := map[string]interface{}{
 			"id":   c.ImageID,
 			"tags": []string{c.Image},
 		}
-		containerInfos = append(containerInfos, map[string]interface{}{
-			"name":   containerName,
-			"image":  imageInfo,
-			"status": c.Status,
-			"id":     c.ID,
-			"ports":  c.Ports,
-		})
+		// Inspect the container so the prompt shows which networks and volumes it depends on.
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return "", fmt.Errorf("error inspecting container %s: %w", containerName, err)
+		}
+		networkNames := []string{}
+		if inspect.NetworkSettings != nil {
+			for networkName := range inspect.NetworkSettings.Networks {
+				networkNames = append(networkNames, networkName)
+			}
+		}
+		sort.Strings(networkNames)
+		mounts := make([]map[string]interface{}, 0, len(inspect.Mounts))
+		for _, m := range inspect.Mounts {
+			source := m.Name
+			if source == "" {
+				source = m.Source
+			}
+			mounts = append(mounts, map[string]interface{}{
+				"type":     string(m.Type),
+				"source":   source,
+				"target":   m.Destination,
+				"readonly": !m.RW,
+			})
+		}
+		info := map[string]interface{}{
+			"name":     containerName,
+			"image":    imageInfo,
+			"status":   c.Status,
+			"id":       c.ID,
+			"ports":    c.Ports,
+			"networks": networkNames,
+			"mounts":   mounts,
+		}
+		if compact {
+			info["image"] = c.Image
+			info["ports"] = compactPorts(c.Ports)
+		}
+		containerInfos = append(containerInfos, info)
 	}
-	containerJSON, err := json.MarshalIndent(containerInfos, "", "  ")
+	containerJSON, err := listingJSON(containerInfos, moreContainers, "containers")
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error marshalling container info: %w", err)
+		return "", fmt.Errorf("error marshalling container info: %w", err)
 	}
 
 	// List volumes with the given label.
@@ -93,18 +265,28 @@ func GetPrompt(ctx context.Context, cli *client.Client, name string, arguments m
 	volumeFilter.Add("label", projectLabel)
 	volList, err := cli.VolumeList(ctx, volume.ListOptions{Filters: volumeFilter})
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error listing volumes: %w", err)
+		return "", fmt.Errorf("error listing volumes: %w", err)
 	}
-	volumeInfos := make([]map[string]interface{}, 0, len(volList.Volumes))
-	for _, v := range volList.Volumes {
-		volumeInfos = append(volumeInfos, map[string]interface{}{
-			"name": v.Name,
-			"id":   v.Name, // Using the volume name as its identifier.
-		})
+	sort.Slice(volList.Volumes, func(i, j int) bool {
+		return volList.Volumes[i].Name < volList.Volumes[j].Name
+	})
+	volumes := volList.Volumes
+	moreVolumes := 0
+	if len(volumes) > maxResources {
+		moreVolumes = len(volumes) - maxResources
+		volumes = volumes[:maxResources]
 	}
-	volumesJSON, err := json.MarshalIndent(volumeInfos, "", "  ")
+	volumeInfos := make([]map[string]interface{}, 0, len(volumes))
+	for _, v := range volumes {
+		info := map[string]interface{}{"name": v.Name}
+		if moreVolumes == 0 || verbose {
+			info["id"] = v.Name // Using the volume name as its identifier.
+		}
+		volumeInfos = append(volumeInfos, info)
+	}
+	volumesJSON, err := listingJSON(volumeInfos, moreVolumes, "volumes")
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error marshalling volume info: %w", err)
+		return "", fmt.Errorf("error marshalling volume info: %w", err)
 	}
 
 	// List networks with the given label.
@@ -112,13 +294,33 @@ func GetPrompt(ctx context.Context, cli *client.Client, name string, arguments m
 	networkFilter.Add("label", projectLabel)
 	networks, err := cli.NetworkList(ctx, network.ListOptions{Filters: networkFilter})
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error listing networks: %w", err)
+		return "", fmt.Errorf("error listing networks: %w", err)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Name < networks[j].Name
+	})
+	moreNetworks := 0
+	if len(networks) > maxResources {
+		moreNetworks = len(networks) - maxResources
+		networks = networks[:maxResources]
 	}
 	networkInfos := make([]map[string]interface{}, 0, len(networks))
 	for _, n := range networks {
+		if moreNetworks > 0 && !verbose {
+			networkInfos = append(networkInfos, map[string]interface{}{
+				"name":       n.Name,
+				"containers": len(n.Containers),
+			})
+			continue
+		}
 		containerList := []map[string]interface{}{}
 		// n.Containers is a map from container ID to network.EndpointResource.
+		containerIDs := make([]string, 0, len(n.Containers))
 		for containerID := range n.Containers {
+			containerIDs = append(containerIDs, containerID)
+		}
+		sort.Strings(containerIDs)
+		for _, containerID := range containerIDs {
 			containerList = append(containerList, map[string]interface{}{
 				"id": containerID,
 			})
@@ -129,9 +331,9 @@ func GetPrompt(ctx context.Context, cli *client.Client, name string, arguments m
 			"containers": containerList,
 		})
 	}
-	networksJSON, err := json.MarshalIndent(networkInfos, "", "  ")
+	networksJSON, err := listingJSON(networkInfos, moreNetworks, "networks")
 	if err != nil {
-		return GetPromptResult{}, fmt.Errorf("error marshalling network info: %w", err)
+		return "", fmt.Errorf("error marshalling network info: %w", err)
 	}
 
 	// Build the multi-line prompt text.
@@ -226,18 +428,45 @@ destruction should occur in the reverse dependency order, and be aborted if dest
 Plans should only create, update, or destroy resources in the project. Relatedly, 'recreate' should
 be used to indicate a destroy followed by a create; always prefer updating a resource when possible,
 only recreating it if required (e.g. for immutable resources like containers).
-`, projectLabel, input.Name, string(containerJSON), string(volumesJSON), string(networksJSON), input.Containers, input.Name)
+`, projectLabel, input.Name, containerJSON, volumesJSON, networksJSON, input.Containers, input.Name)
+	return text, nil
+}
 
-	// Create a prompt message with role "user" and the generated text.
-	message := PromptMessage{
-		Role: "user",
-		Content: TextContent{
-			Type: "text",
-			Text: text,
-		},
+// listingJSON renders the resources listed in a prompt, followed by "...and N more kind" when
+// more resources were left out.
+func listingJSON(infos []map[string]interface{}, more int, kind string) (string, error) {
+	b, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return "", err
 	}
+	if more > 0 {
+		return fmt.Sprintf("%s\n...and %d more %s", b, more, kind), nil
+	}
+	return string(b), nil
+}
 
-	return GetPromptResult{
-		Messages: []PromptMessage{message},
-	}, nil
+// compactPorts renders port bindings in the form docker run -p takes, e.g.
+// "0.0.0.0:8080:80/tcp", or "80/tcp" for a port that is not published.
+func compactPorts(ports []types.Port) []string {
+	out := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			out = append(out, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+			continue
+		}
+		binding := fmt.Sprintf("%d:%d/%s", p.PublicPort, p.PrivatePort, p.Type)
+		if p.IP != "" {
+			binding = p.IP + ":" + binding
+		}
+		out = append(out, binding)
+	}
+	return out
+}
+
+// firstName returns the primary name of a container, or "" if it has none.
+func firstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
 }
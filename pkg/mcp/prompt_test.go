@@ -0,0 +1,311 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// newFakeClient returns a Docker client talking to a fake daemon that serves canned JSON
+// bodies keyed by request path, without the API version prefix.
+func newFakeClient(t *testing.T, bodies map[string]string) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1.47")
+		body, ok := bodies[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "not found: " + path})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// projectDaemon describes a project with a web container attached to two networks and
+// mounting a named volume and a read-only bind.
+var projectDaemon = map[string]string{
+	"/containers/json": `[{"Id": "c1", "Names": ["/shop-web"], "Image": "nginx:latest", "ImageID": "sha256:1", "Status": "Up 1 minute"}]`,
+	"/containers/c1/json": `{
+		"Id": "c1",
+		"Name": "/shop-web",
+		"NetworkSettings": {"Networks": {"shop-front": {}, "shop-back": {}}},
+		"Mounts": [
+			{"Type": "volume", "Name": "shop-data", "Source": "/var/lib/docker/volumes/shop-data/_data", "Destination": "/data", "RW": true},
+			{"Type": "bind", "Source": "/srv/conf", "Destination": "/etc/nginx/conf.d", "RW": false}
+		]
+	}`,
+	"/volumes":  `{"Volumes": [{"Name": "shop-data"}]}`,
+	"/networks": `[{"Name": "shop-front", "Id": "n1"}, {"Name": "shop-back", "Id": "n2"}]`,
+}
+
+func TestGetPromptIncludesContainerDependencies(t *testing.T) {
+	cli := newFakeClient(t, projectDaemon)
+	result, err := GetPrompt(context.Background(), cli, "docker_compose", map[string]string{"name": "shop"})
+	if err != nil {
+		t.Fatalf("GetPrompt() error = %v", err)
+	}
+	if len(result.Messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(result.Messages))
+	}
+	text := result.Messages[0].Content.Text
+	start := strings.Index(text, "<BEGIN CONTAINERS>")
+	end := strings.Index(text, "<END CONTAINERS>")
+	if start < 0 || end < start {
+		t.Fatalf("prompt has no container section:\n%s", text)
+	}
+	var containers []struct {
+		Name     string   `json:"name"`
+		Networks []string `json:"networks"`
+		Mounts   []struct {
+			Type     string `json:"type"`
+			Source   string `json:"source"`
+			Target   string `json:"target"`
+			ReadOnly bool   `json:"readonly"`
+		} `json:"mounts"`
+	}
+	if err := json.Unmarshal([]byte(text[start+len("<BEGIN CONTAINERS>"):end]), &containers); err != nil {
+		t.Fatalf("decoding container section: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Name != "/shop-web" {
+		t.Fatalf("containers = %+v, want shop-web", containers)
+	}
+	c := containers[0]
+	if strings.Join(c.Networks, ",") != "shop-back,shop-front" {
+		t.Errorf("networks = %v, want both networks sorted", c.Networks)
+	}
+	if len(c.Mounts) != 2 {
+		t.Fatalf("mounts = %+v, want 2", c.Mounts)
+	}
+	if m := c.Mounts[0]; m.Type != "volume" || m.Source != "shop-data" || m.Target != "/data" || m.ReadOnly {
+		t.Errorf("volume mount = %+v, want the volume name as source", m)
+	}
+	if m := c.Mounts[1]; m.Type != "bind" || m.Source != "/srv/conf" || m.Target != "/etc/nginx/conf.d" || !m.ReadOnly {
+		t.Errorf("bind mount = %+v, want the host path as source, read-only", m)
+	}
+}
+
+func TestGetPromptArguments(t *testing.T) {
+	cli := newFakeClient(t, projectDaemon)
+	tests := []struct {
+		name    string
+		prompt  string
+		args    map[string]string
+		wantErr string
+	}{
+		{name: "unknown prompt", prompt: "kubernetes", args: map[string]string{"name": "shop"}, wantErr: "unknown prompt name: kubernetes"},
+		{name: "missing project name", prompt: "docker_compose", args: map[string]string{}, wantErr: "missing required argument 'name'"},
+		{name: "non-numeric max_resources", prompt: "docker_compose", args: map[string]string{"name": "shop", "max_resources": "lots"}, wantErr: "argument 'max_resources' must be a positive integer"},
+		{name: "zero max_resources", prompt: "docker_compose", args: map[string]string{"name": "shop", "max_resources": "0"}, wantErr: "argument 'max_resources' must be a positive integer"},
+		{name: "bad verbose", prompt: "docker_compose", args: map[string]string{"name": "shop", "verbose": "loud"}, wantErr: "argument 'verbose' must be true or false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetPrompt(context.Background(), cli, tt.prompt, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetPrompt() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetPromptIsDeterministic(t *testing.T) {
+	inspect := func(id, name string) string {
+		return `{"Id": "` + id + `", "Name": "` + name + `", "NetworkSettings": {"Networks": {}}, "Mounts": []}`
+	}
+	base := map[string]string{
+		"/containers/c1/json": inspect("c1", "/shop-web"),
+		"/containers/c2/json": inspect("c2", "/shop-db"),
+	}
+	forward := map[string]string{
+		"/containers/json": `[
+			{"Id": "c1", "Names": ["/shop-web"], "Image": "nginx", "Ports": [{"PrivatePort": 443, "Type": "tcp"}, {"PrivatePort": 80, "Type": "tcp"}]},
+			{"Id": "c2", "Names": ["/shop-db"], "Image": "mysql"}
+		]`,
+		"/volumes":  `{"Volumes": [{"Name": "shop-logs"}, {"Name": "shop-data"}]}`,
+		"/networks": `[{"Name": "shop-front", "Id": "n1", "Containers": {"c1": {}, "c2": {}}}, {"Name": "shop-back", "Id": "n2"}]`,
+	}
+	reversed := map[string]string{
+		"/containers/json": `[
+			{"Id": "c2", "Names": ["/shop-db"], "Image": "mysql"},
+			{"Id": "c1", "Names": ["/shop-web"], "Image": "nginx", "Ports": [{"PrivatePort": 80, "Type": "tcp"}, {"PrivatePort": 443, "Type": "tcp"}]}
+		]`,
+		"/volumes":  `{"Volumes": [{"Name": "shop-data"}, {"Name": "shop-logs"}]}`,
+		"/networks": `[{"Name": "shop-back", "Id": "n2"}, {"Name": "shop-front", "Id": "n1", "Containers": {"c2": {}, "c1": {}}}]`,
+	}
+	for path, body := range base {
+		forward[path] = body
+		reversed[path] = body
+	}
+
+	render := func(bodies map[string]string) string {
+		result, err := GetPrompt(context.Background(), newFakeClient(t, bodies), "docker_compose", map[string]string{"name": "shop"})
+		if err != nil {
+			t.Fatalf("GetPrompt() error = %v", err)
+		}
+		return result.Messages[0].Content.Text
+	}
+	first, second := render(forward), render(reversed)
+	if first != second {
+		t.Errorf("prompts differ when the daemon lists resources in a different order:\n%s\n---\n%s", first, second)
+	}
+	if db, web := strings.Index(first, "/shop-db"), strings.Index(first, "/shop-web"); db < 0 || web < db {
+		t.Errorf("containers not sorted by name:\n%s", first)
+	}
+}
+
+// largeProjectDaemon describes a project with n containers, volumes and networks. Only the
+// first inspected containers can be inspected, so inspecting more fails the prompt.
+func largeProjectDaemon(n, inspected int) map[string]string {
+	var containers, volumes, networks []string
+	bodies := map[string]string{}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("c%03d", i)
+		containers = append(containers, fmt.Sprintf(`{"Id": %q, "Names": ["/shop-%03d"], "Image": "nginx", "ImageID": "sha256:%03d", "Ports": [{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": %d, "Type": "tcp"}]}`, id, i, i, 8000+i))
+		volumes = append(volumes, fmt.Sprintf(`{"Name": "shop-vol-%03d"}`, i))
+		networks = append(networks, fmt.Sprintf(`{"Name": "shop-net-%03d", "Id": "n%03d", "Containers": {%q: {"Name": "shop-%03d"}}}`, i, i, id, i))
+		if i < inspected {
+			bodies["/containers/"+id+"/json"] = fmt.Sprintf(`{"Id": %q, "NetworkSettings": {"Networks": {}}, "Mounts": []}`, id)
+		}
+	}
+	bodies["/containers/json"] = "[" + strings.Join(containers, ",") + "]"
+	bodies["/volumes"] = `{"Volumes": [` + strings.Join(volumes, ",") + "]}"
+	bodies["/networks"] = "[" + strings.Join(networks, ",") + "]"
+	return bodies
+}
+
+func TestGetPromptTruncatesLargeProjects(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]string
+		inspected int
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "default limit",
+			args:      map[string]string{"name": "shop"},
+			inspected: DefaultMaxResources,
+			want:      []string{"/shop-049", "...and 50 more containers", "...and 50 more volumes", "...and 50 more networks", `"0.0.0.0:8049:80/tcp"`},
+			notWant:   []string{"/shop-050", "shop-vol-050", "sha256:", `"PublicPort"`},
+		},
+		{
+			name:      "custom limit",
+			args:      map[string]string{"name": "shop", "max_resources": "10"},
+			inspected: 10,
+			want:      []string{"/shop-009", "...and 90 more containers", "...and 90 more volumes", "...and 90 more networks"},
+			notWant:   []string{"/shop-010", "shop-net-010"},
+		},
+		{
+			name:      "verbose keeps details",
+			args:      map[string]string{"name": "shop", "max_resources": "10", "verbose": "true"},
+			inspected: 10,
+			want:      []string{"...and 90 more containers", "sha256:009", `"PublicPort": 8009`, `"id": "shop-vol-009"`},
+		},
+		{
+			name:      "limit above the project size",
+			args:      map[string]string{"name": "shop", "max_resources": "100"},
+			inspected: 100,
+			want:      []string{"/shop-099", "sha256:099"},
+			notWant:   []string{"more containers", "more volumes", "more networks"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newFakeClient(t, largeProjectDaemon(100, tt.inspected))
+			result, err := GetPrompt(context.Background(), cli, "docker_compose", tt.args)
+			if err != nil {
+				t.Fatalf("GetPrompt() error = %v", err)
+			}
+			text := result.Messages[0].Content.Text
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("prompt does not contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(text, notWant) {
+					t.Errorf("prompt contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+// registerTestPrompt registers a prompt for the duration of the test.
+func registerTestPrompt(t *testing.T, name string, template PromptTemplate) {
+	t.Helper()
+	RegisterPrompt(name, template)
+	t.Cleanup(func() {
+		promptsMu.Lock()
+		delete(prompts, name)
+		promptsMu.Unlock()
+	})
+}
+
+func TestRegisterPrompt(t *testing.T) {
+	registerTestPrompt(t, "debug_container", PromptTemplate{
+		Description: "Investigate a failing container",
+		Arguments: []PromptArgument{
+			{Name: "container", Required: true},
+			{Name: "symptom"},
+		},
+		Render: func(ctx context.Context, cli *client.Client, arguments map[string]string) (string, error) {
+			if arguments["symptom"] == "crash" {
+				return "", errors.New("cannot render crash reports")
+			}
+			return "Why is " + arguments["container"] + " failing? " + arguments["symptom"], nil
+		},
+	})
+	cli := newFakeClient(t, nil)
+
+	result, err := GetPrompt(context.Background(), cli, "debug_container", map[string]string{"container": "web", "symptom": "slow"})
+	if err != nil {
+		t.Fatalf("GetPrompt() error = %v", err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Role != "user" || result.Messages[0].Content.Text != "Why is web failing? slow" {
+		t.Errorf("GetPrompt() = %+v, want the rendered template as a user message", result)
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]string
+		wantErr string
+	}{
+		{name: "missing required argument", args: map[string]string{"symptom": "slow"}, wantErr: "missing required argument 'container'"},
+		{name: "empty required argument", args: map[string]string{"container": ""}, wantErr: "missing required argument 'container'"},
+		{name: "render error", args: map[string]string{"container": "web", "symptom": "crash"}, wantErr: "cannot render crash reports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetPrompt(context.Background(), cli, "debug_container", tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetPrompt() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	infos := ListPrompts()
+	if len(infos) != 2 || infos[0].Name != "debug_container" || infos[1].Name != "docker_compose" {
+		t.Fatalf("ListPrompts() = %+v, want both prompts sorted by name", infos)
+	}
+	if len(infos[0].Arguments) != 2 || !infos[0].Arguments[0].Required || infos[0].Arguments[1].Required {
+		t.Errorf("debug_container arguments = %+v", infos[0].Arguments)
+	}
+}
@@ -0,0 +1,40 @@
+package mcp
+
+import "santoshkal/mcp-godocker/pkg/state"
+
+// Result statuses.
+const (
+	StatusSuccess = "success"
+)
+
+// ResourceRef identifies a Docker resource a plan or tool call created.
+type ResourceRef = state.ResourceRef
+
+// PlanResult is the result of a successful ExecutePlan call.
+type PlanResult struct {
+	Status  string         `json:"status"`
+	Message string         `json:"message"`
+	Actions []ActionResult `json:"actions"`
+	// CreatedResources lists the resources the plan created, in the order it created them.
+	// Resources that already existed and were reused are not included.
+	CreatedResources []ResourceRef `json:"created_resources"`
+}
+
+// ActionResult reports one action of an executed plan.
+type ActionResult struct {
+	Action string                 `json:"action"`
+	Result map[string]interface{} `json:"result,omitempty"`
+	// Skipped is set for actions a resumed plan had already completed.
+	Skipped bool `json:"skipped,omitempty"`
+	// Cached is set for actions replayed from their idempotency key.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// ToolResult is the result of a successful CallTool call.
+type ToolResult struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message"`
+	Result  map[string]interface{} `json:"result"`
+	// CreatedResources lists the resource the tool created, if any.
+	CreatedResources []ResourceRef `json:"created_resources"`
+}
@@ -3,6 +3,9 @@
 package mcp
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 )
@@ -25,10 +28,12 @@ type RPCResponse struct {
 	ID      *int            `json:"id"`
 }
 
-// RPCError defines an error in JSON-RPC responses.
+// RPCError defines an error in JSON-RPC responses. Data, when present, carries
+// machine-readable details such as {"kind": "not_found"}.
 type RPCError struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"message"`
+	Code    int             `json:"code,omitempty"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
 // RPCErrorResponse is another form of error response.
@@ -51,8 +56,111 @@ func (e *RPCError) String() string {
 	return fmt.Sprintf("RPC Error [Code: %d]: %s", e.Code, e.Message)
 }
 
+// Error implements error, so clients can return an RPCError and callers can recover its
+// code with errors.As.
+func (e *RPCError) Error() string {
+	return e.String()
+}
+
 // ToolCallArgs represents arguments for directly calling a tool.
 type ToolCallArgs struct {
 	ToolName   string                 `json:"tool_name"`
 	Parameters map[string]interface{} `json:"parameters"`
 }
+
+// PlanDocument is the envelope form of a plan, carrying execution options alongside the
+// actions. A bare JSON array of actions is also accepted and treated as the Plan field.
+//
+// When IdempotencyKey is set, a successful result is recorded under it and the same key
+// submitted again replays that result instead of executing the plan; while the first
+// submission is still running, the key is refused with a retryable error. Individual
+// actions may carry their own "idempotency_key" with the same effect for that action.
+//
+// Project must match ^[a-zA-Z0-9][a-zA-Z0-9_.-]*$, as it names the project's state on
+// disk. Plans for the same project run one at a time. OnBusy says what happens when another plan
+// holds the project: "wait" (the default) waits for it within the plan timeout, "fail"
+// fails at once with a retryable error.
+type PlanDocument struct {
+	Project        string                   `json:"project,omitempty"`
+	Resume         bool                     `json:"resume,omitempty"`
+	Profile        string                   `json:"profile,omitempty"`
+	IdempotencyKey string                   `json:"idempotency_key,omitempty"`
+	OnBusy         string                   `json:"on_busy,omitempty"`
+	Plan           []map[string]interface{} `json:"plan"`
+}
+
+// ParsePlan decodes plan JSON given either as a bare array of actions or as a PlanDocument.
+func ParsePlan(data []byte) (PlanDocument, error) {
+	var doc PlanDocument
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &doc.Plan)
+		return doc, err
+	}
+	err := json.Unmarshal(trimmed, &doc)
+	return doc, err
+}
+
+// Hash identifies the plan by its project and actions, ignoring execution options, so that
+// re-submitting the same plan maps to the same checkpoint.
+func (d PlanDocument) Hash() string {
+	// encoding/json sorts map keys, so equal plans marshal identically.
+	data, _ := json.Marshal(struct {
+		Project string                   `json:"project"`
+		Plan    []map[string]interface{} `json:"plan"`
+	}{d.Project, d.Plan})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ProtocolVersion is the Model Context Protocol revision the server implements.
+const ProtocolVersion = "2024-11-05"
+
+// ToolInfo describes a tool in a tools/list response.
+type ToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// ListToolsResult is the result of tools/list.
+type ListToolsResult struct {
+	Tools []ToolInfo `json:"tools"`
+}
+
+// ListPromptsResult is the result of prompts/list and Server.ListPrompts.
+type ListPromptsResult struct {
+	Prompts []PromptInfo `json:"prompts"`
+}
+
+// CallToolParams are the parameters of tools/call.
+type CallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// CallToolResult is the result of tools/call. A tool that fails is reported with IsError
+// set rather than as a JSON-RPC error, so the model can see and react to the failure.
+type CallToolResult struct {
+	Content []TextContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// GetPromptParams are the parameters of prompts/get.
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// InitializeResult is the result of initialize.
+type InitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      ServerInfo             `json:"serverInfo"`
+}
+
+// ServerInfo identifies the server in an initialize response.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
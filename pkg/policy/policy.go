@@ -0,0 +1,226 @@
+// Package policy restricts what plans and tool calls may do, so operators can put guardrails
+// around what the model decides: which actions may run, which images may be used and which
+// host paths may be mounted.
+package policy
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Policy is the set of rules every action is checked against. The zero value allows
+// everything except privileged containers and bind mounts of SensitivePaths.
+type Policy struct {
+	// AllowedActions, when non-empty, lists the only action types that may run.
+	AllowedActions []string `json:"allowed_actions,omitempty" yaml:"allowed_actions,omitempty"`
+	// DeniedActions lists action types that may never run.
+	DeniedActions []string `json:"denied_actions,omitempty" yaml:"denied_actions,omitempty"`
+	// AllowedImages, when non-empty, lists glob patterns (as for path.Match, so "*" does not
+	// cross "/") of the only images that may be used. A pattern matches either the whole
+	// reference ("nginx:1.27") or its repository ("nginx", "ghcr.io/acme/*").
+	AllowedImages []string `json:"allowed_images,omitempty" yaml:"allowed_images,omitempty"`
+	// DeniedImages lists glob patterns of images that may never be used.
+	DeniedImages []string `json:"denied_images,omitempty" yaml:"denied_images,omitempty"`
+	// DeniedBinds lists glob patterns of host paths that may not be bind-mounted. A path is
+	// also denied when it lies under a denied path, except that "/" denies only the root
+	// itself.
+	DeniedBinds []string `json:"denied_binds,omitempty" yaml:"denied_binds,omitempty"`
+	// AllowedBinds lists glob patterns of SensitivePaths that may be bind-mounted anyway,
+	// e.g. "/var/run/docker.sock" for a container that manages Docker itself.
+	AllowedBinds []string `json:"allowed_binds,omitempty" yaml:"allowed_binds,omitempty"`
+	// AllowPrivileged permits actions that ask for a privileged container.
+	AllowPrivileged bool `json:"allow_privileged,omitempty" yaml:"allow_privileged,omitempty"`
+	// AllowedEnv lists glob patterns of the server's environment variables that plans may
+	// reference as ${NAME}. Variables named PassEnvPrefix* are always allowed; no others
+	// are unless listed here, so a plan cannot copy the server's own credentials into a
+	// container.
+	AllowedEnv []string `json:"allowed_env,omitempty" yaml:"allowed_env,omitempty"`
+	// CopyDir is the host directory copy_from_container may write into; destinations
+	// outside it are refused. A relative path, and the default ".", are relative to the
+	// server's working directory.
+	CopyDir string `json:"copy_dir,omitempty" yaml:"copy_dir,omitempty"`
+}
+
+// PassEnvPrefix marks the server's environment variables meant to be passed to containers.
+const PassEnvPrefix = "MCP_PASS_"
+
+// SensitivePaths are host paths that are refused as bind mounts unless AllowedBinds lists
+// them: mounting any of them (or anything under them) hands the container control of the
+// host. As with DeniedBinds, "/" refuses only the root itself.
+var SensitivePaths = []string{"/var/run/docker.sock", "/run/docker.sock", "/", "/etc", "/proc", "/sys"}
+
+// Action is what a policy looks at in one plan action or tool call.
+type Action struct {
+	Type string
+	// Images are the normalized image references the action uses.
+	Images []string
+	// HostPaths are the absolute host paths the action bind-mounts or copies files between.
+	HostPaths []string
+	// CopyDest is the absolute host path the action copies files into, if any.
+	CopyDest string
+	// EnvVars are the server environment variables the action references.
+	EnvVars []string
+	// Privileged reports whether the action asks for a privileged container.
+	Privileged bool
+}
+
+// Violation is the error returned for an action a policy forbids. Rule names the setting
+// that forbade it, e.g. "denied_images".
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("blocked by policy rule %s: %s", v.Rule, v.Message)
+}
+
+// Validate checks that every pattern in the policy is well formed.
+func (p *Policy) Validate() error {
+	for rule, patterns := range map[string][]string{
+		"allowed_images": p.AllowedImages,
+		"denied_images":  p.DeniedImages,
+		"denied_binds":   p.DeniedBinds,
+		"allowed_binds":  p.AllowedBinds,
+		"allowed_env":    p.AllowedEnv,
+	} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid policy.%s pattern %q: %w", rule, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns a *Violation if the policy forbids a, or nil.
+func (p *Policy) Check(a Action) error {
+	if len(p.AllowedActions) > 0 && !contains(p.AllowedActions, a.Type) {
+		return &Violation{Rule: "allowed_actions", Message: fmt.Sprintf("action %s is not in the allowed list", a.Type)}
+	}
+	if contains(p.DeniedActions, a.Type) {
+		return &Violation{Rule: "denied_actions", Message: fmt.Sprintf("action %s is denied", a.Type)}
+	}
+	for _, image := range a.Images {
+		if len(p.AllowedImages) > 0 && !matchImage(p.AllowedImages, image) {
+			return &Violation{Rule: "allowed_images", Message: fmt.Sprintf("image %s does not match any allowed pattern", image)}
+		}
+		if matchImage(p.DeniedImages, image) {
+			return &Violation{Rule: "denied_images", Message: fmt.Sprintf("image %s is denied", image)}
+		}
+	}
+	if a.Privileged && !p.AllowPrivileged {
+		return &Violation{Rule: "allow_privileged", Message: fmt.Sprintf("action %s asks for a privileged container", a.Type)}
+	}
+	for _, hostPath := range a.HostPaths {
+		if err := p.CheckBind(hostPath); err != nil {
+			return err
+		}
+	}
+	for _, name := range a.EnvVars {
+		if err := p.CheckEnv(name); err != nil {
+			return err
+		}
+	}
+	if a.CopyDest != "" {
+		return p.CheckCopyDest(a.CopyDest)
+	}
+	return nil
+}
+
+// CheckBind returns a *Violation if hostPath may not be bind-mounted, or copied to or from
+// a container: it is, or lies under,
+// one of SensitivePaths not listed in AllowedBinds, or it matches DeniedBinds.
+func (p *Policy) CheckBind(hostPath string) error {
+	if pattern, ok := matchPath(SensitivePaths, hostPath); ok {
+		if _, allowed := matchPath(p.AllowedBinds, hostPath); !allowed {
+			return &Violation{Rule: "allowed_binds", Message: fmt.Sprintf("host path %s is refused because %s is sensitive; list it in policy.allowed_binds to permit it", hostPath, pattern)}
+		}
+	}
+	if pattern, ok := matchPath(p.DeniedBinds, hostPath); ok {
+		return &Violation{Rule: "denied_binds", Message: fmt.Sprintf("host path %s matches %s", hostPath, pattern)}
+	}
+	return nil
+}
+
+// CheckCopyDest returns a *Violation unless hostPath, an absolute path files are to be
+// copied into, lies within CopyDir.
+func (p *Policy) CheckCopyDest(hostPath string) error {
+	dir := p.CopyDir
+	if dir == "" {
+		dir = "."
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return &Violation{Rule: "copy_dir", Message: fmt.Sprintf("cannot resolve copy directory %s: %v", dir, err)}
+	}
+	// Callers resolve symlinks in hostPath, so resolve them in the base too.
+	if resolved, err := filepath.EvalSymlinks(base); err == nil {
+		base = resolved
+	}
+	if rel, err := filepath.Rel(base, filepath.Clean(hostPath)); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return &Violation{Rule: "copy_dir", Message: fmt.Sprintf("host path %s is outside %s; set policy.copy_dir to allow copying files elsewhere", hostPath, base)}
+	}
+	return nil
+}
+
+// CheckEnv returns a *Violation unless plans may read the server's environment variable
+// name: it starts with PassEnvPrefix or matches AllowedEnv.
+func (p *Policy) CheckEnv(name string) error {
+	if strings.HasPrefix(name, PassEnvPrefix) {
+		return nil
+	}
+	for _, pattern := range p.AllowedEnv {
+		if ok, _ := path.Match(pattern, name); ok {
+			return nil
+		}
+	}
+	return &Violation{Rule: "allowed_env", Message: fmt.Sprintf("environment variable %s may not be read by plans; name it %s%s or list it in policy.allowed_env", name, PassEnvPrefix, name)}
+}
+
+// matchImage reports whether image, or its repository without the tag, matches a pattern.
+func matchImage(patterns []string, image string) bool {
+	repo := image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo = image[:i]
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, repo); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath returns the pattern that hostPath, or one of its parent directories, matches.
+// The pattern "/" only matches the root itself.
+func matchPath(patterns []string, hostPath string) (string, bool) {
+	hostPath = path.Clean(hostPath)
+	for p := hostPath; ; p = path.Dir(p) {
+		for _, pattern := range patterns {
+			if p == "/" && hostPath != "/" {
+				continue
+			}
+			if ok, _ := path.Match(path.Clean(pattern), p); ok {
+				return pattern, true
+			}
+		}
+		if p == "/" || p == "." {
+			return "", false
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
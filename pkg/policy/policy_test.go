@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// violatedRule returns the rule err was raised for, or "" when err is nil.
+func violatedRule(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var v *Violation
+	if !errors.As(err, &v) {
+		t.Fatalf("expected a *Violation, got %T: %v", err, err)
+	}
+	return v.Rule
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		action Action
+		rule   string
+	}{
+		{"zero policy allows actions", Policy{}, Action{Type: "create_container", Images: []string{"nginx:latest"}}, ""},
+		{"action not in allowed list", Policy{AllowedActions: []string{"list_containers"}}, Action{Type: "remove_container"}, "allowed_actions"},
+		{"action in allowed list", Policy{AllowedActions: []string{"list_containers"}}, Action{Type: "list_containers"}, ""},
+		{"denied action", Policy{DeniedActions: []string{"remove_container"}}, Action{Type: "remove_container"}, "denied_actions"},
+		{"denied wins over allowed", Policy{AllowedActions: []string{"remove_container"}, DeniedActions: []string{"remove_container"}}, Action{Type: "remove_container"}, "denied_actions"},
+		{"image matches allowed repository", Policy{AllowedImages: []string{"nginx"}}, Action{Type: "pull_image", Images: []string{"nginx:1.27"}}, ""},
+		{"image matches allowed glob", Policy{AllowedImages: []string{"ghcr.io/acme/*"}}, Action{Type: "pull_image", Images: []string{"ghcr.io/acme/api:v2"}}, ""},
+		{"glob does not cross slashes", Policy{AllowedImages: []string{"ghcr.io/acme/*"}}, Action{Type: "pull_image", Images: []string{"ghcr.io/acme/team/api:v2"}}, "allowed_images"},
+		{"image outside allowed list", Policy{AllowedImages: []string{"nginx"}}, Action{Type: "pull_image", Images: []string{"redis:7"}}, "allowed_images"},
+		{"registry port is not a tag", Policy{AllowedImages: []string{"registry:5000/app"}}, Action{Type: "pull_image", Images: []string{"registry:5000/app:1"}}, ""},
+		{"denied image", Policy{DeniedImages: []string{"*:latest"}}, Action{Type: "create_container", Images: []string{"nginx:latest"}}, "denied_images"},
+		{"every image is checked", Policy{DeniedImages: []string{"redis"}}, Action{Type: "compose_up", Images: []string{"nginx:1", "redis:7"}}, "denied_images"},
+		{"privileged denied by default", Policy{}, Action{Type: "create_container", Privileged: true}, "allow_privileged"},
+		{"privileged allowed", Policy{AllowPrivileged: true}, Action{Type: "create_container", Privileged: true}, ""},
+		{"sensitive bind", Policy{}, Action{Type: "create_container", HostPaths: []string{"/var/run/docker.sock"}}, "allowed_binds"},
+		{"denied bind", Policy{DeniedBinds: []string{"/srv/secrets"}}, Action{Type: "create_container", HostPaths: []string{"/srv/secrets"}}, "denied_binds"},
+		{"denied env reference", Policy{}, Action{Type: "create_container", EnvVars: []string{"AWS_SECRET_ACCESS_KEY"}}, "allowed_env"},
+		{"passed env reference", Policy{}, Action{Type: "create_container", EnvVars: []string{"MCP_PASS_DB_PASSWORD"}}, ""},
+		{"copy outside copy_dir", Policy{CopyDir: "/srv/copies"}, Action{Type: "copy_from_container", CopyDest: "/srv/other"}, "copy_dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violatedRule(t, tt.policy.Check(tt.action)); got != tt.rule {
+				t.Errorf("Check(%+v) violated %q, want %q", tt.action, got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		env     string
+		rule    string
+	}{
+		{"pass prefix", nil, "MCP_PASS_TOKEN", ""},
+		{"server variable", nil, "HOME", "allowed_env"},
+		{"prefix must lead", nil, "X_MCP_PASS_TOKEN", "allowed_env"},
+		{"listed name", []string{"DB_PASSWORD"}, "DB_PASSWORD", ""},
+		{"listed glob", []string{"APP_*"}, "APP_TOKEN", ""},
+		{"unlisted name", []string{"APP_*"}, "OPENAI_API_KEY", "allowed_env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{AllowedEnv: tt.allowed}
+			if got := violatedRule(t, p.CheckEnv(tt.env)); got != tt.rule {
+				t.Errorf("CheckEnv(%q) violated %q, want %q", tt.env, got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckBind(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		hostPath string
+		rule     string
+	}{
+		{"ordinary directory", Policy{}, "/home/user/app", ""},
+		{"docker socket", Policy{}, "/var/run/docker.sock", "allowed_binds"},
+		{"under a sensitive path", Policy{}, "/etc/nginx/nginx.conf", "allowed_binds"},
+		{"unclean path under a sensitive path", Policy{}, "/srv/../etc/passwd", "allowed_binds"},
+		{"root itself", Policy{}, "/", "allowed_binds"},
+		{"root does not deny everything", Policy{}, "/srv/data", ""},
+		{"sibling of a sensitive path", Policy{}, "/etcetera", ""},
+		{"sensitive path allowed", Policy{AllowedBinds: []string{"/var/run/docker.sock"}}, "/var/run/docker.sock", ""},
+		{"denied path", Policy{DeniedBinds: []string{"/home/*/.ssh"}}, "/home/user/.ssh/id_rsa", "denied_binds"},
+		{"allowed sensitive path still denied", Policy{AllowedBinds: []string{"/etc"}, DeniedBinds: []string{"/etc/shadow"}}, "/etc/shadow", "denied_binds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violatedRule(t, tt.policy.CheckBind(tt.hostPath)); got != tt.rule {
+				t.Errorf("CheckBind(%q) violated %q, want %q", tt.hostPath, got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckCopyDest(t *testing.T) {
+	base := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		copyDir  string
+		hostPath string
+		rule     string
+	}{
+		{"copy_dir itself", base, base, ""},
+		{"under copy_dir", base, filepath.Join(base, "out", "logs"), ""},
+		{"parent of copy_dir", base, filepath.Dir(base), "copy_dir"},
+		{"escape through dot-dot", base, base + "/out/../../elsewhere", "copy_dir"},
+		{"name sharing a prefix", base, base + "-other", "copy_dir"},
+		{"default is the working directory", "", filepath.Join(wd, "out"), ""},
+		{"outside the working directory", "", filepath.Dir(wd), "copy_dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{CopyDir: tt.copyDir}
+			if got := violatedRule(t, p.CheckCopyDest(tt.hostPath)); got != tt.rule {
+				t.Errorf("CheckCopyDest(%q) with copy_dir %q violated %q, want %q", tt.hostPath, tt.copyDir, got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"empty", Policy{}, false},
+		{"valid globs", Policy{AllowedImages: []string{"ghcr.io/acme/*"}, DeniedBinds: []string{"/home/*/.ssh"}, AllowedEnv: []string{"APP_*"}}, false},
+		{"malformed image pattern", Policy{DeniedImages: []string{"nginx["}}, true},
+		{"malformed bind pattern", Policy{DeniedBinds: []string{"/srv/["}}, true},
+		{"malformed allowed bind pattern", Policy{AllowedBinds: []string{"/run/["}}, true},
+		{"malformed env pattern", Policy{AllowedEnv: []string{"APP_["}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
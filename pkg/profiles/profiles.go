@@ -0,0 +1,119 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/distribution/reference"
+)
+
+// ProfilesFileEnv names the environment variable pointing at a JSON profiles file.
+const ProfilesFileEnv = "MCP_PROFILES_FILE"
+
+// Profile is a set of environment-specific overrides merged into a plan before execution.
+type Profile struct {
+	// ImageTag replaces the tag of images that are untagged or tagged "latest".
+	ImageTag string `json:"image_tag,omitempty"`
+	// Overrides maps an action type to parameters that replace the action's own values.
+	Overrides map[string]map[string]interface{} `json:"overrides,omitempty"`
+}
+
+// Defaults are the built-in profiles used when no profiles file is configured.
+var Defaults = map[string]Profile{
+	"dev": {
+		Overrides: map[string]map[string]interface{}{
+			"create_container": {"memory_mb": 256.0, "cpus": 0.5, "restart_policy": "no"},
+			"scale_service":    {"replicas": 1.0},
+		},
+	},
+	"prod": {
+		Overrides: map[string]map[string]interface{}{
+			"create_container": {"memory_mb": 1024.0, "cpus": 2.0, "restart_policy": "unless-stopped"},
+			"scale_service":    {"replicas": 3.0},
+		},
+	},
+}
+
+// Load reads profiles from the JSON file at path, a map from profile name to Profile. An
+// empty path returns the built-in Defaults.
+func Load(path string) (map[string]Profile, error) {
+	if path == "" {
+		return Defaults, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+	}
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// Apply returns a copy of plan with the profile's overrides merged into each action.
+func (p Profile) Apply(plan []map[string]interface{}) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, 0, len(plan))
+	for i, action := range plan {
+		actionType, _ := action["action"].(string)
+		params := map[string]interface{}{}
+		if orig, ok := action["parameters"].(map[string]interface{}); ok {
+			for k, v := range orig {
+				params[k] = v
+			}
+		}
+		for k, v := range p.Overrides[actionType] {
+			params[k] = v
+		}
+		if p.ImageTag != "" {
+			if err := p.retag(actionType, params); err != nil {
+				return nil, fmt.Errorf("action %d (%s): %w", i, actionType, err)
+			}
+		}
+		merged := map[string]interface{}{}
+		for k, v := range action {
+			merged[k] = v
+		}
+		merged["parameters"] = params
+		out = append(out, merged)
+	}
+	return out, nil
+}
+
+// retag applies the profile's image tag to an action's image parameters.
+func (p Profile) retag(actionType string, params map[string]interface{}) error {
+	if image, ok := params["image"].(string); ok && image != "" {
+		tagged, err := withDefaultTag(image, p.ImageTag)
+		if err != nil {
+			return err
+		}
+		params["image"] = tagged
+		return nil
+	}
+	if actionType == "pull_image" {
+		if tag, _ := params["tag"].(string); tag == "" || tag == "latest" {
+			params["tag"] = p.ImageTag
+		}
+	}
+	return nil
+}
+
+// withDefaultTag retags image with tag unless it pins a digest or a tag other than "latest".
+func withDefaultTag(image, tag string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return image, nil
+	}
+	if tagged, ok := named.(reference.Tagged); ok && tagged.Tag() != "latest" {
+		return image, nil
+	}
+	retagged, err := reference.WithTag(reference.TrimNamed(named), tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid image tag %q: %w", tag, err)
+	}
+	return reference.FamiliarString(retagged), nil
+}
@@ -0,0 +1,124 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyDefaultProfiles(t *testing.T) {
+	plan := []map[string]interface{}{
+		{"action": "create_container", "parameters": map[string]interface{}{"name": "web", "image": "nginx", "memory_mb": 64.0}},
+		{"action": "create_network", "parameters": map[string]interface{}{"name": "shop"}},
+	}
+	tests := []struct {
+		profile    string
+		wantMemory float64
+		wantCPUs   float64
+		wantPolicy string
+	}{
+		{profile: "dev", wantMemory: 256, wantCPUs: 0.5, wantPolicy: "no"},
+		{profile: "prod", wantMemory: 1024, wantCPUs: 2, wantPolicy: "unless-stopped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			got, err := Defaults[tt.profile].Apply(plan)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			params := got[0]["parameters"].(map[string]interface{})
+			if params["memory_mb"] != tt.wantMemory || params["cpus"] != tt.wantCPUs || params["restart_policy"] != tt.wantPolicy {
+				t.Errorf("container parameters = %v, want memory %v, cpus %v, restart %s", params, tt.wantMemory, tt.wantCPUs, tt.wantPolicy)
+			}
+			if params["name"] != "web" || params["image"] != "nginx" {
+				t.Errorf("container parameters = %v, want the plan's own values kept", params)
+			}
+			if !reflect.DeepEqual(got[1], plan[1]) {
+				t.Errorf("network action = %v, want it unchanged", got[1])
+			}
+		})
+	}
+	if plan[0]["parameters"].(map[string]interface{})["memory_mb"] != 64.0 {
+		t.Error("Apply() modified the original plan")
+	}
+}
+
+func TestApplyImageTag(t *testing.T) {
+	p := Profile{ImageTag: "1.4.2"}
+	tests := []struct {
+		name    string
+		action  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "untagged image",
+			action: map[string]interface{}{"action": "create_container", "parameters": map[string]interface{}{"image": "ghcr.io/org/app"}},
+			want:   map[string]interface{}{"image": "ghcr.io/org/app:1.4.2"},
+		},
+		{
+			name:   "latest",
+			action: map[string]interface{}{"action": "create_container", "parameters": map[string]interface{}{"image": "app:latest"}},
+			want:   map[string]interface{}{"image": "app:1.4.2"},
+		},
+		{
+			name:   "pinned tag",
+			action: map[string]interface{}{"action": "create_container", "parameters": map[string]interface{}{"image": "app:2.0"}},
+			want:   map[string]interface{}{"image": "app:2.0"},
+		},
+		{
+			name:   "pull without tag",
+			action: map[string]interface{}{"action": "pull_image", "parameters": map[string]interface{}{"name": "app"}},
+			want:   map[string]interface{}{"name": "app", "tag": "1.4.2"},
+		},
+		{
+			name:    "invalid image",
+			action:  map[string]interface{}{"action": "create_container", "parameters": map[string]interface{}{"image": "App"}},
+			wantErr: `action 0 (create_container): invalid image reference "App"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Apply([]map[string]interface{}{tt.action})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Apply() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got[0]["parameters"], tt.want) {
+				t.Errorf("Apply() parameters = %v, want %v", got[0]["parameters"], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if got, err := Load(""); err != nil || !reflect.DeepEqual(got, Defaults) {
+		t.Errorf("Load(\"\") = %v, %v; want the defaults", got, err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "profiles.json")
+	if err := os.WriteFile(path, []byte(`{"staging": {"image_tag": "rc", "overrides": {"create_container": {"memory_mb": 512}}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := map[string]Profile{"staging": {ImageTag: "rc", Overrides: map[string]map[string]interface{}{"create_container": {"memory_mb": 512.0}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+	if err := os.WriteFile(path, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "failed to parse profiles file") {
+		t.Errorf("Load() error = %v, want a parse failure", err)
+	}
+}
@@ -6,41 +6,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"sync/atomic"
 	"time"
 
 	"santoshkal/mcp-godocker/pkg/mcp"
 )
 
+// RPCClient calls the MCP server's JSON-RPC methods over HTTP.
 type RPCClient struct {
 	httpClient *http.Client
 	endpoint   string
+	token      string
+	// verbose, when non-nil, receives every request and raw response for debugging.
+	verbose io.Writer
+	// nextID numbers requests so responses can be told apart in logs.
+	nextID atomic.Int64
 }
 
-func NewRPCClient(endpoint string) *RPCClient {
+// NewRPCClient returns a client for the server at endpoint whose requests each time out
+// after timeout.
+func NewRPCClient(endpoint string, timeout time.Duration) *RPCClient {
 	return &RPCClient{
-		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		httpClient: &http.Client{Timeout: timeout},
 		endpoint:   endpoint,
 	}
 }
 
-// Call performs a JSON-RPC call and returns the raw result.
+// WithToken makes the client authenticate every request with token as a bearer token. An
+// empty token sends no Authorization header.
+func (c *RPCClient) WithToken(token string) *RPCClient {
+	c.token = token
+	return c
+}
+
+// WithVerbose makes the client print each JSON-RPC request and raw response to w. A nil w
+// turns verbose output off.
+func (c *RPCClient) WithVerbose(w io.Writer) *RPCClient {
+	c.verbose = w
+	return c
+}
+
+// authorize adds the client's bearer token to req, if it has one.
+func (c *RPCClient) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// Call performs a JSON-RPC call and returns the raw result. A JSON-RPC error is returned as
+// an *mcp.RPCError.
 func (c *RPCClient) Call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
 	reqBody := mcp.RPCRequest{
 		Version: mcp.JSONRPCVersion,
 		Method:  method,
 		Params:  params,
-		ID:      1,
+		ID:      int(c.nextID.Add(1)),
 	}
 	data, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	if c.verbose != nil {
+		fmt.Fprintf(c.verbose, "--> %s\n", data)
+	}
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	c.authorize(httpReq)
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
@@ -50,16 +87,43 @@ func (c *RPCClient) Call(ctx context.Context, method string, params ...interface
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-	var rpcResp mcp.RPCResponse
+	if c.verbose != nil {
+		fmt.Fprintf(c.verbose, "<-- %d %s\n", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s failed with status %d: %s", method, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
 	if err := json.Unmarshal(body, &rpcResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	if rpcResp.Error != nil {
-		return nil, fmt.Errorf(rpcResp.Error.String())
+	if rpcErr := decodeError(rpcResp.Error); rpcErr != nil {
+		return nil, rpcErr
 	}
 	return rpcResp.Result, nil
 }
 
+// decodeError decodes the error member of a response, or returns nil when there is none.
+// The legacy Server.* methods report errors as a plain string and the MCP methods as a
+// JSON-RPC 2.0 error object; both are returned as an *mcp.RPCError.
+func decodeError(raw json.RawMessage) *mcp.RPCError {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var msg string
+	if err := json.Unmarshal(raw, &msg); err == nil {
+		return mcp.NewError(-32000, msg)
+	}
+	var rpcErr mcp.RPCError
+	if err := json.Unmarshal(raw, &rpcErr); err != nil {
+		return mcp.NewError(-32000, string(raw))
+	}
+	return &rpcErr
+}
+
 // CallAndParse unmarshals the result into out.
 func (c *RPCClient) CallAndParse(ctx context.Context, method string, out interface{}, params ...interface{}) error {
 	result, err := c.Call(ctx, method, params...)
@@ -71,3 +135,82 @@ func (c *RPCClient) CallAndParse(ctx context.Context, method string, out interfa
 	}
 	return nil
 }
+
+// UploadPlan executes planJSON through the server's multipart upload endpoint, shipping each
+// build context (a tar stream) as a file part named after the key that build_image actions
+// reference in their "context" parameter. When onLog is non-nil the server streams build
+// output, and each line is passed to onLog as it arrives.
+func (c *RPCClient) UploadPlan(ctx context.Context, planJSON string, contexts map[string]io.Reader, onLog func(string)) (*mcp.RPCResponse, error) {
+	uploadURL, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", c.endpoint, err)
+	}
+	uploadURL.Path = "/plan/upload"
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("plan", planJSON); err != nil {
+		return nil, fmt.Errorf("failed to write plan field: %w", err)
+	}
+	for name, r := range contexts {
+		part, err := mw.CreateFormFile(name, name+".tar")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create part for build context %q: %w", name, err)
+		}
+		if _, err := io.Copy(part, r); err != nil {
+			return nil, fmt.Errorf("failed to write build context %q: %w", name, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize multipart body: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.String(), &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", mw.FormDataContentType())
+	c.authorize(httpReq)
+	if onLog != nil {
+		httpReq.Header.Set("Accept", "application/x-ndjson")
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("plan upload failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	if onLog == nil {
+		var rpcResp mcp.RPCResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		return &rpcResp, nil
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type     string           `json:"type"`
+			Line     string           `json:"line"`
+			Response *mcp.RPCResponse `json:"response"`
+		}
+		if err := dec.Decode(&event); err == io.EOF {
+			return nil, fmt.Errorf("plan upload stream ended without a result")
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read upload stream: %w", err)
+		}
+		switch event.Type {
+		case "log":
+			onLog(event.Line)
+		case "result":
+			if event.Response == nil {
+				return nil, fmt.Errorf("plan upload stream returned an empty result")
+			}
+			return event.Response, nil
+		}
+	}
+}
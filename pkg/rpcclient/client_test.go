@@ -0,0 +1,177 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+func TestClientSendsToken(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(mcp.RPCResponse{Version: mcp.JSONRPCVersion, Result: json.RawMessage(`"pong"`)})
+	}))
+	defer srv.Close()
+
+	if _, err := NewRPCClient(srv.URL, time.Minute).WithToken("s3cret").Call(context.Background(), "Server.Ping"); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if _, err := NewRPCClient(srv.URL, time.Minute).WithToken("s3cret").UploadPlan(context.Background(), "[]", nil, nil); err != nil {
+		t.Fatalf("UploadPlan() error = %v", err)
+	}
+	if _, err := NewRPCClient(srv.URL, time.Minute).Call(context.Background(), "Server.Ping"); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	want := []string{"Bearer s3cret", "Bearer s3cret", ""}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Authorization headers = %q, want %q", got, want)
+	}
+}
+
+func TestUploadPlan(t *testing.T) {
+	var gotPath, gotPlan string
+	gotContexts := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gotPlan = r.FormValue("plan")
+		for name, files := range r.MultipartForm.File {
+			f, err := files[0].Open()
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			f.Close()
+			gotContexts[name] = string(data)
+		}
+		json.NewEncoder(w).Encode(mcp.RPCResponse{Version: mcp.JSONRPCVersion, Result: json.RawMessage(`{"status":"success"}`)})
+	}))
+	defer srv.Close()
+
+	c := NewRPCClient(srv.URL+"/rpc", time.Minute)
+	plan := `[{"action": "build_image", "parameters": {"tag": "app", "context": "app"}}]`
+	reply, err := c.UploadPlan(context.Background(), plan, map[string]io.Reader{"app": strings.NewReader("tar bytes")}, nil)
+	if err != nil {
+		t.Fatalf("UploadPlan() error = %v", err)
+	}
+	if gotPath != "/plan/upload" {
+		t.Errorf("posted to %s, want /plan/upload", gotPath)
+	}
+	if gotPlan != plan {
+		t.Errorf("plan field = %q, want %q", gotPlan, plan)
+	}
+	if gotContexts["app"] != "tar bytes" || len(gotContexts) != 1 {
+		t.Errorf("build contexts = %q, want the app context", gotContexts)
+	}
+	if string(reply.Result) != `{"status":"success"}` {
+		t.Errorf("result = %s", reply.Result)
+	}
+}
+
+func TestUploadPlanReportsHTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "plan upload requires POST", http.StatusMethodNotAllowed)
+	}))
+	defer srv.Close()
+	_, err := NewRPCClient(srv.URL, time.Minute).UploadPlan(context.Background(), "[]", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "status 405: plan upload requires POST") {
+		t.Errorf("UploadPlan() error = %v, want the status and body", err)
+	}
+}
+
+func TestUploadPlanStreamsLogs(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   string
+		wantLogs []string
+		wantErr  string
+	}{
+		{
+			name: "logs then result",
+			events: `{"type": "log", "line": "Step 1/2 : FROM scratch"}
+{"type": "log", "line": "[1/2] done"}
+{"type": "result", "response": {"jsonrpc": "2.0", "result": {"status": "success"}}}
+`,
+			wantLogs: []string{"Step 1/2 : FROM scratch", "[1/2] done"},
+		},
+		{
+			name:     "stream cut short",
+			events:   `{"type": "log", "line": "Step 1/2 : FROM scratch"}` + "\n",
+			wantLogs: []string{"Step 1/2 : FROM scratch"},
+			wantErr:  "plan upload stream ended without a result",
+		},
+		{
+			name:    "empty result",
+			events:  `{"type": "result"}` + "\n",
+			wantErr: "plan upload stream returned an empty result",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept"); got != "application/x-ndjson" {
+					t.Errorf("Accept = %q, want application/x-ndjson", got)
+				}
+				io.WriteString(w, tt.events)
+			}))
+			defer srv.Close()
+			var logs []string
+			reply, err := NewRPCClient(srv.URL, time.Minute).UploadPlan(context.Background(), "[]", nil, func(line string) {
+				logs = append(logs, line)
+			})
+			if strings.Join(logs, "|") != strings.Join(tt.wantLogs, "|") {
+				t.Errorf("logs = %q, want %q", logs, tt.wantLogs)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("UploadPlan() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UploadPlan() error = %v", err)
+			}
+			if string(reply.Result) != `{"status": "success"}` {
+				t.Errorf("result = %s", reply.Result)
+			}
+		})
+	}
+}
+
+func TestVerbose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result": "pong", "id": 1}`))
+	}))
+	defer srv.Close()
+
+	var log strings.Builder
+	c := NewRPCClient(srv.URL, time.Minute).WithVerbose(&log)
+	if _, err := c.Call(context.Background(), "Server.Echo", "hi"); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	want := `--> {"jsonrpc":"2.0","method":"Server.Echo","params":["hi"],"id":1}` + "\n" + `<-- 200 {"result": "pong", "id": 1}` + "\n"
+	if log.String() != want {
+		t.Errorf("verbose output = %q, want %q", log.String(), want)
+	}
+
+	log.Reset()
+	c.WithVerbose(nil)
+	if _, err := c.Call(context.Background(), "Server.Echo", "hi"); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if log.Len() != 0 {
+		t.Errorf("verbose output after turning it off = %q", log.String())
+	}
+}
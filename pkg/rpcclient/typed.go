@@ -0,0 +1,67 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// CallLLM asks the server for a plan for input and returns it as a JSON array of actions.
+func (c *RPCClient) CallLLM(ctx context.Context, input string) (string, error) {
+	var plan string
+	if err := c.CallAndParse(ctx, "Server.CallLLM", &plan, input); err != nil {
+		return "", err
+	}
+	return plan, nil
+}
+
+// ExecutePlan executes planJSON, either a bare array of actions or an mcp.PlanDocument. A
+// failed plan is returned as an *mcp.RPCError.
+func (c *RPCClient) ExecutePlan(ctx context.Context, planJSON string) (mcp.PlanResult, error) {
+	var result mcp.PlanResult
+	err := c.callWrapped(ctx, "Server.ExecutePlan", &result, planJSON)
+	return result, err
+}
+
+// CallTool runs a single tool. A failed call is returned as an *mcp.RPCError.
+func (c *RPCClient) CallTool(ctx context.Context, name string, params map[string]interface{}) (mcp.ToolResult, error) {
+	var result mcp.ToolResult
+	err := c.callWrapped(ctx, "Server.CallTool", &result, mcp.ToolCallArgs{ToolName: name, Parameters: params})
+	return result, err
+}
+
+// ListTools lists the tools the server offers.
+func (c *RPCClient) ListTools(ctx context.Context) ([]mcp.ToolInfo, error) {
+	var result mcp.ListToolsResult
+	if err := c.CallAndParse(ctx, "tools/list", &result); err != nil {
+		return nil, err
+	}
+	return result.Tools, nil
+}
+
+// ListPrompts lists the prompt templates the server offers, with their arguments.
+func (c *RPCClient) ListPrompts(ctx context.Context) ([]mcp.PromptInfo, error) {
+	var result mcp.ListPromptsResult
+	if err := c.callWrapped(ctx, "Server.ListPrompts", &result, struct{}{}); err != nil {
+		return nil, err
+	}
+	return result.Prompts, nil
+}
+
+// callWrapped calls one of the methods that report their outcome as an mcp.RPCResponse
+// inside the JSON-RPC result, and decodes that response's result into out.
+func (c *RPCClient) callWrapped(ctx context.Context, method string, out interface{}, params ...interface{}) error {
+	var resp mcp.RPCResponse
+	if err := c.CallAndParse(ctx, method, &resp, params...); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to parse %s result into %T: %w", method, out, err)
+	}
+	return nil
+}
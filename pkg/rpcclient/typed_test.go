@@ -0,0 +1,126 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// rpcRequest is a JSON-RPC request as the fake server decodes it.
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     int               `json:"id"`
+}
+
+// fakeServer answers each JSON-RPC method with the canned response body for it, recording
+// the requests it receives.
+func fakeServer(t *testing.T, responses map[string]string, requests *[]rpcRequest) *RPCClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+		body, ok := responses[req.Method]
+		if !ok {
+			http.Error(w, "no such method "+req.Method, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return NewRPCClient(srv.URL, time.Minute)
+}
+
+func TestTypedMethods(t *testing.T) {
+	var requests []rpcRequest
+	c := fakeServer(t, map[string]string{
+		"Server.CallLLM":     `{"result": "[{\"action\":\"create_network\",\"parameters\":{\"name\":\"shop\"}}]", "error": null, "id": 1}`,
+		"Server.ExecutePlan": `{"result": {"jsonrpc": "2.0", "result": {"status": "success", "message": "Plan executed", "actions": [{"action": "create_network", "result": {"id": "n1"}}, {"action": "pull_image", "skipped": true}]}}, "error": null, "id": 2}`,
+		"Server.CallTool":    `{"result": {"jsonrpc": "2.0", "result": {"status": "success", "message": "done", "result": {"id": "n1"}}}, "error": null, "id": 3}`,
+		"tools/list":         `{"jsonrpc": "2.0", "result": {"tools": [{"name": "create_network", "description": "Create a network", "inputSchema": {"type": "object"}}]}, "id": 4}`,
+	}, &requests)
+	ctx := context.Background()
+
+	plan, err := c.CallLLM(ctx, "create a network named shop")
+	if err != nil || plan != `[{"action":"create_network","parameters":{"name":"shop"}}]` {
+		t.Errorf("CallLLM() = %q, %v", plan, err)
+	}
+	result, err := c.ExecutePlan(ctx, plan)
+	if err != nil {
+		t.Fatalf("ExecutePlan() error = %v", err)
+	}
+	if result.Status != "success" || len(result.Actions) != 2 || result.Actions[0].Result["id"] != "n1" || !result.Actions[1].Skipped {
+		t.Errorf("ExecutePlan() = %+v", result)
+	}
+	tool, err := c.CallTool(ctx, "create_network", map[string]interface{}{"name": "shop"})
+	if err != nil || tool.Status != "success" || tool.Result["id"] != "n1" {
+		t.Errorf("CallTool() = %+v, %v", tool, err)
+	}
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "create_network" {
+		t.Errorf("ListTools() = %+v, %v", tools, err)
+	}
+
+	if len(requests) != 4 {
+		t.Fatalf("server received %d requests, want 4", len(requests))
+	}
+	if got := string(requests[0].Params[0]); got != `"create a network named shop"` {
+		t.Errorf("CallLLM params = %s, want the instruction", got)
+	}
+	var args mcp.ToolCallArgs
+	if err := json.Unmarshal(requests[2].Params[0], &args); err != nil || args.ToolName != "create_network" || args.Parameters["name"] != "shop" {
+		t.Errorf("CallTool params = %s", requests[2].Params[0])
+	}
+	for i, req := range requests {
+		if req.ID != i+1 {
+			t.Errorf("request %d has id %d, want ids numbered from 1", i, req.ID)
+		}
+	}
+}
+
+func TestTypedMethodErrors(t *testing.T) {
+	var requests []rpcRequest
+	c := fakeServer(t, map[string]string{
+		"Server.ExecutePlan": `{"result": {"jsonrpc": "2.0", "error": {"code": -32003, "message": "No such image: nginx:nope"}}, "error": null, "id": 1}`,
+		"Server.CallLLM":     `{"result": null, "error": "CallLLM requires an instruction", "id": 2}`,
+		"tools/list":         `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method not found"}, "id": 3}`,
+		"Server.CallTool":    `{"result": {"jsonrpc": "2.0", "result": "not an object"}, "error": null, "id": 4}`,
+	}, &requests)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode int
+		wantErr  string
+	}{
+		{name: "failed plan", call: func() error { _, err := c.ExecutePlan(ctx, "[]"); return err }, wantCode: -32003, wantErr: "No such image"},
+		{name: "legacy string error", call: func() error { _, err := c.CallLLM(ctx, ""); return err }, wantCode: -32000, wantErr: "CallLLM requires an instruction"},
+		{name: "error object", call: func() error { _, err := c.ListTools(ctx); return err }, wantCode: -32601, wantErr: "method not found"},
+		{name: "unexpected result", call: func() error { _, err := c.CallTool(ctx, "x", nil); return err }, wantErr: "failed to parse Server.CallTool result"},
+		{name: "HTTP error", call: func() error { _, err := c.Call(ctx, "Server.Missing"); return err }, wantErr: "Server.Missing failed with status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+			var rpcErr *mcp.RPCError
+			if tt.wantCode != 0 && (!errors.As(err, &rpcErr) || rpcErr.Code != tt.wantCode) {
+				t.Errorf("error = %#v, want an *mcp.RPCError with code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
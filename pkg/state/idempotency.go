@@ -0,0 +1,124 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long the result recorded for an idempotency key is replayed.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyRecord is the result stored for an idempotency key.
+type idempotencyRecord struct {
+	Key       string          `json:"key"`
+	Result    json.RawMessage `json:"result"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// LookupIdempotencyKey returns the result recorded for key, if one was stored and has not
+// expired. Expired records are removed.
+func LookupIdempotencyKey(key string) (json.RawMessage, bool, error) {
+	path := idempotencyPath(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	var rec idempotencyRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Key != key {
+		// A corrupt record, or (very unlikely) a hash collision: treat the key as unused.
+		return nil, false, nil
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		os.Remove(path)
+		return nil, false, nil
+	}
+	return rec.Result, true, nil
+}
+
+// StoreIdempotencyKey records result for key, to be replayed until ttl has passed.
+func StoreIdempotencyKey(key string, result json.RawMessage, ttl time.Duration) error {
+	data, err := json.Marshal(idempotencyRecord{Key: key, Result: result, ExpiresAt: time.Now().UTC().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	return writeFileAtomic(idempotencyPath(key), data)
+}
+
+// ErrIdempotencyKeyInUse is returned by ClaimIdempotencyKey while another execution holds
+// the key.
+var ErrIdempotencyKeyInUse = errors.New("idempotency key is in use")
+
+// staleClaimAge is the age after which a claim is assumed to be left over from a process
+// that died while holding it.
+const staleClaimAge = time.Hour
+
+// ClaimIdempotencyKey atomically claims key for one execution, across goroutines and
+// processes sharing the state directory, and returns the function that releases it. While
+// the key is claimed, other claims fail with ErrIdempotencyKeyInUse. A caller should look
+// the key up only after claiming it, so that two submissions of the same key cannot both
+// miss the record and both execute.
+func ClaimIdempotencyKey(key string) (func(), error) {
+	path := strings.TrimSuffix(idempotencyPath(key), ".json") + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		info, statErr := os.Stat(path)
+		if attempt > 0 || statErr != nil || time.Since(info.ModTime()) < staleClaimAge {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		os.Remove(path)
+	}
+}
+
+// PruneIdempotencyKeys removes expired idempotency records.
+func PruneIdempotencyKeys() error {
+	dir := filepath.Join(Dir(), "idempotency")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var rec idempotencyRecord
+		if json.Unmarshal(data, &rec) != nil || now.After(rec.ExpiresAt) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// idempotencyPath hashes the key so arbitrary client-chosen strings are safe file names.
+func idempotencyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(Dir(), "idempotency", hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyStoreAndLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		store  map[string]time.Duration
+		lookup string
+		want   string
+		found  bool
+	}{
+		{"unknown key", nil, "plan:a", "", false},
+		{"stored key", map[string]time.Duration{"plan:a": time.Hour}, "plan:a", `{"status":"success"}`, true},
+		{"other key", map[string]time.Duration{"plan:a": time.Hour}, "plan:b", "", false},
+		{"namespaces differ", map[string]time.Duration{"plan:a": time.Hour}, "action:a", "", false},
+		{"expired key", map[string]time.Duration{"plan:a": -time.Second}, "plan:a", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(StateDirEnv, t.TempDir())
+			for key, ttl := range tt.store {
+				if err := StoreIdempotencyKey(key, json.RawMessage(`{"status":"success"}`), ttl); err != nil {
+					t.Fatalf("StoreIdempotencyKey: %v", err)
+				}
+			}
+			got, found, err := LookupIdempotencyKey(tt.lookup)
+			if err != nil {
+				t.Fatalf("LookupIdempotencyKey: %v", err)
+			}
+			if found != tt.found || string(got) != tt.want {
+				t.Errorf("LookupIdempotencyKey(%q) = %s, %v; want %s, %v", tt.lookup, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestLookupIdempotencyKeyRemovesExpiredRecords(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	if err := StoreIdempotencyKey("plan:a", json.RawMessage(`{}`), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := LookupIdempotencyKey("plan:a"); found {
+		t.Fatal("expired record was replayed")
+	}
+	if _, err := os.Stat(idempotencyPath("plan:a")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired record still on disk: %v", err)
+	}
+}
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	release, err := ClaimIdempotencyKey("plan:a")
+	if err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	if _, err := ClaimIdempotencyKey("plan:a"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Fatalf("second claim error = %v, want ErrIdempotencyKeyInUse", err)
+	}
+	otherRelease, err := ClaimIdempotencyKey("plan:b")
+	if err != nil {
+		t.Fatalf("claiming another key: %v", err)
+	}
+	otherRelease()
+	release()
+	release, err = ClaimIdempotencyKey("plan:a")
+	if err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+	release()
+}
+
+func TestClaimIdempotencyKeyTakesOverStaleClaims(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	if _, err := ClaimIdempotencyKey("plan:a"); err != nil {
+		t.Fatal(err)
+	}
+	// Age the claim as if its holder had died long ago without releasing it.
+	lock := strings.TrimSuffix(idempotencyPath("plan:a"), ".json") + ".lock"
+	old := time.Now().Add(-2 * staleClaimAge)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	release, err := ClaimIdempotencyKey("plan:a")
+	if err != nil {
+		t.Fatalf("claim over a stale lock: %v", err)
+	}
+	release()
+}
+
+func TestPruneIdempotencyKeys(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	if err := StoreIdempotencyKey("plan:live", json.RawMessage(`{}`), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := StoreIdempotencyKey("plan:expired", json.RawMessage(`{}`), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	release, err := ClaimIdempotencyKey("plan:running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if err := PruneIdempotencyKeys(); err != nil {
+		t.Fatalf("PruneIdempotencyKeys: %v", err)
+	}
+	if _, err := os.Stat(idempotencyPath("plan:live")); err != nil {
+		t.Errorf("live record was pruned: %v", err)
+	}
+	if _, err := os.Stat(idempotencyPath("plan:expired")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired record was kept: %v", err)
+	}
+	if _, err := ClaimIdempotencyKey("plan:running"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Errorf("pruning released a claim: %v", err)
+	}
+}
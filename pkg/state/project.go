@@ -0,0 +1,175 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ResourceRef identifies a Docker resource the server created for a project.
+type ResourceRef struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// AppliedPlan records one successfully executed plan.
+type AppliedPlan struct {
+	Hash      string                   `json:"hash"`
+	Plan      []map[string]interface{} `json:"plan"`
+	AppliedAt time.Time                `json:"applied_at"`
+	Created   []ResourceRef            `json:"created,omitempty"`
+}
+
+// ProjectState is the persisted record of what the server has applied to a project.
+// Resources lists everything the server created and has not since destroyed, in creation
+// order. Workflow tracks where the project is in the plan/apply loop.
+type ProjectState struct {
+	Project   string        `json:"project"`
+	Plans     []AppliedPlan `json:"plans"`
+	Resources []ResourceRef `json:"resources"`
+	Workflow  *Workflow     `json:"workflow,omitempty"`
+}
+
+// stateMu serializes access to project state within the process; the lock file guards
+// against other processes sharing the state directory.
+var stateMu sync.Mutex
+
+// projectNamePattern restricts project names to something safe to use as a file name.
+var projectNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// LoadProjectState returns the recorded state of a project, or an empty state if nothing
+// has been recorded. A state file that cannot be parsed is moved aside (with a ".corrupt"
+// suffix) and an empty state is returned, so one bad write does not wedge the project.
+func LoadProjectState(project string) (*ProjectState, error) {
+	path, err := projectPath(project)
+	if err != nil {
+		return nil, err
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readProjectState(project, path)
+}
+
+// SaveProjectState persists the state of a project, replacing what was recorded before.
+func SaveProjectState(st *ProjectState) error {
+	path, err := projectPath(st.Project)
+	if err != nil {
+		return err
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeProjectState(path, st)
+}
+
+// UpdateProjectState loads a project's state, applies update and saves the result while
+// holding the lock, so concurrent updates are not lost.
+func UpdateProjectState(project string, update func(*ProjectState) error) error {
+	path, err := projectPath(project)
+	if err != nil {
+		return err
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	st, err := readProjectState(project, path)
+	if err != nil {
+		return err
+	}
+	if err := update(st); err != nil {
+		return err
+	}
+	return writeProjectState(path, st)
+}
+
+func readProjectState(project, path string) (*ProjectState, error) {
+	st := &ProjectState{Project: project}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state for project %s: %w", project, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		corrupt := path + ".corrupt"
+		log.Printf("[state] State for project %s is corrupt (%v); moving it to %s", project, err, corrupt)
+		if err := os.Rename(path, corrupt); err != nil {
+			return nil, fmt.Errorf("failed to move aside corrupt state for project %s: %w", project, err)
+		}
+		return &ProjectState{Project: project}, nil
+	}
+	st.Project = project
+	return st, nil
+}
+
+func writeProjectState(path string, st *ProjectState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state for project %s: %w", st.Project, err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func projectPath(project string) (string, error) {
+	if !projectNamePattern.MatchString(project) {
+		return "", fmt.Errorf("invalid project name %q", project)
+	}
+	return filepath.Join(Dir(), "projects", project+".json"), nil
+}
+
+// Lock file tuning: how long to wait for a lock, and when an abandoned lock is broken.
+const (
+	lockTimeout  = 10 * time.Second
+	lockRetry    = 50 * time.Millisecond
+	lockStaleAge = time.Minute
+)
+
+// lockFile takes an exclusive lock on path by creating path+".lock", waiting up to
+// lockTimeout for another holder to release it. Lock files older than lockStaleAge are
+// assumed to belong to a crashed process and are removed.
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockStaleAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock on %s", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProjectStateRoundTrip(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	st, err := LoadProjectState("shop")
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if st.Project != "shop" || len(st.Plans) != 0 || len(st.Resources) != 0 {
+		t.Fatalf("LoadProjectState() = %+v, want an empty state", st)
+	}
+	want := &ProjectState{
+		Project: "shop",
+		Plans: []AppliedPlan{{
+			Hash:      "abc",
+			Plan:      []map[string]interface{}{{"action": "create_network", "parameters": map[string]interface{}{"name": "shop-net"}}},
+			AppliedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Created:   []ResourceRef{{Type: "network", Name: "shop-net", ID: "n1"}},
+		}},
+		Resources: []ResourceRef{{Type: "network", Name: "shop-net", ID: "n1"}},
+	}
+	if err := SaveProjectState(want); err != nil {
+		t.Fatalf("SaveProjectState() error = %v", err)
+	}
+	got, err := LoadProjectState("shop")
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadProjectState() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadProjectStateRecoversFromCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(StateDirEnv, dir)
+	path := filepath.Join(dir, "projects", "shop.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"project": "shop", "resources": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	st, err := LoadProjectState("shop")
+	if err != nil {
+		t.Fatalf("LoadProjectState() error = %v", err)
+	}
+	if st.Project != "shop" || len(st.Resources) != 0 {
+		t.Errorf("LoadProjectState() = %+v, want an empty state", st)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("corrupt state was not moved aside: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt state file still in place: %v", err)
+	}
+	// The project is usable again straight away.
+	if err := SaveProjectState(&ProjectState{Project: "shop"}); err != nil {
+		t.Errorf("SaveProjectState() after recovery error = %v", err)
+	}
+}
+
+func TestProjectStateRejectsInvalidNames(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	for _, name := range []string{"", "../etc", "-shop", "shop/web"} {
+		if _, err := LoadProjectState(name); err == nil || !strings.Contains(err.Error(), "invalid project name") {
+			t.Errorf("LoadProjectState(%q) error = %v, want the name rejected", name, err)
+		}
+	}
+}
+
+func TestUpdateProjectStateSerializesUpdates(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdateProjectState("shop", func(st *ProjectState) error {
+				st.Resources = append(st.Resources, ResourceRef{Type: "volume", Name: "v", ID: "v"})
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	st, err := LoadProjectState("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Resources) != 10 {
+		t.Errorf("recorded %d resources, want all 10 updates kept", len(st.Resources))
+	}
+}
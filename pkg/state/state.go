@@ -0,0 +1,98 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateDirEnv names the environment variable that overrides where state files are kept.
+const StateDirEnv = "MCP_STATE_DIR"
+
+// Dir returns the directory state files are stored in: $MCP_STATE_DIR when set, otherwise
+// an mcp-godocker directory under the user's cache directory (or the temp directory).
+func Dir() string {
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		return dir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "mcp-godocker")
+}
+
+// Checkpoint records which actions of a plan have completed, so that an interrupted apply
+// can be resumed without repeating them.
+type Checkpoint struct {
+	PlanHash  string       `json:"plan_hash"`
+	Completed map[int]bool `json:"completed"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// LoadCheckpoint returns the checkpoint for the plan with the given hash, or an empty one
+// when none has been recorded.
+func LoadCheckpoint(planHash string) (*Checkpoint, error) {
+	cp := &Checkpoint{PlanHash: planHash, Completed: map[int]bool{}}
+	data, err := os.ReadFile(checkpointPath(planHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if cp.Completed == nil {
+		cp.Completed = map[int]bool{}
+	}
+	return cp, nil
+}
+
+// SaveCheckpoint writes the checkpoint atomically.
+func SaveCheckpoint(cp *Checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	return writeFileAtomic(checkpointPath(cp.PlanHash), data)
+}
+
+// DeleteCheckpoint removes the checkpoint for a plan once it has fully completed.
+func DeleteCheckpoint(planHash string) error {
+	err := os.Remove(checkpointPath(planHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func checkpointPath(planHash string) string {
+	return filepath.Join(Dir(), "checkpoints", planHash+".json")
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	cp, err := LoadCheckpoint("abc")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if cp.PlanHash != "abc" || len(cp.Completed) != 0 {
+		t.Fatalf("LoadCheckpoint() = %+v, want an empty checkpoint", cp)
+	}
+	cp.Completed[0] = true
+	cp.Completed[2] = true
+	if err := SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	got, err := LoadCheckpoint("abc")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if len(got.Completed) != 2 || !got.Completed[0] || !got.Completed[2] || got.UpdatedAt.IsZero() {
+		t.Errorf("LoadCheckpoint() = %+v, want actions 0 and 2 completed", got)
+	}
+	if err := DeleteCheckpoint("abc"); err != nil {
+		t.Fatalf("DeleteCheckpoint() error = %v", err)
+	}
+	if err := DeleteCheckpoint("abc"); err != nil {
+		t.Errorf("DeleteCheckpoint() of a missing checkpoint error = %v", err)
+	}
+	if got, _ := LoadCheckpoint("abc"); len(got.Completed) != 0 {
+		t.Errorf("checkpoint survived deletion: %+v", got)
+	}
+}
+
+func TestLoadCheckpointRejectsCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(StateDirEnv, dir)
+	if err := os.MkdirAll(filepath.Join(dir, "checkpoints"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "checkpoints", "abc.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadCheckpoint("abc")
+	if err == nil || !strings.Contains(err.Error(), "failed to parse checkpoint") {
+		t.Errorf("LoadCheckpoint() error = %v, want a parse failure", err)
+	}
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// Phase is a project's position in the plan → approve → apply → observe loop.
+type Phase string
+
+const (
+	// PhasePlanning means a plan is being generated or revised for the project.
+	PhasePlanning Phase = "planning"
+	// PhaseAwaitingApproval means a plan exists and is waiting for the user to confirm it.
+	PhaseAwaitingApproval Phase = "awaiting_approval"
+	// PhaseApplying means a plan is being executed.
+	PhaseApplying Phase = "applying"
+	// PhaseConverged means the last plan was applied successfully.
+	PhaseConverged Phase = "converged"
+	// PhaseFailed means the last apply stopped with an error.
+	PhaseFailed Phase = "failed"
+)
+
+// phaseTransitions lists the phases each phase may move to. Applying a converged project
+// again, or going straight from planning to applying (confirmation skipped), are allowed;
+// Applying → Applying covers resuming an apply that was interrupted.
+var phaseTransitions = map[Phase][]Phase{
+	PhasePlanning:         {PhaseAwaitingApproval, PhaseApplying},
+	PhaseAwaitingApproval: {PhaseApplying, PhasePlanning},
+	PhaseApplying:         {PhaseConverged, PhaseFailed, PhaseApplying},
+	PhaseConverged:        {PhasePlanning, PhaseApplying},
+	PhaseFailed:           {PhasePlanning, PhaseApplying},
+}
+
+// maxWorkflowHistory bounds the transitions kept per project.
+const maxWorkflowHistory = 50
+
+// Transition records one phase change.
+type Transition struct {
+	From     Phase     `json:"from"`
+	To       Phase     `json:"to"`
+	PlanHash string    `json:"plan_hash,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Workflow is the persisted state of a project's plan/apply loop. A project with no
+// recorded workflow is in PhasePlanning.
+type Workflow struct {
+	Phase     Phase        `json:"phase"`
+	PlanHash  string       `json:"plan_hash,omitempty"`
+	Detail    string       `json:"detail,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	History   []Transition `json:"history,omitempty"`
+}
+
+// ParsePhase validates a phase name.
+func ParsePhase(name string) (Phase, error) {
+	p := Phase(name)
+	if _, ok := phaseTransitions[p]; !ok {
+		return "", fmt.Errorf("unknown phase %q: expected one of planning, awaiting_approval, applying, converged, failed", name)
+	}
+	return p, nil
+}
+
+// CanTransition reports whether a project may move from one phase to another.
+func CanTransition(from, to Phase) bool {
+	for _, next := range phaseTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadWorkflow returns a project's workflow, which starts in PhasePlanning.
+func LoadWorkflow(project string) (Workflow, error) {
+	st, err := LoadProjectState(project)
+	if err != nil {
+		return Workflow{}, err
+	}
+	return st.workflow(), nil
+}
+
+// AdvanceWorkflow moves a project to phase to, recording planHash and detail with the
+// transition. It fails without changing anything if the transition is not allowed.
+func AdvanceWorkflow(project string, to Phase, planHash, detail string) (Workflow, error) {
+	var wf Workflow
+	err := UpdateProjectState(project, func(st *ProjectState) error {
+		wf = st.workflow()
+		if !CanTransition(wf.Phase, to) {
+			return fmt.Errorf("project %s cannot move from %s to %s", project, wf.Phase, to)
+		}
+		now := time.Now().UTC()
+		wf.History = append(wf.History, Transition{From: wf.Phase, To: to, PlanHash: planHash, Detail: detail, At: now})
+		if len(wf.History) > maxWorkflowHistory {
+			wf.History = wf.History[len(wf.History)-maxWorkflowHistory:]
+		}
+		wf.Phase = to
+		wf.PlanHash = planHash
+		wf.Detail = detail
+		wf.UpdatedAt = now
+		st.Workflow = &wf
+		return nil
+	})
+	return wf, err
+}
+
+func (st *ProjectState) workflow() Workflow {
+	if st.Workflow == nil {
+		return Workflow{Phase: PhasePlanning}
+	}
+	return *st.Workflow
+}
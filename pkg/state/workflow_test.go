@@ -0,0 +1,119 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Phase
+		want     bool
+	}{
+		{PhasePlanning, PhaseAwaitingApproval, true},
+		{PhasePlanning, PhaseApplying, true},
+		{PhasePlanning, PhaseConverged, false},
+		{PhasePlanning, PhaseFailed, false},
+		{PhaseAwaitingApproval, PhaseApplying, true},
+		{PhaseAwaitingApproval, PhasePlanning, true},
+		{PhaseAwaitingApproval, PhaseConverged, false},
+		{PhaseApplying, PhaseConverged, true},
+		{PhaseApplying, PhaseFailed, true},
+		{PhaseApplying, PhaseApplying, true},
+		{PhaseApplying, PhasePlanning, false},
+		{PhaseConverged, PhasePlanning, true},
+		{PhaseConverged, PhaseApplying, true},
+		{PhaseConverged, PhaseFailed, false},
+		{PhaseFailed, PhaseApplying, true},
+		{PhaseFailed, PhaseConverged, false},
+		{Phase("unknown"), PhasePlanning, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestWorkflowLoop(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	wf, err := LoadWorkflow("shop")
+	if err != nil {
+		t.Fatalf("LoadWorkflow() error = %v", err)
+	}
+	if wf.Phase != PhasePlanning {
+		t.Fatalf("new project is %s, want planning", wf.Phase)
+	}
+
+	steps := []struct {
+		to      Phase
+		hash    string
+		detail  string
+		wantErr string
+	}{
+		{to: PhaseAwaitingApproval, hash: "p1"},
+		{to: PhaseConverged, hash: "p1", wantErr: "project shop cannot move from awaiting_approval to converged"},
+		{to: PhaseApplying, hash: "p1"},
+		{to: PhaseFailed, hash: "p1", detail: "image not found"},
+		{to: PhasePlanning},
+		{to: PhaseApplying, hash: "p2"},
+		{to: PhasePlanning, wantErr: "project shop cannot move from applying to planning"},
+		{to: PhaseConverged, hash: "p2"},
+	}
+	var applied int
+	for _, step := range steps {
+		wf, err := AdvanceWorkflow("shop", step.to, step.hash, step.detail)
+		if step.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), step.wantErr) {
+				t.Fatalf("AdvanceWorkflow(%s) error = %v, want one containing %q", step.to, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("AdvanceWorkflow(%s) error = %v", step.to, err)
+		}
+		applied++
+		if wf.Phase != step.to || wf.PlanHash != step.hash || wf.Detail != step.detail {
+			t.Errorf("AdvanceWorkflow(%s) = %+v", step.to, wf)
+		}
+	}
+
+	wf, err = LoadWorkflow("shop")
+	if err != nil {
+		t.Fatalf("LoadWorkflow() error = %v", err)
+	}
+	if wf.Phase != PhaseConverged || wf.PlanHash != "p2" {
+		t.Errorf("workflow = %+v, want converged on p2", wf)
+	}
+	if len(wf.History) != applied {
+		t.Fatalf("history has %d transitions, want %d; rejected moves must not be recorded", len(wf.History), applied)
+	}
+	if h := wf.History[2]; h.From != PhaseApplying || h.To != PhaseFailed || h.Detail != "image not found" {
+		t.Errorf("third transition = %+v, want applying → failed with the error", h)
+	}
+}
+
+func TestWorkflowHistoryIsBounded(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	for i := 0; i < maxWorkflowHistory+5; i++ {
+		if _, err := AdvanceWorkflow("shop", PhaseApplying, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wf, err := LoadWorkflow("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wf.History) != maxWorkflowHistory {
+		t.Errorf("history has %d transitions, want %d", len(wf.History), maxWorkflowHistory)
+	}
+}
+
+func TestParsePhase(t *testing.T) {
+	if p, err := ParsePhase("awaiting_approval"); err != nil || p != PhaseAwaitingApproval {
+		t.Errorf("ParsePhase(awaiting_approval) = %v, %v", p, err)
+	}
+	if _, err := ParsePhase("done"); err == nil || !strings.Contains(err.Error(), `unknown phase "done"`) {
+		t.Errorf("ParsePhase(done) error = %v, want it rejected", err)
+	}
+}
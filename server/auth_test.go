@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name   string
+		token  string
+		path   string
+		header string
+		want   int
+	}{
+		{name: "authorized", token: "s3cret", path: "/rpc", header: "Bearer s3cret", want: http.StatusNoContent},
+		{name: "missing header", token: "s3cret", path: "/rpc", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cret", path: "/rpc", header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "s3cret", path: "/plan/upload", header: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "no token configured", path: "/rpc", want: http.StatusNoContent},
+		{name: "healthz exempt", token: "s3cret", path: "/healthz", want: http.StatusNoContent},
+		{name: "readyz exempt", token: "s3cret", path: "/readyz", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(tt.token, ok).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// coerceParameters returns a copy of params with values converted to the JSON-schema types
+// the tool's input schema declares, so handlers see the types they assert on even when the
+// model writes a port as "3306" or a tag as 8. Numbers (integer or number) are always
+// float64, as encoding/json produces. A value that should be a number or boolean but cannot
+// be read as one is an error; other mismatches are left for the handler to report.
+func coerceParameters(params map[string]interface{}, schema map[string]interface{}) (map[string]interface{}, error) {
+	if params == nil {
+		return nil, nil
+	}
+	out, err := coerceValue("", params, schema)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]interface{}), nil
+}
+
+// coerceValue converts v to the type schema declares. path names v in error messages.
+func coerceValue(path string, v interface{}, schema map[string]interface{}) (interface{}, error) {
+	if schema == nil || v == nil {
+		return v, nil
+	}
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "integer", "number":
+		n, ok := toNumber(v)
+		if !ok {
+			kind := "a number"
+			if typ == "integer" {
+				kind = "an integer"
+			}
+			return nil, fmt.Errorf("parameter %s must be %s, got %s", path, kind, describeValue(v))
+		}
+		if typ == "integer" && n != math.Trunc(n) {
+			return nil, fmt.Errorf("parameter %s must be an integer, got %v", path, n)
+		}
+		return n, nil
+	case "boolean":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, fmt.Errorf("parameter %s must be a boolean, got %s", path, describeValue(v))
+	case "string":
+		switch s := v.(type) {
+		case float64:
+			return strconv.FormatFloat(s, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(s), nil
+		}
+		return v, nil
+	case "array":
+		list, ok := v.([]interface{})
+		items, _ := schema["items"].(map[string]interface{})
+		if !ok || items == nil {
+			return v, nil
+		}
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			coerced, err := coerceValue(fmt.Sprintf("%s[%d]", path, i), item, items)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = coerced
+		}
+		return out, nil
+	case "object", "":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		out := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			propSchema, _ := properties[key].(map[string]interface{})
+			if propSchema == nil {
+				propSchema = additional
+			}
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			coerced, err := coerceValue(name, value, propSchema)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = coerced
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// toNumber reads v as a number, accepting numeric strings.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
+
+// describeValue renders v for an error message: strings quoted, other types by kind.
+func describeValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoerceParameters(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"port":    map[string]interface{}{"type": "integer"},
+			"cpus":    map[string]interface{}{"type": "number"},
+			"detach":  map[string]interface{}{"type": "boolean"},
+			"tag":     map[string]interface{}{"type": "string"},
+			"ports":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			"limits":  map[string]interface{}{"type": "object", "properties": map[string]interface{}{"memory_mb": map[string]interface{}{"type": "integer"}}},
+			"labels":  map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			"command": map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"type": "string"}, map[string]interface{}{"type": "array"}}},
+		},
+	}
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{name: "nil params", params: nil, want: nil},
+		{name: "numeric string to integer", params: map[string]interface{}{"port": "3306"}, want: map[string]interface{}{"port": float64(3306)}},
+		{name: "padded numeric string", params: map[string]interface{}{"cpus": " 1.5 "}, want: map[string]interface{}{"cpus": 1.5}},
+		{name: "number stays a number", params: map[string]interface{}{"port": float64(80)}, want: map[string]interface{}{"port": float64(80)}},
+		{name: "fractional integer", params: map[string]interface{}{"port": 80.5}, wantErr: "parameter port must be an integer"},
+		{name: "word for a number", params: map[string]interface{}{"port": "http"}, wantErr: `parameter port must be an integer, got "http"`},
+		{name: "NaN is not a number", params: map[string]interface{}{"cpus": "NaN"}, wantErr: "parameter cpus must be a number"},
+		{name: "boolean string", params: map[string]interface{}{"detach": "true"}, want: map[string]interface{}{"detach": true}},
+		{name: "boolean digit", params: map[string]interface{}{"detach": "0"}, want: map[string]interface{}{"detach": false}},
+		{name: "word for a boolean", params: map[string]interface{}{"detach": "yes please"}, wantErr: "parameter detach must be a boolean"},
+		{name: "number to string", params: map[string]interface{}{"tag": float64(8)}, want: map[string]interface{}{"tag": "8"}},
+		{name: "boolean to string", params: map[string]interface{}{"tag": true}, want: map[string]interface{}{"tag": "true"}},
+		{name: "array items", params: map[string]interface{}{"ports": []interface{}{"80", float64(443)}}, want: map[string]interface{}{"ports": []interface{}{float64(80), float64(443)}}},
+		{name: "array item error names the index", params: map[string]interface{}{"ports": []interface{}{"80", "x"}}, wantErr: "parameter ports[1]"},
+		{name: "non-array left for the handler", params: map[string]interface{}{"ports": "80"}, want: map[string]interface{}{"ports": "80"}},
+		{name: "nested object", params: map[string]interface{}{"limits": map[string]interface{}{"memory_mb": "512"}}, want: map[string]interface{}{"limits": map[string]interface{}{"memory_mb": float64(512)}}},
+		{name: "nested error names the path", params: map[string]interface{}{"limits": map[string]interface{}{"memory_mb": "lots"}}, wantErr: "parameter limits.memory_mb"},
+		{name: "additional properties", params: map[string]interface{}{"labels": map[string]interface{}{"replicas": float64(3)}}, want: map[string]interface{}{"labels": map[string]interface{}{"replicas": "3"}}},
+		{name: "oneOf string passes through", params: map[string]interface{}{"command": "sh -c 'echo hi'"}, want: map[string]interface{}{"command": "sh -c 'echo hi'"}},
+		{name: "oneOf array passes through", params: map[string]interface{}{"command": []interface{}{"echo", "hi"}}, want: map[string]interface{}{"command": []interface{}{"echo", "hi"}}},
+		{name: "unknown parameter kept", params: map[string]interface{}{"extra": "3306"}, want: map[string]interface{}{"extra": "3306"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceParameters(tt.params, schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("coerceParameters() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("coerceParameters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coerceParameters() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceParametersDoesNotModifyInput(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"port": map[string]interface{}{"type": "integer"}}}
+	params := map[string]interface{}{"port": "3306"}
+	if _, err := coerceParameters(params, schema); err != nil {
+		t.Fatal(err)
+	}
+	if params["port"] != "3306" {
+		t.Errorf("input was modified: %#v", params)
+	}
+}
+
+func TestCoerceParametersWithToolSchemas(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		tool   string
+		params map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			tool:   "create_container",
+			params: map[string]interface{}{"name": "db", "image": "mysql", "max_retries": "3", "memory_mb": "512", "command": "mysqld --skip-name-resolve"},
+			want:   map[string]interface{}{"name": "db", "image": "mysql", "max_retries": float64(3), "memory_mb": float64(512), "command": "mysqld --skip-name-resolve"},
+		},
+		{
+			tool:   "pull_image",
+			params: map[string]interface{}{"name": "redis", "tag": float64(7)},
+			want:   map[string]interface{}{"name": "redis", "tag": "7"},
+		},
+		{
+			tool:   "scale_service",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "replicas": "3", "entrypoint": []interface{}{"nginx", "-g", "daemon off;"}},
+			want:   map[string]interface{}{"name": "web", "image": "nginx", "replicas": float64(3), "entrypoint": []interface{}{"nginx", "-g", "daemon off;"}},
+		},
+		{
+			tool:   "update_container",
+			params: map[string]interface{}{"name": "web", "memory_mb": " 256 "},
+			want:   map[string]interface{}{"name": "web", "memory_mb": float64(256)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			tool, ok := s.tools[tt.tool]
+			if !ok {
+				t.Fatalf("tool %s is not registered", tt.tool)
+			}
+			got, err := coerceParameters(tt.params, tool.InputSchema)
+			if err != nil {
+				t.Fatalf("coerceParameters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coerceParameters() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunToolCoercesParameters(t *testing.T) {
+	s := newTestServer(t, nil)
+	var got map[string]interface{}
+	s.RegisterTool("echo", "Echo the parameters", map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+	}, func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+		got = params
+		return params, nil
+	})
+	if _, err := s.runTool(context.Background(), s.tools["echo"], map[string]interface{}{"count": "2"}); err != nil {
+		t.Fatalf("runTool() error = %v", err)
+	}
+	if got["count"] != float64(2) {
+		t.Errorf("handler saw count = %#v, want 2", got["count"])
+	}
+	got = nil
+	_, err := s.runTool(context.Background(), s.tools["echo"], map[string]interface{}{"count": "two"})
+	if err == nil || !strings.Contains(err.Error(), "parameter count must be") {
+		t.Errorf("runTool() error = %v, want a coercion error", err)
+	}
+	if got != nil {
+		t.Errorf("handler ran with %v despite the coercion error", got)
+	}
+}
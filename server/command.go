@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+)
+
+// parseProcessConfig reads the command, entrypoint, working_dir, user and labels parameters
+// of create_container into config. Labels are added to those already on config, which keep
+// precedence so a plan cannot relabel a container into another project.
+func parseProcessConfig(params map[string]interface{}, config *container.Config) error {
+	var err error
+	if config.Cmd, err = commandParam(params, "command"); err != nil {
+		return err
+	}
+	if config.Entrypoint, err = commandParam(params, "entrypoint"); err != nil {
+		return err
+	}
+	if v, ok := params["working_dir"]; ok && v != nil {
+		dir, ok := v.(string)
+		if !ok || !strings.HasPrefix(dir, "/") {
+			return fmt.Errorf("working_dir must be an absolute path inside the container, got %v", v)
+		}
+		config.WorkingDir = dir
+	}
+	if v, ok := params["user"]; ok && v != nil {
+		user, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("user must be a string such as \"1000\" or \"1000:1000\", got %T", v)
+		}
+		config.User = user
+	}
+	labels, err := stringMapParam(params, "labels")
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	config.Labels = labels
+	return nil
+}
+
+// commandParam reads a command given either as a list of arguments or as a string, which is
+// split into arguments the way a shell would split it (without expanding anything), so
+// "sh -c 'echo hello'" becomes ["sh", "-c", "echo hello"].
+func commandParam(params map[string]interface{}, key string) (strslice.StrSlice, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		args, err := splitCommand(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return args, nil
+	case []interface{}:
+		args := make(strslice.StrSlice, 0, len(v))
+		for _, item := range v {
+			arg, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s entries must be strings, got %v", key, item)
+			}
+			args = append(args, arg)
+		}
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("%s must be a string or a list of strings, got %T", key, v)
+	}
+}
+
+// splitCommand splits s into words at unquoted whitespace. Single quotes keep everything up
+// to the closing quote; double quotes keep everything but let a backslash escape \ and ";
+// outside quotes a backslash escapes the next character.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", s)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated double quote in %q", s)
+			}
+			inWord = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr string
+	}{
+		{name: "empty", s: "", want: nil},
+		{name: "whitespace only", s: " \t\n", want: nil},
+		{name: "words", s: "nginx -g daemon", want: []string{"nginx", "-g", "daemon"}},
+		{name: "repeated whitespace", s: "  echo \t hi\n", want: []string{"echo", "hi"}},
+		{name: "single quotes", s: "sh -c 'echo hello world'", want: []string{"sh", "-c", "echo hello world"}},
+		{name: "single quotes are literal", s: `echo '$HOME \n "x"'`, want: []string{"echo", `$HOME \n "x"`}},
+		{name: "double quotes", s: `nginx -g "daemon off;"`, want: []string{"nginx", "-g", "daemon off;"}},
+		{name: "escapes in double quotes", s: `echo "say \"hi\" \\ \n"`, want: []string{"echo", `say "hi" \ \n`}},
+		{name: "backslash outside quotes", s: `touch my\ file`, want: []string{"touch", "my file"}},
+		{name: "quotes join a word", s: `--name='a b'"c d"e`, want: []string{"--name=a bc de"}},
+		{name: "empty quoted argument", s: `echo '' ""`, want: []string{"echo", "", ""}},
+		{name: "trailing backslash kept", s: `echo a\`, want: []string{"echo", `a\`}},
+		{name: "unterminated single quote", s: "sh -c 'echo", wantErr: "unterminated single quote"},
+		{name: "unterminated double quote", s: `sh -c "echo`, wantErr: "unterminated double quote"},
+		{name: "escaped closing double quote", s: `echo "a\"`, wantErr: "unterminated double quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCommand(tt.s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("splitCommand(%q) error = %v, want one containing %q", tt.s, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitCommand(%q) error = %v", tt.s, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    strslice.StrSlice
+		wantErr string
+	}{
+		{name: "missing", value: nil, want: nil},
+		{name: "string", value: "sh -c 'echo hi'", want: strslice.StrSlice{"sh", "-c", "echo hi"}},
+		{name: "empty string", value: "  ", want: nil},
+		{name: "list", value: []interface{}{"nginx", "-g", "daemon off;"}, want: strslice.StrSlice{"nginx", "-g", "daemon off;"}},
+		{name: "empty list", value: []interface{}{}, want: nil},
+		{name: "list with a number", value: []interface{}{"sleep", float64(5)}, wantErr: "command entries must be strings"},
+		{name: "wrong type", value: float64(1), wantErr: "command must be a string or a list of strings"},
+		{name: "error names the parameter", value: "echo 'hi", wantErr: "command: unterminated single quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]interface{}{}
+			if tt.value != nil {
+				params["command"] = tt.value
+			}
+			got, err := commandParam(params, "command")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("commandParam() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commandParam() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commandParam() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProcessConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		labels  map[string]string
+		want    container.Config
+		wantErr string
+	}{
+		{name: "nothing set", params: map[string]interface{}{}, want: container.Config{}},
+		{
+			name: "all fields",
+			params: map[string]interface{}{
+				"command":     "mysqld --skip-name-resolve",
+				"entrypoint":  []interface{}{"docker-entrypoint.sh"},
+				"working_dir": "/srv/app",
+				"user":        "1000:1000",
+				"labels":      map[string]interface{}{"tier": "db"},
+			},
+			want: container.Config{
+				Cmd:        strslice.StrSlice{"mysqld", "--skip-name-resolve"},
+				Entrypoint: strslice.StrSlice{"docker-entrypoint.sh"},
+				WorkingDir: "/srv/app",
+				User:       "1000:1000",
+				Labels:     map[string]string{"tier": "db"},
+			},
+		},
+		{
+			name:   "existing labels keep precedence",
+			params: map[string]interface{}{"labels": map[string]interface{}{"tier": "db", "mcp.project": "other"}},
+			labels: map[string]string{"mcp.project": "shop"},
+			want:   container.Config{Labels: map[string]string{"tier": "db", "mcp.project": "shop"}},
+		},
+		{
+			name:   "no labels leaves existing ones",
+			params: map[string]interface{}{},
+			labels: map[string]string{"mcp.project": "shop"},
+			want:   container.Config{Labels: map[string]string{"mcp.project": "shop"}},
+		},
+		{name: "relative working_dir", params: map[string]interface{}{"working_dir": "app"}, wantErr: "working_dir must be an absolute path"},
+		{name: "non-string working_dir", params: map[string]interface{}{"working_dir": float64(1)}, wantErr: "working_dir must be an absolute path"},
+		{name: "non-string user", params: map[string]interface{}{"user": float64(1000)}, wantErr: "user must be a string"},
+		{name: "non-string label", params: map[string]interface{}{"labels": map[string]interface{}{"replicas": float64(3)}}, wantErr: "labels.replicas must be a string"},
+		{name: "bad entrypoint", params: map[string]interface{}{"entrypoint": `"sh`}, wantErr: "entrypoint: unterminated double quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := container.Config{Labels: tt.labels}
+			err := parseProcessConfig(tt.params, &config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseProcessConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcessConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.want) {
+				t.Errorf("parseProcessConfig() = %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateContainerSendsProcessConfig(t *testing.T) {
+	var created container.Config
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/containers/create" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "abc123"}`)
+	})
+	params := map[string]interface{}{
+		"name":        "db",
+		"image":       "mysql:8",
+		"idempotent":  false,
+		"command":     "mysqld --character-set-server='utf8mb4'",
+		"entrypoint":  []interface{}{"docker-entrypoint.sh"},
+		"working_dir": "/var/lib/mysql",
+		"user":        float64(999),
+		"labels":      map[string]interface{}{"tier": "db"},
+	}
+	reply, err := s.runTool(context.Background(), s.tools["create_container"], params)
+	if err != nil {
+		t.Fatalf("create_container: %v", err)
+	}
+	if reply["id"] != "abc123" {
+		t.Errorf("reply = %v, want the created id", reply)
+	}
+	want := container.Config{
+		Image:      "mysql:8",
+		Cmd:        strslice.StrSlice{"mysqld", "--character-set-server=utf8mb4"},
+		Entrypoint: strslice.StrSlice{"docker-entrypoint.sh"},
+		WorkingDir: "/var/lib/mysql",
+		User:       "999",
+		Labels:     map[string]string{"tier": "db"},
+	}
+	got := container.Config{
+		Image:      created.Image,
+		Cmd:        created.Cmd,
+		Entrypoint: created.Entrypoint,
+		WorkingDir: created.WorkingDir,
+		User:       created.User,
+		Labels:     created.Labels,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("daemon received %+v, want %+v", got, want)
+	}
+}
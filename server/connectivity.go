@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// commandUnavailable reports whether an exec exit code means the probe command could not be
+// run at all (not found or not executable) rather than that the probe failed.
+func commandUnavailable(exitCode int) bool {
+	return exitCode == 126 || exitCode == 127
+}
+
+// testConnectivityHandler checks that the "from" container can reach the "to" container. It
+// pings the target when no port is given and ping is available; otherwise it dials a TCP
+// port (the given one, or the first one the target exposes) with nc or bash's /dev/tcp.
+func testConnectivityHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	from, _ := params["from"].(string)
+	to, _ := params["to"].(string)
+	if from == "" || to == "" {
+		return nil, errors.New("test_connectivity requires from and to container names")
+	}
+	source, err := docker.FindContainer(ctx, s.dockerClient, from)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, fmt.Errorf("container %s does not exist", from)
+	}
+	target, err := docker.FindContainer(ctx, s.dockerClient, to)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, fmt.Errorf("container %s does not exist", to)
+	}
+	if source.State == nil || !source.State.Running {
+		return nil, fmt.Errorf("container %s is not running", from)
+	}
+
+	var sourceNetworks, targetNetworks map[string]*network.EndpointSettings
+	if source.NetworkSettings != nil {
+		sourceNetworks = source.NetworkSettings.Networks
+	}
+	if target.NetworkSettings != nil {
+		targetNetworks = target.NetworkSettings.Networks
+	}
+	shared := sharedNetworks(sourceNetworks, targetNetworks)
+	if len(shared) == 0 {
+		return nil, fmt.Errorf("containers %s and %s share no network, so %s cannot reach %s", from, to, from, to)
+	}
+
+	port := 0
+	if n, ok, err := numberParam(params, "port"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 1 || n > 65535 {
+			return nil, fmt.Errorf("port must be between 1 and 65535, got %v", n)
+		}
+		port = int(n)
+	}
+
+	result := map[string]interface{}{"from": from, "to": to, "networks": shared}
+	if port == 0 {
+		exitCode, output, err := docker.ExecCommand(ctx, s.dockerClient, from, []string{"ping", "-c", "1", "-W", "2", to})
+		if err != nil {
+			return nil, err
+		}
+		if !commandUnavailable(exitCode) {
+			result["method"] = "ping"
+			return connectivityResult(result, exitCode, output)
+		}
+		// No ping in the image: fall back to dialing a port the target exposes.
+		if target.Config != nil {
+			port = firstExposedPort(target.Config.ExposedPorts)
+		}
+		if port == 0 {
+			return nil, fmt.Errorf("ping is not available in %s and %s exposes no TCP port to dial; pass a port", from, to)
+		}
+	}
+
+	probes := [][]string{
+		{"nc", "-z", "-w", "2", to, strconv.Itoa(port)},
+		{"bash", "-c", fmt.Sprintf("exec 3<>/dev/tcp/%s/%d", to, port)},
+	}
+	for _, probe := range probes {
+		exitCode, output, err := docker.ExecCommand(ctx, s.dockerClient, from, probe)
+		if err != nil {
+			return nil, err
+		}
+		if commandUnavailable(exitCode) {
+			continue
+		}
+		result["method"] = "tcp:" + probe[0]
+		result["port"] = port
+		return connectivityResult(result, exitCode, output)
+	}
+	return nil, fmt.Errorf("neither nc nor bash is available in %s to dial %s:%d", from, to, port)
+}
+
+// connectivityResult turns a probe's exit code into the tool result, failing the tool when
+// the target was unreachable so that a plan verifying its deployment stops there.
+func connectivityResult(result map[string]interface{}, exitCode int, output string) (map[string]interface{}, error) {
+	result["output"] = strings.TrimSpace(output)
+	if exitCode != 0 {
+		return nil, fmt.Errorf("%s cannot reach %s via %s (exit code %d): %s", result["from"], result["to"], result["method"], exitCode, strings.TrimSpace(output))
+	}
+	result["reachable"] = true
+	return result, nil
+}
+
+// sharedNetworks returns the sorted names of the networks present in both endpoint maps.
+func sharedNetworks(a, b map[string]*network.EndpointSettings) []string {
+	var shared []string
+	for name := range a {
+		if _, ok := b[name]; ok {
+			shared = append(shared, name)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
+
+// firstExposedPort returns the lowest TCP port in an ExposedPorts set, or 0 if there is none.
+func firstExposedPort(exposed nat.PortSet) int {
+	lowest := 0
+	for p := range exposed {
+		if p.Proto() != "tcp" {
+			continue
+		}
+		if n := p.Int(); n > 0 && (lowest == 0 || n < lowest) {
+			lowest = n
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// execResult is the canned outcome of running a command in a fake container.
+type execResult struct {
+	exitCode int
+	output   string
+}
+
+// connectivityDaemon serves two containers, web and db, attached to the given networks,
+// with web running. Exec'd commands are answered from results by their first word, with
+// anything unlisted reported as not found (exit code 127); every command run is recorded.
+func connectivityDaemon(t *testing.T, webNetworks, dbNetworks []string, webRunning bool, results map[string]execResult, ran *[][]string) http.HandlerFunc {
+	var mu sync.Mutex
+	execs := map[string][]string{}
+	endpoints := func(names []string) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, n := range names {
+			m[n] = map[string]interface{}{}
+		}
+		return m
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id": "c1", "Name": "/web",
+				"State":           map[string]interface{}{"Running": webRunning},
+				"NetworkSettings": map[string]interface{}{"Networks": endpoints(webNetworks)},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/db/json":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id": "c2", "Name": "/db",
+				"State":           map[string]interface{}{"Running": true},
+				"Config":          map[string]interface{}{"ExposedPorts": map[string]struct{}{"5432/tcp": {}, "53/udp": {}}},
+				"NetworkSettings": map[string]interface{}{"Networks": endpoints(dbNetworks)},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/containers/web/exec":
+			var req struct{ Cmd []string }
+			json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			id := fmt.Sprintf("e%d", len(execs))
+			execs[id] = req.Cmd
+			*ran = append(*ran, req.Cmd)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"Id": id})
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/exec/") && strings.HasSuffix(r.URL.Path, "/start"):
+			mu.Lock()
+			cmd := execs[strings.Split(r.URL.Path, "/")[2]]
+			mu.Unlock()
+			hijackWithOutput(t, w, results[cmd[0]].output)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/exec/"):
+			mu.Lock()
+			cmd := execs[strings.Split(r.URL.Path, "/")[2]]
+			mu.Unlock()
+			res, ok := results[cmd[0]]
+			if !ok {
+				res.exitCode = 127
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"ExitCode": res.exitCode})
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+// hijackWithOutput answers an exec attach the way the daemon does: it upgrades the
+// connection and writes output as a multiplexed stdout frame.
+func hijackWithOutput(t *testing.T, w http.ResponseWriter, output string) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	if output != "" {
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(output)))
+		buf.Write(header)
+		buf.WriteString(output)
+	}
+	buf.Flush()
+}
+
+func TestTestConnectivity(t *testing.T) {
+	shared := []string{"shop-back", "shop-front"}
+	tests := []struct {
+		name        string
+		params      map[string]interface{}
+		dbNetworks  []string
+		webStopped  bool
+		results     map[string]execResult
+		want        map[string]interface{}
+		wantErr     string
+		wantCommand []string
+	}{
+		{
+			name:        "ping",
+			params:      map[string]interface{}{"from": "web", "to": "db"},
+			results:     map[string]execResult{"ping": {0, "1 packets received\n"}},
+			want:        map[string]interface{}{"from": "web", "to": "db", "networks": shared, "method": "ping", "output": "1 packets received", "reachable": true},
+			wantCommand: []string{"ping", "-c", "1", "-W", "2", "db"},
+		},
+		{
+			name:        "no ping falls back to an exposed port",
+			params:      map[string]interface{}{"from": "web", "to": "db"},
+			results:     map[string]execResult{"nc": {0, ""}},
+			want:        map[string]interface{}{"from": "web", "to": "db", "networks": shared, "method": "tcp:nc", "port": 5432, "output": "", "reachable": true},
+			wantCommand: []string{"nc", "-z", "-w", "2", "db", "5432"},
+		},
+		{
+			name:        "given port without nc",
+			params:      map[string]interface{}{"from": "web", "to": "db", "port": float64(8080)},
+			results:     map[string]execResult{"bash": {0, ""}},
+			want:        map[string]interface{}{"from": "web", "to": "db", "networks": shared, "method": "tcp:bash", "port": 8080, "output": "", "reachable": true},
+			wantCommand: []string{"bash", "-c", "exec 3<>/dev/tcp/db/8080"},
+		},
+		{
+			name:    "unreachable",
+			params:  map[string]interface{}{"from": "web", "to": "db"},
+			results: map[string]execResult{"ping": {1, "0 packets received\n"}},
+			wantErr: "web cannot reach db via ping (exit code 1): 0 packets received",
+		},
+		{
+			name:    "no probe available",
+			params:  map[string]interface{}{"from": "web", "to": "db", "port": float64(80)},
+			wantErr: "neither nc nor bash is available in web to dial db:80",
+		},
+		{
+			name:       "no shared network",
+			params:     map[string]interface{}{"from": "web", "to": "db"},
+			dbNetworks: []string{"other"},
+			wantErr:    "containers web and db share no network",
+		},
+		{
+			name:       "source not running",
+			params:     map[string]interface{}{"from": "web", "to": "db"},
+			webStopped: true,
+			wantErr:    "container web is not running",
+		},
+		{
+			name:    "missing target",
+			params:  map[string]interface{}{"from": "web", "to": "cache"},
+			wantErr: "container cache does not exist",
+		},
+		{
+			name:    "port out of range",
+			params:  map[string]interface{}{"from": "web", "to": "db", "port": float64(70000)},
+			wantErr: "port must be between 1 and 65535",
+		},
+		{
+			name:    "missing names",
+			params:  map[string]interface{}{"from": "web"},
+			wantErr: "test_connectivity requires from and to container names",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbNetworks := tt.dbNetworks
+			if dbNetworks == nil {
+				dbNetworks = shared
+			}
+			var ran [][]string
+			s := newTestServer(t, connectivityDaemon(t, shared, dbNetworks, !tt.webStopped, tt.results, &ran))
+			got, err := s.tools["test_connectivity"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("test_connectivity error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("test_connectivity: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("test_connectivity = %v, want %v", got, tt.want)
+			}
+			if len(ran) == 0 || !reflect.DeepEqual(ran[len(ran)-1], tt.wantCommand) {
+				t.Errorf("ran %q, want the last probe to be %q", ran, tt.wantCommand)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// Defaults for WatchConverge.
+const (
+	defaultConvergeInterval = 30 * time.Second
+	minConvergeInterval     = time.Second
+	maxConvergeEvents       = 100
+)
+
+// ConvergeArgs are the parameters of the WatchConverge RPC. MaxIterations of zero keeps
+// reconciling until StopConverge is called or the server stops.
+type ConvergeArgs struct {
+	Project         string  `json:"project"`
+	IntervalSeconds float64 `json:"interval_seconds,omitempty"`
+	MaxIterations   int     `json:"max_iterations,omitempty"`
+}
+
+// Drift is a difference between a project's last applied plan and what is running.
+type Drift struct {
+	Index  int    `json:"index"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ConvergeEvent reports one reconciliation pass.
+type ConvergeEvent struct {
+	Iteration int       `json:"iteration"`
+	At        time.Time `json:"at"`
+	Drift     []Drift   `json:"drift"`
+	Repaired  int       `json:"repaired"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ConvergeStatus describes a project's reconciliation loop.
+type ConvergeStatus struct {
+	Project    string          `json:"project"`
+	Running    bool            `json:"running"`
+	Interval   string          `json:"interval"`
+	Iterations int             `json:"iterations"`
+	Events     []ConvergeEvent `json:"events"`
+}
+
+// convergeWatcher is the reconciliation loop of one project.
+type convergeWatcher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status ConvergeStatus
+}
+
+// convergers holds the running reconciliation loops, keyed by project.
+type convergers struct {
+	mu       sync.Mutex
+	watchers map[string]*convergeWatcher
+}
+
+// WatchConverge starts reconciling a project against its last applied plan every interval,
+// replacing any loop already running for it. Each pass re-applies only the actions whose
+// resources have drifted: a missing network, volume or container is created again and a
+// container the plan ran is started again if it is not running.
+func (s *Server) WatchConverge(args *ConvergeArgs, reply *ConvergeStatus) error {
+	if args == nil || args.Project == "" {
+		return errors.New("WatchConverge requires a project name")
+	}
+	if args.MaxIterations < 0 {
+		return fmt.Errorf("max_iterations must not be negative, got %d", args.MaxIterations)
+	}
+	interval := defaultConvergeInterval
+	if args.IntervalSeconds != 0 {
+		interval = time.Duration(args.IntervalSeconds * float64(time.Second))
+		if interval < minConvergeInterval {
+			return fmt.Errorf("interval_seconds must be at least %v", minConvergeInterval.Seconds())
+		}
+	}
+	st, err := state.LoadProjectState(args.Project)
+	if err != nil {
+		return err
+	}
+	if len(st.Plans) == 0 {
+		return fmt.Errorf("project %s has no applied plan to converge to", args.Project)
+	}
+
+	s.StopConverge(&args.Project, &ConvergeStatus{})
+	ctx, cancel := context.WithCancel(s.ctx)
+	w := &convergeWatcher{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: ConvergeStatus{Project: args.Project, Running: true, Interval: interval.String(), Events: []ConvergeEvent{}},
+	}
+	s.convergers.mu.Lock()
+	if s.convergers.watchers == nil {
+		s.convergers.watchers = map[string]*convergeWatcher{}
+	}
+	s.convergers.watchers[args.Project] = w
+	s.convergers.mu.Unlock()
+
+	go s.runConverge(ctx, w, args.Project, interval, args.MaxIterations)
+	*reply = w.snapshot()
+	return nil
+}
+
+// StopConverge stops a project's reconciliation loop and returns its final status.
+func (s *Server) StopConverge(args *string, reply *ConvergeStatus) error {
+	if args == nil || *args == "" {
+		return errors.New("StopConverge requires a project name")
+	}
+	s.convergers.mu.Lock()
+	w := s.convergers.watchers[*args]
+	delete(s.convergers.watchers, *args)
+	s.convergers.mu.Unlock()
+	if w == nil {
+		*reply = ConvergeStatus{Project: *args, Events: []ConvergeEvent{}}
+		return nil
+	}
+	w.cancel()
+	<-w.done
+	*reply = w.snapshot()
+	return nil
+}
+
+// ConvergeStatus returns the state and recent events of a project's reconciliation loop.
+func (s *Server) ConvergeStatus(args *string, reply *ConvergeStatus) error {
+	if args == nil || *args == "" {
+		return errors.New("ConvergeStatus requires a project name")
+	}
+	s.convergers.mu.Lock()
+	w := s.convergers.watchers[*args]
+	s.convergers.mu.Unlock()
+	if w == nil {
+		*reply = ConvergeStatus{Project: *args, Events: []ConvergeEvent{}}
+		return nil
+	}
+	*reply = w.snapshot()
+	return nil
+}
+
+func (s *Server) runConverge(ctx context.Context, w *convergeWatcher, project string, interval time.Duration, maxIterations int) {
+	defer close(w.done)
+	defer func() {
+		w.mu.Lock()
+		w.status.Running = false
+		w.mu.Unlock()
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for iteration := 1; maxIterations == 0 || iteration <= maxIterations; iteration++ {
+		event := s.reconcile(ctx, project)
+		event.Iteration = iteration
+		if ctx.Err() != nil {
+			return
+		}
+		w.record(event)
+		if event.Error != "" {
+			log.Printf("[Converge] %s pass %d: %d drifted, %d repaired, error: %s", project, iteration, len(event.Drift), event.Repaired, event.Error)
+		} else if len(event.Drift) > 0 {
+			log.Printf("[Converge] %s pass %d: %d drifted, %d repaired", project, iteration, len(event.Drift), event.Repaired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile compares a project's last applied plan with the running resources and re-applies
+// the drifted actions in plan order, each checked against the current policy first. A pass
+// holds the project's lock and is bounded like a plan; while another plan holds the project
+// the pass is skipped and reported busy.
+func (s *Server) reconcile(ctx context.Context, project string) ConvergeEvent {
+	event := ConvergeEvent{At: time.Now().UTC(), Drift: []Drift{}}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.PlanDeadline(false))
+	defer cancel()
+	unlock, err := s.projects.lock(ctx, project, onBusyFail)
+	if err != nil {
+		event.Error = fmt.Sprintf("skipped: project %s is busy applying another plan", project)
+		return event
+	}
+	defer unlock()
+	st, err := state.LoadProjectState(project)
+	if err != nil {
+		event.Error = err.Error()
+		return event
+	}
+	if len(st.Plans) == 0 {
+		event.Error = "no applied plan"
+		return event
+	}
+	plan := st.Plans[len(st.Plans)-1].Plan
+	if event.Drift, err = s.detectDrift(ctx, plan); err != nil {
+		event.Error = err.Error()
+		return event
+	}
+
+	ctx = withProject(ctx, project)
+	var created []state.ResourceRef
+	for _, d := range event.Drift {
+		parameters, _ := plan[d.Index]["parameters"].(map[string]interface{})
+		tool, ok := s.tools[d.Action]
+		if !ok {
+			event.Error = s.unknownToolMessage("action", d.Action)
+			break
+		}
+		// The policy may have been reloaded since the plan was applied.
+		if err := s.checkPolicy(d.Action, parameters); err != nil {
+			event.Error = fmt.Sprintf("refusing to repair %s %s: %v", d.Action, d.Name, err)
+			break
+		}
+		out, err := s.runTool(ctx, tool, parameters)
+		if err != nil {
+			event.Error = fmt.Sprintf("failed to repair %s %s: %v", d.Action, d.Name, err)
+			break
+		}
+		event.Repaired++
+		if ref, ok := createdResource(d.Action, parameters, out); ok {
+			created = append(created, ref)
+		}
+	}
+	if len(created) > 0 {
+		if err := state.UpdateProjectState(project, func(st *state.ProjectState) error {
+			st.Resources = replaceResources(st.Resources, created)
+			return nil
+		}); err != nil {
+			log.Printf("[Converge] Failed to record recreated resources for project %s: %v", project, err)
+		}
+	}
+	return event
+}
+
+// detectDrift returns the actions of plan whose resources no longer match it.
+func (s *Server) detectDrift(ctx context.Context, plan []map[string]interface{}) ([]Drift, error) {
+	drift := []Drift{}
+	for i, action := range plan {
+		actionType, _ := action["action"].(string)
+		parameters, _ := action["parameters"].(map[string]interface{})
+		name, _ := parameters["name"].(string)
+		if name == "" {
+			continue
+		}
+		var reason string
+		switch actionType {
+		case "create_network":
+			n, err := docker.FindNetwork(ctx, s.dockerClient, name)
+			if err != nil {
+				return nil, err
+			}
+			if n == nil {
+				reason = "network is missing"
+			}
+		case "create_volume":
+			v, err := docker.FindVolume(ctx, s.dockerClient, name)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				reason = "volume is missing"
+			}
+		case "create_container":
+			c, err := docker.FindContainer(ctx, s.dockerClient, name)
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				reason = "container is missing"
+			}
+		case "run_container":
+			c, err := docker.FindContainer(ctx, s.dockerClient, name)
+			if err != nil {
+				return nil, err
+			}
+			if c == nil || c.State == nil || !c.State.Running {
+				reason = "container is not running"
+			}
+		}
+		if reason != "" {
+			drift = append(drift, Drift{Index: i, Action: actionType, Name: name, Reason: reason})
+		}
+	}
+	return drift, nil
+}
+
+// replaceResources updates refs with recreated resources, replacing entries of the same type
+// and name and appending the rest.
+func replaceResources(refs, recreated []state.ResourceRef) []state.ResourceRef {
+	for _, r := range recreated {
+		replaced := false
+		for i := range refs {
+			if refs[i].Type == r.Type && refs[i].Name == r.Name {
+				refs[i] = r
+				replaced = true
+			}
+		}
+		if !replaced {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+func (w *convergeWatcher) record(event ConvergeEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Iterations = event.Iteration
+	w.status.Events = append(w.status.Events, event)
+	if len(w.status.Events) > maxConvergeEvents {
+		w.status.Events = w.status.Events[len(w.status.Events)-maxConvergeEvents:]
+	}
+}
+
+func (w *convergeWatcher) snapshot() ConvergeStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.Events = append([]ConvergeEvent{}, w.status.Events...)
+	return status
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// driftDaemon serves a project whose network exists and whose web container is stopped
+// until it is started, counting the starts.
+func driftDaemon(starts *int) http.HandlerFunc {
+	var mu sync.Mutex
+	running := false
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks/shop":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Id": "n1", "Name": "shop"})
+		case r.Method == http.MethodPost && r.URL.Path == "/containers/web/start":
+			*starts++
+			running = true
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			status := "exited"
+			if running {
+				status = "running"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":    "c1",
+				"Name":  "/web",
+				"State": map[string]interface{}{"Status": status, "Running": running},
+			})
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestReconcileRestartsStoppedContainer(t *testing.T) {
+	var starts int
+	s := newTestServer(t, driftDaemon(&starts))
+	plan := []map[string]interface{}{
+		{"action": "create_network", "parameters": map[string]interface{}{"name": "shop"}},
+		{"action": "run_container", "parameters": map[string]interface{}{"name": "web"}},
+	}
+	if err := state.UpdateProjectState("shop", func(st *state.ProjectState) error {
+		st.Plans = append(st.Plans, state.AppliedPlan{Hash: "h1", Plan: plan})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := s.reconcile(context.Background(), "shop")
+	if event.Error != "" {
+		t.Fatalf("reconcile() error = %s", event.Error)
+	}
+	want := []Drift{{Index: 1, Action: "run_container", Name: "web", Reason: "container is not running"}}
+	if !reflect.DeepEqual(event.Drift, want) {
+		t.Errorf("drift = %+v, want %+v", event.Drift, want)
+	}
+	if event.Repaired != 1 || starts != 1 {
+		t.Errorf("repaired %d with %d starts, want the container started once", event.Repaired, starts)
+	}
+
+	event = s.reconcile(context.Background(), "shop")
+	if event.Error != "" || len(event.Drift) != 0 || event.Repaired != 0 || starts != 1 {
+		t.Errorf("second pass = %+v with %d starts, want no drift once converged", event, starts)
+	}
+}
+
+func TestReconcileChecksCurrentPolicy(t *testing.T) {
+	var starts int
+	s := newTestServer(t, driftDaemon(&starts))
+	plan := []map[string]interface{}{
+		{"action": "run_container", "parameters": map[string]interface{}{"name": "web"}},
+	}
+	if err := state.UpdateProjectState("shop", func(st *state.ProjectState) error {
+		st.Plans = append(st.Plans, state.AppliedPlan{Hash: "h1", Plan: plan})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.cfg.Policy = policy.Policy{DeniedActions: []string{"run_container"}}
+
+	event := s.reconcile(context.Background(), "shop")
+	if len(event.Drift) != 1 || event.Repaired != 0 || starts != 0 {
+		t.Errorf("reconcile() = %+v with %d starts, want the drift found but not repaired", event, starts)
+	}
+	if !strings.Contains(event.Error, "refusing to repair run_container web") || !strings.Contains(event.Error, "denied") {
+		t.Errorf("reconcile() error = %q, want the policy violation", event.Error)
+	}
+}
+
+func TestReconcileSkipsBusyProject(t *testing.T) {
+	var starts int
+	s := newTestServer(t, driftDaemon(&starts))
+	plan := []map[string]interface{}{
+		{"action": "run_container", "parameters": map[string]interface{}{"name": "web"}},
+	}
+	if err := state.UpdateProjectState("shop", func(st *state.ProjectState) error {
+		st.Plans = append(st.Plans, state.AppliedPlan{Hash: "h1", Plan: plan})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := s.projects.lock(context.Background(), "shop", onBusyFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := s.reconcile(context.Background(), "shop")
+	if event.Repaired != 0 || starts != 0 {
+		t.Errorf("reconcile() = %+v with %d starts, want nothing repaired while the project is busy", event, starts)
+	}
+	if !strings.Contains(event.Error, "project shop is busy") {
+		t.Errorf("reconcile() error = %q, want the project reported busy", event.Error)
+	}
+	unlock()
+	if event := s.reconcile(context.Background(), "shop"); event.Repaired != 1 || event.Error != "" {
+		t.Errorf("reconcile() = %+v once the project is free, want the container repaired", event)
+	}
+}
+
+func TestWatchConvergeArguments(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name    string
+		args    *ConvergeArgs
+		wantErr string
+	}{
+		{name: "no project", args: &ConvergeArgs{}, wantErr: "requires a project name"},
+		{name: "negative iterations", args: &ConvergeArgs{Project: "shop", MaxIterations: -1}, wantErr: "max_iterations must not be negative"},
+		{name: "interval too short", args: &ConvergeArgs{Project: "shop", IntervalSeconds: 0.1}, wantErr: "interval_seconds must be at least 1"},
+		{name: "nothing applied", args: &ConvergeArgs{Project: "shop"}, wantErr: "project shop has no applied plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status ConvergeStatus
+			err := s.WatchConverge(tt.args, &status)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("WatchConverge() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// daemonRetryInterval is how often an unreachable Docker daemon is pinged in the background.
+const daemonRetryInterval = 5 * time.Second
+
+// daemonState remembers whether the Docker daemon answered the last ping.
+type daemonState struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (d *daemonState) set(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *daemonState) reachable() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err == nil
+}
+
+// pingDaemon pings the Docker daemon, bounded by readinessTimeout, and records the outcome.
+func (s *Server) pingDaemon(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	err := docker.Ping(ctx, s.dockerClient)
+	s.daemon.set(err)
+	return err
+}
+
+// waitForDaemon pings the Docker daemon at startup, retrying for up to wait or until ctx is
+// cancelled. A daemon that is still unreachable does not stop the server: the failure is
+// logged, /readyz reports it, and the daemon is pinged every daemonRetryInterval until it
+// answers or ctx is cancelled.
+func (s *Server) waitForDaemon(ctx context.Context, wait time.Duration) {
+	deadline := time.Now().Add(wait)
+	err := s.pingDaemon(ctx)
+	for err != nil && time.Now().Add(daemonRetryInterval).Before(deadline) {
+		timer := time.NewTimer(daemonRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		err = s.pingDaemon(ctx)
+	}
+	if err == nil {
+		return
+	}
+	log.Printf("[Docker] %v; tool calls will fail until it answers", err)
+	go func() {
+		ticker := time.NewTicker(daemonRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if s.pingDaemon(ctx) == nil {
+					log.Printf("[Docker] Daemon at %s is reachable", s.dockerClient.DaemonHost())
+					return
+				}
+			}
+		}
+	}()
+}
+
+// requireDaemon returns nil if the Docker daemon answered its last ping. Otherwise it pings
+// again, so a daemon that has come back is noticed at once, and returns the ping's error.
+func (s *Server) requireDaemon(ctx context.Context) error {
+	if s.daemon.reachable() {
+		return nil
+	}
+	return s.pingDaemon(ctx)
+}
+
+// explainDaemonError replaces a connection failure reported by a tool with the clearer error
+// of a fresh ping, when the daemon has indeed gone away.
+func (s *Server) explainDaemonError(ctx context.Context, err error) error {
+	if err == nil || !client.IsErrConnectionFailed(err) {
+		return err
+	}
+	if pingErr := s.pingDaemon(ctx); pingErr != nil {
+		return pingErr
+	}
+	return err
+}
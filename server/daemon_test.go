@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+func TestToolsFailWhileDaemonUnreachable(t *testing.T) {
+	useFakeDaemon(t, nil)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	s, err := NewServer(WithDockerHost("tcp://" + down.Listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("NewServer with Docker down: %v", err)
+	}
+	defer s.Close()
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+
+	_, err = s.runTool(context.Background(), s.tools["count"], map[string]interface{}{})
+	want := "Docker daemon unreachable at tcp://" + down.Listener.Addr().String() + "; is Docker running?"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("runTool error = %v, want one containing %q", err, want)
+	}
+	if !client.IsErrConnectionFailed(err) {
+		t.Errorf("error %v no longer matches client.IsErrConnectionFailed", err)
+	}
+	if calls != 0 {
+		t.Errorf("tool ran %d times with Docker down", calls)
+	}
+}
+
+func TestRequireDaemonNoticesRecovery(t *testing.T) {
+	useFakeDaemon(t, nil)
+	var up atomic.Bool
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			writeDaemonError(w, http.StatusInternalServerError, "daemon is starting")
+			return
+		}
+		w.Header().Set("API-Version", "1.47")
+		io.WriteString(w, "OK")
+	}))
+	defer daemon.Close()
+	s, err := NewServer(WithDockerHost("tcp://" + daemon.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+
+	if s.daemon.reachable() {
+		t.Fatal("daemon reported reachable after a failed startup ping")
+	}
+	if _, err := s.runTool(context.Background(), s.tools["count"], map[string]interface{}{}); err == nil || calls != 0 {
+		t.Fatalf("runTool = %v after %d calls, want an error without running the tool", err, calls)
+	}
+	up.Store(true)
+	if _, err := s.runTool(context.Background(), s.tools["count"], map[string]interface{}{}); err != nil || calls != 1 {
+		t.Fatalf("runTool = %v after %d calls, want the tool to run once the daemon answers", err, calls)
+	}
+	if !s.daemon.reachable() {
+		t.Error("daemon still reported unreachable after answering a ping")
+	}
+}
+
+func TestWaitForDaemonStopsWhenCancelled(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		writeDaemonError(w, http.StatusInternalServerError, "daemon is starting")
+	}))
+	defer daemon.Close()
+	dc, err := client.NewClientWithOpts(client.WithHost("tcp://"+daemon.Listener.Addr().String()), client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	s.dockerClient = dc
+
+	done := make(chan struct{})
+	go func() {
+		s.waitForDaemon(ctx, time.Hour)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(daemonRetryInterval / 2):
+		t.Fatal("waitForDaemon kept retrying after its context was cancelled")
+	}
+	if s.daemon.reachable() {
+		t.Error("daemon reported reachable after a failed ping")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+const (
+	// diagnosticLogLines is how many of a failed container's last log lines are attached to
+	// the error reported for it.
+	diagnosticLogLines = 50
+	// diagnosticTimeout bounds fetching them, which also runs after the action's own deadline
+	// has passed.
+	diagnosticTimeout = 5 * time.Second
+)
+
+// containerFailure is a run_container or wait_container error together with the container's
+// exit code and last log lines, which toolError attaches to the RPC error's data so the
+// caller can see why the container died.
+type containerFailure struct {
+	err      error
+	name     string
+	exitCode int
+	logs     []string
+}
+
+func (e *containerFailure) Error() string { return e.err.Error() }
+func (e *containerFailure) Unwrap() error { return e.err }
+
+// withContainerDiagnostics wraps err, a failure of the named container, in a
+// containerFailure. Logs are redacted like the server's own; if they cannot be read (the
+// container may not even exist) err is returned unchanged.
+func (s *Server) withContainerDiagnostics(ctx context.Context, name string, err error) error {
+	if err == nil || name == "" {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticTimeout)
+	defer cancel()
+	logs, exitCode, logErr := docker.ContainerLogTail(ctx, s.dockerClient, name, diagnosticLogLines)
+	if logErr != nil {
+		if !errdefs.IsNotFound(logErr) {
+			log.Printf("Failed to read logs of failed container %s: %v", name, logErr)
+		}
+		return err
+	}
+	for i, line := range logs {
+		logs[i] = secrets.redact(line)
+	}
+	return &containerFailure{err: err, name: name, exitCode: exitCode, logs: logs}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// multiplexed frames lines as the daemon streams the logs of a container without a TTY,
+// alternating stdout and stderr.
+func multiplexed(lines ...string) []byte {
+	var b bytes.Buffer
+	for i, line := range lines {
+		header := make([]byte, 8)
+		header[0] = byte(1 + i%2)
+		binary.BigEndian.PutUint32(header[4:], uint32(len(line)+1))
+		b.Write(header)
+		b.WriteString(line + "\n")
+	}
+	return b.Bytes()
+}
+
+func TestWaitContainerAttachesDiagnostics(t *testing.T) {
+	secrets.remember("hunter2-secret")
+	tests := []struct {
+		name     string
+		tty      bool
+		logs     []byte
+		wantLogs []string
+	}{
+		{
+			name:     "multiplexed logs",
+			logs:     multiplexed("starting migration", "error: password hunter2-secret rejected"),
+			wantLogs: []string{"starting migration", "error: password [REDACTED] rejected"},
+		},
+		{
+			name:     "tty logs",
+			tty:      true,
+			logs:     []byte("starting migration\r\npanic: no database\n"),
+			wantLogs: []string{"starting migration\r", "panic: no database"},
+		},
+		{name: "no output", logs: nil, wantLogs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tail string
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/containers/job/wait":
+					fmt.Fprint(w, `{"StatusCode": 3}`)
+				case r.Method == http.MethodGet && r.URL.Path == "/containers/job/json":
+					fmt.Fprintf(w, `{"Id": "j1", "Name": "/job", "State": {"Status": "exited", "ExitCode": 3}, "Config": {"Tty": %t}}`, tt.tty)
+				case r.Method == http.MethodGet && r.URL.Path == "/containers/job/logs":
+					tail = r.URL.Query().Get("tail")
+					w.Write(tt.logs)
+				default:
+					writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+				}
+			})
+			var reply mcp.RPCResponse
+			args := mcp.ToolCallArgs{ToolName: "wait_container", Parameters: map[string]interface{}{"name": "job"}}
+			if err := s.CallTool(context.Background(), &args, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Error == nil {
+				t.Fatalf("CallTool() = %s, want the non-zero exit reported", reply.Result)
+			}
+			var data struct {
+				Container string   `json:"container"`
+				ExitCode  int      `json:"exit_code"`
+				Logs      []string `json:"logs"`
+			}
+			if err := json.Unmarshal(reply.Error.Data, &data); err != nil {
+				t.Fatalf("decoding error data %s: %v", reply.Error.Data, err)
+			}
+			if data.Container != "job" || data.ExitCode != 3 || !reflect.DeepEqual(data.Logs, tt.wantLogs) {
+				t.Errorf("error data = %+v, want job's exit code 3 and logs %q", data, tt.wantLogs)
+			}
+			if tail != "50" {
+				t.Errorf("logs requested with tail %q, want 50", tail)
+			}
+		})
+	}
+}
+
+func TestRunContainerWithoutDiagnostics(t *testing.T) {
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonError(w, http.StatusNotFound, "No such container: web")
+	})
+	var reply mcp.RPCResponse
+	args := mcp.ToolCallArgs{ToolName: "run_container", Parameters: map[string]interface{}{"name": "web"}}
+	if err := s.CallTool(context.Background(), &args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if reply.Error == nil || json.Unmarshal(reply.Error.Data, &data) != nil || data["logs"] != nil || data["exit_code"] != nil {
+		t.Errorf("CallTool() error = %+v, want no diagnostics for a missing container", reply.Error)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// errorType is used to check that RPC methods return an error.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// dispatch invokes method with JSON params. Standard MCP methods (tools/list, tools/call,
+// prompts/get, ...) are routed through mcpMethods; legacy names ("Server.CallTool", ...)
+// reach the same rpcService methods as the net/rpc endpoint, so every transport offers the
+// same methods. For legacy methods, params may be the argument itself or, as net/rpc
+// clients send it, a one-element array holding it.
+func (s *Server) dispatch(ctx context.Context, method string, params json.RawMessage) (interface{}, *mcp.RPCError) {
+	if fn, ok := mcpMethods[method]; ok {
+		return fn(ctx, s, params)
+	}
+	name, ok := strings.CutPrefix(method, "Server.")
+	if !ok || name == "" {
+		return nil, mcp.NewError(-32601, fmt.Sprintf("method not found: %s", method))
+	}
+	m := reflect.ValueOf(&rpcService{ctx: ctx, s: s}).MethodByName(name)
+	if !m.IsValid() {
+		return nil, mcp.NewError(-32601, fmt.Sprintf("method not found: %s", method))
+	}
+	mt := m.Type()
+	if mt.NumIn() != 2 || mt.NumOut() != 1 || mt.Out(0) != errorType ||
+		mt.In(0).Kind() != reflect.Pointer || mt.In(1).Kind() != reflect.Pointer {
+		return nil, mcp.NewError(-32601, fmt.Sprintf("method not found: %s", method))
+	}
+	args := reflect.New(mt.In(0).Elem())
+	if err := decodeParams(params, args.Interface()); err != nil {
+		return nil, mcp.NewError(-32602, fmt.Sprintf("invalid params for %s: %v", method, err))
+	}
+	reply := reflect.New(mt.In(1).Elem())
+	if errv := m.Call([]reflect.Value{args, reply})[0]; !errv.IsNil() {
+		return nil, mcp.NewError(-32000, errv.Interface().(error).Error())
+	}
+	return reply.Elem().Interface(), nil
+}
+
+// decodeParams unmarshals params into v, unwrapping a one-element positional array.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	trimmed := bytes.TrimSpace(params)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+	if trimmed[0] == '[' && reflect.TypeOf(v).Elem().Kind() != reflect.Slice {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(trimmed, &positional); err != nil {
+			return err
+		}
+		switch len(positional) {
+		case 0:
+			return nil
+		case 1:
+			trimmed = positional[0]
+		default:
+			return fmt.Errorf("expected a single parameter, got %d", len(positional))
+		}
+	}
+	return json.Unmarshal(trimmed, v)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"santoshkal/mcp-godocker/pkg/policy"
+)
+
+// envTemplatePattern matches ${NAME} and ${env:NAME} references, with an optional
+// ":-default", and the escape "$${", which stands for a literal "${".
+var envTemplatePattern = regexp.MustCompile(`\$\$\{|\$\{(?:env:)?([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// resolveEnvTemplates replaces ${NAME} (or ${env:NAME}) references in value with the
+// server's environment variable NAME, so plans can refer to secrets without containing them.
+// ${NAME:-default} falls back to default when NAME is unset or empty; without a default an
+// unset variable is an error. Only variables p allows may be referenced (a nil p allows
+// only the MCP_PASS_ ones); any other reference is a policy violation, with or without a
+// default. So ${env:DB_PW} resolves once policy.allowed_env lists DB_PW, which keeps a plan
+// from copying the server's own credentials into a container. Values taken from the
+// environment are redacted from the logs.
+func resolveEnvTemplates(value string, p *policy.Policy) (string, error) {
+	if p == nil {
+		p = &policy.Policy{}
+	}
+	var missing []string
+	var denied error
+	resolved := envTemplatePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		m := envTemplatePattern.FindStringSubmatch(ref)
+		if m[1] == "" {
+			return "${"
+		}
+		if err := p.CheckEnv(m[1]); err != nil {
+			if denied == nil {
+				denied = err
+			}
+			return ref
+		}
+		if v := os.Getenv(m[1]); v != "" {
+			secrets.remember(v)
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		missing = append(missing, m[1])
+		return ref
+	})
+	if denied != nil {
+		return "", denied
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set on the server (use ${%s:-default} to fall back to a default)", strings.Join(missing, ", "), missing[0])
+	}
+	return resolved, nil
+}
+
+// envReferences returns the names of the server environment variables referenced in the
+// environment parameter, in either of its forms.
+func envReferences(params map[string]interface{}) []string {
+	var values []string
+	switch v := params["environment"].(type) {
+	case map[string]interface{}:
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	var names []string
+	for _, value := range values {
+		for _, m := range envTemplatePattern.FindAllStringSubmatch(value, -1) {
+			if m[1] != "" {
+				names = append(names, m[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseEnvironment reads the environment parameter, given either as an object of names to
+// values or as a list of "NAME=value" strings, and returns it in the "NAME=value" form the
+// Docker API expects, with ${NAME} references resolved as p allows. Object entries are
+// sorted by name.
+//
+// Variables from the dotenv files named by env_from_file (a path or a list of paths, read
+// when the action runs) come first, sorted by name; a later file overrides an earlier one and
+// environment overrides both. File values are taken literally and redacted from the logs.
+// When p is non-nil, the files must pass the same host path checks as bind mounts.
+func parseEnvironment(params map[string]interface{}, p *policy.Policy) ([]string, error) {
+	fromFiles, err := envFromFiles(params, p)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	switch v := params["environment"].(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				s = fmt.Sprint(value)
+			}
+			env = append(env, name+"="+s)
+		}
+		sort.Strings(env)
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok || !strings.Contains(s, "=") {
+				return nil, fmt.Errorf("environment entries must be NAME=value strings, got %v", item)
+			}
+			env = append(env, s)
+		}
+	default:
+		return nil, fmt.Errorf("environment must be an object or a list of NAME=value strings, got %T", v)
+	}
+	for i, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		resolved, err := resolveEnvTemplates(value, p)
+		if err != nil {
+			return nil, fmt.Errorf("environment %s: %w", name, err)
+		}
+		env[i] = name + "=" + resolved
+		delete(fromFiles, name)
+	}
+	if len(fromFiles) == 0 {
+		return env, nil
+	}
+	names := make([]string, 0, len(fromFiles))
+	for name := range fromFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	merged := make([]string, 0, len(names)+len(env))
+	for _, name := range names {
+		merged = append(merged, name+"="+fromFiles[name])
+	}
+	return append(merged, env...), nil
+}
+
+// envFromFiles reads the dotenv files named by the env_from_file parameter.
+func envFromFiles(params map[string]interface{}, p *policy.Policy) (map[string]string, error) {
+	var paths []string
+	switch v := params["env_from_file"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		paths = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			path, ok := item.(string)
+			if !ok || path == "" {
+				return nil, fmt.Errorf("env_from_file entries must be file paths, got %v", item)
+			}
+			paths = append(paths, path)
+		}
+	default:
+		return nil, fmt.Errorf("env_from_file must be a path or a list of paths, got %T", v)
+	}
+	vars := map[string]string{}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("env_from_file %s: %w", path, err)
+		}
+		if p != nil {
+			if err := p.CheckBind(abs); err != nil {
+				return nil, fmt.Errorf("env_from_file %s: %w", path, err)
+			}
+		}
+		f, err := os.Open(abs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("env_from_file %s does not exist on the server", path)
+			}
+			return nil, fmt.Errorf("env_from_file %s: %w", path, err)
+		}
+		err = readEnvFile(f, vars)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("env_from_file %s: %w", path, err)
+		}
+	}
+	return vars, nil
+}
+
+// envNamePattern matches valid environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// readEnvFile parses a dotenv file into vars: one NAME=value per line, blank lines and lines
+// starting with "#" ignored, an optional "export " prefix, and values optionally wrapped in
+// single quotes (taken as is) or double quotes (with Go escapes such as \n). Errors name the
+// line but never quote it, since it may hold a secret.
+func readEnvFile(r io.Reader, vars map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return fmt.Errorf("line %d is not of the form NAME=value", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("line %d (%s) has an invalid double-quoted value", n, name)
+			}
+			value = unquoted
+		}
+		secrets.remember(value)
+		vars[name] = value
+	}
+	return scanner.Err()
+}
+
+// minSecretLength is the length below which resolved values are not redacted: masking every
+// occurrence of a value like "1" or "yes" would garble the logs without hiding anything.
+const minSecretLength = 4
+
+// secrets holds the values resolved from the server's environment and from env files, which
+// are masked wherever they appear in the log.
+var secrets secretSet
+
+// secretSet is a set of values to redact.
+type secretSet struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+// remember adds value to the set.
+func (s *secretSet) remember(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = map[string]struct{}{}
+	}
+	s.values[value] = struct{}{}
+}
+
+// redact replaces every remembered value in text with "[REDACTED]".
+func (s *secretSet) redact(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for value := range s.values {
+		text = strings.ReplaceAll(text, value, "[REDACTED]")
+	}
+	return text
+}
+
+// redactingWriter masks remembered secrets in everything written through it; the server's
+// log is sent through one.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, secrets.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactedError is an error whose message has remembered secrets masked; it still unwraps to
+// the original so the error class is kept.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError masks remembered secrets in err's message.
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := secrets.redact(err.Error()); msg != err.Error() {
+		return &redactedError{err: err, msg: msg}
+	}
+	return err
+}
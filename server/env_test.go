@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+
+	"santoshkal/mcp-godocker/pkg/policy"
+)
+
+func TestResolveEnvTemplates(t *testing.T) {
+	t.Setenv("MCP_PASS_DB_PASSWORD", "s3cret-pass")
+	t.Setenv("MCP_PASS_EMPTY", "")
+	t.Setenv("APP_TOKEN", "app-token-value")
+	t.Setenv("HOME_SECRET", "not-for-plans")
+	allowApp := &policy.Policy{AllowedEnv: []string{"APP_*"}}
+	tests := []struct {
+		name    string
+		value   string
+		policy  *policy.Policy
+		want    string
+		wantErr string
+		rule    string
+	}{
+		{name: "no references", value: "plain value", want: "plain value"},
+		{name: "passed variable", value: "${MCP_PASS_DB_PASSWORD}", want: "s3cret-pass"},
+		{name: "env: prefix", value: "${env:MCP_PASS_DB_PASSWORD}", want: "s3cret-pass"},
+		{name: "embedded reference", value: "mysql://root:${MCP_PASS_DB_PASSWORD}@db:3306", want: "mysql://root:s3cret-pass@db:3306"},
+		{name: "default for an unset variable", value: "${MCP_PASS_MISSING:-fallback}", want: "fallback"},
+		{name: "default for an empty variable", value: "${MCP_PASS_EMPTY:-fallback}", want: "fallback"},
+		{name: "empty default", value: "x${MCP_PASS_MISSING:-}y", want: "xy"},
+		{name: "set variable ignores default", value: "${MCP_PASS_DB_PASSWORD:-fallback}", want: "s3cret-pass"},
+		{name: "escaped reference", value: "$${MCP_PASS_DB_PASSWORD}", want: "${MCP_PASS_DB_PASSWORD}"},
+		{name: "unset variable", value: "${MCP_PASS_MISSING}", wantErr: "MCP_PASS_MISSING is not set on the server"},
+		{name: "server variable refused", value: "${HOME_SECRET}", rule: "allowed_env"},
+		{name: "server variable refused despite a default", value: "${HOME_SECRET:-x}", rule: "allowed_env"},
+		{name: "nil policy allows only the prefix", value: "${APP_TOKEN}", rule: "allowed_env"},
+		{name: "allowed by policy", value: "${APP_TOKEN}", policy: allowApp, want: "app-token-value"},
+		{name: "other variables still refused", value: "${APP_TOKEN}${HOME_SECRET}", policy: allowApp, rule: "allowed_env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveEnvTemplates(tt.value, tt.policy)
+			switch {
+			case tt.rule != "":
+				var v *policy.Violation
+				if !errors.As(err, &v) || v.Rule != tt.rule {
+					t.Fatalf("resolveEnvTemplates(%q) = %q, %v; want a violation of %s", tt.value, got, err, tt.rule)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveEnvTemplates(%q) error = %v, want one containing %q", tt.value, err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("resolveEnvTemplates(%q) error = %v", tt.value, err)
+			case got != tt.want:
+				t.Errorf("resolveEnvTemplates(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEnvTemplatesRedactsResolvedValues(t *testing.T) {
+	t.Setenv("MCP_PASS_REDACT_ME", "value-to-redact")
+	if _, err := resolveEnvTemplates("${MCP_PASS_REDACT_ME}", nil); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	redactingWriter{w: &buf}.Write([]byte("connecting with value-to-redact\n"))
+	if got := buf.String(); got != "connecting with [REDACTED]\n" {
+		t.Errorf("log line = %q, want the value redacted", got)
+	}
+	err := redactError(errdefs.InvalidParameter(errors.New("bad password value-to-redact")))
+	if err.Error() != "bad password [REDACTED]" {
+		t.Errorf("error = %q, want the value redacted", err)
+	}
+	if !errdefs.IsInvalidParameter(err) {
+		t.Error("redacted error lost its class")
+	}
+}
+
+func TestEnvReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   []string
+	}{
+		{"no environment", map[string]interface{}{}, nil},
+		{"object form", map[string]interface{}{"environment": map[string]interface{}{"B": "${ZED}", "A": "${env:ALPHA:-x}", "C": float64(1)}}, []string{"ALPHA", "ZED"}},
+		{"list form", map[string]interface{}{"environment": []interface{}{"URL=http://${HOST}:${PORT}", "PLAIN=1"}}, []string{"HOST", "PORT"}},
+		{"escaped reference", map[string]interface{}{"environment": []interface{}{"LITERAL=$${NOT_A_REF}"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envReferences(tt.params); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envReferences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	t.Setenv("MCP_PASS_API_KEY", "api-key-value")
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.env")
+	override := filepath.Join(dir, "override.env")
+	if err := os.WriteFile(base, []byte("SHARED=base\nONLY_BASE=1\nPORT=80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("SHARED=override\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		policy  *policy.Policy
+		want    []string
+		wantErr string
+	}{
+		{name: "none", params: map[string]interface{}{}, want: nil},
+		{name: "object sorted by name", params: map[string]interface{}{"environment": map[string]interface{}{"B": "2", "A": "1"}}, want: []string{"A=1", "B=2"}},
+		{name: "object with a number", params: map[string]interface{}{"environment": map[string]interface{}{"PORT": float64(3306)}}, want: []string{"PORT=3306"}},
+		{name: "list keeps order", params: map[string]interface{}{"environment": []interface{}{"B=2", "A=1=x"}}, want: []string{"B=2", "A=1=x"}},
+		{name: "list entry without =", params: map[string]interface{}{"environment": []interface{}{"B"}}, wantErr: "NAME=value"},
+		{name: "wrong type", params: map[string]interface{}{"environment": "A=1"}, wantErr: "must be an object or a list"},
+		{name: "references resolved", params: map[string]interface{}{"environment": map[string]interface{}{"KEY": "${MCP_PASS_API_KEY}"}}, want: []string{"KEY=api-key-value"}},
+		{name: "error names the variable", params: map[string]interface{}{"environment": map[string]interface{}{"KEY": "${MCP_PASS_UNSET}"}}, wantErr: "environment KEY: "},
+		{
+			name:   "files merged, later files and environment win",
+			params: map[string]interface{}{"env_from_file": []interface{}{base, override}, "environment": map[string]interface{}{"PORT": "8080"}},
+			want:   []string{"ONLY_BASE=1", "SHARED=override", "PORT=8080"},
+		},
+		{name: "single file path", params: map[string]interface{}{"env_from_file": override}, want: []string{"SHARED=override"}},
+		{name: "missing file", params: map[string]interface{}{"env_from_file": filepath.Join(dir, "missing.env")}, wantErr: "does not exist on the server"},
+		{name: "file refused by policy", params: map[string]interface{}{"env_from_file": base}, policy: &policy.Policy{DeniedBinds: []string{dir}}, wantErr: "denied_binds"},
+		{name: "sensitive file refused", params: map[string]interface{}{"env_from_file": "/etc/environment"}, policy: &policy.Policy{}, wantErr: "allowed_binds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEnvironment(tt.params, tt.policy)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseEnvironment() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEnvironment() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEnvironment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "plain", content: "A=1\nB=two words\n", want: map[string]string{"A": "1", "B": "two words"}},
+		{name: "comments and blank lines", content: "# comment\n\nA=1\n  # indented comment\n", want: map[string]string{"A": "1"}},
+		{name: "export prefix", content: "export A=1\n", want: map[string]string{"A": "1"}},
+		{name: "spaces around =", content: "A = 1 \n", want: map[string]string{"A": "1"}},
+		{name: "single quotes are literal", content: `A='x\ny $HOME'` + "\n", want: map[string]string{"A": `x\ny $HOME`}},
+		{name: "double quotes unescape", content: `A="x\ny"` + "\n", want: map[string]string{"A": "x\ny"}},
+		{name: "empty value", content: "A=\n", want: map[string]string{"A": ""}},
+		{name: "value containing =", content: "URL=postgres://u:p@h/db?sslmode=disable\n", want: map[string]string{"URL": "postgres://u:p@h/db?sslmode=disable"}},
+		{name: "later line wins", content: "A=1\nA=2\n", want: map[string]string{"A": "2"}},
+		{name: "missing =", content: "A=1\nsecret-without-name\n", wantErr: "line 2 is not of the form NAME=value"},
+		{name: "invalid name", content: "1A=x\n", wantErr: "line 1"},
+		{name: "bad double quotes", content: `A="\q"` + "\n", wantErr: "line 1 (A) has an invalid double-quoted value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{}
+			err := readEnvFile(strings.NewReader(tt.content), vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readEnvFile() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "secret-without-name") {
+					t.Errorf("error %q quotes the offending line", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readEnvFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(vars, tt.want) {
+				t.Errorf("readEnvFile() = %q, want %q", vars, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateContainerResolvesEnvironment(t *testing.T) {
+	t.Setenv("MCP_PASS_DB_PW", "s3cret-pass")
+	var created container.CreateRequest
+	s := newTestServer(t, createDaemon(t, &created))
+	params := map[string]interface{}{
+		"name":        "db",
+		"image":       "mysql:8",
+		"environment": map[string]interface{}{"MYSQL_ROOT_PASSWORD": "${MCP_PASS_DB_PW}", "MYSQL_DATABASE": "shop"},
+	}
+	if _, err := s.tools["create_container"].Handler(context.Background(), s, params); err != nil {
+		t.Fatalf("create_container: %v", err)
+	}
+	if want := []string{"MYSQL_DATABASE=shop", "MYSQL_ROOT_PASSWORD=s3cret-pass"}; !reflect.DeepEqual(created.Env, want) {
+		t.Errorf("env = %q, want %q", created.Env, want)
+	}
+	if params["environment"].(map[string]interface{})["MYSQL_ROOT_PASSWORD"] != "${MCP_PASS_DB_PW}" {
+		t.Error("the plan's environment was rewritten with the secret")
+	}
+}
+
+func TestCreateContainerResolvesAllowedServerEnv(t *testing.T) {
+	t.Setenv("DB_PW", "s3cret-pass")
+	params := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name":        "db",
+			"image":       "mysql:8",
+			"environment": map[string]interface{}{"MYSQL_ROOT_PASSWORD": "${env:DB_PW}"},
+		}
+	}
+	var created container.CreateRequest
+	s := newTestServer(t, createDaemon(t, &created))
+
+	_, err := s.tools["create_container"].Handler(context.Background(), s, params())
+	var v *policy.Violation
+	if !errors.As(err, &v) || v.Rule != "allowed_env" || !strings.Contains(v.Message, "list it in policy.allowed_env") {
+		t.Fatalf("create_container without allowed_env = %v, want an allowed_env violation naming the fix", err)
+	}
+
+	s.cfg.Policy = policy.Policy{AllowedEnv: []string{"DB_PW"}}
+	if _, err := s.tools["create_container"].Handler(context.Background(), s, params()); err != nil {
+		t.Fatalf("create_container with DB_PW allowed: %v", err)
+	}
+	if want := []string{"MYSQL_ROOT_PASSWORD=s3cret-pass"}; !reflect.DeepEqual(created.Env, want) {
+		t.Errorf("env = %q, want %q", created.Env, want)
+	}
+}
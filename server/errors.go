@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"santoshkal/mcp-godocker/pkg/errclass"
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/policy"
+)
+
+// toolError builds the RPC error reported for a failed tool call or plan action: the code
+// and the data's kind come from the classification of err, msg is the message. Policy
+// rejections also name the rule that was hit, and failed containers carry their exit code
+// and last log lines.
+func toolError(err error, msg string) *mcp.RPCError {
+	class := errclass.Classify(err)
+	rpcErr := mcp.NewError(class.Code, msg)
+	data := map[string]interface{}{
+		"kind":      class.Kind,
+		"retryable": class.Retryable,
+	}
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		data["rule"] = violation.Rule
+	}
+	var failure *containerFailure
+	if errors.As(err, &failure) {
+		data["container"] = failure.name
+		data["exit_code"] = failure.exitCode
+		data["logs"] = failure.logs
+	}
+	rpcErr.Data, _ = json.Marshal(data)
+	return rpcErr
+}
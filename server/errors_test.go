@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/errclass"
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+func TestToolError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantData string
+	}{
+		{name: "generic failure", err: errors.New("boom"), wantCode: errclass.CodeUnknown, wantData: `{"kind":"unknown","retryable":false}`},
+		{name: "rate limited", err: fmt.Errorf("pulling redis: %w", docker.ErrRateLimited), wantCode: errclass.CodeRateLimited, wantData: `{"kind":"rate_limited","retryable":true}`},
+		{name: "missing container", err: errdefs.NotFound(errors.New("No such container: web")), wantCode: errclass.CodeNotFound, wantData: `{"kind":"not_found","retryable":false}`},
+		{
+			name:     "failed container",
+			err:      &containerFailure{err: errors.New("container job exited with code 2"), name: "job", exitCode: 2, logs: []string{"no such table"}},
+			wantCode: errclass.CodeUnknown,
+			wantData: `{"container":"job","exit_code":2,"kind":"unknown","logs":["no such table"],"retryable":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toolError(tt.err, "failed to execute tool x")
+			if got.Code != tt.wantCode || got.Message != "failed to execute tool x" || string(got.Data) != tt.wantData {
+				t.Errorf("toolError(%v) = %d %q %s, want %d with data %s", tt.err, got.Code, got.Message, got.Data, tt.wantCode, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestCallToolClassifiesDaemonErrors(t *testing.T) {
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonError(w, http.StatusNotFound, "No such container: web")
+	})
+	var reply mcp.RPCResponse
+	args := mcp.ToolCallArgs{ToolName: "run_container", Parameters: map[string]interface{}{"name": "web"}}
+	if err := s.CallTool(context.Background(), &args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == nil || reply.Error.Code != errclass.CodeNotFound || string(reply.Error.Data) != `{"kind":"not_found","retryable":false}` {
+		t.Errorf("CallTool() error = %+v, want a not_found error", reply.Error)
+	}
+}
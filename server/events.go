@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+const (
+	// defaultEventLimit and defaultEventWait bound a docker_events call that does not set
+	// limit or timeout_seconds.
+	defaultEventLimit = 10
+	defaultEventWait  = 10 * time.Second
+	// maxEventLimit caps limit so one call cannot hold a large backlog in memory.
+	maxEventLimit = 1000
+)
+
+// errEventLimit stops the event stream once enough events were collected.
+var errEventLimit = errors.New("event limit reached")
+
+// eventsHandler waits for the next limit Docker events about a project's resources and
+// returns them, or whatever arrived when timeout_seconds ran out.
+func eventsHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	project, _ := params["project"].(string)
+	if project == "" {
+		project = projectFrom(ctx)
+	}
+	if project == "" {
+		return nil, errors.New("missing project name for docker_events")
+	}
+	limit := defaultEventLimit
+	if n, ok, err := numberParam(params, "limit"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 1 || n > maxEventLimit || n != float64(int(n)) {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d, got %v", maxEventLimit, n)
+		}
+		limit = int(n)
+	}
+	wait := defaultEventWait
+	if n, ok, err := numberParam(params, "timeout_seconds"); err != nil {
+		return nil, err
+	} else if ok {
+		if n <= 0 {
+			return nil, fmt.Errorf("timeout_seconds must be positive, got %v", n)
+		}
+		wait = time.Duration(n * float64(time.Second))
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	collected := make([]docker.Event, 0, limit)
+	err := docker.StreamEvents(waitCtx, s.dockerClient, project, func(e docker.Event) error {
+		collected = append(collected, e)
+		if len(collected) == limit {
+			return errEventLimit
+		}
+		return nil
+	})
+	timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+	if err != nil && !errors.Is(err, errEventLimit) && !timedOut {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"project":   project,
+		"events":    collected,
+		"timed_out": timedOut,
+	}, nil
+}
+
+// handleEvents streams the Docker events about a project's resources as Server-Sent Events,
+// one "data:" frame per event, until the client disconnects. The project is given by the
+// project query parameter. If the daemon's stream fails an "event: error" frame is sent
+// before the response ends.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "the event stream requires GET", http.StatusMethodNotAllowed)
+		return
+	}
+	project := r.URL.Query().Get("project")
+	if project == "" {
+		http.Error(w, "missing project query parameter", http.StatusBadRequest)
+		return
+	}
+	if err := s.requireDaemon(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, payload interface{}) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if event != "" {
+			if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	log.Printf("[Events] Streaming events for project %s", project)
+	err := docker.StreamEvents(r.Context(), s.dockerClient, project, func(e docker.Event) error {
+		return send("", e)
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("[Events] Event stream for project %s ended: %v", project, err)
+		_ = send("error", map[string]string{"message": err.Error()})
+	}
+}
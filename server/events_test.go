@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// eventsDaemon streams the given event messages from /events, then holds the stream open
+// until the client goes away. The filters of each request are sent on filters.
+func eventsDaemon(messages []string, filters chan<- string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/events" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		select {
+		case filters <- r.URL.Query().Get("filters"):
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		for _, m := range messages {
+			fmt.Fprintln(w, m)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+}
+
+// shopEvents are a container start and die and a network connect, as the daemon reports them.
+var shopEvents = []string{
+	`{"Type": "container", "Action": "start", "Actor": {"ID": "c1", "Attributes": {"name": "shop-web"}}, "time": 1714564800, "timeNano": 1714564800000000000}`,
+	`{"Type": "container", "Action": "die", "Actor": {"ID": "c1", "Attributes": {"name": "shop-web", "exitCode": "1"}}, "time": 1714564801, "timeNano": 1714564801000000000}`,
+	`{"Type": "network", "Action": "connect", "Actor": {"ID": "n1", "Attributes": {"name": "shop-net"}}, "time": 1714564802, "timeNano": 1714564802000000000}`,
+}
+
+func TestDockerEvents(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       map[string]interface{}
+		project      string
+		wantProject  string
+		wantActions  []string
+		wantTimedOut bool
+		wantErr      string
+	}{
+		{name: "limit reached", params: map[string]interface{}{"project": "shop", "limit": float64(2)}, wantProject: "shop", wantActions: []string{"start", "die"}},
+		{name: "timeout returns what arrived", params: map[string]interface{}{"project": "shop", "timeout_seconds": 0.05}, wantProject: "shop", wantActions: []string{"start", "die", "connect"}, wantTimedOut: true},
+		{name: "project from the plan", params: map[string]interface{}{"limit": float64(1)}, project: "blog", wantProject: "blog", wantActions: []string{"start"}},
+		{name: "missing project", params: map[string]interface{}{}, wantErr: "missing project name for docker_events"},
+		{name: "limit too large", params: map[string]interface{}{"project": "shop", "limit": float64(5000)}, wantErr: "limit must be an integer between 1 and 1000"},
+		{name: "fractional limit", params: map[string]interface{}{"project": "shop", "limit": 1.5}, wantErr: "limit must be an integer"},
+		{name: "non-positive timeout", params: map[string]interface{}{"project": "shop", "timeout_seconds": float64(0)}, wantErr: "timeout_seconds must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := make(chan string, 1)
+			s := newTestServer(t, eventsDaemon(shopEvents, filters))
+			ctx := context.Background()
+			if tt.project != "" {
+				ctx = withProject(ctx, tt.project)
+			}
+			got, err := s.tools["docker_events"].Handler(ctx, s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("docker_events error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("docker_events: %v", err)
+			}
+			if f := <-filters; !strings.Contains(f, docker.ProjectLabel+"="+tt.wantProject) {
+				t.Errorf("daemon filters = %s, want the %s project label", f, tt.wantProject)
+			}
+			events := got["events"].([]docker.Event)
+			var actions []string
+			for _, e := range events {
+				actions = append(actions, e.Action)
+			}
+			if got["project"] != tt.wantProject || got["timed_out"] != tt.wantTimedOut || strings.Join(actions, ",") != strings.Join(tt.wantActions, ",") {
+				t.Errorf("docker_events = %v with actions %v, want project %s, actions %v and timed_out %v", got, actions, tt.wantProject, tt.wantActions, tt.wantTimedOut)
+			}
+			if first := events[0]; first.Type != "container" || first.ActorID != "c1" || first.ActorName != "shop-web" || !first.Time.Equal(time.Unix(1714564800, 0)) {
+				t.Errorf("first event = %+v, want the normalized container start", first)
+			}
+		})
+	}
+}
+
+func TestHandleEventsStreamsProjectEvents(t *testing.T) {
+	filters := make(chan string, 1)
+	s := newTestServer(t, eventsDaemon(shopEvents, filters))
+	srv := httptest.NewServer(http.HandlerFunc(s.handleEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events?project=shop", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("response = %d %s, want an event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	sc := bufio.NewScanner(resp.Body)
+	var events []docker.Event
+	for len(events) < len(shopEvents) && sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e docker.Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("decoding frame %q: %v", data, err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 || events[1].Action != "die" || events[2].Type != "network" || events[2].ActorName != "shop-net" {
+		t.Errorf("streamed events = %+v, want the three project events", events)
+	}
+	if f := <-filters; !strings.Contains(f, docker.ProjectLabel+"=shop") {
+		t.Errorf("daemon filters = %s, want the shop project label", f)
+	}
+}
+
+func TestHandleEventsRejects(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{method: http.MethodPost, target: "/events?project=shop", want: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, target: "/events", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleEvents(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// graphEdge links two containers that can reach each other over the networks they share.
+type graphEdge struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Networks []string `json:"networks"`
+}
+
+// projectGraphHandler builds the connectivity graph of a project: every labelled container,
+// the networks it is attached to, and an edge between each pair of containers sharing a
+// network. With format "dot" the graph is also rendered for Graphviz.
+func projectGraphHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	project, _ := params["project"].(string)
+	if project == "" {
+		project = projectFrom(ctx)
+	}
+	if project == "" {
+		return nil, errors.New("missing project name for project_graph")
+	}
+	format, _ := params["format"].(string)
+	if format != "" && format != "json" && format != "dot" {
+		return nil, fmt.Errorf("unsupported format %q: use json or dot", format)
+	}
+
+	list, err := docker.ListContainersByLabels(ctx, s.dockerClient, map[string]string{docker.ProjectLabel: project}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers for project %s: %w", project, err)
+	}
+	containerNetworks := map[string][]string{}
+	networkMembers := map[string][]string{}
+	for _, c := range list {
+		info, err := s.dockerClient.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", c.ID, err)
+		}
+		name := strings.TrimPrefix(info.Name, "/")
+		networks := []string{}
+		if info.NetworkSettings != nil {
+			for n := range info.NetworkSettings.Networks {
+				networks = append(networks, n)
+				networkMembers[n] = append(networkMembers[n], name)
+			}
+		}
+		sort.Strings(networks)
+		containerNetworks[name] = networks
+	}
+
+	names := make([]string, 0, len(containerNetworks))
+	for name := range containerNetworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	containers := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		containers = append(containers, map[string]interface{}{"name": name, "networks": containerNetworks[name]})
+	}
+	networkNames := make([]string, 0, len(networkMembers))
+	for n := range networkMembers {
+		networkNames = append(networkNames, n)
+	}
+	sort.Strings(networkNames)
+	networks := make([]map[string]interface{}, 0, len(networkNames))
+	for _, n := range networkNames {
+		sort.Strings(networkMembers[n])
+		networks = append(networks, map[string]interface{}{"name": n, "containers": networkMembers[n]})
+	}
+	edges := []graphEdge{}
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if shared := sharedNames(containerNetworks[a], containerNetworks[b]); len(shared) > 0 {
+				edges = append(edges, graphEdge{From: a, To: b, Networks: shared})
+			}
+		}
+	}
+
+	out := map[string]interface{}{
+		"project":    project,
+		"containers": containers,
+		"networks":   networks,
+		"edges":      edges,
+	}
+	if format == "dot" {
+		out["dot"] = graphDOT(project, names, containerNetworks)
+	}
+	return out, nil
+}
+
+// sharedNames returns the names present in both sorted lists.
+func sharedNames(a, b []string) []string {
+	var shared []string
+	for _, x := range a {
+		if i := sort.SearchStrings(b, x); i < len(b) && b[i] == x {
+			shared = append(shared, x)
+		}
+	}
+	return shared
+}
+
+// graphDOT renders containers as boxes and networks as ellipses, with an edge from each
+// container to every network it is attached to.
+func graphDOT(project string, names []string, containerNetworks map[string][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "graph %q {\n", project)
+	seen := map[string]bool{}
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %q [shape=box];\n", name)
+		for _, n := range containerNetworks[name] {
+			if !seen[n] {
+				fmt.Fprintf(&b, "  %q [shape=ellipse];\n", "net:"+n)
+				seen[n] = true
+			}
+			fmt.Fprintf(&b, "  %q -- %q;\n", name, "net:"+n)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
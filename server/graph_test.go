@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graphDaemon serves the containers of project shop: web on the front network, api on both,
+// db on the back network and a detached worker.
+func graphDaemon(w http.ResponseWriter, r *http.Request) {
+	networks := map[string][]string{
+		"web":    {"shop-front"},
+		"api":    {"shop-front", "shop-back"},
+		"db":     {"shop-back"},
+		"worker": {},
+	}
+	switch {
+	case r.URL.Path == "/containers/json":
+		if !strings.Contains(r.URL.Query().Get("filters"), "shop") {
+			json.NewEncoder(w).Encode([]interface{}{})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"Id": "db"}, {"Id": "web"}, {"Id": "worker"}, {"Id": "api"}})
+	case strings.HasPrefix(r.URL.Path, "/containers/") && strings.HasSuffix(r.URL.Path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+		attached := map[string]interface{}{}
+		for _, n := range networks[name] {
+			attached[n] = map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Id": name, "Name": "/" + name, "NetworkSettings": map[string]interface{}{"Networks": attached}})
+	default:
+		writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+	}
+}
+
+func TestProjectGraph(t *testing.T) {
+	s := newTestServer(t, graphDaemon)
+	got, err := s.tools["project_graph"].Handler(context.Background(), s, map[string]interface{}{"project": "shop", "format": "dot"})
+	if err != nil {
+		t.Fatalf("project_graph: %v", err)
+	}
+	wantEdges := []graphEdge{
+		{From: "api", To: "db", Networks: []string{"shop-back"}},
+		{From: "api", To: "web", Networks: []string{"shop-front"}},
+	}
+	if !reflect.DeepEqual(got["edges"], wantEdges) {
+		t.Errorf("edges = %+v, want %+v", got["edges"], wantEdges)
+	}
+	wantNetworks := []map[string]interface{}{
+		{"name": "shop-back", "containers": []string{"api", "db"}},
+		{"name": "shop-front", "containers": []string{"api", "web"}},
+	}
+	if !reflect.DeepEqual(got["networks"], wantNetworks) {
+		t.Errorf("networks = %v, want %v", got["networks"], wantNetworks)
+	}
+	containers := got["containers"].([]map[string]interface{})
+	if len(containers) != 4 || containers[3]["name"] != "worker" || len(containers[3]["networks"].([]string)) != 0 {
+		t.Errorf("containers = %v, want all four sorted, the worker on no network", containers)
+	}
+	dot := got["dot"].(string)
+	for _, want := range []string{`graph "shop" {`, `"api" -- "net:shop-back";`, `"web" -- "net:shop-front";`, `"worker" [shape=box];`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot output lacks %s:\n%s", want, dot)
+		}
+	}
+}
+
+func TestProjectGraphArguments(t *testing.T) {
+	s := newTestServer(t, graphDaemon)
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{name: "no project", params: map[string]interface{}{}, wantErr: "missing project name"},
+		{name: "bad format", params: map[string]interface{}{"project": "shop", "format": "svg"}, wantErr: `unsupported format "svg"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.tools["project_graph"].Handler(context.Background(), s, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("project_graph error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := s.tools["project_graph"].Handler(withProject(context.Background(), "shop"), s, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("project_graph: %v", err)
+	}
+	if got["project"] != "shop" || got["dot"] != nil {
+		t.Errorf("project_graph = %v, want the plan's project as JSON only", got)
+	}
+}
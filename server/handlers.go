@@ -0,0 +1,1033 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+
+	"santoshkal/mcp-godocker/pkg/compose"
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/docker/images"
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// createNetworkHandler creates a network, or with idempotent (the default) reuses an existing
+// network of the same name that belongs to the plan's project. The driver defaults to the
+// daemon's (bridge); an existing network with a different driver is an error.
+func createNetworkHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing network name")
+	}
+	opts, err := networkCreateOptions(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if idempotent(params) {
+		existing, err := docker.FindNetwork(ctx, s.dockerClient, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if err := checkProjectLabel(ctx, "network", name, existing.Labels); err != nil {
+				return nil, err
+			}
+			if opts.Driver != "" && existing.Driver != opts.Driver {
+				return nil, fmt.Errorf("network %s already exists with driver %s, not %s; remove it first to change the driver", name, existing.Driver, opts.Driver)
+			}
+			return map[string]interface{}{"id": existing.ID, "existing": true}, nil
+		}
+	}
+	id, err := docker.CreateNetworkWithOptions(ctx, s.dockerClient, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id}, nil
+}
+
+// builtinNetworkDrivers are the network drivers Docker ships with. Other names are passed
+// through as plugin drivers.
+var builtinNetworkDrivers = map[string]bool{
+	"bridge": true, "overlay": true, "macvlan": true, "ipvlan": true, "host": true, "none": true,
+}
+
+// networkDriverPattern matches plausible driver names, including plugin references such as
+// "vendor/driver:tag".
+var networkDriverPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._/:-]*$`)
+
+// networkCreateOptions reads the driver, driver_opts, internal, attachable and ipam
+// parameters of create_network.
+func networkCreateOptions(ctx context.Context, params map[string]interface{}) (network.CreateOptions, error) {
+	opts := network.CreateOptions{Labels: projectLabels(ctx)}
+	if driver, _ := params["driver"].(string); driver != "" {
+		driver = strings.ToLower(driver)
+		if !networkDriverPattern.MatchString(driver) {
+			return opts, fmt.Errorf("invalid network driver %q", driver)
+		}
+		if driver == "host" || driver == "none" {
+			return opts, fmt.Errorf("the %s driver has a single built-in network and cannot be used to create another", driver)
+		}
+		if !builtinNetworkDrivers[driver] {
+			log.Printf("[create_network] Using plugin network driver %q", driver)
+		}
+		opts.Driver = driver
+	}
+	driverOpts, err := stringMapParam(params, "driver_opts")
+	if err != nil {
+		return opts, err
+	}
+	opts.Options = driverOpts
+	opts.Internal, _ = params["internal"].(bool)
+	opts.Attachable, _ = params["attachable"].(bool)
+	raw, ok := params["ipam"]
+	if !ok || raw == nil {
+		return opts, nil
+	}
+	ipam, ok := raw.(map[string]interface{})
+	if !ok {
+		return opts, fmt.Errorf("ipam must be an object with subnet and gateway, got %T", raw)
+	}
+	subnet, _ := ipam["subnet"].(string)
+	gateway, _ := ipam["gateway"].(string)
+	if subnet == "" {
+		if gateway != "" {
+			return opts, errors.New("ipam.gateway requires ipam.subnet")
+		}
+		return opts, nil
+	}
+	_, cidr, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return opts, fmt.Errorf("ipam.subnet %q is not a CIDR such as 172.28.0.0/16", subnet)
+	}
+	if gateway != "" {
+		ip := net.ParseIP(gateway)
+		if ip == nil {
+			return opts, fmt.Errorf("ipam.gateway %q is not an IP address", gateway)
+		}
+		if !cidr.Contains(ip) {
+			return opts, fmt.Errorf("ipam.gateway %s is outside ipam.subnet %s", gateway, subnet)
+		}
+	}
+	opts.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: subnet, Gateway: gateway}}}
+	return opts, nil
+}
+
+// createVolumeHandler creates a volume, or with idempotent (the default) reuses an existing
+// volume of the same name that belongs to the plan's project.
+func createVolumeHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("invalid or missing volume name")
+	}
+	opts, err := volumeCreateOptions(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	if idempotent(params) {
+		existing, err := docker.FindVolume(ctx, s.dockerClient, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if err := checkProjectLabel(ctx, "volume", name, existing.Labels); err != nil {
+				return nil, err
+			}
+			if existing.Driver != opts.Driver {
+				return nil, fmt.Errorf("volume %s already exists with driver %s, not %s; remove it first to change the driver", name, existing.Driver, opts.Driver)
+			}
+			return map[string]interface{}{"id": existing.Name, "existing": true}, nil
+		}
+	}
+	if err := docker.CreateVolumeWithOptions(ctx, s.dockerClient, opts); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": name}, nil
+}
+
+// volumeCreateOptions reads the driver (default "local"), driver_opts and labels parameters
+// of create_volume. The project label is always set and cannot be overridden.
+func volumeCreateOptions(ctx context.Context, name string, params map[string]interface{}) (volume.CreateOptions, error) {
+	opts := volume.CreateOptions{Name: name, Driver: "local"}
+	if driver, _ := params["driver"].(string); driver != "" {
+		opts.Driver = driver
+	}
+	driverOpts, err := stringMapParam(params, "driver_opts")
+	if err != nil {
+		return opts, err
+	}
+	opts.DriverOpts = driverOpts
+	labels, err := stringMapParam(params, "labels")
+	if err != nil {
+		return opts, err
+	}
+	if project := projectLabels(ctx); project != nil {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range project {
+			labels[k] = v
+		}
+	}
+	opts.Labels = labels
+	return opts, nil
+}
+
+// createContainerHandler creates a container from the action parameters, including its
+// restart policy and resource limits. With idempotent (the default) an existing container of
+// the same name and image is reused; one running a different image is reported as a conflict.
+func createContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	image, _ := params["image"].(string)
+	if name == "" || image == "" {
+		return nil, errors.New("missing container name or image")
+	}
+	image, err := images.NormalizeImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+	if s.config().Swarm {
+		return createServiceHandler(ctx, s, name, image, params)
+	}
+	if idempotent(params) {
+		existing, err := docker.FindContainer(ctx, s.dockerClient, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			var labels map[string]string
+			existingImage := ""
+			if existing.Config != nil {
+				labels = existing.Config.Labels
+				existingImage, _ = images.NormalizeImageRef(existing.Config.Image)
+			}
+			if err := checkProjectLabel(ctx, "container", name, labels); err != nil {
+				return nil, err
+			}
+			if existingImage != image {
+				return nil, fmt.Errorf("container %s already exists with image %s instead of %s; recreate it (remove, then create) to change the image", name, existingImage, image)
+			}
+			return map[string]interface{}{"id": existing.ID, "existing": true}, nil
+		}
+	}
+	config, hostConfig, err := s.containerSpec(ctx, image, params)
+	if err != nil {
+		return nil, err
+	}
+	id, err := docker.CreateContainer(ctx, s.dockerClient, name, config, hostConfig)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id}, nil
+}
+
+// containerSpec builds the container and host configuration of a container running image
+// from create_container parameters, labelled as part of the plan's project. The command,
+// entrypoint, working directory and user default to the image's.
+func (s *Server) containerSpec(ctx context.Context, image string, params map[string]interface{}) (*container.Config, *container.HostConfig, error) {
+	hostConfig, err := parseHostConfig(s.withProjectDefaults(ctx, params))
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := parseEnvironment(params, &s.config().Policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hostConfig.Binds, err = parseVolumes(params, &s.config().Policy); err != nil {
+		return nil, nil, err
+	}
+	exposed, bindings, err := parsePorts(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostConfig.PortBindings = bindings
+	hostConfig.Privileged, _ = params["privileged"].(bool)
+	config := &container.Config{Image: image, Env: env, ExposedPorts: exposed, Labels: projectLabels(ctx)}
+	if err := parseProcessConfig(params, config); err != nil {
+		return nil, nil, err
+	}
+	return config, hostConfig, nil
+}
+
+// withProjectDefaults returns params with the configured defaults of the plan's project
+// filled in where the action leaves them out. params itself is not modified.
+func (s *Server) withProjectDefaults(ctx context.Context, params map[string]interface{}) map[string]interface{} {
+	defaults, ok := s.config().Projects[projectFrom(ctx)]
+	if !ok {
+		return params
+	}
+	if _, set := params["restart_policy"]; set || defaults.RestartPolicy == "" {
+		return params
+	}
+	merged := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		merged[k] = v
+	}
+	merged["restart_policy"] = defaults.RestartPolicy
+	return merged
+}
+
+// idempotent reports whether the idempotent parameter is set, defaulting to true.
+func idempotent(params map[string]interface{}) bool {
+	v, ok := params["idempotent"].(bool)
+	return !ok || v
+}
+
+// projectLabels returns the labels that mark a resource as belonging to the plan's project,
+// or nil outside a project.
+func projectLabels(ctx context.Context) map[string]string {
+	project := projectFrom(ctx)
+	if project == "" {
+		return nil
+	}
+	return map[string]string{docker.ProjectLabel: project}
+}
+
+// checkProjectLabel rejects reusing an existing resource that does not belong to the plan's
+// project. Outside a project any resource with a matching name is accepted.
+func checkProjectLabel(ctx context.Context, kind, name string, labels map[string]string) error {
+	project := projectFrom(ctx)
+	if project == "" || labels[docker.ProjectLabel] == project {
+		return nil
+	}
+	if owner := labels[docker.ProjectLabel]; owner != "" {
+		return fmt.Errorf("%s %s already exists but belongs to project %s", kind, name, owner)
+	}
+	return fmt.Errorf("%s %s already exists but is not part of project %s", kind, name, project)
+}
+
+// runContainerHandler starts a container and reports the state it settled in.
+func runContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	attempts := docker.DefaultStartAttempts
+	if n, ok, err := numberParam(params, "start_attempts"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 1 {
+			return nil, fmt.Errorf("start_attempts must be at least 1, got %v", n)
+		}
+		attempts = int(n)
+	}
+	state, err := docker.RunContainer(ctx, s.dockerClient, name, attempts, s.dockerRetryAttempts())
+	if err != nil {
+		return nil, s.withContainerDiagnostics(ctx, name, err)
+	}
+	out := map[string]interface{}{
+		"name":      name,
+		"status":    state.Status,
+		"running":   state.Running,
+		"exit_code": state.ExitCode,
+	}
+	if state.Restarting {
+		out["warning"] = fmt.Sprintf("container %s is restarting and may be crash-looping; check it with project_ps", name)
+		log.Printf("[run_container] %s", out["warning"])
+		if progress := progressFrom(ctx); progress != nil {
+			progress(fmt.Sprintf("warning: %s", out["warning"]))
+		}
+	}
+	return out, nil
+}
+
+// waitContainerHandler blocks until a container exits, for one-shot jobs such as migrations.
+// A non-zero exit code is an error; so is the action timing out first, reported separately
+// so the two are not confused.
+func waitContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing container name for wait_container")
+	}
+	if n, ok, err := numberParam(params, "timeout_seconds"); err != nil {
+		return nil, err
+	} else if ok {
+		if n <= 0 {
+			return nil, fmt.Errorf("timeout_seconds must be positive, got %v", n)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(n*float64(time.Second)))
+		defer cancel()
+	}
+	start := time.Now()
+	code, waitErr, err := docker.WaitContainer(ctx, s.dockerClient, name)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s waiting for container %s to exit; it is still running", time.Since(start).Round(time.Second), name)
+		return nil, s.withContainerDiagnostics(ctx, name, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{"name": name, "exit_code": code}
+	if waitErr != "" {
+		out["error"] = waitErr
+	}
+	if code != 0 {
+		err := fmt.Errorf("container %s exited with code %d", name, code)
+		if waitErr != "" {
+			err = fmt.Errorf("container %s exited with code %d: %s", name, code, waitErr)
+		}
+		return nil, s.withContainerDiagnostics(ctx, name, err)
+	}
+	return out, nil
+}
+
+// containerStatsHandler reports a container's current CPU, memory and network usage.
+func containerStatsHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	usage, err := docker.ContainerStats(ctx, s.dockerClient, name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":            name,
+		"cpu_percent":     usage.CPUPercent,
+		"mem_usage_bytes": usage.MemUsageBytes,
+		"mem_limit_bytes": usage.MemLimitBytes,
+		"mem_percent":     usage.MemPercent,
+		"net_rx":          usage.NetRxBytes,
+		"net_tx":          usage.NetTxBytes,
+	}, nil
+}
+
+// pauseContainerHandler freezes a running container without stopping it.
+func pauseContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing container name for pause_container")
+	}
+	if err := docker.PauseContainer(ctx, s.dockerClient, name); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": name, "paused": true}, nil
+}
+
+// unpauseContainerHandler resumes a paused container.
+func unpauseContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing container name for unpause_container")
+	}
+	if err := docker.UnpauseContainer(ctx, s.dockerClient, name); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": name, "paused": false}, nil
+}
+
+// copyToContainerHandler copies a local file or directory into a container, e.g. to seed a
+// configuration file before starting it.
+func copyToContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	source, _ := params["source_path"].(string)
+	dest, _ := params["dest_path"].(string)
+	if source != "" {
+		local, err := s.checkCopyPath(source, false)
+		if err != nil {
+			return nil, err
+		}
+		source = local
+	}
+	if err := docker.CopyToContainer(ctx, s.dockerClient, name, source, dest); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": name, "source_path": source, "dest_path": dest}, nil
+}
+
+// copyFromContainerHandler copies a file or directory out of a container into a local
+// directory, e.g. to collect generated output.
+func copyFromContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	source, _ := params["source_path"].(string)
+	dest, _ := params["dest_path"].(string)
+	if dest != "" {
+		local, err := s.checkCopyPath(dest, true)
+		if err != nil {
+			return nil, err
+		}
+		dest = local
+	}
+	files, err := docker.CopyFromContainer(ctx, s.dockerClient, name, source, dest)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": name, "source_path": source, "dest_path": dest, "files": files}, nil
+}
+
+// pullImageHandler pulls an image given either a combined "image" reference or separate
+// "name" and "tag" parameters (tag defaulting to "latest"). An image already present
+// locally is reused unless force_pull is set; the result says which happened.
+func pullImageHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	image, _ := params["image"].(string)
+	if image == "" {
+		name, _ := params["name"].(string)
+		if name == "" {
+			return nil, errors.New("missing image name for pull_image")
+		}
+		image = name
+		if tag, _ := params["tag"].(string); tag != "" {
+			image = fmt.Sprintf("%s:%s", name, tag)
+		}
+	}
+	image, err := images.NormalizeImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+	forcePull, _ := params["force_pull"].(bool)
+	if !forcePull {
+		if s.images.present(image, s.imageCacheTTL()) {
+			return map[string]interface{}{"image": image, "pulled": false}, nil
+		}
+		exists, err := docker.ImageExists(ctx, s.dockerClient, image)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			s.images.add(image)
+			return map[string]interface{}{"image": image, "pulled": false}, nil
+		}
+	}
+	onLine := progressFrom(ctx)
+	if onLine == nil {
+		onLine = func(line string) { log.Printf("[pull_image] %s: %s", image, line) }
+	}
+	if err := docker.PullImage(ctx, s.dockerClient, image, s.timeouts.Pull, s.dockerRetryAttempts(), onLine); err != nil {
+		return nil, err
+	}
+	s.images.add(image)
+	return map[string]interface{}{"image": image, "pulled": true}, nil
+}
+
+// immutableContainerParams lists create_container parameters Docker cannot change on a
+// running container.
+var immutableContainerParams = []string{"image", "environment", "ports", "volumes", "networks", "command", "entrypoint", "working_dir", "user", "labels", "privileged"}
+
+// updateContainerHandler changes a container's restart policy or resource limits without
+// recreating it. Parameters that can only change by recreating the container are rejected
+// with an error saying so.
+func updateContainerHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing container name for update_container")
+	}
+	for _, key := range immutableContainerParams {
+		if _, ok := params[key]; ok {
+			return nil, fmt.Errorf("%s cannot be updated on an existing container; recreate container %s (remove, then create) to change it", key, name)
+		}
+	}
+	hostConfig, err := parseHostConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	update := container.UpdateConfig{Resources: hostConfig.Resources}
+	if hostConfig.RestartPolicy.Name != "" || hostConfig.RestartPolicy.MaximumRetryCount != 0 {
+		update.RestartPolicy = hostConfig.RestartPolicy
+	}
+	if swapMB, ok, err := numberParam(params, "memory_swap_mb"); err != nil {
+		return nil, err
+	} else if ok {
+		switch {
+		case update.Memory == 0:
+			return nil, errors.New("memory_swap_mb needs memory_mb in the same update")
+		case swapMB == -1:
+			update.MemorySwap = -1
+		case int64(swapMB*1024*1024) < update.Memory:
+			return nil, fmt.Errorf("memory_swap_mb must be at least memory_mb, or -1 for unlimited swap, got %v", swapMB)
+		default:
+			update.MemorySwap = int64(swapMB * 1024 * 1024)
+		}
+	} else if update.Memory > 0 {
+		// Match what Docker does at creation: swap defaults to twice the memory limit.
+		update.MemorySwap = 2 * update.Memory
+	}
+	if update.RestartPolicy.Name == "" && update.Memory == 0 && update.NanoCPUs == 0 {
+		return nil, errors.New("update_container needs at least one of restart_policy, max_retries, memory_mb or cpus")
+	}
+	existing, err := docker.FindContainer(ctx, s.dockerClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("container %s does not exist", name)
+	}
+	var labels map[string]string
+	if existing.Config != nil {
+		labels = existing.Config.Labels
+	}
+	if err := checkProjectLabel(ctx, "container", name, labels); err != nil {
+		return nil, err
+	}
+	warnings, err := docker.UpdateContainer(ctx, s.dockerClient, name, update)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{"id": existing.ID}
+	if len(warnings) > 0 {
+		out["warnings"] = warnings
+	}
+	return out, nil
+}
+
+// connectNetworkHandler attaches a container, running or not, to a network.
+func connectNetworkHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	containerName, _ := params["container"].(string)
+	networkName, _ := params["network"].(string)
+	if containerName == "" || networkName == "" {
+		return nil, errors.New("connect_network requires both container and network")
+	}
+	var aliases []string
+	if raw, ok := params["aliases"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("aliases must be a list of strings, got %T", raw)
+		}
+		for _, item := range list {
+			alias, ok := item.(string)
+			if !ok || alias == "" {
+				return nil, fmt.Errorf("aliases must be non-empty strings, got %v", item)
+			}
+			aliases = append(aliases, alias)
+		}
+	}
+	if err := docker.ConnectNetwork(ctx, s.dockerClient, networkName, containerName, aliases); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"container": containerName, "network": networkName, "aliases": aliases}, nil
+}
+
+// disconnectNetworkHandler detaches a container from a network.
+func disconnectNetworkHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	containerName, _ := params["container"].(string)
+	networkName, _ := params["network"].(string)
+	if containerName == "" || networkName == "" {
+		return nil, errors.New("disconnect_network requires both container and network")
+	}
+	if err := docker.DisconnectNetwork(ctx, s.dockerClient, networkName, containerName); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"container": containerName, "network": networkName}, nil
+}
+
+// tagImageHandler tags a local image with another reference, e.g. myapp:build as
+// myapp:latest.
+func tagImageHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	source, _ := params["source"].(string)
+	target, _ := params["target"].(string)
+	if source == "" || target == "" {
+		return nil, errors.New("tag_image requires both source and target")
+	}
+	source, err := images.NormalizeImageRef(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source image: %w", err)
+	}
+	if target, err = images.NormalizeImageRef(target); err != nil {
+		return nil, fmt.Errorf("invalid target image: %w", err)
+	}
+	if err := docker.ImageTag(ctx, s.dockerClient, source, target); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"source": source, "target": target}, nil
+}
+
+// removeImageHandler removes a local image and reports the references untagged and the
+// layers deleted.
+func removeImageHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	ref, _ := params["image"].(string)
+	if ref == "" {
+		return nil, errors.New("missing image for remove_image")
+	}
+	// An image ID (or ID prefix) is removed as given; anything else is a reference.
+	if !strings.HasPrefix(ref, "sha256:") {
+		normalized, err := images.NormalizeImageRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image: %w", err)
+		}
+		ref = normalized
+	}
+	force, _ := params["force"].(bool)
+	pruneChildren := true
+	if v, ok := params["prune_children"].(bool); ok {
+		pruneChildren = v
+	}
+	removal, err := docker.RemoveImage(ctx, s.dockerClient, ref, force, pruneChildren)
+	s.images.forget(ref)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"image": ref, "untagged": removal.Untagged, "deleted": removal.Deleted}, nil
+}
+
+// listImagesHandler lists local images, optionally filtered by reference, label or project,
+// so the model can reuse an image that is already present instead of pulling it.
+func listImagesHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	reference, _ := params["reference"].(string)
+	var labels []string
+	if label, _ := params["label"].(string); label != "" {
+		labels = append(labels, label)
+	}
+	if project, _ := params["project"].(string); project != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", docker.ProjectLabel, project))
+	}
+	list, err := docker.ListImages(ctx, s.dockerClient, reference, labels)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"images": list}, nil
+}
+
+// composeUpHandler applies a compose file (given as a path or inline content) to a project.
+func composeUpHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	projectName, _ := params["project"].(string)
+	if projectName == "" {
+		return nil, errors.New("missing project name for compose_up")
+	}
+	var (
+		project *compose.Project
+		baseDir string
+		err     error
+	)
+	if file, _ := params["file"].(string); file != "" {
+		project, err = compose.ParseFile(file)
+		baseDir = filepath.Dir(file)
+	} else if content, _ := params["content"].(string); content != "" {
+		project, err = compose.Parse([]byte(content))
+		baseDir, _ = os.Getwd()
+	} else {
+		return nil, errors.New("compose_up requires either file or content")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules := s.config().Policy
+	action := policy.Action{Type: "compose_up"}
+	for _, ref := range project.Images() {
+		image, err := images.NormalizeImageRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		action.Images = append(action.Images, image)
+	}
+	if err := rules.Check(action); err != nil {
+		return nil, err
+	}
+	for _, hostPath := range project.HostPaths(baseDir) {
+		if err := rules.CheckBind(hostPath); err != nil {
+			return nil, err
+		}
+	}
+	services, err := compose.Up(ctx, s.dockerClient, projectName, project, baseDir, s.timeouts.Pull)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"project": projectName, "services": services}, nil
+}
+
+// destroyProjectHandler removes, newest first, the resources recorded in the project's state
+// as created by the server. Resources that merely carry the project label are left alone.
+// Removal stops at the first failure, keeping the remaining resources recorded.
+func destroyProjectHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	project, _ := params["project"].(string)
+	if project == "" {
+		project = projectFrom(ctx)
+	}
+	if project == "" {
+		return nil, errors.New("missing project name for destroy_project")
+	}
+	st, err := state.LoadProjectState(project)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make(map[state.ResourceRef]bool)
+	var removeErr error
+	for i := len(st.Resources) - 1; i >= 0; i-- {
+		ref := st.Resources[i]
+		var err error
+		switch ref.Type {
+		case "container":
+			err = docker.RemoveContainer(ctx, s.dockerClient, ref.ID)
+		case "network":
+			err = docker.RemoveNetwork(ctx, s.dockerClient, ref.ID)
+		case "volume":
+			err = docker.RemoveVolume(ctx, s.dockerClient, ref.ID)
+		case "service":
+			err = docker.RemoveService(ctx, s.dockerClient, ref.ID)
+		default:
+			err = fmt.Errorf("unknown resource type %q", ref.Type)
+		}
+		if err != nil && !errdefs.IsNotFound(err) {
+			removeErr = fmt.Errorf("failed to remove %s %s: %w", ref.Type, ref.Name, err)
+			break
+		}
+		removed[ref] = true
+	}
+
+	if err := state.UpdateProjectState(project, func(st *state.ProjectState) error {
+		remaining := st.Resources[:0]
+		for _, ref := range st.Resources {
+			if !removed[ref] {
+				remaining = append(remaining, ref)
+			}
+		}
+		st.Resources = remaining
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if removeErr != nil {
+		return nil, removeErr
+	}
+
+	refs := make([]state.ResourceRef, 0, len(removed))
+	for i := len(st.Resources) - 1; i >= 0; i-- {
+		if removed[st.Resources[i]] {
+			refs = append(refs, st.Resources[i])
+		}
+	}
+	return map[string]interface{}{"project": project, "removed": refs}, nil
+}
+
+// parseHostConfig maps the restart_policy, max_retries, memory_mb and cpus parameters onto
+// a HostConfig.
+func parseHostConfig(params map[string]interface{}) (*container.HostConfig, error) {
+	hostConfig := &container.HostConfig{}
+
+	if raw, ok := params["restart_policy"]; ok {
+		policy, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("restart_policy must be a string, got %T", raw)
+		}
+		hostConfig.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyMode(policy)}
+	}
+	if retries, ok, err := numberParam(params, "max_retries"); err != nil {
+		return nil, err
+	} else if ok {
+		if retries < 0 || retries != float64(int(retries)) {
+			return nil, fmt.Errorf("max_retries must be a non-negative integer, got %v", retries)
+		}
+		hostConfig.RestartPolicy.MaximumRetryCount = int(retries)
+	}
+	if hostConfig.RestartPolicy.Name != "" || hostConfig.RestartPolicy.MaximumRetryCount != 0 {
+		if err := container.ValidateRestartPolicy(hostConfig.RestartPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	if memoryMB, ok, err := numberParam(params, "memory_mb"); err != nil {
+		return nil, err
+	} else if ok {
+		if memoryMB <= 0 {
+			return nil, fmt.Errorf("memory_mb must be positive, got %v", memoryMB)
+		}
+		hostConfig.Resources.Memory = int64(memoryMB * 1024 * 1024)
+	}
+	if cpus, ok, err := numberParam(params, "cpus"); err != nil {
+		return nil, err
+	} else if ok {
+		if cpus <= 0 {
+			return nil, fmt.Errorf("cpus must be positive, got %v", cpus)
+		}
+		hostConfig.Resources.NanoCPUs = int64(cpus * 1e9)
+	}
+	return hostConfig, nil
+}
+
+// parsePorts reads the ports parameter, a list of {"published", "target", "protocol"}
+// objects, into the exposed ports of the container config and the bindings of its host
+// config. protocol defaults to tcp; an entry without published only exposes the port.
+func parsePorts(params map[string]interface{}) (nat.PortSet, nat.PortMap, error) {
+	raw, ok := params["ports"]
+	if !ok || raw == nil {
+		return nil, nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("ports must be a list of {published, target} objects, got %T", raw)
+	}
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+	for i, entry := range list {
+		p, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("ports[%d]: expected a {published, target} object, got %T", i, entry)
+		}
+		target, ok, err := portNumber(p, "target")
+		if err != nil {
+			return nil, nil, fmt.Errorf("ports[%d]: %w", i, err)
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("ports[%d]: target is required", i)
+		}
+		protocol := "tcp"
+		if v, ok := p["protocol"]; ok {
+			if protocol, ok = v.(string); !ok || (protocol != "tcp" && protocol != "udp" && protocol != "sctp") {
+				return nil, nil, fmt.Errorf("ports[%d]: protocol must be tcp, udp or sctp, got %v", i, v)
+			}
+		}
+		port, err := nat.NewPort(protocol, strconv.Itoa(target))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ports[%d]: %w", i, err)
+		}
+		exposed[port] = struct{}{}
+		published, ok, err := portNumber(p, "published")
+		if err != nil {
+			return nil, nil, fmt.Errorf("ports[%d]: %w", i, err)
+		}
+		if ok {
+			bindings[port] = append(bindings[port], nat.PortBinding{HostPort: strconv.Itoa(published)})
+		}
+	}
+	return exposed, bindings, nil
+}
+
+// portNumber reads a port number field, which must be an integer from 1 to 65535.
+func portNumber(p map[string]interface{}, key string) (int, bool, error) {
+	n, ok, err := numberParam(p, key)
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+	if n != float64(int(n)) || n < 1 || n > 65535 {
+		return 0, false, fmt.Errorf("%s port %v is out of range (1-65535)", key, n)
+	}
+	return int(n), true, nil
+}
+
+// volumeNamePattern is what Docker accepts as a named volume.
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// parseVolumes reads the volumes parameter and returns Docker bind specs
+// ("source:target[:ro]"). Each entry is either such a string or an object
+// {"source", "target", "readonly"}, the form the system prompt asks the model for. A source
+// that looks like a path (absolute, or starting with "." or "~") is a host bind mount and is
+// made absolute; anything else must be a valid named volume. When p is non-nil, host paths
+// it refuses (the Docker socket, "/", /etc and so on, unless allowed) are rejected.
+func parseVolumes(params map[string]interface{}, p *policy.Policy) ([]string, error) {
+	raw, ok := params["volumes"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("volumes must be a list, got %T", raw)
+	}
+	binds := make([]string, 0, len(list))
+	for i, entry := range list {
+		var source, target string
+		readonly := false
+		switch v := entry.(type) {
+		case string:
+			parts := strings.Split(v, ":")
+			if len(parts) < 2 || len(parts) > 3 {
+				return nil, fmt.Errorf("volumes[%d]: %q is not of the form source:target[:ro]", i, v)
+			}
+			source, target = parts[0], parts[1]
+			if len(parts) == 3 {
+				switch parts[2] {
+				case "ro":
+					readonly = true
+				case "rw":
+				default:
+					return nil, fmt.Errorf("volumes[%d]: unknown mode %q (use ro or rw)", i, parts[2])
+				}
+			}
+		case map[string]interface{}:
+			source, _ = v["source"].(string)
+			target, _ = v["target"].(string)
+			if ro, ok := v["readonly"]; ok {
+				if readonly, ok = ro.(bool); !ok {
+					return nil, fmt.Errorf("volumes[%d]: readonly must be a boolean, got %T", i, ro)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("volumes[%d]: expected a \"source:target\" string or a {source, target} object, got %T", i, entry)
+		}
+		if source == "" || target == "" {
+			return nil, fmt.Errorf("volumes[%d]: both source and target are required", i)
+		}
+		if !strings.HasPrefix(target, "/") {
+			return nil, fmt.Errorf("volumes[%d]: target %q must be an absolute path inside the container", i, target)
+		}
+		if isHostPath(source) {
+			abs, err := hostPath(source)
+			if err != nil {
+				return nil, fmt.Errorf("volumes[%d]: %w", i, err)
+			}
+			source = abs
+			if p != nil {
+				if err := p.CheckBind(source); err != nil {
+					return nil, fmt.Errorf("volumes[%d]: %w", i, err)
+				}
+			}
+		} else if !volumeNamePattern.MatchString(source) {
+			return nil, fmt.Errorf("volumes[%d]: %q is neither a host path nor a valid volume name", i, source)
+		}
+		bind := source + ":" + target
+		if readonly {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
+	return binds, nil
+}
+
+// isHostPath reports whether a volume source names a host directory rather than a volume.
+func isHostPath(source string) bool {
+	return strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~")
+}
+
+// hostPath expands a leading "~" and makes source absolute.
+func hostPath(source string) (string, error) {
+	if source == "~" || strings.HasPrefix(source, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand %s: %w", source, err)
+		}
+		source = filepath.Join(home, strings.TrimPrefix(source, "~"))
+	}
+	return filepath.Abs(source)
+}
+
+// numberParam reads an optional numeric parameter. JSON numbers decode as float64, so that
+// is the only accepted type.
+func numberParam(params map[string]interface{}, key string) (float64, bool, error) {
+	raw, ok := params[key]
+	if !ok || raw == nil {
+		return 0, false, nil
+	}
+	n, ok := raw.(float64)
+	if !ok {
+		return 0, false, fmt.Errorf("%s must be a number, got %T", key, raw)
+	}
+	return n, true, nil
+}
+
+// stringMapParam reads an optional object parameter whose values must all be strings.
+func stringMapParam(params map[string]interface{}, key string) (map[string]string, error) {
+	raw, ok := params[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object of strings, got %T", key, raw)
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string, got %T", key, k, v)
+		}
+		out[k] = value
+	}
+	return out, nil
+}
@@ -0,0 +1,1307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+
+	"santoshkal/mcp-godocker/pkg/config"
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+func TestParseHostConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    container.HostConfig
+		wantErr string
+	}{
+		{name: "nothing set", params: map[string]interface{}{}, want: container.HostConfig{}},
+		{
+			name:   "restart policy",
+			params: map[string]interface{}{"restart_policy": "unless-stopped"},
+			want:   container.HostConfig{RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}},
+		},
+		{
+			name:   "on-failure with retries",
+			params: map[string]interface{}{"restart_policy": "on-failure", "max_retries": float64(5)},
+			want:   container.HostConfig{RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 5}},
+		},
+		{
+			name:   "memory and cpus",
+			params: map[string]interface{}{"memory_mb": float64(512), "cpus": 1.5},
+			want:   container.HostConfig{Resources: container.Resources{Memory: 512 * 1024 * 1024, NanoCPUs: 1500000000}},
+		},
+		{name: "unknown restart policy", params: map[string]interface{}{"restart_policy": "sometimes"}, wantErr: "sometimes"},
+		{name: "retries need on-failure", params: map[string]interface{}{"restart_policy": "always", "max_retries": float64(3)}, wantErr: "on-failure"},
+		{name: "non-string restart policy", params: map[string]interface{}{"restart_policy": float64(1)}, wantErr: "restart_policy must be a string"},
+		{name: "negative retries", params: map[string]interface{}{"restart_policy": "on-failure", "max_retries": float64(-1)}, wantErr: "max_retries must be a non-negative integer"},
+		{name: "fractional retries", params: map[string]interface{}{"restart_policy": "on-failure", "max_retries": 1.5}, wantErr: "max_retries must be a non-negative integer"},
+		{name: "zero memory", params: map[string]interface{}{"memory_mb": float64(0)}, wantErr: "memory_mb must be positive"},
+		{name: "negative cpus", params: map[string]interface{}{"cpus": float64(-1)}, wantErr: "cpus must be positive"},
+		{name: "string memory", params: map[string]interface{}{"memory_mb": "512"}, wantErr: "memory_mb must be a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHostConfig(tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseHostConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHostConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseHostConfig() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVolumes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		volumes interface{}
+		policy  *policy.Policy
+		want    []string
+		wantErr string
+	}{
+		{name: "strings", volumes: []interface{}{"shop-data:/data", "/srv/conf:/etc/nginx/conf.d:ro", "logs:/logs:rw"}, want: []string{"shop-data:/data", "/srv/conf:/etc/nginx/conf.d:ro", "logs:/logs"}},
+		{name: "single-character volume name", volumes: []interface{}{"d:/data"}, want: []string{"d:/data"}},
+		{
+			name: "objects",
+			volumes: []interface{}{
+				map[string]interface{}{"source": "shop-data", "target": "/data"},
+				map[string]interface{}{"source": "/srv/conf", "target": "/conf", "readonly": true},
+			},
+			want: []string{"shop-data:/data", "/srv/conf:/conf:ro"},
+		},
+		{
+			name:    "mixed forms",
+			volumes: []interface{}{"shop-data:/data", map[string]interface{}{"source": "./conf", "target": "/conf", "readonly": false}},
+			want:    []string{"shop-data:/data", filepath.Join(wd, "conf") + ":/conf"},
+		},
+		{name: "home directory", volumes: []interface{}{"~/app:/app"}, want: []string{filepath.Join(home, "app") + ":/app"}},
+		{name: "not a list", volumes: "shop-data:/data", wantErr: "volumes must be a list"},
+		{name: "no target", volumes: []interface{}{"shop-data"}, wantErr: `volumes[0]: "shop-data" is not of the form source:target[:ro]`},
+		{name: "unknown mode", volumes: []interface{}{"shop-data:/data:rx"}, wantErr: `unknown mode "rx"`},
+		{name: "object without source", volumes: []interface{}{"a1:/a", map[string]interface{}{"target": "/data"}}, wantErr: "volumes[1]: both source and target are required"},
+		{name: "readonly not a boolean", volumes: []interface{}{map[string]interface{}{"source": "data", "target": "/data", "readonly": "yes"}}, wantErr: "readonly must be a boolean"},
+		{name: "relative target", volumes: []interface{}{"shop-data:data"}, wantErr: `target "data" must be an absolute path`},
+		{name: "invalid volume name", volumes: []interface{}{"shop data:/data"}, wantErr: "neither a host path nor a valid volume name"},
+		{name: "wrong entry type", volumes: []interface{}{float64(1)}, wantErr: "expected a \"source:target\" string or a {source, target} object"},
+		{name: "ordinary bind under policy", volumes: []interface{}{"/srv/conf:/conf"}, policy: &policy.Policy{}, want: []string{"/srv/conf:/conf"}},
+		{name: "named volume under policy", volumes: []interface{}{"data:/data"}, policy: &policy.Policy{DeniedBinds: []string{"/data"}}, want: []string{"data:/data"}},
+		{name: "docker socket refused", volumes: []interface{}{"/var/run/docker.sock:/var/run/docker.sock"}, policy: &policy.Policy{}, wantErr: "volumes[0]: blocked by policy rule allowed_binds"},
+		{name: "under a sensitive path", volumes: []interface{}{"data:/data", map[string]interface{}{"source": "/etc/nginx", "target": "/conf"}}, policy: &policy.Policy{}, wantErr: "volumes[1]: blocked by policy rule allowed_binds"},
+		{name: "relative path into a sensitive path", volumes: []interface{}{"/srv/../etc:/host-etc"}, policy: &policy.Policy{}, wantErr: "blocked by policy rule allowed_binds"},
+		{name: "sensitive path allowed", volumes: []interface{}{"/var/run/docker.sock:/var/run/docker.sock"}, policy: &policy.Policy{AllowedBinds: []string{"/var/run/docker.sock"}}, want: []string{"/var/run/docker.sock:/var/run/docker.sock"}},
+		{name: "denied bind", volumes: []interface{}{"/home/user/.ssh:/ssh:ro"}, policy: &policy.Policy{DeniedBinds: []string{"/home/*/.ssh"}}, wantErr: "blocked by policy rule denied_binds"},
+		{name: "no policy", volumes: []interface{}{"/var/run/docker.sock:/var/run/docker.sock"}, want: []string{"/var/run/docker.sock:/var/run/docker.sock"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVolumes(map[string]interface{}{"volumes": tt.volumes}, tt.policy)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseVolumes() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVolumes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVolumes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		ports       interface{}
+		wantExposed nat.PortSet
+		wantBinds   nat.PortMap
+		wantErr     string
+	}{
+		{
+			name:        "tcp and udp",
+			ports:       []interface{}{map[string]interface{}{"published": float64(8080), "target": float64(80)}, map[string]interface{}{"published": float64(53), "target": float64(53), "protocol": "udp"}},
+			wantExposed: nat.PortSet{"80/tcp": {}, "53/udp": {}},
+			wantBinds:   nat.PortMap{"80/tcp": {{HostPort: "8080"}}, "53/udp": {{HostPort: "53"}}},
+		},
+		{
+			name:        "same port on two host ports",
+			ports:       []interface{}{map[string]interface{}{"published": float64(80), "target": float64(80)}, map[string]interface{}{"published": float64(8080), "target": float64(80)}},
+			wantExposed: nat.PortSet{"80/tcp": {}},
+			wantBinds:   nat.PortMap{"80/tcp": {{HostPort: "80"}, {HostPort: "8080"}}},
+		},
+		{
+			name:        "expose only",
+			ports:       []interface{}{map[string]interface{}{"target": float64(9000)}},
+			wantExposed: nat.PortSet{"9000/tcp": {}},
+			wantBinds:   nat.PortMap{},
+		},
+		{name: "not a list", ports: "8080:80", wantErr: "ports must be a list"},
+		{name: "string entry", ports: []interface{}{"8080:80"}, wantErr: "ports[0]: expected a {published, target} object"},
+		{name: "no target", ports: []interface{}{map[string]interface{}{"published": float64(8080)}}, wantErr: "ports[0]: target is required"},
+		{name: "target out of range", ports: []interface{}{map[string]interface{}{"target": float64(70000)}}, wantErr: "target port 70000 is out of range (1-65535)"},
+		{name: "published zero", ports: []interface{}{map[string]interface{}{"published": float64(0), "target": float64(80)}}, wantErr: "published port 0 is out of range"},
+		{name: "fractional port", ports: []interface{}{map[string]interface{}{"target": 80.5}}, wantErr: "out of range"},
+		{name: "port range string", ports: []interface{}{map[string]interface{}{"target": "8000-8010"}}, wantErr: "target must be a number"},
+		{name: "unknown protocol", ports: []interface{}{map[string]interface{}{"target": float64(80), "protocol": "http"}}, wantErr: "protocol must be tcp, udp or sctp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exposed, binds, err := parsePorts(map[string]interface{}{"ports": tt.ports})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePorts() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePorts() error = %v", err)
+			}
+			if !reflect.DeepEqual(exposed, tt.wantExposed) || !reflect.DeepEqual(binds, tt.wantBinds) {
+				t.Errorf("parsePorts() = %v, %v; want %v, %v", exposed, binds, tt.wantExposed, tt.wantBinds)
+			}
+		})
+	}
+}
+
+// createDaemon accepts container creation, decoding the request body into created.
+func createDaemon(t *testing.T, created *container.CreateRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/containers/create" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(created); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "abc123"}`)
+	}
+}
+
+func TestCreateContainerSendsHostConfig(t *testing.T) {
+	var created container.CreateRequest
+	s := newTestServer(t, createDaemon(t, &created))
+	params := map[string]interface{}{
+		"name":           "db",
+		"image":          "mysql:8",
+		"restart_policy": "on-failure",
+		"max_retries":    float64(3),
+		"memory_mb":      float64(256),
+		"cpus":           0.5,
+		"privileged":     true,
+		"volumes":        []interface{}{map[string]interface{}{"source": "db-data", "target": "/var/lib/mysql"}},
+	}
+	if _, err := s.tools["create_container"].Handler(context.Background(), s, params); err != nil {
+		t.Fatalf("create_container: %v", err)
+	}
+	if created.Image != "mysql:8" {
+		t.Errorf("image = %q, want mysql:8", created.Image)
+	}
+	hc := created.HostConfig
+	if hc == nil {
+		t.Fatal("daemon received no host config")
+	}
+	if hc.RestartPolicy.Name != container.RestartPolicyOnFailure || hc.RestartPolicy.MaximumRetryCount != 3 {
+		t.Errorf("restart policy = %+v, want on-failure with 3 retries", hc.RestartPolicy)
+	}
+	if hc.Memory != 256*1024*1024 || hc.NanoCPUs != 500000000 {
+		t.Errorf("resources = memory %d, nano cpus %d; want 256MiB and half a CPU", hc.Memory, hc.NanoCPUs)
+	}
+	if len(hc.Binds) != 1 || hc.Binds[0] != "db-data:/var/lib/mysql" {
+		t.Errorf("binds = %q, want the db-data volume", hc.Binds)
+	}
+	if !hc.Privileged {
+		t.Error("host config is not privileged, want privileged")
+	}
+}
+
+func TestCreateContainerInheritsProjectRestartPolicy(t *testing.T) {
+	var created container.CreateRequest
+	s := newTestServer(t, createDaemon(t, &created))
+	writeConfig(t, "projects:\n  shop:\n    restart_policy: unless-stopped\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		project string
+		params  map[string]interface{}
+		want    container.RestartPolicyMode
+	}{
+		{name: "project default", project: "shop", params: map[string]interface{}{}, want: container.RestartPolicyUnlessStopped},
+		{name: "plan value wins", project: "shop", params: map[string]interface{}{"restart_policy": "no"}, want: container.RestartPolicyDisabled},
+		{name: "other project", project: "blog", params: map[string]interface{}{}, want: ""},
+		{name: "no project", params: map[string]interface{}{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created = container.CreateRequest{}
+			tt.params["name"] = "web"
+			tt.params["image"] = "nginx:latest"
+			ctx := withProject(context.Background(), tt.project)
+			if _, err := s.tools["create_container"].Handler(ctx, s, tt.params); err != nil {
+				t.Fatalf("create_container: %v", err)
+			}
+			if created.HostConfig == nil || created.HostConfig.RestartPolicy.Name != tt.want {
+				t.Errorf("host config = %+v, want restart policy %q", created.HostConfig, tt.want)
+			}
+			if _, set := tt.params["restart_policy"]; set != (tt.name == "plan value wins") {
+				t.Errorf("params = %v, want the caller's map left unchanged", tt.params)
+			}
+		})
+	}
+}
+
+func TestUpdateContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    container.UpdateConfig
+		wantErr string
+	}{
+		{
+			name:   "limits",
+			params: map[string]interface{}{"name": "web", "memory_mb": float64(256), "cpus": 0.5},
+			want:   container.UpdateConfig{Resources: container.Resources{Memory: 256 << 20, MemorySwap: 512 << 20, NanoCPUs: 500000000}},
+		},
+		{
+			name:   "explicit swap",
+			params: map[string]interface{}{"name": "web", "memory_mb": float64(256), "memory_swap_mb": float64(1024)},
+			want:   container.UpdateConfig{Resources: container.Resources{Memory: 256 << 20, MemorySwap: 1024 << 20}},
+		},
+		{
+			name:   "unlimited swap",
+			params: map[string]interface{}{"name": "web", "memory_mb": float64(256), "memory_swap_mb": float64(-1)},
+			want:   container.UpdateConfig{Resources: container.Resources{Memory: 256 << 20, MemorySwap: -1}},
+		},
+		{
+			name:   "restart policy",
+			params: map[string]interface{}{"name": "web", "restart_policy": "on-failure", "max_retries": float64(2)},
+			want:   container.UpdateConfig{RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 2}},
+		},
+		{name: "image is immutable", params: map[string]interface{}{"name": "web", "image": "nginx:1.27"}, wantErr: "image cannot be updated on an existing container; recreate container web"},
+		{name: "privileged is immutable", params: map[string]interface{}{"name": "web", "privileged": true}, wantErr: "privileged cannot be updated on an existing container; recreate container web"},
+		{name: "environment is immutable", params: map[string]interface{}{"name": "web", "memory_mb": float64(256), "environment": map[string]interface{}{"A": "1"}}, wantErr: "environment cannot be updated"},
+		{name: "nothing to change", params: map[string]interface{}{"name": "web"}, wantErr: "needs at least one of"},
+		{name: "swap below memory", params: map[string]interface{}{"name": "web", "memory_mb": float64(256), "memory_swap_mb": float64(128)}, wantErr: "memory_swap_mb must be at least memory_mb"},
+		{name: "swap without memory", params: map[string]interface{}{"name": "web", "memory_swap_mb": float64(512)}, wantErr: "memory_swap_mb needs memory_mb"},
+		{name: "missing container", params: map[string]interface{}{"name": "db", "cpus": float64(1)}, wantErr: "container db does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updates []container.UpdateConfig
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+					json.NewEncoder(w).Encode(map[string]interface{}{"Id": "abc", "Name": "/web", "Config": map[string]interface{}{}})
+				case r.Method == http.MethodPost && r.URL.Path == "/containers/web/update":
+					var update container.UpdateConfig
+					if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+						t.Error(err)
+					}
+					updates = append(updates, update)
+					json.NewEncoder(w).Encode(map[string]interface{}{"Warnings": []string{}})
+				default:
+					writeDaemonError(w, http.StatusNotFound, "No such container: "+r.URL.Path)
+				}
+			})
+			got, err := s.tools["update_container"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("update_container error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if len(updates) != 0 {
+					t.Errorf("sent updates %+v despite the error", updates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("update_container: %v", err)
+			}
+			if got["id"] != "abc" {
+				t.Errorf("update_container = %v, want the container's ID", got)
+			}
+			if len(updates) != 1 || !reflect.DeepEqual(updates[0], tt.want) {
+				t.Errorf("updates = %+v, want %+v", updates, tt.want)
+			}
+		})
+	}
+}
+
+// networkDaemon serves the network shop and the container web, recording connect and
+// disconnect requests.
+func networkDaemon(t *testing.T, connects *[]network.ConnectOptions, disconnects *[]network.DisconnectOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks/shop":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Id": "n1", "Name": "shop"})
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Id": "c1", "Name": "/web"})
+		case r.Method == http.MethodPost && r.URL.Path == "/networks/shop/connect":
+			var opts network.ConnectOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				t.Error(err)
+			}
+			*connects = append(*connects, opts)
+		case r.Method == http.MethodPost && r.URL.Path == "/networks/shop/disconnect":
+			var opts network.DisconnectOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				t.Error(err)
+			}
+			*disconnects = append(*disconnects, opts)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestPauseAndUnpauseContainer(t *testing.T) {
+	states := map[string]string{
+		"running": `{"Status": "running", "Running": true}`,
+		"paused":  `{"Status": "paused", "Running": true, "Paused": true}`,
+		"exited":  `{"Status": "exited"}`,
+	}
+	tests := []struct {
+		tool      string
+		container string
+		want      bool
+		wantErr   string
+		conflict  bool
+	}{
+		{tool: "pause_container", container: "running", want: true},
+		{tool: "pause_container", container: "paused", wantErr: "container paused is already paused", conflict: true},
+		{tool: "pause_container", container: "exited", wantErr: "container exited is not running (status exited); only a running container can be paused", conflict: true},
+		{tool: "pause_container", container: "missing", wantErr: "container missing does not exist"},
+		{tool: "pause_container", wantErr: "missing container name for pause_container"},
+		{tool: "unpause_container", container: "paused", want: false},
+		{tool: "unpause_container", container: "running", wantErr: "container running is not paused (status running)", conflict: true},
+		{tool: "unpause_container", container: "missing", wantErr: "container missing does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool+" "+tt.container, func(t *testing.T) {
+			var requests []string
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/containers/"), "/")
+				state, ok := states[name]
+				switch {
+				case !ok:
+					writeDaemonError(w, http.StatusNotFound, "No such container: "+name)
+				case r.Method == http.MethodGet && rest == "json":
+					w.Header().Set("Content-Type", "application/json")
+					io.WriteString(w, `{"Id": "`+name+`", "Name": "/`+name+`", "State": `+state+`}`)
+				case r.Method == http.MethodPost && (rest == "pause" || rest == "unpause"):
+					requests = append(requests, rest+" "+name)
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+				}
+			})
+			params := map[string]interface{}{}
+			if tt.container != "" {
+				params["name"] = tt.container
+			}
+			got, err := s.tools[tt.tool].Handler(context.Background(), s, params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("%s error = %v, want one containing %q", tt.tool, err, tt.wantErr)
+				}
+				if tt.conflict && !errdefs.IsConflict(err) {
+					t.Errorf("%s error %v is not classified as a conflict", tt.tool, err)
+				}
+				if len(requests) != 0 {
+					t.Errorf("daemon received %v despite the error", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.tool, err)
+			}
+			if got["name"] != tt.container || got["paused"] != tt.want {
+				t.Errorf("%s = %v, want %s with paused %v", tt.tool, got, tt.container, tt.want)
+			}
+			if want := strings.TrimSuffix(tt.tool, "_container") + " " + tt.container; len(requests) != 1 || requests[0] != want {
+				t.Errorf("daemon received %v, want %q", requests, want)
+			}
+		})
+	}
+}
+
+func TestConnectNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    []string
+		wantErr string
+	}{
+		{name: "aliases", params: map[string]interface{}{"container": "web", "network": "shop", "aliases": []interface{}{"frontend", "www"}}, want: []string{"frontend", "www"}},
+		{name: "no aliases", params: map[string]interface{}{"container": "web", "network": "shop"}},
+		{name: "missing network", params: map[string]interface{}{"container": "web", "network": "blog"}, wantErr: "network blog does not exist"},
+		{name: "missing container", params: map[string]interface{}{"container": "db", "network": "shop"}, wantErr: "container db does not exist"},
+		{name: "no container given", params: map[string]interface{}{"network": "shop"}, wantErr: "requires both container and network"},
+		{name: "aliases not a list", params: map[string]interface{}{"container": "web", "network": "shop", "aliases": "www"}, wantErr: "aliases must be a list of strings"},
+		{name: "empty alias", params: map[string]interface{}{"container": "web", "network": "shop", "aliases": []interface{}{""}}, wantErr: "aliases must be non-empty strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connects []network.ConnectOptions
+			var disconnects []network.DisconnectOptions
+			s := newTestServer(t, networkDaemon(t, &connects, &disconnects))
+			_, err := s.tools["connect_network"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("connect_network error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if len(connects) != 0 {
+					t.Errorf("connected %+v despite the error", connects)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("connect_network: %v", err)
+			}
+			if len(connects) != 1 || connects[0].Container != "web" || connects[0].EndpointConfig == nil {
+				t.Fatalf("connects = %+v, want web connected with endpoint settings", connects)
+			}
+			if got := connects[0].EndpointConfig.Aliases; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("endpoint aliases = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisconnectNetwork(t *testing.T) {
+	var connects []network.ConnectOptions
+	var disconnects []network.DisconnectOptions
+	s := newTestServer(t, networkDaemon(t, &connects, &disconnects))
+	if _, err := s.tools["disconnect_network"].Handler(context.Background(), s, map[string]interface{}{"container": "web", "network": "shop"}); err != nil {
+		t.Fatalf("disconnect_network: %v", err)
+	}
+	if len(disconnects) != 1 || disconnects[0].Container != "web" || disconnects[0].Force {
+		t.Errorf("disconnects = %+v, want web disconnected without force", disconnects)
+	}
+	_, err := s.tools["disconnect_network"].Handler(context.Background(), s, map[string]interface{}{"container": "web", "network": "blog"})
+	if err == nil || !strings.Contains(err.Error(), "network blog does not exist") {
+		t.Errorf("disconnect_network error = %v, want the missing network named", err)
+	}
+}
+
+// startDaemon starts the container named web and reports it in the given states, one per
+// inspect, repeating the last one, counting the inspects made.
+func startDaemon(states []string, inspects *int) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/containers/web/start":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			mu.Lock()
+			status := states[min(*inspects, len(states)-1)]
+			*inspects++
+			mu.Unlock()
+			exitCode := 0
+			if status == "exited" {
+				exitCode = 1
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":    "abc",
+				"Name":  "/web",
+				"State": map[string]interface{}{"Status": status, "Running": status == "running", "ExitCode": exitCode},
+			})
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestRunContainerWaitsForRunningState(t *testing.T) {
+	tests := []struct {
+		name         string
+		states       []string
+		attempts     float64
+		want         map[string]interface{}
+		wantErr      string
+		wantInspects int
+	}{
+		{
+			name:         "running after a few polls",
+			states:       []string{"created", "created", "running"},
+			want:         map[string]interface{}{"name": "web", "status": "running", "running": true, "exit_code": 0},
+			wantInspects: 3,
+		},
+		{
+			name:         "exited immediately",
+			states:       []string{"exited"},
+			want:         map[string]interface{}{"name": "web", "status": "exited", "running": false, "exit_code": 1},
+			wantInspects: 1,
+		},
+		{
+			name:     "never settles",
+			states:   []string{"created"},
+			attempts: 2,
+			wantErr:  "container web did not reach running state after 2 checks (status: created)",
+			// Two readiness checks, then one more to attach the container's diagnostics.
+			wantInspects: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inspects int
+			s := newTestServer(t, startDaemon(tt.states, &inspects))
+			params := map[string]interface{}{"name": "web"}
+			if tt.attempts > 0 {
+				params["start_attempts"] = tt.attempts
+			}
+			got, err := s.tools["run_container"].Handler(context.Background(), s, params)
+			if inspects != tt.wantInspects {
+				t.Errorf("inspected %d times, want %d", inspects, tt.wantInspects)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run_container error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run_container: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("run_container = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunContainerRejectsBadStartAttempts(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, err := s.tools["run_container"].Handler(context.Background(), s, map[string]interface{}{"name": "web", "start_attempts": float64(0)})
+	if err == nil || !strings.Contains(err.Error(), "start_attempts must be at least 1") {
+		t.Errorf("run_container error = %v, want start_attempts rejected", err)
+	}
+}
+
+func TestPullImageNormalizesReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{name: "short name", params: map[string]interface{}{"image": "redis"}, want: "redis:latest"},
+		{name: "name and tag", params: map[string]interface{}{"name": "nginx", "tag": "1.27"}, want: "nginx:1.27"},
+		{name: "name without tag", params: map[string]interface{}{"name": "docker.io/library/mysql"}, want: "mysql:latest"},
+		{name: "image wins over name", params: map[string]interface{}{"image": "ghcr.io/org/app:v1", "name": "other"}, want: "ghcr.io/org/app:v1"},
+		{name: "missing", params: map[string]interface{}{}, wantErr: "missing image name for pull_image"},
+		{name: "uppercase", params: map[string]interface{}{"image": "Redis"}, wantErr: "repository names must be lowercase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pulled []string
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/images/create" {
+					writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+					return
+				}
+				pulled = append(pulled, r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag"))
+				io.WriteString(w, `{"status": "Downloaded"}`+"\n")
+			})
+			got, err := s.tools["pull_image"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("pull_image error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if len(pulled) != 0 {
+					t.Errorf("pulled %v despite the error", pulled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pull_image: %v", err)
+			}
+			if got["image"] != tt.want {
+				t.Errorf("pull_image = %v, want image %s", got, tt.want)
+			}
+			if len(pulled) != 1 || pulled[0] != tt.want {
+				t.Errorf("daemon pulled %v, want %s", pulled, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullImageReusesLocalImages(t *testing.T) {
+	var inspects, pulls int
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/images/redis:latest/json":
+			inspects++
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"Id": "sha256:1", "RepoTags": ["redis:latest"]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/images/create":
+			pulls++
+			io.WriteString(w, `{"status": "Downloaded"}`+"\n")
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	})
+	pull := func(params map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		got, err := s.tools["pull_image"].Handler(context.Background(), s, params)
+		if err != nil {
+			t.Fatalf("pull_image: %v", err)
+		}
+		return got
+	}
+
+	if got := pull(map[string]interface{}{"image": "redis"}); got["pulled"] != false {
+		t.Errorf("pull_image = %v, want pulled false for a local image", got)
+	}
+	if pulls != 0 || inspects != 1 {
+		t.Errorf("daemon saw %d pulls and %d inspects, want 0 and 1", pulls, inspects)
+	}
+	pull(map[string]interface{}{"image": "redis"})
+	if pulls != 0 || inspects != 1 {
+		t.Errorf("second call made %d pulls and %d inspects, want the cached answer", pulls, inspects)
+	}
+	if got := pull(map[string]interface{}{"image": "redis", "force_pull": true}); got["pulled"] != true {
+		t.Errorf("pull_image = %v, want pulled true with force_pull", got)
+	}
+	if pulls != 1 {
+		t.Errorf("daemon saw %d pulls, want 1 with force_pull", pulls)
+	}
+}
+
+func TestPullImageRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		failures  int
+		status    int
+		wantPulls int
+		wantErr   string
+	}{
+		{name: "transient failure retried", failures: 1, status: http.StatusServiceUnavailable, wantPulls: 2},
+		{name: "attempts from the config", config: "docker_retry_attempts: 2\n", failures: 5, status: http.StatusServiceUnavailable, wantPulls: 2, wantErr: "failed after 2 attempts"},
+		{name: "missing image not retried", failures: 5, status: http.StatusNotFound, wantPulls: 1, wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pulls int
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/images/create" {
+					writeDaemonError(w, http.StatusNotFound, "No such image: "+r.URL.Path)
+					return
+				}
+				pulls++
+				if pulls <= tt.failures {
+					writeDaemonError(w, tt.status, "not found: try again")
+					return
+				}
+				io.WriteString(w, `{"status": "Downloaded"}`+"\n")
+			})
+			writeConfig(t, tt.config)
+			var result ReloadResult
+			if err := s.ReloadConfig(nil, &result); err != nil {
+				t.Fatalf("ReloadConfig() error = %v", err)
+			}
+			_, err := s.tools["pull_image"].Handler(context.Background(), s, map[string]interface{}{"image": "redis"})
+			if pulls != tt.wantPulls {
+				t.Errorf("daemon saw %d pulls, want %d", pulls, tt.wantPulls)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("pull_image: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("pull_image error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPullImageUsesPullTimeout(t *testing.T) {
+	t.Setenv(config.EnvPullTimeout, "50ms")
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/create" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		<-r.Context().Done()
+	})
+	if s.timeouts.Pull != 50*time.Millisecond {
+		t.Fatalf("pull timeout = %v, want the %s override", s.timeouts.Pull, config.EnvPullTimeout)
+	}
+	_, err := s.tools["pull_image"].Handler(context.Background(), s, map[string]interface{}{"image": "redis"})
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("pull_image error = %v, want the pull to time out", err)
+	}
+}
+
+func TestTagImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{name: "retag", params: map[string]interface{}{"source": "myapp:build", "target": "myapp:latest"}, want: "myapp:latest"},
+		{name: "registry target", params: map[string]interface{}{"source": "myapp:build", "target": "ghcr.io/org/myapp:v1"}, want: "ghcr.io/org/myapp:v1"},
+		{name: "untagged target", params: map[string]interface{}{"source": "myapp:build", "target": "myapp"}, want: "myapp:latest"},
+		{name: "missing target", params: map[string]interface{}{"source": "myapp:build"}, wantErr: "tag_image requires both source and target"},
+		{name: "invalid target", params: map[string]interface{}{"source": "myapp:build", "target": "MyApp"}, wantErr: "invalid target image"},
+		{name: "missing source image", params: map[string]interface{}{"source": "other:build", "target": "other:latest"}, wantErr: "source image other:build does not exist locally"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tagged []string
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/images/myapp:build/json":
+					io.WriteString(w, `{"Id": "sha256:1", "RepoTags": ["myapp:build"]}`)
+				case r.Method == http.MethodPost && r.URL.Path == "/images/myapp:build/tag":
+					tagged = append(tagged, r.URL.Query().Get("repo")+":"+r.URL.Query().Get("tag"))
+					w.WriteHeader(http.StatusCreated)
+				default:
+					writeDaemonError(w, http.StatusNotFound, "No such image: "+r.URL.Path)
+				}
+			})
+			got, err := s.tools["tag_image"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("tag_image error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if len(tagged) != 0 {
+					t.Errorf("tagged %v despite the error", tagged)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tag_image: %v", err)
+			}
+			if got["source"] != "myapp:build" || got["target"] != tt.want {
+				t.Errorf("tag_image = %v, want myapp:build tagged as %s", got, tt.want)
+			}
+			if len(tagged) != 1 || tagged[0] != tt.want {
+				t.Errorf("daemon tagged %v, want %s", tagged, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      map[string]interface{}
+		wantDeleted []string
+		wantQuery   string
+		wantErr     string
+	}{
+		{name: "removal", params: map[string]interface{}{"image": "redis"}, wantDeleted: []string{"sha256:1"}},
+		{name: "forced without pruning", params: map[string]interface{}{"image": "redis", "force": true, "prune_children": false}, wantDeleted: []string{"sha256:1"}, wantQuery: "force=1&noprune=1"},
+		{name: "missing image parameter", params: map[string]interface{}{}, wantErr: "missing image for remove_image"},
+		{name: "invalid reference", params: map[string]interface{}{"image": "Redis"}, wantErr: "invalid image"},
+		{name: "missing image", params: map[string]interface{}{"image": "other"}, wantErr: "image other:latest does not exist locally"},
+		{name: "used by a stopped container", params: map[string]interface{}{"image": "mysql"}, wantErr: "image mysql:latest is in use by a container; remove the container first, or set force if it is stopped"},
+		{name: "used by a running container", params: map[string]interface{}{"image": "nginx", "force": true}, wantErr: "image nginx:latest is in use by a container; stop and remove the container first"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete {
+					writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+					return
+				}
+				query = r.URL.RawQuery
+				switch r.URL.Path {
+				case "/images/redis:latest":
+					w.Header().Set("Content-Type", "application/json")
+					io.WriteString(w, `[{"Untagged": "redis:latest"}, {"Deleted": "sha256:1"}]`)
+				case "/images/mysql:latest":
+					writeDaemonError(w, http.StatusConflict, `conflict: unable to remove repository reference "mysql:latest" (must force) - container c1 is using its referenced image sha256:2`)
+				case "/images/nginx:latest":
+					writeDaemonError(w, http.StatusConflict, "conflict: unable to delete nginx:latest (cannot be forced) - image is being used by running container c2")
+				default:
+					writeDaemonError(w, http.StatusNotFound, "No such image: "+r.URL.Path)
+				}
+			})
+			s.images.add("redis:latest")
+			got, err := s.tools["remove_image"].Handler(context.Background(), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("remove_image error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("remove_image: %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("daemon query = %q, want %q", query, tt.wantQuery)
+			}
+			if got["image"] != "redis:latest" || !reflect.DeepEqual(got["untagged"], []string{"redis:latest"}) || !reflect.DeepEqual(got["deleted"], tt.wantDeleted) {
+				t.Errorf("remove_image = %v, want redis:latest untagged and %v deleted", got, tt.wantDeleted)
+			}
+			if s.images.present("redis:latest", time.Hour) {
+				t.Error("removed image is still cached as present")
+			}
+		})
+	}
+}
+
+func TestCreateContainerNormalizesImage(t *testing.T) {
+	var created container.CreateRequest
+	s := newTestServer(t, createDaemon(t, &created))
+	if _, err := s.tools["create_container"].Handler(context.Background(), s, map[string]interface{}{"name": "db", "image": "docker.io/library/mysql"}); err != nil {
+		t.Fatalf("create_container: %v", err)
+	}
+	if created.Image != "mysql:latest" {
+		t.Errorf("image = %q, want mysql:latest", created.Image)
+	}
+	_, err := s.tools["create_container"].Handler(context.Background(), s, map[string]interface{}{"name": "db", "image": "mysql::8"})
+	if err == nil || !strings.Contains(err.Error(), "invalid image reference") {
+		t.Errorf("create_container error = %v, want the reference rejected", err)
+	}
+}
+
+func TestComposeUpArguments(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{name: "missing project", params: map[string]interface{}{"content": "services: {}"}, wantErr: "missing project name for compose_up"},
+		{name: "no compose file", params: map[string]interface{}{"project": "shop"}, wantErr: "compose_up requires either file or content"},
+		{name: "missing file", params: map[string]interface{}{"project": "shop", "file": "/nonexistent/compose.yaml"}, wantErr: "failed to read compose file"},
+		{name: "invalid content", params: map[string]interface{}{"project": "shop", "content": "services:\n  web: {}\n"}, wantErr: `service "web" has no image`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.tools["compose_up"].Handler(context.Background(), s, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("compose_up error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetworkCreateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    network.CreateOptions
+		wantErr string
+	}{
+		{name: "defaults", params: map[string]interface{}{}, want: network.CreateOptions{}},
+		{
+			name:   "macvlan with driver options",
+			params: map[string]interface{}{"driver": "MacVLAN", "driver_opts": map[string]interface{}{"parent": "eth0"}},
+			want:   network.CreateOptions{Driver: "macvlan", Options: map[string]string{"parent": "eth0"}},
+		},
+		{
+			name:   "attachable internal overlay",
+			params: map[string]interface{}{"driver": "overlay", "internal": true, "attachable": true},
+			want:   network.CreateOptions{Driver: "overlay", Internal: true, Attachable: true},
+		},
+		{name: "plugin driver", params: map[string]interface{}{"driver": "weaveworks/net-plugin:latest"}, want: network.CreateOptions{Driver: "weaveworks/net-plugin:latest"}},
+		{
+			name:   "ipam",
+			params: map[string]interface{}{"ipam": map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "172.28.0.1"}},
+			want:   network.CreateOptions{IPAM: &network.IPAM{Config: []network.IPAMConfig{{Subnet: "172.28.0.0/16", Gateway: "172.28.0.1"}}}},
+		},
+		{name: "empty ipam", params: map[string]interface{}{"ipam": map[string]interface{}{}}, want: network.CreateOptions{}},
+		{name: "host driver", params: map[string]interface{}{"driver": "host"}, wantErr: "the host driver has a single built-in network"},
+		{name: "invalid driver", params: map[string]interface{}{"driver": "my driver"}, wantErr: `invalid network driver "my driver"`},
+		{name: "driver option not a string", params: map[string]interface{}{"driver_opts": map[string]interface{}{"mtu": float64(1400)}}, wantErr: "driver_opts.mtu must be a string"},
+		{name: "driver options not an object", params: map[string]interface{}{"driver_opts": "parent=eth0"}, wantErr: "driver_opts must be an object of strings"},
+		{name: "gateway without subnet", params: map[string]interface{}{"ipam": map[string]interface{}{"gateway": "172.28.0.1"}}, wantErr: "ipam.gateway requires ipam.subnet"},
+		{name: "bad subnet", params: map[string]interface{}{"ipam": map[string]interface{}{"subnet": "172.28.0.0"}}, wantErr: `ipam.subnet "172.28.0.0" is not a CIDR`},
+		{name: "bad gateway", params: map[string]interface{}{"ipam": map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "gw"}}, wantErr: `ipam.gateway "gw" is not an IP address`},
+		{name: "gateway outside subnet", params: map[string]interface{}{"ipam": map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "10.0.0.1"}}, wantErr: "ipam.gateway 10.0.0.1 is outside ipam.subnet 172.28.0.0/16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := networkCreateOptions(context.Background(), tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("networkCreateOptions() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("networkCreateOptions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("networkCreateOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateNetworkSendsOptions(t *testing.T) {
+	var created network.CreateRequest
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/networks/create" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "n1"}`)
+	})
+	params := map[string]interface{}{
+		"name":        "lan",
+		"driver":      "macvlan",
+		"driver_opts": map[string]interface{}{"parent": "eth0"},
+		"ipam":        map[string]interface{}{"subnet": "192.168.10.0/24"},
+	}
+	if _, err := s.tools["create_network"].Handler(withProject(context.Background(), "shop"), s, params); err != nil {
+		t.Fatalf("create_network: %v", err)
+	}
+	if created.Name != "lan" || created.Driver != "macvlan" || created.Options["parent"] != "eth0" || created.Labels[docker.ProjectLabel] != "shop" {
+		t.Errorf("daemon received %+v, want the macvlan driver, its options and the project label", created)
+	}
+	if created.IPAM == nil || len(created.IPAM.Config) != 1 || created.IPAM.Config[0].Subnet != "192.168.10.0/24" {
+		t.Errorf("daemon received IPAM %+v, want the subnet", created.IPAM)
+	}
+}
+
+func TestCreateVolumeSendsOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    volume.CreateOptions
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			params: map[string]interface{}{"name": "data"},
+			want:   volume.CreateOptions{Name: "data", Driver: "local", Labels: map[string]string{docker.ProjectLabel: "shop"}},
+		},
+		{
+			name: "nfs mount",
+			params: map[string]interface{}{
+				"name":        "data",
+				"driver_opts": map[string]interface{}{"type": "nfs", "o": "addr=10.0.0.5,rw", "device": ":/exports/data"},
+				"labels":      map[string]interface{}{"tier": "db", docker.ProjectLabel: "other"},
+			},
+			want: volume.CreateOptions{
+				Name:       "data",
+				Driver:     "local",
+				DriverOpts: map[string]string{"type": "nfs", "o": "addr=10.0.0.5,rw", "device": ":/exports/data"},
+				Labels:     map[string]string{"tier": "db", docker.ProjectLabel: "shop"},
+			},
+		},
+		{
+			name:   "plugin driver",
+			params: map[string]interface{}{"name": "data", "driver": "rexray/ebs", "driver_opts": map[string]interface{}{"size": "20"}},
+			want:   volume.CreateOptions{Name: "data", Driver: "rexray/ebs", DriverOpts: map[string]string{"size": "20"}, Labels: map[string]string{docker.ProjectLabel: "shop"}},
+		},
+		{name: "driver option not a string", params: map[string]interface{}{"name": "data", "driver_opts": map[string]interface{}{"size": float64(20)}}, wantErr: "driver_opts.size must be a string"},
+		{name: "labels not an object", params: map[string]interface{}{"name": "data", "labels": []interface{}{"tier=db"}}, wantErr: "labels must be an object of strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *volume.CreateOptions
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/volumes/create" {
+					writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+					return
+				}
+				created = &volume.CreateOptions{}
+				if err := json.NewDecoder(r.Body).Decode(created); err != nil {
+					t.Error(err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"Name": "data"}`)
+			})
+			_, err := s.tools["create_volume"].Handler(withProject(context.Background(), "shop"), s, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("create_volume error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if created != nil {
+					t.Errorf("daemon created %+v despite the error", created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("create_volume: %v", err)
+			}
+			if created == nil || !reflect.DeepEqual(*created, tt.want) {
+				t.Errorf("daemon received %+v, want %+v", created, tt.want)
+			}
+		})
+	}
+}
+
+// existingDaemon reports a container web running the given image with the given labels, a
+// network and a volume named shared, and counts the create requests it receives.
+func existingDaemon(image string, labels map[string]string, creates *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":     "c1",
+				"Name":   "/web",
+				"Config": map[string]interface{}{"Image": image, "Labels": labels},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/networks/shared":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Id": "n1", "Name": "shared", "Driver": "bridge", "Labels": labels})
+		case r.Method == http.MethodGet && r.URL.Path == "/volumes/shared":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Name": "shared", "Driver": "local", "Labels": labels})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/create"):
+			*creates++
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id": "new", "Name": "new"}`)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestCreateHandlersReuseExistingResources(t *testing.T) {
+	owned := map[string]string{docker.ProjectLabel: "shop"}
+	tests := []struct {
+		name        string
+		tool        string
+		params      map[string]interface{}
+		project     string
+		image       string
+		labels      map[string]string
+		want        map[string]interface{}
+		wantErr     string
+		wantCreates int
+	}{
+		{
+			name:   "container with the same image",
+			tool:   "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx"},
+			image:  "nginx:latest",
+			want:   map[string]interface{}{"id": "c1", "existing": true},
+		},
+		{
+			name:    "container with a conflicting image",
+			tool:    "create_container",
+			params:  map[string]interface{}{"name": "web", "image": "nginx:1.27"},
+			image:   "nginx:latest",
+			wantErr: "container web already exists with image nginx:latest instead of nginx:1.27",
+		},
+		{
+			name:        "new container",
+			tool:        "create_container",
+			params:      map[string]interface{}{"name": "api", "image": "nginx"},
+			want:        map[string]interface{}{"id": "new"},
+			wantCreates: 1,
+		},
+		{
+			name:        "not idempotent",
+			tool:        "create_container",
+			params:      map[string]interface{}{"name": "web", "image": "nginx", "idempotent": false},
+			image:       "nginx:latest",
+			want:        map[string]interface{}{"id": "new"},
+			wantCreates: 1,
+		},
+		{
+			name:    "network in the same project",
+			tool:    "create_network",
+			params:  map[string]interface{}{"name": "shared"},
+			project: "shop",
+			labels:  owned,
+			want:    map[string]interface{}{"id": "n1", "existing": true},
+		},
+		{
+			name:    "network of another project",
+			tool:    "create_network",
+			params:  map[string]interface{}{"name": "shared"},
+			project: "blog",
+			labels:  owned,
+			wantErr: "network shared already exists but belongs to project shop",
+		},
+		{
+			name:   "network with the same driver",
+			tool:   "create_network",
+			params: map[string]interface{}{"name": "shared", "driver": "bridge"},
+			want:   map[string]interface{}{"id": "n1", "existing": true},
+		},
+		{
+			name:    "network with another driver",
+			tool:    "create_network",
+			params:  map[string]interface{}{"name": "shared", "driver": "overlay"},
+			wantErr: "network shared already exists with driver bridge, not overlay; remove it first to change the driver",
+		},
+		{
+			name:    "unlabelled volume",
+			tool:    "create_volume",
+			params:  map[string]interface{}{"name": "shared"},
+			project: "shop",
+			wantErr: "volume shared already exists but is not part of project shop",
+		},
+		{
+			name:    "volume with another driver",
+			tool:    "create_volume",
+			params:  map[string]interface{}{"name": "shared", "driver": "nfs-plugin"},
+			wantErr: "volume shared already exists with driver local, not nfs-plugin; remove it first to change the driver",
+		},
+		{
+			name:   "volume outside a project",
+			tool:   "create_volume",
+			params: map[string]interface{}{"name": "shared"},
+			want:   map[string]interface{}{"id": "shared", "existing": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var creates int
+			s := newTestServer(t, existingDaemon(tt.image, tt.labels, &creates))
+			ctx := context.Background()
+			if tt.project != "" {
+				ctx = withProject(ctx, tt.project)
+			}
+			got, err := s.tools[tt.tool].Handler(ctx, s, tt.params)
+			if creates != tt.wantCreates {
+				t.Errorf("daemon received %d create requests, want %d", creates, tt.wantCreates)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("%s error = %v, want one containing %q", tt.tool, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.tool, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestroyProjectRemovesRecordedResources(t *testing.T) {
+	var mu sync.Mutex
+	var removed []string
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/networks/create":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id": "n1"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/create":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Name": "v1"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/containers/create":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id": "c1"}`)
+		case r.Method == http.MethodDelete:
+			if r.URL.Path == "/volumes/gone" {
+				writeDaemonError(w, http.StatusNotFound, "no such volume")
+				return
+			}
+			mu.Lock()
+			removed = append(removed, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	})
+	plan := `{"project": "shop", "plan": [
+		{"action": "create_network", "parameters": {"name": "shop-net"}},
+		{"action": "create_volume", "parameters": {"name": "v1"}},
+		{"action": "create_container", "parameters": {"name": "web", "image": "nginx"}}
+	]}`
+	var reply mcp.RPCResponse
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil || reply.Error != nil {
+		t.Fatalf("ExecutePlan() = %v, %v", reply.Error, err)
+	}
+	st, err := state.LoadProjectState("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Plans) != 1 || len(st.Resources) != 3 {
+		t.Fatalf("state = %+v, want one plan and three resources", st)
+	}
+	// A resource already removed outside the server is skipped.
+	if err := state.UpdateProjectState("shop", func(st *state.ProjectState) error {
+		st.Resources = append([]state.ResourceRef{{Type: "volume", Name: "gone", ID: "gone"}}, st.Resources...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.tools["destroy_project"].Handler(context.Background(), s, map[string]interface{}{"project": "shop"})
+	if err != nil {
+		t.Fatalf("destroy_project: %v", err)
+	}
+	want := []string{"/containers/c1", "/volumes/v1", "/networks/n1"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	if refs, _ := got["removed"].([]state.ResourceRef); len(refs) != 4 {
+		t.Errorf("destroy_project reported %v, want 4 resources", got["removed"])
+	}
+	if st, _ := state.LoadProjectState("shop"); len(st.Resources) != 0 {
+		t.Errorf("resources still recorded after destroy: %+v", st.Resources)
+	}
+}
+
+func TestDestroyProjectKeepsResourcesAfterFailure(t *testing.T) {
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonError(w, http.StatusConflict, "network has active endpoints")
+	})
+	resources := []state.ResourceRef{{Type: "network", Name: "shop-net", ID: "n1"}}
+	if err := state.SaveProjectState(&state.ProjectState{Project: "shop", Resources: resources}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.tools["destroy_project"].Handler(context.Background(), s, map[string]interface{}{"project": "shop"})
+	if err == nil || !strings.Contains(err.Error(), "failed to remove network shop-net") {
+		t.Fatalf("destroy_project error = %v, want the removal failure", err)
+	}
+	if st, _ := state.LoadProjectState("shop"); !reflect.DeepEqual(st.Resources, resources) {
+		t.Errorf("resources = %+v, want the network still recorded", st.Resources)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds each dependency check made by /readyz.
+const readinessTimeout = 5 * time.Second
+
+// dependencyStatus reports one dependency checked by /readyz.
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleHealthz is the liveness probe: the process is up and serving HTTP.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// handleReadyz is the readiness probe. It pings the Docker daemon and, when
+// ready_check_llm is enabled in the configuration, makes a one-token LLM request (off by
+// default, since it uses API quota). It returns 200 only if every check passes, and 503
+// otherwise, with each dependency's status in the body.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	deps := map[string]dependencyStatus{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			deps[name] = dependencyStatus{Status: "unavailable", Error: err.Error()}
+			ready = false
+			return
+		}
+		deps[name] = dependencyStatus{Status: "ok"}
+	}
+	check("docker", s.pingDaemon(ctx))
+	if s.config().ReadyCheckLLM {
+		check("llm", s.llm().Ping(ctx))
+	} else {
+		deps["llm"] = dependencyStatus{Status: "skipped"}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, map[string]interface{}{"status": status, "dependencies": deps})
+}
+
+func writeHealth(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// probe calls a health handler and decodes its JSON body.
+func probe(t *testing.T, handler http.HandlerFunc) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	return rec.Code, body
+}
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t, nil)
+	if code, body := probe(t, s.handleHealthz); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("healthz = %d %v, want 200 ok", code, body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t, nil)
+	code, body := probe(t, s.handleReadyz)
+	deps, _ := body["dependencies"].(map[string]interface{})
+	if code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("readyz = %d %v, want 200 ready", code, body)
+	}
+	if docker, _ := deps["docker"].(map[string]interface{}); docker["status"] != "ok" {
+		t.Errorf("docker = %v, want ok", deps["docker"])
+	}
+	if llm, _ := deps["llm"].(map[string]interface{}); llm["status"] != "skipped" {
+		t.Errorf("llm = %v, want skipped unless ready_check_llm is set", deps["llm"])
+	}
+}
+
+func TestReadyzReportsDockerPingError(t *testing.T) {
+	useFakeDaemon(t, nil)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	s, err := NewServer(WithDockerHost("tcp://" + down.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	code, body := probe(t, s.handleReadyz)
+	deps, _ := body["dependencies"].(map[string]interface{})
+	docker, _ := deps["docker"].(map[string]interface{})
+	if code != http.StatusServiceUnavailable || body["status"] != "not_ready" {
+		t.Errorf("readyz = %d %v, want 503 not_ready", code, body)
+	}
+	if docker["status"] != "unavailable" || !strings.Contains(docker["error"].(string), "connect") {
+		t.Errorf("docker = %v, want unavailable with the ping error", docker)
+	}
+	if code, _ := probe(t, s.handleHealthz); code != http.StatusOK {
+		t.Errorf("healthz = %d with Docker down, want 200", code)
+	}
+}
+
+func TestReadyzChecksLLMWhenConfigured(t *testing.T) {
+	requests := useFakeLLM(t, "pong")
+	s := newTestServer(t, nil)
+	writeConfig(t, "ready_check_llm: true\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatal(err)
+	}
+	code, body := probe(t, s.handleReadyz)
+	deps, _ := body["dependencies"].(map[string]interface{})
+	if llm, _ := deps["llm"].(map[string]interface{}); code != http.StatusOK || llm["status"] != "ok" {
+		t.Errorf("readyz = %d %v, want the LLM checked and ok", code, body)
+	}
+	if len(*requests) != 1 || (*requests)[0]["max_tokens"] != float64(1) {
+		t.Errorf("LLM requests = %v, want one limited to a single token", *requests)
+	}
+}
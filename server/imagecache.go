@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// defaultImageCacheTTL is how long a confirmed local image is trusted without inspecting it
+// again, when image_cache_ttl_seconds is not configured.
+const defaultImageCacheTTL = time.Minute
+
+// imageCache remembers which image references were recently seen locally, so repeated
+// plans do not inspect (or pull) the same image over and over.
+type imageCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// present reports whether image was seen locally within ttl.
+func (c *imageCache) present(image string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at, ok := c.seen[image]
+	if ok && time.Since(at) > ttl {
+		delete(c.seen, image)
+		return false
+	}
+	return ok
+}
+
+// add records that image is present locally.
+func (c *imageCache) add(image string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = map[string]time.Time{}
+	}
+	c.seen[image] = time.Now()
+}
+
+// forget drops image, so the next use inspects the daemon again.
+func (c *imageCache) forget(image string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, image)
+}
+
+// imageCacheTTL returns the configured image cache lifetime.
+func (s *Server) imageCacheTTL() time.Duration {
+	if secs := s.config().ImageCacheTTLSeconds; secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultImageCacheTTL
+}
+
+// dockerRetryAttempts returns how many times transient Docker failures are tried.
+func (s *Server) dockerRetryAttempts() int {
+	if n := s.config().DockerRetryAttempts; n > 0 {
+		return n
+	}
+	return docker.DefaultRetryAttempts
+}
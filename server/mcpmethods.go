@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// serverName and serverVersion identify the server to MCP clients.
+const (
+	serverName    = "mcp-godocker"
+	serverVersion = "0.1.0"
+)
+
+// mcpMethod implements one Model Context Protocol method.
+type mcpMethod func(ctx context.Context, s *Server, params json.RawMessage) (interface{}, *mcp.RPCError)
+
+// mcpMethods maps the standard MCP method names onto the server's implementations. Methods
+// not listed here fall through to the legacy "Server.*" names.
+var mcpMethods = map[string]mcpMethod{
+	"initialize":                mcpInitialize,
+	"notifications/initialized": func(context.Context, *Server, json.RawMessage) (interface{}, *mcp.RPCError) { return nil, nil },
+	"ping":                      func(context.Context, *Server, json.RawMessage) (interface{}, *mcp.RPCError) { return struct{}{}, nil },
+	"tools/list":                mcpListTools,
+	"tools/call":                mcpCallTool,
+	"prompts/list":              mcpListPrompts,
+	"prompts/get":               mcpGetPrompt,
+}
+
+func mcpInitialize(context.Context, *Server, json.RawMessage) (interface{}, *mcp.RPCError) {
+	return mcp.InitializeResult{
+		ProtocolVersion: mcp.ProtocolVersion,
+		Capabilities: map[string]interface{}{
+			"tools":   map[string]interface{}{},
+			"prompts": map[string]interface{}{},
+		},
+		ServerInfo: mcp.ServerInfo{Name: serverName, Version: serverVersion},
+	}, nil
+}
+
+// mcpListTools lists the registered tools sorted by name.
+func mcpListTools(_ context.Context, s *Server, _ json.RawMessage) (interface{}, *mcp.RPCError) {
+	tools := make([]mcp.ToolInfo, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, mcp.ToolInfo{Name: t.Name, Description: t.Description, InputSchema: t.InputSchema})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return mcp.ListToolsResult{Tools: tools}, nil
+}
+
+// mcpCallTool runs a tool through CallTool and reports its result, or its failure, as text
+// content.
+func mcpCallTool(ctx context.Context, s *Server, params json.RawMessage) (interface{}, *mcp.RPCError) {
+	var p mcp.CallToolParams
+	if err := decodeParams(params, &p); err != nil || p.Name == "" {
+		return nil, mcp.NewError(-32602, "tools/call requires params {\"name\": ..., \"arguments\": {...}}")
+	}
+	if _, ok := s.tools[p.Name]; !ok {
+		return nil, mcp.NewError(-32602, s.unknownToolMessage("tool", p.Name))
+	}
+	args := mcp.ToolCallArgs{ToolName: p.Name, Parameters: p.Arguments}
+	if args.Parameters == nil {
+		args.Parameters = map[string]interface{}{}
+	}
+	var resp mcp.RPCResponse
+	if err := s.CallTool(ctx, &args, &resp); err != nil {
+		return nil, mcp.NewError(-32603, err.Error())
+	}
+	if resp.Error != nil {
+		return mcp.CallToolResult{
+			Content: []mcp.TextContent{{Type: "text", Text: resp.Error.Message}},
+			IsError: true,
+		}, nil
+	}
+	return mcp.CallToolResult{Content: []mcp.TextContent{{Type: "text", Text: string(resp.Result)}}}, nil
+}
+
+func mcpListPrompts(context.Context, *Server, json.RawMessage) (interface{}, *mcp.RPCError) {
+	return mcp.ListPromptsResult{Prompts: mcp.ListPrompts()}, nil
+}
+
+func mcpGetPrompt(ctx context.Context, s *Server, params json.RawMessage) (interface{}, *mcp.RPCError) {
+	var p mcp.GetPromptParams
+	if err := decodeParams(params, &p); err != nil || p.Name == "" {
+		return nil, mcp.NewError(-32602, "prompts/get requires params {\"name\": ..., \"arguments\": {...}}")
+	}
+	result, err := mcp.GetPrompt(ctx, s.dockerClient, p.Name, p.Arguments)
+	if err != nil {
+		return nil, mcp.NewError(-32602, fmt.Sprintf("prompts/get %s: %v", p.Name, err))
+	}
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// postRPC posts a JSON-RPC request body to the server's /rpc handler and decodes the
+// response, returning nil for an empty body.
+func postRPC(t *testing.T, s *Server, body string) (int, map[string]interface{}) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRPC))
+	defer srv.Close()
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("decoding response to %s: %v", body, err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestMCPMethods(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+
+	_, resp := postRPC(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2024-11-05"}}`)
+	result, _ := resp["result"].(map[string]interface{})
+	info, _ := result["serverInfo"].(map[string]interface{})
+	caps, _ := result["capabilities"].(map[string]interface{})
+	if resp["jsonrpc"] != "2.0" || resp["id"] != float64(1) || result["protocolVersion"] != "2024-11-05" || info["name"] != "mcp-godocker" || caps["tools"] == nil || caps["prompts"] == nil {
+		t.Errorf("initialize = %v, want the protocol version, server info and capabilities", resp)
+	}
+
+	_, resp = postRPC(t, s, `{"jsonrpc": "2.0", "id": "list", "method": "tools/list"}`)
+	result, _ = resp["result"].(map[string]interface{})
+	tools, _ := result["tools"].([]interface{})
+	var names []string
+	for _, tool := range tools {
+		tool := tool.(map[string]interface{})
+		names = append(names, tool["name"].(string))
+		if schema, _ := tool["inputSchema"].(map[string]interface{}); schema["type"] != "object" {
+			t.Errorf("tool %v has no object inputSchema", tool["name"])
+		}
+	}
+	if resp["id"] != "list" || len(names) != len(s.tools) || !sort.StringsAreSorted(names) {
+		t.Errorf("tools/list names = %v, want all %d tools sorted", names, len(s.tools))
+	}
+
+	_, resp = postRPC(t, s, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "count", "arguments": {}}}`)
+	result, _ = resp["result"].(map[string]interface{})
+	content, _ := result["content"].([]interface{})
+	if len(content) != 1 || result["isError"] != nil {
+		t.Fatalf("tools/call = %v, want one text content item", resp)
+	}
+	if item := content[0].(map[string]interface{}); item["type"] != "text" || !strings.Contains(item["text"].(string), `"calls":1`) {
+		t.Errorf("tools/call content = %v, want the tool result as text", item)
+	}
+
+	fail = true
+	_, resp = postRPC(t, s, `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "count"}}`)
+	result, _ = resp["result"].(map[string]interface{})
+	content, _ = result["content"].([]interface{})
+	if resp["error"] != nil || result["isError"] != true || len(content) != 1 || !strings.Contains(content[0].(map[string]interface{})["text"].(string), "count failed") {
+		t.Errorf("failing tools/call = %v, want the failure as content with isError", resp)
+	}
+
+	_, resp = postRPC(t, s, `{"jsonrpc": "2.0", "id": 4, "method": "prompts/list"}`)
+	result, _ = resp["result"].(map[string]interface{})
+	if prompts, _ := result["prompts"].([]interface{}); len(prompts) == 0 {
+		t.Errorf("prompts/list = %v, want the registered prompts", resp)
+	}
+
+	_, resp = postRPC(t, s, `{"jsonrpc": "2.0", "id": 5, "method": "ping"}`)
+	if result, ok := resp["result"].(map[string]interface{}); !ok || len(result) != 0 {
+		t.Errorf("ping = %v, want an empty result", resp)
+	}
+
+	errorTests := []struct {
+		name     string
+		body     string
+		wantCode float64
+		wantMsg  string
+	}{
+		{name: "unknown tool", body: `{"jsonrpc": "2.0", "id": 6, "method": "tools/call", "params": {"name": "nope"}}`, wantCode: -32602, wantMsg: "nope"},
+		{name: "tools/call without a name", body: `{"jsonrpc": "2.0", "id": 7, "method": "tools/call", "params": {}}`, wantCode: -32602, wantMsg: "tools/call requires params"},
+		{name: "prompts/get without a name", body: `{"jsonrpc": "2.0", "id": 8, "method": "prompts/get", "params": {}}`, wantCode: -32602, wantMsg: "prompts/get requires params"},
+		{name: "unknown prompt", body: `{"jsonrpc": "2.0", "id": 9, "method": "prompts/get", "params": {"name": "nope"}}`, wantCode: -32602, wantMsg: "unknown prompt name: nope"},
+		{name: "unknown method", body: `{"jsonrpc": "2.0", "id": 10, "method": "resources/list"}`, wantCode: -32601, wantMsg: "method not found"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resp := postRPC(t, s, tt.body)
+			rpcErr, _ := resp["error"].(map[string]interface{})
+			if rpcErr == nil || rpcErr["code"] != tt.wantCode || !strings.Contains(rpcErr["message"].(string), tt.wantMsg) {
+				t.Errorf("response = %v, want error %v containing %q", resp, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+
+	if status, _ := postRPC(t, s, `{"jsonrpc": "2.0", "method": "notifications/initialized"}`); status != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", status)
+	}
+}
+
+func TestHandleRPCKeepsLegacyMethods(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	_, resp := postRPC(t, s, `{"id": 1, "method": "Server.CallTool", "params": [{"tool_name": "count", "parameters": {}}]}`)
+	reply, _ := resp["result"].(map[string]interface{})
+	result, _ := reply["result"].(map[string]interface{})
+	if resp["error"] != nil || result["status"] != "success" || calls != 1 {
+		t.Errorf("Server.CallTool = %v, want the net/rpc response", resp)
+	}
+}
+
+func TestStdioToolsList(t *testing.T) {
+	s := newTestServer(t, nil)
+	send := stdioSession(t, s)
+	resp := send(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	result, _ := resp["result"].(map[string]interface{})
+	tools, _ := result["tools"].([]interface{})
+	if resp["id"] != float64(1) || len(tools) != len(s.tools) {
+		t.Errorf("tools/list over stdio = %v, want all %d tools", resp, len(s.tools))
+	}
+}
+
+func TestListPrompts(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, resp := postRPC(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"}`)
+	listed, _ := resp["result"].(map[string]interface{})
+	_, resp = postRPC(t, s, `{"id": 2, "method": "Server.ListPrompts", "params": [{}]}`)
+	reply, _ := resp["result"].(map[string]interface{})
+	legacy, _ := reply["result"].(map[string]interface{})
+
+	for method, result := range map[string]map[string]interface{}{"prompts/list": listed, "Server.ListPrompts": legacy} {
+		prompts, _ := result["prompts"].([]interface{})
+		var compose map[string]interface{}
+		for _, p := range prompts {
+			if p := p.(map[string]interface{}); p["name"] == "docker_compose" {
+				compose = p
+			}
+		}
+		if compose == nil {
+			t.Fatalf("%s = %v, want the docker_compose prompt", method, result)
+		}
+		required := map[string]bool{}
+		args, _ := compose["arguments"].([]interface{})
+		for _, a := range args {
+			a := a.(map[string]interface{})
+			required[a["name"].(string)] = a["required"] == true
+		}
+		if len(required) != 4 || !required["name"] || required["containers"] || required["max_resources"] || required["verbose"] {
+			t.Errorf("%s docker_compose arguments = %v, want name required and containers, max_resources and verbose optional", method, args)
+		}
+	}
+}
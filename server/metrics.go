@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"santoshkal/mcp-godocker/pkg/llm"
+)
+
+// metricsEnv enables the /metrics endpoint when set to a true value ("1", "true", ...).
+const metricsEnv = "ENABLE_METRICS"
+
+// Plan outcome label values.
+const (
+	planOutcomeSuccess = "success"
+	planOutcomePartial = "partial"
+	planOutcomeFailed  = "failed"
+)
+
+// metricsRegistry holds the server's metrics, kept separate from the global registry so
+// only what the server records is exported.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	planActionCount = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mcp_plan_actions",
+		Help:    "Number of actions in each executed plan.",
+		Buckets: []float64{1, 2, 4, 8, 16, 32, 64},
+	})
+	planOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_plan_outcomes_total",
+		Help: "Executed plans by outcome (success, partial, failed).",
+	}, []string{"outcome"})
+	planActionTypes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_plan_action_types_total",
+		Help: "Actions requested in executed plans, by action type.",
+	}, []string{"action"})
+	planDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mcp_execute_plan_duration_seconds",
+		Help:    "Time taken by ExecutePlan calls.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	})
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_tool_calls_total",
+		Help: "Tool executions, from plans and direct calls, by tool and outcome (success, error).",
+	}, []string{"tool", "outcome"})
+	llmCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_llm_calls_total",
+		Help: "Plan generation requests sent to the LLM, by outcome (success, error).",
+	}, []string{"outcome"})
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_llm_tokens_total",
+		Help: "Tokens used by plan generation, by type (prompt, completion).",
+	}, []string{"type"})
+	inflightRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mcp_inflight_requests",
+		Help: "Requests currently being handled, by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(planActionCount, planOutcomes, planActionTypes,
+		planDuration, toolCalls, llmCalls, llmTokens, inflightRequests)
+}
+
+// metricsEnabled reports whether ENABLE_METRICS asks for the /metrics endpoint.
+func metricsEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(metricsEnv))
+	return enabled
+}
+
+// trackInflight counts a request to method as in flight until the returned function is
+// called.
+func trackInflight(method string) func() {
+	g := inflightRequests.WithLabelValues(method)
+	g.Inc()
+	return g.Dec
+}
+
+// runTool coerces params to the tool's input schema, executes the tool and records the
+// outcome, in the metrics and as a tool.call span. Tools are not run while the Docker daemon is unreachable.
+func (s *Server) runTool(ctx context.Context, tool RegisteredTool, params map[string]interface{}) (_ map[string]interface{}, err error) {
+	ctx, span := tracer.Start(ctx, "tool.call", trace.WithAttributes(attribute.String("tool.name", tool.Name)))
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		span.SetAttributes(attribute.String("tool.outcome", outcome))
+		endSpan(span, err)
+	}()
+	params, err = coerceParameters(params, tool.InputSchema)
+	if err == nil {
+		err = s.requireDaemon(ctx)
+	}
+	if err != nil {
+		toolCalls.WithLabelValues(tool.Name, "error").Inc()
+		return nil, err
+	}
+	out, err := tool.Handler(ctx, s, params)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	toolCalls.WithLabelValues(tool.Name, outcome).Inc()
+	return out, redactError(s.explainDaemonError(ctx, err))
+}
+
+// recordLLMCall records the outcome of a plan generation request and the tokens it used.
+func recordLLMCall(response *llms.ContentResponse, err error) {
+	if err != nil {
+		llmCalls.WithLabelValues("error").Inc()
+		return
+	}
+	llmCalls.WithLabelValues("success").Inc()
+	prompt, completion := llm.Usage(response)
+	llmTokens.WithLabelValues("prompt").Add(float64(prompt))
+	llmTokens.WithLabelValues("completion").Add(float64(completion))
+}
+
+// observePlanDuration records how long an ExecutePlan call took.
+func observePlanDuration(start time.Time) {
+	planDuration.Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler serves the server's metrics in the Prometheus exposition format.
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// recordPlan records the size, action mix and outcome of a plan once it has finished.
+// completed is the number of actions that ran successfully before the plan stopped.
+func (s *Server) recordPlan(plan []map[string]interface{}, completed int, failed bool) {
+	if len(plan) > 0 {
+		planActionCount.Observe(float64(len(plan)))
+	}
+	for _, action := range plan {
+		actionType, _ := action["action"].(string)
+		if _, known := s.tools[actionType]; !known {
+			// Unknown names come straight from the model; fold them into one label value.
+			actionType = "unknown"
+		}
+		planActionTypes.WithLabelValues(actionType).Inc()
+	}
+	switch {
+	case !failed:
+		planOutcomes.WithLabelValues(planOutcomeSuccess).Inc()
+	case completed > 0:
+		planOutcomes.WithLabelValues(planOutcomePartial).Inc()
+	default:
+		planOutcomes.WithLabelValues(planOutcomeFailed).Inc()
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// scrapeMetric returns the value of the sample named series, including its labels (e.g.
+// `mcp_plan_outcomes_total{outcome="success"}`), from the metrics endpoint, or 0 when the
+// series has not been recorded yet.
+func scrapeMetric(t *testing.T, series string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		value, ok := strings.CutPrefix(line, series+" ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", line, err)
+		}
+		return v
+	}
+	return 0
+}
+
+func TestExecutePlanRecordsMetrics(t *testing.T) {
+	const (
+		success = `mcp_plan_outcomes_total{outcome="success"}`
+		partial = `mcp_plan_outcomes_total{outcome="partial"}`
+		failed  = `mcp_plan_outcomes_total{outcome="failed"}`
+		plans   = `mcp_plan_actions_count`
+		actions = `mcp_plan_actions_sum`
+		counts  = `mcp_plan_action_types_total{action="count"}`
+		unknown = `mcp_plan_action_types_total{action="unknown"}`
+	)
+	tests := []struct {
+		name string
+		plan string
+		// failAfter makes the count tool fail once it has run this many times; -1 never fails.
+		failAfter int
+		want      map[string]float64
+	}{
+		{
+			name:      "success",
+			plan:      `[{"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}]`,
+			failAfter: -1,
+			want:      map[string]float64{success: 1, plans: 1, actions: 2, counts: 2},
+		},
+		{
+			name:      "partial",
+			plan:      `[{"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}]`,
+			failAfter: 1,
+			want:      map[string]float64{partial: 1, plans: 1, actions: 2, counts: 2},
+		},
+		{
+			name:      "failed",
+			plan:      `[{"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}]`,
+			failAfter: 0,
+			want:      map[string]float64{failed: 1, plans: 1, actions: 3, counts: 3},
+		},
+		{
+			name:      "unknown action",
+			plan:      `[{"action": "count", "parameters": {}}, {"action": "launch_rocket", "parameters": {}}]`,
+			failAfter: -1,
+			want:      map[string]float64{partial: 1, plans: 1, actions: 2, counts: 1, unknown: 1},
+		},
+		{
+			name:      "unparseable plan",
+			plan:      `not json`,
+			failAfter: -1,
+			want:      map[string]float64{failed: 1},
+		},
+	}
+	series := []string{success, partial, failed, plans, actions, counts, unknown}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			calls := 0
+			s.RegisterTool("count", "Count calls", map[string]interface{}{"type": "object"},
+				func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+					if tt.failAfter >= 0 && calls >= tt.failAfter {
+						return nil, errors.New("count failed")
+					}
+					calls++
+					return nil, nil
+				})
+			before := map[string]float64{}
+			for _, name := range series {
+				before[name] = scrapeMetric(t, name)
+			}
+			s.executePlan(context.Background(), &tt.plan)
+			for _, name := range series {
+				if got := scrapeMetric(t, name) - before[name]; got != tt.want[name] {
+					t.Errorf("%s grew by %v, want %v", name, got, tt.want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestReplayedPlanIsNotRecorded(t *testing.T) {
+	const (
+		success = `mcp_plan_outcomes_total{outcome="success"}`
+		plans   = `mcp_plan_actions_count`
+		actions = `mcp_plan_actions_sum`
+	)
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	plan := `{"idempotency_key": "metrics", "plan": [{"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}]}`
+	for i, want := range []map[string]float64{
+		{success: 1, plans: 1, actions: 2},
+		{success: 0, plans: 0, actions: 0},
+	} {
+		before := map[string]float64{}
+		for series := range want {
+			before[series] = scrapeMetric(t, series)
+		}
+		planActions(t, s.executePlan(context.Background(), &plan))
+		for series, delta := range want {
+			if got := scrapeMetric(t, series) - before[series]; got != delta {
+				t.Errorf("submission %d: %s grew by %v, want %v", i+1, series, got, delta)
+			}
+		}
+	}
+	if calls != 2 {
+		t.Errorf("tool ran %d times, want 2 with the second submission replayed", calls)
+	}
+}
+
+func TestToolAndLLMMetrics(t *testing.T) {
+	const (
+		toolSuccess  = `mcp_tool_calls_total{outcome="success",tool="count"}`
+		toolError    = `mcp_tool_calls_total{outcome="error",tool="count"}`
+		llmSuccess   = `mcp_llm_calls_total{outcome="success"}`
+		promptTokens = `mcp_llm_tokens_total{type="prompt"}`
+		outTokens    = `mcp_llm_tokens_total{type="completion"}`
+		plansTimed   = `mcp_execute_plan_duration_seconds_count`
+		inflight     = `mcp_inflight_requests{method="CallTool"}`
+	)
+	useFakeLLM(t, `[{"action": "count", "parameters": {}}]`)
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	before := map[string]float64{}
+	for _, series := range []string{toolSuccess, toolError, llmSuccess, promptTokens, outTokens, plansTimed} {
+		before[series] = scrapeMetric(t, series)
+	}
+
+	var reply mcp.RPCResponse
+	args := mcp.ToolCallArgs{ToolName: "count", Parameters: map[string]interface{}{}}
+	s.CallTool(context.Background(), &args, &reply)
+	fail = true
+	s.CallTool(context.Background(), &args, &reply)
+	fail = false
+	input := &CallLLMArgs{Input: "count once"}
+	var plan string
+	if err := s.CallLLM(context.Background(), input, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{toolSuccess: 2, toolError: 1, llmSuccess: 1, promptTokens: 12, outTokens: 5, plansTimed: 1}
+	for series, delta := range want {
+		if got := scrapeMetric(t, series) - before[series]; got != delta {
+			t.Errorf("%s rose by %v, want %v", series, got, delta)
+		}
+	}
+	if got := scrapeMetric(t, inflight); got != 0 {
+		t.Errorf("%s = %v after the calls returned, want 0", inflight, got)
+	}
+}
+
+func TestMetricsEnabled(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "0": false, "false": false, "1": true, "true": true, "yes": false} {
+		t.Setenv(metricsEnv, value)
+		if got := metricsEnabled(); got != want {
+			t.Errorf("metricsEnabled() with %s=%q = %v, want %v", metricsEnv, value, got, want)
+		}
+	}
+}
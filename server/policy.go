@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"santoshkal/mcp-godocker/pkg/docker/images"
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// imageParams lists the parameters that name an image, across all tools.
+var imageParams = []string{"image", "source", "target"}
+
+// checkPolicy checks one plan action or tool call against the configured policy, returning
+// a *policy.Violation when it is forbidden. The parameters are first coerced to the tool's
+// input schema, as they will be when the tool runs, so the policy sees the same values the
+// tool does. Malformed parameters are left for the tool itself to reject.
+func (s *Server) checkPolicy(actionType string, params map[string]interface{}) error {
+	if tool, ok := s.tools[actionType]; ok {
+		if coerced, err := coerceParameters(params, tool.InputSchema); err == nil {
+			params = coerced
+		}
+	}
+	p := s.config().Policy
+	return p.Check(policyAction(actionType, params))
+}
+
+// policyAction extracts what the policy looks at from a tool's parameters.
+func policyAction(actionType string, params map[string]interface{}) policy.Action {
+	a := policy.Action{Type: actionType}
+	refs := []string{}
+	for _, key := range imageParams {
+		if ref, _ := params[key].(string); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	// pull_image also takes the image as separate name and tag parameters.
+	if name, _ := params["name"].(string); name != "" && actionType == "pull_image" {
+		if tag, _ := params["tag"].(string); tag != "" {
+			name += ":" + tag
+		}
+		refs = append(refs, name)
+	}
+	for _, ref := range refs {
+		if normalized, err := images.NormalizeImageRef(ref); err == nil {
+			a.Images = append(a.Images, normalized)
+		}
+	}
+	a.Privileged, _ = params["privileged"].(bool)
+	if binds, err := parseVolumes(params, nil); err == nil {
+		for _, bind := range binds {
+			if source, _, _ := strings.Cut(bind, ":"); strings.HasPrefix(source, "/") {
+				a.HostPaths = append(a.HostPaths, source)
+			}
+		}
+	}
+	// The copy tools read source_path, or write into dest_path, on the server's host.
+	if local, _ := params[copyLocalParam[actionType]].(string); local != "" {
+		if abs, err := hostPath(local); err == nil {
+			a.HostPaths = append(a.HostPaths, abs)
+			if actionType == "copy_from_container" {
+				a.CopyDest = abs
+			}
+		}
+	}
+	a.EnvVars = envReferences(params)
+	return a
+}
+
+// copyLocalParam names the parameter holding the host path of each copy tool.
+var copyLocalParam = map[string]string{
+	"copy_to_container":   "source_path",
+	"copy_from_container": "dest_path",
+}
+
+// checkCopyPath resolves local, the host path of a copy tool, to an absolute path with
+// symlinks resolved and checks it against the policy as checkPolicy does, so a link cannot
+// point a copy somewhere the policy forbids. Destinations (dest set) may not be in the
+// server's state directory either.
+func (s *Server) checkCopyPath(local string, dest bool) (string, error) {
+	abs, err := hostPath(local)
+	if err != nil {
+		return "", err
+	}
+	abs, err = resolveSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	p := s.config().Policy
+	if err := p.CheckBind(abs); err != nil {
+		return "", err
+	}
+	if !dest {
+		return abs, nil
+	}
+	if err := p.CheckCopyDest(abs); err != nil {
+		return "", err
+	}
+	if stateDir, err := resolveSymlinks(state.Dir()); err == nil {
+		if rel, err := filepath.Rel(stateDir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return "", &policy.Violation{Rule: "copy_dir", Message: fmt.Sprintf("host path %s is in the server's state directory", abs)}
+		}
+	}
+	return abs, nil
+}
+
+// resolveSymlinks resolves the symlinks in the longest existing prefix of the absolute path
+// p, leaving the rest, which does not exist yet, as it is.
+func resolveSymlinks(p string) (string, error) {
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// checkPlanPolicy checks every action of a plan before any of them runs, so a forbidden
+// action never leaves the plan half applied.
+func (s *Server) checkPlanPolicy(plan []map[string]interface{}) error {
+	for i, action := range plan {
+		actionType, _ := action["action"].(string)
+		parameters, _ := action["parameters"].(map[string]interface{})
+		if err := s.checkPolicy(actionType, parameters); err != nil {
+			return fmt.Errorf("action %d (%s): %w", i, actionType, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/errclass"
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy policy.Policy
+		action string
+		params map[string]interface{}
+		rule   string
+	}{
+		{
+			name:   "short image names are normalized",
+			policy: policy.Policy{DeniedImages: []string{"nginx"}},
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx"},
+			rule:   "denied_images",
+		},
+		{
+			name:   "allowed image",
+			policy: policy.Policy{AllowedImages: []string{"nginx"}},
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx:1.27"},
+		},
+		{
+			name:   "pull_image name and tag",
+			policy: policy.Policy{DeniedImages: []string{"redis:7"}},
+			action: "pull_image",
+			params: map[string]interface{}{"name": "redis", "tag": "7"},
+			rule:   "denied_images",
+		},
+		{
+			name:   "numeric tag is checked as coerced",
+			policy: policy.Policy{DeniedImages: []string{"redis:7"}},
+			action: "pull_image",
+			params: map[string]interface{}{"name": "redis", "tag": float64(7)},
+			rule:   "denied_images",
+		},
+		{
+			name:   "tag_image target",
+			policy: policy.Policy{DeniedImages: []string{"ghcr.io/acme/*"}},
+			action: "tag_image",
+			params: map[string]interface{}{"source": "app:1", "target": "ghcr.io/acme/app:1"},
+			rule:   "denied_images",
+		},
+		{
+			name:   "denied bind in string form",
+			policy: policy.Policy{DeniedBinds: []string{"/srv/secrets"}},
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "volumes": []interface{}{"/srv/secrets:/secrets"}},
+			rule:   "denied_binds",
+		},
+		{
+			name:   "denied bind in object form",
+			policy: policy.Policy{DeniedBinds: []string{"/home/*/.ssh"}},
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "volumes": []interface{}{map[string]interface{}{"source": "/home/user/.ssh", "target": "/ssh"}}},
+			rule:   "denied_binds",
+		},
+		{
+			name:   "sensitive bind without any policy",
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "volumes": []interface{}{"/var/run/docker.sock:/var/run/docker.sock"}},
+			rule:   "allowed_binds",
+		},
+		{
+			name:   "named volume is not a host path",
+			policy: policy.Policy{DeniedBinds: []string{"/data"}},
+			action: "create_container",
+			params: map[string]interface{}{"name": "db", "image": "postgres", "volumes": []interface{}{"data:/var/lib/postgresql/data"}},
+		},
+		{
+			name:   "denied action",
+			policy: policy.Policy{DeniedActions: []string{"remove_container"}},
+			action: "remove_container",
+			params: map[string]interface{}{"name": "web"},
+			rule:   "denied_actions",
+		},
+		{
+			name:   "privileged container",
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "privileged": true},
+			rule:   "allow_privileged",
+		},
+		{
+			name:   "privileged container allowed",
+			policy: policy.Policy{AllowPrivileged: true},
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "privileged": true},
+		},
+		{
+			name:   "copy_to_container reads a sensitive path",
+			action: "copy_to_container",
+			params: map[string]interface{}{"name": "web", "source_path": "/var/run/docker.sock", "dest_path": "/tmp/docker.sock"},
+			rule:   "allowed_binds",
+		},
+		{
+			name:   "copy_from_container writes outside copy_dir",
+			policy: policy.Policy{CopyDir: "/srv/copies"},
+			action: "copy_from_container",
+			params: map[string]interface{}{"name": "web", "source_path": "/var/log", "dest_path": "/srv/other"},
+			rule:   "copy_dir",
+		},
+		{
+			name:   "server environment reference",
+			action: "create_container",
+			params: map[string]interface{}{"name": "web", "image": "nginx", "environment": map[string]interface{}{"TOKEN": "${GITHUB_TOKEN}"}},
+			rule:   "allowed_env",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			s.cfg.Policy = tt.policy
+			err := s.checkPolicy(tt.action, tt.params)
+			var v *policy.Violation
+			switch {
+			case tt.rule == "" && err != nil:
+				t.Errorf("checkPolicy() = %v, want no violation", err)
+			case tt.rule != "" && (!errors.As(err, &v) || v.Rule != tt.rule):
+				t.Errorf("checkPolicy() = %v, want a violation of %s", err, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckPlanPolicyNamesTheAction(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.cfg.Policy = policy.Policy{DeniedImages: []string{"redis"}}
+	plan := []map[string]interface{}{
+		{"action": "pull_image", "parameters": map[string]interface{}{"image": "nginx"}},
+		{"action": "pull_image", "parameters": map[string]interface{}{"image": "redis:7"}},
+	}
+	err := s.checkPlanPolicy(plan)
+	var v *policy.Violation
+	if !errors.As(err, &v) || v.Rule != "denied_images" {
+		t.Fatalf("checkPlanPolicy() = %v, want a denied_images violation", err)
+	}
+	if !strings.HasPrefix(err.Error(), "action 1 (pull_image): ") {
+		t.Errorf("error %q does not name the offending action", err)
+	}
+}
+
+func TestPolicyRejectsBeforeRunning(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.cfg.Policy = policy.Policy{DeniedActions: []string{"count"}}
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+
+	plan := `[{"action": "count", "parameters": {}}]`
+	var planReply mcp.RPCResponse
+	if err := s.ExecutePlan(context.Background(), &plan, &planReply); err != nil {
+		t.Fatal(err)
+	}
+	var toolReply mcp.RPCResponse
+	if err := s.CallTool(context.Background(), &mcp.ToolCallArgs{ToolName: "count"}, &toolReply); err != nil {
+		t.Fatal(err)
+	}
+	for name, reply := range map[string]mcp.RPCResponse{"ExecutePlan": planReply, "CallTool": toolReply} {
+		if reply.Error == nil || reply.Error.Code != errclass.CodePolicy {
+			t.Errorf("%s error = %+v, want code %d", name, reply.Error, errclass.CodePolicy)
+			continue
+		}
+		var data map[string]interface{}
+		json.Unmarshal(reply.Error.Data, &data)
+		if data["kind"] != errclass.KindPolicy || data["rule"] != "denied_actions" {
+			t.Errorf("%s error data = %s, want the policy kind and rule", name, reply.Error.Data)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("count ran %d times despite the policy", calls)
+	}
+}
+
+func TestComposeUpRefusesSensitiveBinds(t *testing.T) {
+	var requests int
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeDaemonError(w, http.StatusNotFound, "not found")
+	})
+	content := "services:\n  agent:\n    image: portainer/agent\n    volumes: [\"/var/run/docker.sock:/var/run/docker.sock\"]\n"
+	_, err := composeUpHandler(context.Background(), s, map[string]interface{}{"project": "ops", "content": content})
+	var v *policy.Violation
+	if !errors.As(err, &v) || v.Rule != "allowed_binds" {
+		t.Fatalf("composeUpHandler() = %v, want an allowed_binds violation", err)
+	}
+	if requests != 0 {
+		t.Errorf("daemon received %d requests before the bind was refused", requests)
+	}
+}
+func TestComposeUpChecksServiceImages(t *testing.T) {
+	const content = `
+services:
+  web:
+    image: nginx:1.27
+  cache:
+    image: redis:7
+`
+	tests := []struct {
+		name   string
+		policy policy.Policy
+		rule   string
+	}{
+		{"denied service image", policy.Policy{DeniedImages: []string{"redis"}}, "denied_images"},
+		{"service image outside allowed list", policy.Policy{AllowedImages: []string{"nginx"}}, "allowed_images"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The daemon answers nothing but pings, so reaching compose.Up fails differently.
+			s := newTestServer(t, nil)
+			s.cfg.Policy = tt.policy
+			_, err := composeUpHandler(context.Background(), s, map[string]interface{}{"project": "demo", "content": content})
+			var v *policy.Violation
+			if !errors.As(err, &v) || v.Rule != tt.rule {
+				t.Errorf("composeUpHandler() = %v, want a violation of %s", err, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckCopyPath(t *testing.T) {
+	s := newTestServer(t, nil)
+	base := filepath.Dir(state.Dir())
+	s.cfg.Policy = policy.Policy{CopyDir: base}
+	// t.TempDir shares its parent with the state directory, so make one outside base.
+	outside, err := os.MkdirTemp("", "outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(outside) })
+	if err := os.Symlink("/etc", filepath.Join(base, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(base, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		local string
+		dest  bool
+		want  string
+		rule  string
+	}{
+		{name: "destination in copy_dir", local: filepath.Join(base, "out"), dest: true, want: filepath.Join(base, "out")},
+		{name: "destination outside copy_dir", local: outside, dest: true, rule: "copy_dir"},
+		{name: "destination escaping with dot-dot", local: filepath.Join(base, "out", "..", ".."), dest: true, rule: "copy_dir"},
+		{name: "destination in the state directory", local: filepath.Join(state.Dir(), "idempotency"), dest: true, rule: "copy_dir"},
+		{name: "destination through a symlink out of copy_dir", local: filepath.Join(base, "elsewhere", "out"), dest: true, rule: "copy_dir"},
+		{name: "destination through a symlink to a sensitive path", local: filepath.Join(base, "etc", "cron.d"), dest: true, rule: "allowed_binds"},
+		{name: "source outside copy_dir", local: outside, want: outside},
+		{name: "sensitive source", local: "/etc/hosts", rule: "allowed_binds"},
+		{name: "sensitive source through a symlink", local: filepath.Join(base, "etc", "hosts"), rule: "allowed_binds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.checkCopyPath(tt.local, tt.dest)
+			if tt.rule == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("checkCopyPath(%q) = %q, %v; want %q", tt.local, got, err, tt.want)
+				}
+				return
+			}
+			var v *policy.Violation
+			if !errors.As(err, &v) || v.Rule != tt.rule {
+				t.Errorf("checkCopyPath(%q) = %q, %v; want a violation of %s", tt.local, got, err, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCopyFromContainerRefusesDestinationsBeforeCopying(t *testing.T) {
+	var requests []string
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		writeDaemonError(w, http.StatusNotFound, "not found")
+	})
+	s.cfg.Policy = policy.Policy{CopyDir: filepath.Join(filepath.Dir(state.Dir()), "copies")}
+	params := map[string]interface{}{"name": "web", "source_path": "/var/log", "dest_path": t.TempDir()}
+	_, err := copyFromContainerHandler(context.Background(), s, params)
+	var v *policy.Violation
+	if !errors.As(err, &v) || v.Rule != "copy_dir" {
+		t.Fatalf("copyFromContainerHandler() = %v, want a copy_dir violation", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("daemon was called before the destination was checked: %v", requests)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// Values of a plan document's on_busy option.
+const (
+	onBusyWait = "wait"
+	onBusyFail = "fail"
+)
+
+// projectLocks serializes plan execution per project, so two plans never create or remove
+// the same project's resources at once while plans for different projects run in parallel.
+type projectLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// lock acquires project's lock and returns the function that releases it. With onBusy
+// "fail" a project another plan holds is reported at once as a retryable unavailable
+// error; otherwise lock waits until the project is free or ctx is done.
+func (p *projectLocks) lock(ctx context.Context, project, onBusy string) (func(), error) {
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = map[string]chan struct{}{}
+	}
+	ch, ok := p.locks[project]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		p.locks[project] = ch
+	}
+	p.mu.Unlock()
+
+	release := func() { <-ch }
+	if onBusy == onBusyFail {
+		select {
+		case ch <- struct{}{}:
+			return release, nil
+		default:
+			return nil, errdefs.Unavailable(fmt.Errorf("project %s is busy: another plan is being applied to it; retry later, or set on_busy to %q to wait", project, onBusyWait))
+		}
+	}
+	select {
+	case ch <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting for another plan on project %s to finish: %w", project, ctx.Err())
+	}
+}
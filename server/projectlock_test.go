@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestProjectLocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		held    string
+		project string
+		onBusy  string
+		// timeout bounds the wait for a busy project.
+		timeout time.Duration
+		check   func(error) bool
+	}{
+		{name: "free project", held: "", project: "shop", onBusy: onBusyFail, check: func(err error) bool { return err == nil }},
+		{name: "other project held", held: "blog", project: "shop", onBusy: onBusyFail, check: func(err error) bool { return err == nil }},
+		{name: "fail while busy", held: "shop", project: "shop", onBusy: onBusyFail, check: errdefs.IsUnavailable},
+		{
+			name: "wait gives up with the context", held: "shop", project: "shop", onBusy: onBusyWait, timeout: 20 * time.Millisecond,
+			check: func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		},
+		{
+			name: "empty on_busy waits", held: "shop", project: "shop", onBusy: "", timeout: 20 * time.Millisecond,
+			check: func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var locks projectLocks
+			if tt.held != "" {
+				release, err := locks.lock(context.Background(), tt.held, onBusyFail)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer release()
+			}
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			release, err := locks.lock(ctx, tt.project, tt.onBusy)
+			if !tt.check(err) {
+				t.Fatalf("lock() error = %v", err)
+			}
+			if err == nil {
+				release()
+			}
+		})
+	}
+}
+
+func TestProjectLocksFailMessageSuggestsWaiting(t *testing.T) {
+	var locks projectLocks
+	release, err := locks.lock(context.Background(), "shop", onBusyWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	_, err = locks.lock(context.Background(), "shop", onBusyFail)
+	if err == nil || !strings.Contains(err.Error(), `set on_busy to "wait"`) {
+		t.Errorf("lock() error = %v, want advice to wait", err)
+	}
+}
+
+func TestProjectLocksWaitForRelease(t *testing.T) {
+	var locks projectLocks
+	release, err := locks.lock(context.Background(), "shop", onBusyWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan func())
+	go func() {
+		next, err := locks.lock(context.Background(), "shop", onBusyWait)
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
+		}
+		acquired <- next
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second plan acquired the lock while the first held it")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case next := <-acquired:
+		if next != nil {
+			next()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second plan did not acquire the lock after it was released")
+	}
+}
+
+func TestProjectLocksSerializePlans(t *testing.T) {
+	var (
+		locks   projectLocks
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		running int
+		maxSeen int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := locks.lock(context.Background(), "shop", onBusyWait)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			mu.Lock()
+			running++
+			maxSeen = max(maxSeen, running)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if maxSeen != 1 {
+		t.Errorf("%d plans held the project lock at once, want 1", maxSeen)
+	}
+}
+
+func TestExecutePlanRejectsBusyProject(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	release, err := s.projects.lock(context.Background(), "shop", onBusyFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	plan := `{"project": "shop", "on_busy": "fail", "plan": [{"action": "count", "parameters": {}}]}`
+	reply := s.executePlan(context.Background(), &plan)
+	if reply.Error == nil || !strings.Contains(reply.Error.Message, "project shop is busy") {
+		t.Fatalf("error = %v, want the project reported busy", reply.Error)
+	}
+	if calls != 0 {
+		t.Errorf("tool ran %d times while the project was busy", calls)
+	}
+	other := `{"project": "blog", "on_busy": "fail", "plan": [{"action": "count", "parameters": {}}]}`
+	planActions(t, s.executePlan(context.Background(), &other))
+	if calls != 1 {
+		t.Errorf("plan for another project ran the tool %d times, want 1", calls)
+	}
+}
+
+func TestExecutePlanRejectsUnknownOnBusy(t *testing.T) {
+	s := newTestServer(t, nil)
+	plan := `{"project": "shop", "on_busy": "queue", "plan": [{"action": "count", "parameters": {}}]}`
+	reply := s.executePlan(context.Background(), &plan)
+	if reply.Error == nil || reply.Error.Code != -32602 || !strings.Contains(reply.Error.Message, `invalid on_busy "queue"`) {
+		t.Errorf("error = %v, want the on_busy value rejected", reply.Error)
+	}
+}
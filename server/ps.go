@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// projectPsHandler lists a project's containers with their state and restart count, flagging
+// those that are crash-looping.
+func projectPsHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	project, _ := params["project"].(string)
+	if project == "" {
+		project = projectFrom(ctx)
+	}
+	if project == "" {
+		return nil, errors.New("missing project name for project_ps")
+	}
+	window := docker.DefaultCrashLoopWindow
+	if n, ok, err := numberParam(params, "crash_window_seconds"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 0 {
+			return nil, fmt.Errorf("crash_window_seconds must not be negative, got %v", n)
+		}
+		window = time.Duration(n * float64(time.Second))
+	}
+	containers, err := docker.ProjectContainerHealth(ctx, s.dockerClient, project, window)
+	if err != nil {
+		return nil, err
+	}
+	crashLooping := []string{}
+	for _, c := range containers {
+		if c.CrashLooping {
+			crashLooping = append(crashLooping, c.Name)
+		}
+	}
+	return map[string]interface{}{
+		"project":       project,
+		"containers":    containers,
+		"crash_looping": crashLooping,
+	}, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	"santoshkal/mcp-godocker/pkg/config"
+	"santoshkal/mcp-godocker/pkg/llm"
+	"santoshkal/mcp-godocker/pkg/profiles"
+)
+
+// Configuration reload
+//
+// Sending SIGHUP to the server, or calling the Server.ReloadConfig RPC, re-reads the config
+// file (MCP_CONFIG, default mcp.yaml) and the profiles file (MCP_PROFILES_FILE). Requests
+// already in flight finish with the settings they started with; only new requests see the
+// reloaded values.
+//
+// Hot-reloadable:
+//   - llm.model, llm.api_key_env and llm.max_tokens (a new LLM client is created when any
+//     of them changes)
+//   - llm.plan_format
+//   - llm.system_prompt and llm.system_prompt_file (the file is read again on reload)
+//   - llm.token_budget (tokens already spent still count against the new budget)
+//   - default_project
+//   - trace_tool_calls
+//   - swarm
+//   - ready_check_llm
+//   - image_cache_ttl_seconds
+//   - docker_retry_attempts
+//   - policy
+//   - projects (per-project defaults such as restart_policy)
+//   - environment profiles
+//
+// Require a restart:
+//   - llm.provider
+//   - docker_host and docker_tls (the Docker client is created once at startup)
+//   - endpoint (the listen address is fixed at startup)
+
+// ReloadResult reports what a configuration reload changed.
+type ReloadResult struct {
+	Model   string   `json:"model"`
+	Changed []string `json:"changed"`
+	// RestartRequired lists settings that changed on disk but only take effect after a restart.
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// newLLMClient creates the LLM client described by cfg, reading the API key from the
+// environment variable it names.
+func newLLMClient(cfg *config.Config) (*llm.LLMClient, error) {
+	return llm.NewLLMClient(os.Getenv(cfg.LLM.APIKeyEnv), cfg.LLM.Model, cfg.LLM.MaxTokens)
+}
+
+// config returns the configuration currently in use.
+func (s *Server) config() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// llm returns the LLM client currently in use.
+func (s *Server) llm() *llm.LLMClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.llmClient
+}
+
+// profileSet returns the environment profiles currently in use.
+func (s *Server) profileSet() map[string]profiles.Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.profiles
+}
+
+// Reload re-reads the configuration and profiles and swaps in the hot-reloadable settings.
+// Nothing is changed if either file fails to load or validate.
+func (s *Server) Reload() (ReloadResult, error) {
+	cfg, err := config.Load(s.configPath)
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("reload failed, keeping current configuration: %w", err)
+	}
+	profileSet, err := profiles.Load(os.Getenv(profiles.ProfilesFileEnv))
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("reload failed, keeping current configuration: %w", err)
+	}
+
+	s.mu.RLock()
+	current := s.cfg
+	s.mu.RUnlock()
+
+	result := ReloadResult{Model: cfg.LLM.Model}
+	var llmClient *llm.LLMClient
+	if cfg.LLM.Model != current.LLM.Model || cfg.LLM.APIKeyEnv != current.LLM.APIKeyEnv || cfg.LLM.MaxTokens != current.LLM.MaxTokens {
+		if llmClient, err = newLLMClient(cfg); err != nil {
+			return ReloadResult{}, fmt.Errorf("reload failed, keeping current configuration: %w", err)
+		}
+		result.Changed = append(result.Changed, "llm")
+	}
+	if cfg.DefaultProject != current.DefaultProject {
+		result.Changed = append(result.Changed, "default_project")
+	}
+	if cfg.LLM.PlanFormat != current.LLM.PlanFormat {
+		result.Changed = append(result.Changed, "llm.plan_format")
+	}
+	if cfg.LLM.SystemPromptTemplate() != current.LLM.SystemPromptTemplate() {
+		result.Changed = append(result.Changed, "llm.system_prompt")
+	}
+	if cfg.LLM.TokenBudget != current.LLM.TokenBudget {
+		result.Changed = append(result.Changed, "llm.token_budget")
+	}
+	if !reflect.DeepEqual(cfg.Policy, current.Policy) {
+		result.Changed = append(result.Changed, "policy")
+	}
+	if !reflect.DeepEqual(cfg.Projects, current.Projects) {
+		result.Changed = append(result.Changed, "projects")
+	}
+	if cfg.ImageCacheTTLSeconds != current.ImageCacheTTLSeconds {
+		result.Changed = append(result.Changed, "image_cache_ttl_seconds")
+	}
+	if cfg.DockerRetryAttempts != current.DockerRetryAttempts {
+		result.Changed = append(result.Changed, "docker_retry_attempts")
+	}
+	if cfg.ReadyCheckLLM != current.ReadyCheckLLM {
+		result.Changed = append(result.Changed, "ready_check_llm")
+	}
+	if cfg.Swarm != current.Swarm {
+		result.Changed = append(result.Changed, "swarm")
+	}
+	if cfg.TraceToolCalls != current.TraceToolCalls {
+		result.Changed = append(result.Changed, "trace_tool_calls")
+	}
+	result.Changed = append(result.Changed, "profiles")
+
+	// Settings that cannot change at runtime keep their startup values.
+	if cfg.LLM.Provider != current.LLM.Provider {
+		result.RestartRequired = append(result.RestartRequired, "llm.provider")
+		cfg.LLM.Provider = current.LLM.Provider
+	}
+	if cfg.DockerHost != current.DockerHost {
+		result.RestartRequired = append(result.RestartRequired, "docker_host")
+		cfg.DockerHost = current.DockerHost
+	}
+	if !reflect.DeepEqual(cfg.DockerTLS, current.DockerTLS) {
+		result.RestartRequired = append(result.RestartRequired, "docker_tls")
+		cfg.DockerTLS = current.DockerTLS
+	}
+	if cfg.Endpoint != current.Endpoint {
+		result.RestartRequired = append(result.RestartRequired, "endpoint")
+		cfg.Endpoint = current.Endpoint
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	if llmClient != nil {
+		s.llmClient = llmClient
+	}
+	s.profiles = profileSet
+	s.mu.Unlock()
+	return result, nil
+}
+
+// ReloadConfig is the admin RPC form of Reload.
+func (s *Server) ReloadConfig(_ *struct{}, reply *ReloadResult) error {
+	result, err := s.Reload()
+	if err != nil {
+		return err
+	}
+	*reply = result
+	log.Printf("[ReloadConfig] Configuration reloaded: model=%s changed=%v restart_required=%v", result.Model, result.Changed, result.RestartRequired)
+	return nil
+}
+
+// reloadOnSIGHUP reloads the configuration each time the process receives SIGHUP, until ctx
+// is cancelled.
+func (s *Server) reloadOnSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			var result ReloadResult
+			if err := s.ReloadConfig(nil, &result); err != nil {
+				log.Printf("[Reload] %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/config"
+)
+
+// writeConfig replaces the configuration file the test server loads.
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(os.Getenv(config.EnvConfigFile), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadChangesModel(t *testing.T) {
+	requests := useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop"}}]`)
+	s := newTestServer(t, nil)
+	input := &CallLLMArgs{Input: "create a network named shop"}
+	var plan string
+	if err := s.CallLLM(context.Background(), input, &plan); err != nil {
+		t.Fatalf("CallLLM() error = %v", err)
+	}
+
+	writeConfig(t, "llm:\n  model: gpt-4o-mini\ndefault_project: shop\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	want := ReloadResult{Model: "gpt-4o-mini", Changed: []string{"llm", "default_project", "profiles"}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("ReloadConfig() = %+v, want %+v", result, want)
+	}
+	if err := s.CallLLM(context.Background(), input, &plan); err != nil {
+		t.Fatalf("CallLLM() after reload error = %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("LLM received %d requests, want 2", len(*requests))
+	}
+	if before, after := (*requests)[0]["model"], (*requests)[1]["model"]; before != "gpt-4o" || after != "gpt-4o-mini" {
+		t.Errorf("models used = %v then %v, want gpt-4o then gpt-4o-mini", before, after)
+	}
+}
+
+func TestReloadKeepsStartupOnlySettings(t *testing.T) {
+	s := newTestServer(t, nil)
+	writeConfig(t, "endpoint: http://elsewhere:9999/rpc\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.RestartRequired, []string{"endpoint"}) {
+		t.Errorf("restart required = %v, want [endpoint]", result.RestartRequired)
+	}
+	if s.cfg.Endpoint != config.Default().Endpoint {
+		t.Errorf("endpoint = %s, want the startup value kept", s.cfg.Endpoint)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	s := newTestServer(t, nil)
+	before := s.llm()
+	writeConfig(t, "llm:\n  model: \"\"\n")
+	var result ReloadResult
+	err := s.ReloadConfig(nil, &result)
+	if err == nil || !strings.Contains(err.Error(), "reload failed, keeping current configuration: llm.model is empty") {
+		t.Fatalf("ReloadConfig() error = %v, want the invalid config rejected", err)
+	}
+	if s.llm() != before || s.cfg.LLM.Model != "gpt-4o" {
+		t.Errorf("configuration changed despite the failed reload")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/docker/images"
+)
+
+// scaleServiceHandler runs replicas copies of a container spec as containers named
+// <name>-1 ... <name>-N. Replicas are found by their service label (and the plan's project),
+// so scaling reconciles against what exists: missing replicas are created, stopped ones are
+// started and surplus ones (the highest-numbered) are removed.
+func scaleServiceHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := params["name"].(string)
+	image, _ := params["image"].(string)
+	if name == "" || image == "" {
+		return nil, errors.New("scale_service requires a service name and image")
+	}
+	if s.config().Swarm {
+		return nil, errors.New("scale_service manages standalone containers; in swarm mode set replicas on create_container instead")
+	}
+	n, ok, err := numberParam(params, "replicas")
+	if err != nil {
+		return nil, err
+	}
+	if !ok || n < 0 || n != float64(int(n)) {
+		return nil, fmt.Errorf("replicas must be a non-negative integer, got %v", params["replicas"])
+	}
+	replicas := int(n)
+	image, err = images.NormalizeImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+	_, bindings, err := parsePorts(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(bindings) > 0 && replicas > 1 {
+		return nil, errors.New("replicas cannot share published ports; leave published out so each replica only exposes its target port")
+	}
+
+	labels := map[string]string{docker.ServiceLabel: name}
+	if project := projectFrom(ctx); project != "" {
+		labels[docker.ProjectLabel] = project
+	}
+	existing, err := docker.ListContainersByLabels(ctx, s.dockerClient, labels, true)
+	if err != nil {
+		return nil, err
+	}
+	current := map[int]string{}
+	var removed []string
+	for _, c := range existing {
+		replicaName := strings.TrimPrefix(firstContainerName(c.Names), "/")
+		index, ok := replicaIndex(name, replicaName)
+		if !ok || index > replicas {
+			if err := docker.RemoveContainer(ctx, s.dockerClient, c.ID); err != nil {
+				return nil, fmt.Errorf("failed to remove surplus replica %s: %w", replicaName, err)
+			}
+			removed = append(removed, replicaName)
+			continue
+		}
+		if existingImage, _ := images.NormalizeImageRef(c.Image); existingImage != image {
+			return nil, fmt.Errorf("replica %s runs image %s instead of %s; remove the service's replicas (scale to 0) to change the image", replicaName, existingImage, image)
+		}
+		current[index] = replicaName
+	}
+	sort.Strings(removed)
+
+	var networks []string
+	if raw, ok := params["networks"].([]interface{}); ok {
+		for _, item := range raw {
+			if network, ok := item.(string); ok && network != "" {
+				networks = append(networks, network)
+			}
+		}
+	}
+	var created []string
+	result := make([]map[string]interface{}, 0, replicas)
+	for i := 1; i <= replicas; i++ {
+		replicaName, ok := current[i]
+		if !ok {
+			replicaName = fmt.Sprintf("%s-%d", name, i)
+			config, hostConfig, err := s.containerSpec(ctx, image, params)
+			if err != nil {
+				return nil, err
+			}
+			if config.Labels == nil {
+				config.Labels = map[string]string{}
+			}
+			config.Labels[docker.ServiceLabel] = name
+			if _, err := docker.CreateContainer(ctx, s.dockerClient, replicaName, config, hostConfig); err != nil {
+				return nil, fmt.Errorf("failed to create replica %s: %w", replicaName, err)
+			}
+			for _, network := range networks {
+				if err := docker.ConnectNetwork(ctx, s.dockerClient, network, replicaName, []string{name}); err != nil {
+					return nil, fmt.Errorf("failed to connect replica %s to network %s: %w", replicaName, network, err)
+				}
+			}
+			created = append(created, replicaName)
+		}
+		state, err := docker.RunContainer(ctx, s.dockerClient, replicaName, docker.DefaultStartAttempts, s.dockerRetryAttempts())
+		if err != nil {
+			return nil, fmt.Errorf("failed to start replica %s: %w", replicaName, err)
+		}
+		result = append(result, map[string]interface{}{"name": replicaName, "status": state.Status, "running": state.Running})
+	}
+	return map[string]interface{}{
+		"service":  name,
+		"replicas": result,
+		"created":  nonNil(created),
+		"removed":  nonNil(removed),
+	}, nil
+}
+
+// replicaIndex returns N for a replica container named <service>-N.
+func replicaIndex(service, containerName string) (int, bool) {
+	suffix, ok := strings.CutPrefix(containerName, service+"-")
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil || index < 1 || strconv.Itoa(index) != suffix {
+		return 0, false
+	}
+	return index, true
+}
+
+// nonNil returns list, or an empty list instead of nil so results always carry an array.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// replicaDaemon fakes the container endpoints scale_service uses, keeping the containers it
+// creates so successive calls see each other's replicas.
+type replicaDaemon struct {
+	t          *testing.T
+	mu         sync.Mutex
+	containers map[string]*fakeReplica
+}
+
+type fakeReplica struct {
+	image   string
+	labels  map[string]string
+	running bool
+}
+
+func newReplicaDaemon(t *testing.T) *replicaDaemon {
+	return &replicaDaemon{t: t, containers: map[string]*fakeReplica{}}
+}
+
+// add records an existing container.
+func (d *replicaDaemon) add(name, image string, running bool) {
+	d.containers[name] = &fakeReplica{image: image, labels: map[string]string{docker.ServiceLabel: "web"}, running: running}
+}
+
+// names returns the containers that exist, and those running, sorted.
+func (d *replicaDaemon) names() (all, running []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, c := range d.containers {
+		all = append(all, name)
+		if c.running {
+			running = append(running, name)
+		}
+	}
+	sort.Strings(all)
+	sort.Strings(running)
+	return all, running
+}
+
+func (d *replicaDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == "/containers/json":
+		if !strings.Contains(r.URL.Query().Get("filters"), docker.ServiceLabel+"=web") {
+			d.t.Errorf("containers listed with filters %s, want the service label", r.URL.Query().Get("filters"))
+		}
+		list := []map[string]interface{}{}
+		for name, c := range d.containers {
+			list = append(list, map[string]interface{}{"Id": name, "Names": []string{"/" + name}, "Image": c.image, "Labels": c.labels})
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var req container.CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			d.t.Error(err)
+		}
+		name := r.URL.Query().Get("name")
+		d.containers[name] = &fakeReplica{image: req.Image, labels: req.Labels}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id": %q}`, name)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/start"):
+		c, ok := d.containers[strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/start")]
+		if !ok {
+			writeDaemonError(w, http.StatusNotFound, "No such container")
+			return
+		}
+		c.running = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json")
+		c, ok := d.containers[name]
+		if !ok {
+			writeDaemonError(w, http.StatusNotFound, "No such container")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Id": name, "Name": "/" + name, "State": map[string]interface{}{"Status": "running", "Running": c.running}})
+	case r.Method == http.MethodDelete:
+		delete(d.containers, strings.TrimPrefix(path, "/containers/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeDaemonError(w, http.StatusNotFound, "not found: "+path)
+	}
+}
+
+func TestScaleService(t *testing.T) {
+	d := newReplicaDaemon(t)
+	s := newTestServer(t, d.ServeHTTP)
+	scale := func(replicas float64) map[string]interface{} {
+		t.Helper()
+		got, err := s.tools["scale_service"].Handler(context.Background(), s, map[string]interface{}{"name": "web", "image": "nginx", "replicas": replicas})
+		if err != nil {
+			t.Fatalf("scale_service to %v: %v", replicas, err)
+		}
+		return got
+	}
+
+	steps := []struct {
+		replicas    float64
+		wantCreated []string
+		wantRemoved []string
+		wantAll     []string
+	}{
+		{replicas: 2, wantCreated: []string{"web-1", "web-2"}, wantRemoved: []string{}, wantAll: []string{"web-1", "web-2"}},
+		{replicas: 4, wantCreated: []string{"web-3", "web-4"}, wantRemoved: []string{}, wantAll: []string{"web-1", "web-2", "web-3", "web-4"}},
+		{replicas: 4, wantCreated: []string{}, wantRemoved: []string{}, wantAll: []string{"web-1", "web-2", "web-3", "web-4"}},
+		{replicas: 1, wantCreated: []string{}, wantRemoved: []string{"web-2", "web-3", "web-4"}, wantAll: []string{"web-1"}},
+		{replicas: 0, wantCreated: []string{}, wantRemoved: []string{"web-1"}},
+	}
+	for _, step := range steps {
+		got := scale(step.replicas)
+		if !reflect.DeepEqual(got["created"], step.wantCreated) || !reflect.DeepEqual(got["removed"], step.wantRemoved) {
+			t.Errorf("scale to %v: created %v and removed %v, want %v and %v", step.replicas, got["created"], got["removed"], step.wantCreated, step.wantRemoved)
+		}
+		if replicas := got["replicas"].([]map[string]interface{}); len(replicas) != int(step.replicas) {
+			t.Errorf("scale to %v reported %d replicas", step.replicas, len(replicas))
+		}
+		all, running := d.names()
+		if !reflect.DeepEqual(all, step.wantAll) || !reflect.DeepEqual(running, step.wantAll) {
+			t.Errorf("after scaling to %v the daemon has %v (running %v), want %v running", step.replicas, all, running, step.wantAll)
+		}
+	}
+}
+
+func TestScaleServiceReconcilesExistingContainers(t *testing.T) {
+	d := newReplicaDaemon(t)
+	d.add("web-1", "nginx:latest", true)
+	d.add("web-2", "nginx:latest", false)
+	d.add("web-old", "nginx:latest", true)
+	s := newTestServer(t, d.ServeHTTP)
+	got, err := s.tools["scale_service"].Handler(context.Background(), s, map[string]interface{}{"name": "web", "image": "nginx", "replicas": float64(2)})
+	if err != nil {
+		t.Fatalf("scale_service: %v", err)
+	}
+	if !reflect.DeepEqual(got["created"], []string{}) || !reflect.DeepEqual(got["removed"], []string{"web-old"}) {
+		t.Errorf("scale_service = %v, want nothing created and the unnumbered container removed", got)
+	}
+	if all, running := d.names(); !reflect.DeepEqual(all, []string{"web-1", "web-2"}) || !reflect.DeepEqual(running, all) {
+		t.Errorf("daemon has %v (running %v), want web-1 and web-2 running", all, running)
+	}
+}
+
+func TestScaleServiceRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{name: "missing image", params: map[string]interface{}{"name": "web", "replicas": float64(1)}, wantErr: "scale_service requires a service name and image"},
+		{name: "negative replicas", params: map[string]interface{}{"name": "web", "image": "nginx", "replicas": float64(-1)}, wantErr: "replicas must be a non-negative integer"},
+		{name: "fractional replicas", params: map[string]interface{}{"name": "web", "image": "nginx", "replicas": 1.5}, wantErr: "replicas must be a non-negative integer"},
+		{
+			name:    "published port on several replicas",
+			params:  map[string]interface{}{"name": "web", "image": "nginx", "replicas": float64(2), "ports": []interface{}{map[string]interface{}{"target": float64(80), "published": float64(8080)}}},
+			wantErr: "replicas cannot share published ports",
+		},
+		{name: "replica running another image", params: map[string]interface{}{"name": "web", "image": "httpd", "replicas": float64(1)}, wantErr: "replica web-1 runs image nginx:latest instead of httpd:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newReplicaDaemon(t)
+			d.add("web-1", "nginx:latest", true)
+			s := newTestServer(t, d.ServeHTTP)
+			_, err := s.tools["scale_service"].Handler(context.Background(), s, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("scale_service error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if all, _ := d.names(); !reflect.DeepEqual(all, []string{"web-1"}) {
+				t.Errorf("daemon has %v after the error, want web-1 untouched", all)
+			}
+		})
+	}
+}
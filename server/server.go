@@ -1,29 +1,46 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"santoshkal/mcp-godocker/pkg/config"
 	"santoshkal/mcp-godocker/pkg/docker"
 	"santoshkal/mcp-godocker/pkg/llm"
 	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/policy"
+	"santoshkal/mcp-godocker/pkg/profiles"
+	"santoshkal/mcp-godocker/pkg/state"
 	"santoshkal/mcp-godocker/utils"
 )
 
-// ToolHandler defines the function signature for tool execution.
-type ToolHandler func(ctx context.Context, s *Server, parameters map[string]interface{}) error
+// ToolHandler defines the function signature for tool execution. The returned map, when
+// non-nil, is reported back to the caller as the tool's result.
+type ToolHandler func(ctx context.Context, s *Server, parameters map[string]interface{}) (map[string]interface{}, error)
 
 // RegisteredTool holds metadata and the handler for a tool.
 type RegisteredTool struct {
@@ -36,28 +53,104 @@ type RegisteredTool struct {
 // Server encapsulates the Docker client, LLM client, and a registry of tools.
 type Server struct {
 	dockerClient *client.Client
-	llmClient    *llm.LLMClient
 	tools        map[string]RegisteredTool
+	configPath   string
+
+	// mu guards the settings that Reload may replace while requests are in flight. Use the
+	// llm and profileSet accessors rather than reading the fields directly.
+	mu        sync.RWMutex
+	cfg       *config.Config
+	llmClient *llm.LLMClient
+	profiles  map[string]profiles.Profile
+
+	traceMu sync.Mutex
+	traces  []ToolCallTrace
+
+	convergers convergers
+	images     imageCache
+	tokens     tokenCounter
+	timeouts   config.Timeouts
+	sessions   sessions
+	daemon     daemonState
+	projects   projectLocks
+
+	// ctx is cancelled by Close so that in-flight plans and tool calls abort.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// ServerOption customises a Server created by NewServer.
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	dockerHost string
+	dockerTLS  *docker.TLSConfig
+}
+
+// WithDockerHost points the server at a Docker daemon other than the one named by the
+// configuration or DOCKER_HOST, e.g. "tcp://build-host:2376" or "ssh://user@host".
+func WithDockerHost(host string) ServerOption {
+	return func(o *serverOptions) { o.dockerHost = host }
+}
+
+// WithDockerTLS authenticates to the Docker daemon with the given CA, certificate and key
+// files, overriding the configuration and DOCKER_CERT_PATH.
+func WithDockerTLS(tlsConfig *docker.TLSConfig) ServerOption {
+	return func(o *serverOptions) { o.dockerTLS = tlsConfig }
 }
 
-// NewServer creates and configures a new Server.
-func NewServer() (*Server, error) {
-	dc, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// NewServer creates and configures a new Server. The Docker daemon defaults to docker_host
+// and docker_tls from the configuration file, falling back to the DOCKER_* environment.
+func NewServer(opts ...ServerOption) (*Server, error) {
+	configPath := os.Getenv(config.EnvConfigFile)
+	if configPath == "" {
+		configPath = config.DefaultPath
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	o := serverOptions{dockerHost: cfg.DockerHost}
+	if t := cfg.DockerTLS; t != nil {
+		o.dockerTLS = &docker.TLSConfig{CACert: t.CACert, Cert: t.Cert, Key: t.Key}
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	dc, err := docker.NewClient(o.dockerHost, o.dockerTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	llmClient, err := newLLMClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts, err := config.TimeoutsFromEnv()
 	if err != nil {
 		return nil, err
 	}
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	llmClient, err := llm.NewLLMClient(apiKey, "gpt-4o")
+	profileSet, err := profiles.Load(os.Getenv(profiles.ProfilesFileEnv))
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &Server{
 		dockerClient: dc,
-		llmClient:    llmClient,
 		tools:        make(map[string]RegisteredTool),
+		configPath:   configPath,
+		cfg:          cfg,
+		llmClient:    llmClient,
+		profiles:     profileSet,
+		timeouts:     timeouts,
+		ctx:          ctx,
+		cancel:       cancel,
 	}
+	s.waitForDaemon(ctx, timeouts.DockerWait)
 
 	// Register Docker operation tools.
 	s.RegisterTool("create_network", "Create a Docker network", map[string]interface{}{
@@ -67,12 +160,79 @@ func NewServer() (*Server, error) {
 				"type":        "string",
 				"description": "Name of the network",
 			},
+			"driver": map[string]interface{}{
+				"type":        "string",
+				"description": "Network driver: bridge (default), overlay, macvlan, ipvlan or a plugin driver",
+			},
+			"driver_opts": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Driver-specific options, e.g. {\"parent\": \"eth0\"} for macvlan",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"internal": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Restrict external access to the network",
+			},
+			"attachable": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Allow standalone containers to attach to an overlay network",
+			},
+			"ipam": map[string]interface{}{
+				"type":        "object",
+				"description": "IP address management for the network",
+				"properties": map[string]interface{}{
+					"subnet": map[string]interface{}{
+						"type":        "string",
+						"description": "Subnet in CIDR form, e.g. 172.28.0.0/16",
+					},
+					"gateway": map[string]interface{}{
+						"type":        "string",
+						"description": "Gateway address within the subnet, e.g. 172.28.0.1",
+					},
+				},
+			},
+			"idempotent": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat an existing resource with the same name as success (default true)",
+			},
 		},
 		"required": []string{"name"},
-	}, func(ctx context.Context, s *Server, params map[string]interface{}) error {
-		name, _ := params["name"].(string)
-		return docker.CreateNetwork(ctx, s.dockerClient, name)
-	})
+	}, createNetworkHandler)
+
+	s.RegisterTool("connect_network", "Attach an existing container to a network", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"container": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"network": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the network",
+			},
+			"aliases": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Extra DNS names for the container on this network",
+			},
+		},
+		"required": []string{"container", "network"},
+	}, connectNetworkHandler)
+
+	s.RegisterTool("disconnect_network", "Detach a container from a network", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"container": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"network": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the network",
+			},
+		},
+		"required": []string{"container", "network"},
+	}, disconnectNetworkHandler)
 
 	s.RegisterTool("create_container", "Create a Docker container", map[string]interface{}{
 		"type": "object",
@@ -85,16 +245,169 @@ func NewServer() (*Server, error) {
 				"type":        "string",
 				"description": "Docker image to use",
 			},
+			"restart_policy": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"no", "on-failure", "always", "unless-stopped"},
+				"description": "Restart policy for the container (defaults to the project's configured policy, if any)",
+			},
+			"max_retries": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum restart attempts (on-failure policy only)",
+			},
+			"volumes": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"oneOf": []interface{}{
+						map[string]interface{}{"type": "string", "description": "source:target[:ro]"},
+						map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"source":   map[string]interface{}{"type": "string"},
+								"target":   map[string]interface{}{"type": "string"},
+								"readonly": map[string]interface{}{"type": "boolean"},
+							},
+							"required": []string{"source", "target"},
+						},
+					},
+				},
+				"description": "Volumes or host paths to mount; a source that is a path (/, ., ~) is a host bind, anything else a named volume",
+			},
+			"ports": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"published": map[string]interface{}{"type": "integer", "description": "Port on the host"},
+						"target":    map[string]interface{}{"type": "integer", "description": "Port inside the container"},
+						"protocol":  map[string]interface{}{"type": "string", "enum": []string{"tcp", "udp", "sctp"}},
+					},
+					"required": []string{"target"},
+				},
+				"description": "Ports to expose, and publish on the host when published is given",
+			},
+			"replicas": map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of tasks to run (swarm mode only, default 1)",
+			},
+			"networks": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Networks to attach the service to (swarm mode only)",
+			},
+			"environment": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "Environment variables; a value may reference the server's environment as ${env:NAME} (or ${NAME}, with an optional :-default) instead of containing a secret, where NAME starts with MCP_PASS_ or is listed in policy.allowed_env",
+			},
+			"env_from_file": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Paths of dotenv files on the server to read variables from when the action runs; environment overrides them",
+			},
+			"command": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"description": "Command to run instead of the image's, as a list of arguments or a string split like a shell would, e.g. \"sh -c 'echo hello'\"",
+			},
+			"entrypoint": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"description": "Entrypoint to use instead of the image's, as a list of arguments or a string",
+			},
+			"working_dir": map[string]interface{}{
+				"type":        "string",
+				"description": "Absolute working directory inside the container",
+			},
+			"user": map[string]interface{}{
+				"type":        "string",
+				"description": "User (and optionally group) to run as, e.g. \"1000:1000\" or \"nobody\"",
+			},
+			"labels": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Labels to set on the container",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"memory_mb": map[string]interface{}{
+				"type":        "number",
+				"description": "Memory limit in megabytes",
+			},
+			"cpus": map[string]interface{}{
+				"type":        "number",
+				"description": "Number of CPUs the container may use (e.g. 0.5)",
+			},
+			"privileged": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Run the container privileged; refused unless policy.allow_privileged is set",
+			},
+			"idempotent": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat an existing resource with the same name as success (default true)",
+			},
 		},
 		"required": []string{"name", "image"},
-	}, func(ctx context.Context, s *Server, params map[string]interface{}) error {
-		name, _ := params["name"].(string)
-		image, _ := params["image"].(string)
-		if name == "" || image == "" {
-			return errors.New("missing container name or image")
+	}, createContainerHandler)
+
+	// scale_service takes the same container settings as create_container.
+	scaleProperties := map[string]interface{}{}
+	for key, schema := range s.tools["create_container"].InputSchema["properties"].(map[string]interface{}) {
+		if key != "idempotent" {
+			scaleProperties[key] = schema
 		}
-		return docker.CreateContainer(ctx, s.dockerClient, name, image)
-	})
+	}
+	scaleProperties["name"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Name of the service; replicas are named <name>-1 ... <name>-N",
+	}
+	scaleProperties["replicas"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Number of replicas to run; extra replicas are removed, highest-numbered first",
+	}
+	scaleProperties["networks"] = map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "Existing networks to attach new replicas to, reachable there under the service name",
+	}
+	s.RegisterTool("scale_service", "Run N replica containers of one container spec, creating, starting or removing replicas to match", map[string]interface{}{
+		"type":       "object",
+		"properties": scaleProperties,
+		"required":   []string{"name", "image", "replicas"},
+	}, scaleServiceHandler)
+
+	s.RegisterTool("update_container", "Change the restart policy or resource limits of an existing container without recreating it", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"restart_policy": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"no", "on-failure", "always", "unless-stopped"},
+				"description": "Restart policy for the container",
+			},
+			"max_retries": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum restart attempts (on-failure policy only)",
+			},
+			"memory_mb": map[string]interface{}{
+				"type":        "number",
+				"description": "Memory limit in megabytes",
+			},
+			"memory_swap_mb": map[string]interface{}{
+				"type":        "number",
+				"description": "Memory plus swap limit in megabytes, at least memory_mb, or -1 for unlimited swap (default twice memory_mb)",
+			},
+			"cpus": map[string]interface{}{
+				"type":        "number",
+				"description": "Number of CPUs the container may use (e.g. 0.5)",
+			},
+		},
+		"required": []string{"name"},
+	}, updateContainerHandler)
 
 	s.RegisterTool("create_volume", "Create a Docker volume", map[string]interface{}{
 		"type": "object",
@@ -103,12 +416,27 @@ func NewServer() (*Server, error) {
 				"type":        "string",
 				"description": "Name of the volume",
 			},
+			"driver": map[string]interface{}{
+				"type":        "string",
+				"description": "Volume driver (default local)",
+			},
+			"driver_opts": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Driver options, e.g. {\"type\": \"nfs\", \"o\": \"addr=10.0.0.5,rw\", \"device\": \":/exports/data\"} for an NFS mount with the local driver",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"labels": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Labels to set on the volume",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"idempotent": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat an existing resource with the same name as success (default true)",
+			},
 		},
 		"required": []string{"name"},
-	}, func(ctx context.Context, s *Server, params map[string]interface{}) error {
-		name, _ := params["name"].(string)
-		return docker.CreateVolume(ctx, s.dockerClient, name)
-	})
+	}, createVolumeHandler)
 
 	s.RegisterTool("run_container", "Run (start) a Docker container", map[string]interface{}{
 		"type": "object",
@@ -117,12 +445,99 @@ func NewServer() (*Server, error) {
 				"type":        "string",
 				"description": "Name of the container",
 			},
+			"start_attempts": map[string]interface{}{
+				"type":        "integer",
+				"description": "How many times to poll for the container to report running after start",
+			},
 		},
 		"required": []string{"name"},
-	}, func(ctx context.Context, s *Server, params map[string]interface{}) error {
-		name, _ := params["name"].(string)
-		return docker.RunContainer(ctx, s.dockerClient, name)
-	})
+	}, runContainerHandler)
+
+	s.RegisterTool("wait_container", "Wait for a container to exit and report its exit code; fails on a non-zero exit", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "number",
+				"description": "Give up waiting after this many seconds (bounded by the action timeout)",
+			},
+		},
+		"required": []string{"name"},
+	}, waitContainerHandler)
+
+	s.RegisterTool("pause_container", "Pause a running container, freezing its processes without stopping it", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+		},
+		"required": []string{"name"},
+	}, pauseContainerHandler)
+
+	s.RegisterTool("unpause_container", "Resume a paused container", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+		},
+		"required": []string{"name"},
+	}, unpauseContainerHandler)
+
+	s.RegisterTool("container_stats", "Report a running container's CPU, memory and network usage", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+		},
+		"required": []string{"name"},
+	}, containerStatsHandler)
+
+	s.RegisterTool("copy_to_container", "Copy a local file or directory into a container", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"source_path": map[string]interface{}{
+				"type":        "string",
+				"description": "Local file or directory to copy",
+			},
+			"dest_path": map[string]interface{}{
+				"type":        "string",
+				"description": "Absolute path the copy should have inside the container; its parent directory must exist",
+			},
+		},
+		"required": []string{"name", "source_path", "dest_path"},
+	}, copyToContainerHandler)
+
+	s.RegisterTool("copy_from_container", "Copy a file or directory out of a container into a local directory", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the container",
+			},
+			"source_path": map[string]interface{}{
+				"type":        "string",
+				"description": "File or directory inside the container",
+			},
+			"dest_path": map[string]interface{}{
+				"type":        "string",
+				"description": "Local directory to extract into (created if missing); it must lie within the policy's copy_dir, by default the server's working directory",
+			},
+		},
+		"required": []string{"name", "source_path", "dest_path"},
+	}, copyFromContainerHandler)
 
 	s.RegisterTool("pull_image", "Pull a Docker image", map[string]interface{}{
 		"type": "object",
@@ -139,15 +554,288 @@ func NewServer() (*Server, error) {
 				"type":        "string",
 				"description": "Image tag",
 			},
+			"force_pull": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Pull even if the image is already present locally (e.g. to refresh latest)",
+			},
 		},
 		"required": []string{"image"},
-	}, func(ctx context.Context, s *Server, params map[string]interface{}) error {
-		return docker.PullImage(ctx, s.dockerClient, params)
+	}, pullImageHandler)
+
+	s.RegisterTool("tag_image", "Tag a local Docker image with another reference", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"source": map[string]interface{}{
+				"type":        "string",
+				"description": "Existing local image (e.g. myapp:build)",
+			},
+			"target": map[string]interface{}{
+				"type":        "string",
+				"description": "New reference for the image (e.g. myapp:latest)",
+			},
+		},
+		"required": []string{"source", "target"},
+	}, tagImageHandler)
+
+	s.RegisterTool("remove_image", "Remove a local Docker image to free disk space", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"image": map[string]interface{}{
+				"type":        "string",
+				"description": "Image reference (e.g. nginx:latest) or ID",
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Remove the image even if stopped containers use it or it has other tags",
+			},
+			"prune_children": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also delete untagged parent images (default true)",
+			},
+		},
+		"required": []string{"image"},
+	}, removeImageHandler)
+
+	s.RegisterTool("list_images", "List local Docker images", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"reference": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list images matching this reference (e.g. nginx or nginx:latest)",
+			},
+			"label": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list images with this label (key or key=value)",
+			},
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list images labelled as belonging to this project",
+			},
+		},
+	}, listImagesHandler)
+
+	s.RegisterTool("build_image", "Build a Docker image from an uploaded build context", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tag": map[string]interface{}{
+				"type":        "string",
+				"description": "Tag for the built image (e.g. myapp:latest)",
+			},
+			"context": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the uploaded tar build context",
+			},
+			"dockerfile": map[string]interface{}{
+				"type":        "string",
+				"description": "Path of the Dockerfile inside the build context",
+			},
+		},
+		"required": []string{"tag", "context"},
+	}, func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+		tag, _ := params["tag"].(string)
+		contextName, _ := params["context"].(string)
+		dockerfile, _ := params["dockerfile"].(string)
+		fh, ok := buildContextFrom(ctx, contextName)
+		if !ok {
+			return nil, fmt.Errorf("build context %q was not uploaded", contextName)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open build context %q: %w", contextName, err)
+		}
+		defer f.Close()
+		ctx, cancel := context.WithTimeout(ctx, s.timeouts.Build)
+		defer cancel()
+		buildLog, err := docker.BuildImage(ctx, s.dockerClient, f, tag, dockerfile, progressFrom(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"tag": tag, "log": buildLog}, nil
 	})
 
+	s.RegisterTool("compose_up", "Create and start the services of a docker-compose file under a project", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project name used to label and prefix the created resources",
+			},
+			"file": map[string]interface{}{
+				"type":        "string",
+				"description": "Path of the compose file on the server",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "Inline compose file content (used when file is not given)",
+			},
+		},
+		"required": []string{"project"},
+	}, composeUpHandler)
+
+	s.RegisterTool("destroy_project", "Remove the resources the server created for a project", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project whose recorded resources should be removed",
+			},
+		},
+		"required": []string{"project"},
+	}, destroyProjectHandler)
+
+	s.RegisterTool("test_connectivity", "Check that one container can reach another over the network", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "Container to run the check from",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "Container to reach",
+			},
+			"port": map[string]interface{}{
+				"type":        "integer",
+				"description": "TCP port to dial on the target (ping is used when omitted)",
+			},
+		},
+		"required": []string{"from", "to"},
+	}, testConnectivityHandler)
+
+	s.RegisterTool("project_ps", "List the containers of a project with their state, flagging crash-looping ones", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project to list (defaults to the project of the plan being executed)",
+			},
+			"crash_window_seconds": map[string]interface{}{
+				"type":        "number",
+				"description": "How long to watch restart counts for crash loops (default 3, 0 disables)",
+			},
+		},
+	}, projectPsHandler)
+
+	s.RegisterTool("docker_events", "Wait for the next Docker events about a project's containers, networks and volumes", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project to watch (defaults to the project of the plan being executed)",
+			},
+			"limit": map[string]interface{}{
+				"type":        "number",
+				"description": "Number of events to wait for (default 10, at most 1000)",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "number",
+				"description": "How long to wait before returning the events seen so far (default 10)",
+			},
+		},
+	}, eventsHandler)
+
+	s.RegisterTool("project_graph", "Show which containers of a project share networks", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project to graph (defaults to the project of the plan being executed)",
+			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"json", "dot"},
+				"description": "Also render the graph in Graphviz DOT format when set to dot",
+			},
+		},
+	}, projectGraphHandler)
+
+	s.RegisterTool("stop_by_label", "Stop every running container matching a set of labels", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "Labels a container must carry to be stopped; an empty value matches any value",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Seconds to wait for each container to exit before killing it",
+			},
+		},
+		"required": []string{"labels"},
+	}, stopByLabelHandler)
+
 	return s, nil
 }
 
+// projectKey is the context key under which the project a plan applies to is stored.
+type projectKey struct{}
+
+// withProject returns a copy of ctx carrying the project name of the plan being executed.
+func withProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, projectKey{}, project)
+}
+
+// projectFrom returns the project of the plan being executed, or "" outside a project.
+func projectFrom(ctx context.Context) string {
+	project, _ := ctx.Value(projectKey{}).(string)
+	return project
+}
+
+// buildContextsKey is the context key under which uploaded build contexts are stored.
+type buildContextsKey struct{}
+
+// withBuildContexts returns a copy of ctx carrying the uploaded build contexts, keyed by name.
+func withBuildContexts(ctx context.Context, contexts map[string]*multipart.FileHeader) context.Context {
+	return context.WithValue(ctx, buildContextsKey{}, contexts)
+}
+
+// buildContextFrom looks up an uploaded build context by name.
+func buildContextFrom(ctx context.Context, name string) (*multipart.FileHeader, bool) {
+	contexts, _ := ctx.Value(buildContextsKey{}).(map[string]*multipart.FileHeader)
+	fh, ok := contexts[name]
+	return fh, ok
+}
+
+// progressKey is the context key under which a streaming client's progress callback is stored.
+type progressKey struct{}
+
+// withProgress returns a copy of ctx whose long-running tools report progress lines to fn.
+func withProgress(ctx context.Context, fn func(string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFrom returns the progress callback carried by ctx, or nil when the caller is not
+// streaming.
+func progressFrom(ctx context.Context) func(string) {
+	fn, _ := ctx.Value(progressKey{}).(func(string))
+	return fn
+}
+
+// validateBuildContexts checks that every build_image action references an uploaded build context.
+func validateBuildContexts(ctx context.Context, plan []map[string]interface{}) error {
+	for i, action := range plan {
+		if actionType, _ := action["action"].(string); actionType != "build_image" {
+			continue
+		}
+		parameters, _ := action["parameters"].(map[string]interface{})
+		name, _ := parameters["context"].(string)
+		if name == "" {
+			return fmt.Errorf("action %d (build_image) is missing a build context", i)
+		}
+		if _, ok := buildContextFrom(ctx, name); !ok {
+			return fmt.Errorf("action %d (build_image) references build context %q which was not uploaded", i, name)
+		}
+	}
+	return nil
+}
+
+// Close cancels any in-flight plans and tool calls and releases the Docker client.
+func (s *Server) Close() error {
+	s.cancel()
+	return s.dockerClient.Close()
+}
+
 // RegisterTool adds a new tool to the server's registry.
 func (s *Server) RegisterTool(name, description string, inputSchema map[string]interface{}, handler ToolHandler) {
 	s.tools[name] = RegisteredTool{
@@ -158,9 +846,88 @@ func (s *Server) RegisterTool(name, description string, inputSchema map[string]i
 	}
 }
 
+// CallLLMArgs are the arguments of CallLLM. A bare JSON string is accepted as the
+// instruction alone, so existing clients keep working.
+type CallLLMArgs struct {
+	Input string `json:"input"`
+	// History holds earlier turns of the conversation, oldest first, so the model can
+	// refine its previous plan instead of starting over.
+	History []SessionTurn `json:"history,omitempty"`
+	// SessionID, when set, takes the history from that session and records the new
+	// instruction and plan in it.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// UnmarshalJSON accepts either a JSON string (the instruction) or a CallLLMArgs object.
+func (a *CallLLMArgs) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		*a = CallLLMArgs{}
+		return json.Unmarshal(trimmed, &a.Input)
+	}
+	type plain CallLLMArgs
+	return json.Unmarshal(data, (*plain)(a))
+}
+
 // CallLLM sends user instructions to the LLM and returns a generated plan (JSON).
-func (s *Server) CallLLM(args *string, reply *string) error {
-	log.Printf("[CallLLM] Received user input: %s", *args)
+func (s *Server) CallLLM(ctx context.Context, args *CallLLMArgs, reply *string) error {
+	defer trackInflight("CallLLM")()
+	if args == nil || strings.TrimSpace(args.Input) == "" {
+		return errors.New("CallLLM requires an instruction")
+	}
+	history := args.History
+	if args.SessionID != "" {
+		sessionHistory, err := s.sessionHistory(args.SessionID)
+		if err != nil {
+			return err
+		}
+		history = append(sessionHistory, history...)
+	}
+	plan, err := s.generatePlan(ctx, args.Input, history)
+	if err != nil {
+		return err
+	}
+	if args.SessionID != "" {
+		s.recordSessionPlan(args.SessionID, args.Input, plan)
+	}
+	*reply = plan
+	return nil
+}
+
+// generatePlan asks the LLM for a plan for input, following on from the conversation in
+// history.
+func (s *Server) generatePlan(ctx context.Context, input string, history []SessionTurn) (string, error) {
+	log.Printf("[CallLLM] Received user input: %s", input)
+	if err := s.checkTokenBudget(); err != nil {
+		return "", err
+	}
+	prompt, registeredTools := s.planPrompt(input, history)
+	ctx, span := tracer.Start(ctx, "llm.generate", trace.WithAttributes(attribute.String("llm.model", s.config().LLM.Model)))
+	response, err := s.llm().GeneratePlan(ctx, prompt, registeredTools)
+	promptTokens, completionTokens := llm.Usage(response)
+	span.SetAttributes(attribute.Int("llm.prompt_tokens", promptTokens), attribute.Int("llm.completion_tokens", completionTokens))
+	endSpan(span, err)
+	recordLLMCall(response, err)
+	s.tokens.add(response)
+	s.traceToolCalls(input, response, err)
+	if err != nil {
+		log.Printf("[CallLLM] OpenAI error: %v", err)
+		return "", fmt.Errorf("CallLLM OpenAI API error: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("CallLLM received an empty response from OpenAI")
+	}
+	plan, err := normalizePlan(response.Choices[0].Content)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[CallLLM] Returning JSON plan: %s", plan)
+	return plan, nil
+}
+
+// planPrompt builds the messages and tool definitions sent to the LLM for an instruction:
+// the system prompt first, then the earlier turns of the conversation, then the new
+// instruction.
+func (s *Server) planPrompt(input string, history []SessionTurn) ([]llms.MessageContent, []llms.Tool) {
 	var registeredTools []llms.Tool
 	for _, tool := range s.tools {
 		registeredTools = append(registeredTools, llms.Tool{
@@ -172,107 +939,376 @@ func (s *Server) CallLLM(args *string, reply *string) error {
 			},
 		})
 	}
-	prompt := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeHuman, *args),
-		llms.TextParts(llms.ChatMessageTypeSystem, utils.GetSystemPrompt()),
+	prompt := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, s.systemPrompt())}
+	for _, turn := range history {
+		role := llms.ChatMessageTypeHuman
+		if turn.Role == "assistant" {
+			role = llms.ChatMessageTypeAI
+		}
+		prompt = append(prompt, llms.TextParts(role, turn.Content))
+	}
+	prompt = append(prompt, llms.TextParts(llms.ChatMessageTypeHuman, input))
+	return prompt, registeredTools
+}
+
+// systemPrompt renders the configured system prompt template, or the built-in one, with the
+// actions the server offers and the policy allows. A custom template was checked when the
+// configuration was loaded; should it still fail to render, the built-in prompt is used.
+func (s *Server) systemPrompt() string {
+	cfg := s.config()
+	var allowed []string
+	for name := range s.tools {
+		if cfg.Policy.Check(policy.Action{Type: name}) == nil {
+			allowed = append(allowed, name)
+		}
+	}
+	sort.Strings(allowed)
+	data := utils.PromptData{PlanShape: utils.PlanShape(cfg.LLM.PlanFormat), AllowedActions: strings.Join(allowed, ", ")}
+	text := cfg.LLM.SystemPromptTemplate()
+	if text == "" {
+		text = utils.DefaultSystemPrompt
 	}
-	response, err := s.llmClient.GeneratePlan(context.Background(), prompt, registeredTools)
+	prompt, err := utils.RenderSystemPrompt(text, data)
 	if err != nil {
-		log.Printf("[CallLLM] OpenAI error: %v", err)
-		return fmt.Errorf("CallLLM OpenAI API error: %w", err)
+		log.Printf("[CallLLM] Custom system prompt failed, using the built-in one: %v", err)
+		prompt, _ = utils.RenderSystemPrompt(utils.DefaultSystemPrompt, data)
 	}
-	if len(response.Choices) == 0 {
-		return fmt.Errorf("CallLLM received an empty response from OpenAI")
+	return prompt
+}
+
+// normalizePlan checks that the LLM output is a plan, given either as a bare JSON array of
+// actions or wrapped as {"plan": [...]}, and re-marshals the actions as a compact bare array.
+// That array is the one format CallLLM returns; ExecutePlan accepts it directly or inside a
+// plan document.
+// Output that is JSON but not shaped like a plan is rejected with the JSON pointer of the
+// offending element, which gives the retry loop something precise to correct.
+func normalizePlan(content string) (string, error) {
+	if err := mcp.ValidatePlan([]byte(content)); err != nil {
+		var schemaErr *mcp.PlanSchemaError
+		if errors.As(err, &schemaErr) {
+			log.Printf("[CallLLM] LLM response does not match the plan schema: %v", err)
+			return "", fmt.Errorf("CallLLM returned a malformed plan: %w", err)
+		}
 	}
-	var plan []map[string]interface{}
-	if err := json.Unmarshal([]byte(response.Choices[0].Content), &plan); err != nil {
+	doc, err := mcp.ParsePlan([]byte(content))
+	if err != nil {
 		log.Printf("[CallLLM] LLM response is not valid JSON: %v", err)
-		return fmt.Errorf("CallLLM returned invalid JSON: %w", err)
+		return "", fmt.Errorf("CallLLM returned invalid JSON: %w", err)
+	}
+	if doc.Plan == nil {
+		return "", fmt.Errorf("CallLLM returned JSON without a plan: expected an array of actions or {\"plan\": [...]}")
 	}
-	planBytes, err := json.Marshal(plan)
+	planBytes, err := json.Marshal(doc.Plan)
 	if err != nil {
-		return fmt.Errorf("CallLLM failed to marshal plan: %w", err)
+		return "", fmt.Errorf("CallLLM failed to marshal plan: %w", err)
 	}
-	*reply = string(planBytes)
-	log.Printf("[CallLLM] Returning JSON plan: %s", *reply)
+	return string(planBytes), nil
+}
+
+// ExecutePlan processes and executes the plan using the registered tool handlers. A plan
+// naming a project records its workflow phase and checkpoints under that name, so the name
+// must match ^[a-zA-Z0-9][a-zA-Z0-9_.-]*$ (Docker's own rule for names); any other project
+// name is refused before an action runs.
+func (s *Server) ExecutePlan(ctx context.Context, args *string, reply *mcp.RPCResponse) error {
+	defer trackInflight("ExecutePlan")()
+	defer observePlanDuration(time.Now())
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.PlanDeadline(false))
+	defer cancel()
+	*reply = s.executePlan(ctx, args)
 	return nil
 }
 
-// ExecutePlan processes and executes the plan using the registered tool handlers.
-func (s *Server) ExecutePlan(args *string, reply *mcp.RPCResponse) error {
-	response := mcp.RPCResponse{Version: mcp.JSONRPCVersion}
+// executePlan parses the plan JSON and runs each action in order, stopping at the first
+// failure. Completed actions are checkpointed so that a plan submitted again with
+// "resume": true skips the actions that already ran.
+func (s *Server) executePlan(ctx context.Context, args *string) (response mcp.RPCResponse) {
+	response = mcp.RPCResponse{Version: mcp.JSONRPCVersion}
+	var plan []map[string]interface{}
+	completed := 0
+	// replayed is set when an idempotency key replays a recorded result, which ran nothing
+	// and so is left out of the plan metrics.
+	replayed := false
+	ctx, span := tracer.Start(ctx, "plan.execute")
+	defer func() {
+		if !replayed {
+			s.recordPlan(plan, completed, response.Error != nil)
+		}
+		span.SetAttributes(attribute.Int("plan.actions", len(plan)), attribute.Int("plan.completed", completed))
+		var err error
+		if response.Error != nil {
+			err = response.Error
+		}
+		endSpan(span, err)
+	}()
 	if args == nil || *args == "" {
 		response.Error = mcp.NewError(-32602, "ExecutePlan received empty plan")
-		*reply = response
-		return nil
+		return response
 	}
 	log.Printf("[ExecutePlan] Received Plan: %s", *args)
-	var plan []map[string]interface{}
-	if err := json.Unmarshal([]byte(*args), &plan); err != nil {
+	doc, err := mcp.ParsePlan([]byte(*args))
+	if err != nil {
 		response.Error = mcp.NewError(-32700, fmt.Sprintf("failed to parse plan JSON: %v", err))
-		*reply = response
-		return nil
+		return response
+	}
+	span.SetAttributes(attribute.String("project", doc.Project))
+	if doc.IdempotencyKey != "" {
+		release, err := state.ClaimIdempotencyKey(planIdempotencyKey(doc.IdempotencyKey))
+		if errors.Is(err, state.ErrIdempotencyKeyInUse) {
+			err = errdefs.Unavailable(fmt.Errorf("a plan with idempotency key %q is still running; retry later to get its result", doc.IdempotencyKey))
+		}
+		if err != nil {
+			response.Error = toolError(err, err.Error())
+			return response
+		}
+		defer release()
+		if cached, ok, err := state.LookupIdempotencyKey(planIdempotencyKey(doc.IdempotencyKey)); err != nil {
+			log.Printf("[ExecutePlan] Failed to look up idempotency key: %v", err)
+		} else if ok {
+			log.Printf("[ExecutePlan] Replaying result recorded for idempotency key %q", doc.IdempotencyKey)
+			replayed = true
+			response.Result = cached
+			return response
+		}
 	}
+	plan = doc.Plan
 	if len(plan) == 0 {
 		response.Error = mcp.NewError(-32602, "received empty plan from LLM")
-		*reply = response
-		return nil
+		return response
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	for _, action := range plan {
+	if doc.Profile != "" {
+		profile, ok := s.profileSet()[doc.Profile]
+		if !ok {
+			response.Error = mcp.NewError(-32602, fmt.Sprintf("unknown profile: %s", doc.Profile))
+			return response
+		}
+		if doc.Plan, err = profile.Apply(doc.Plan); err != nil {
+			response.Error = mcp.NewError(-32602, fmt.Sprintf("failed to apply profile %s: %v", doc.Profile, err))
+			return response
+		}
+		plan = doc.Plan
+	}
+	if err := validateBuildContexts(ctx, plan); err != nil {
+		response.Error = mcp.NewError(-32602, err.Error())
+		return response
+	}
+	if err := s.checkPlanPolicy(plan); err != nil {
+		response.Error = toolError(err, err.Error())
+		return response
+	}
+	if doc.Project != "" {
+		if doc.OnBusy != "" && doc.OnBusy != onBusyWait && doc.OnBusy != onBusyFail {
+			response.Error = mcp.NewError(-32602, fmt.Sprintf("invalid on_busy %q: use %q or %q", doc.OnBusy, onBusyWait, onBusyFail))
+			return response
+		}
+		unlock, err := s.projects.lock(ctx, doc.Project, doc.OnBusy)
+		if err != nil {
+			response.Error = toolError(err, err.Error())
+			return response
+		}
+		defer unlock()
+		ctx = withProject(ctx, doc.Project)
+		if _, err := state.AdvanceWorkflow(doc.Project, state.PhaseApplying, doc.Hash(), ""); err != nil {
+			response.Error = mcp.NewError(-32602, fmt.Sprintf("cannot apply plan: %v", err))
+			return response
+		}
+		defer func() {
+			to, detail := state.PhaseConverged, ""
+			if response.Error != nil {
+				to, detail = state.PhaseFailed, response.Error.Message
+			}
+			if _, err := state.AdvanceWorkflow(doc.Project, to, doc.Hash(), detail); err != nil {
+				log.Printf("[ExecutePlan] Failed to record workflow phase for project %s: %v", doc.Project, err)
+			}
+		}()
+	}
+	checkpoint := &state.Checkpoint{PlanHash: doc.Hash(), Completed: map[int]bool{}}
+	if doc.Resume {
+		if checkpoint, err = state.LoadCheckpoint(doc.Hash()); err != nil {
+			response.Error = mcp.NewError(-32000, fmt.Sprintf("cannot resume plan: %v", err))
+			return response
+		}
+	}
+	results := make([]mcp.ActionResult, 0, len(plan))
+	created := []mcp.ResourceRef{}
+	for i, action := range plan {
+		// Stop before the next action once the caller has gone away or the server is stopping.
+		if err := ctx.Err(); err != nil {
+			response.Error = mcp.NewError(-32000, fmt.Sprintf("plan aborted before action %d: %v", i, err))
+			return response
+		}
 		log.Printf("[ExecutePlan] Processing action: %+v", action)
 		actionType, ok := action["action"].(string)
 		if !ok || actionType == "" {
 			response.Error = mcp.NewError(-32602, "invalid action format")
-			*reply = response
-			return nil
+			return response
+		}
+		if checkpoint.Completed[i] {
+			log.Printf("[ExecutePlan] Skipping action %d (%s), already completed", i, actionType)
+			results = append(results, mcp.ActionResult{Action: actionType, Skipped: true})
+			continue
 		}
 		parameters, _ := action["parameters"].(map[string]interface{})
+		actionKey, _ := action["idempotency_key"].(string)
+		if actionKey != "" {
+			if cached, ok, err := state.LookupIdempotencyKey(actionIdempotencyKey(actionKey)); err != nil {
+				log.Printf("[ExecutePlan] Failed to look up idempotency key: %v", err)
+			} else if ok {
+				log.Printf("[ExecutePlan] Replaying action %d (%s) recorded for idempotency key %q", i, actionType, actionKey)
+				completed++
+				checkpoint.Completed[i] = true
+				if err := state.SaveCheckpoint(checkpoint); err != nil {
+					log.Printf("[ExecutePlan] Failed to checkpoint action %d: %v", i, err)
+				}
+				var out map[string]interface{}
+				if err := json.Unmarshal(cached, &out); err != nil {
+					log.Printf("[ExecutePlan] Failed to decode result recorded for idempotency key %q: %v", actionKey, err)
+				}
+				results = append(results, mcp.ActionResult{Action: actionType, Result: out, Cached: true})
+				continue
+			}
+		}
 		if tool, exists := s.tools[actionType]; exists {
-			if err := tool.Handler(ctx, s, parameters); err != nil {
-				response.Error = mcp.NewError(-32000, fmt.Sprintf("failed to execute tool %s: %v", actionType, err))
-				*reply = response
-				return nil
+			out, err := s.runTool(ctx, tool, parameters)
+			if err != nil {
+				response.Error = toolError(err, fmt.Sprintf("failed to execute tool %s: %v", actionType, err))
+				return response
+			}
+			completed++
+			if ref, ok := createdResource(actionType, parameters, out); ok {
+				created = append(created, ref)
+			}
+			checkpoint.Completed[i] = true
+			if err := state.SaveCheckpoint(checkpoint); err != nil {
+				log.Printf("[ExecutePlan] Failed to checkpoint action %d: %v", i, err)
 			}
+			if actionKey != "" {
+				if err := storeIdempotentResult(actionIdempotencyKey(actionKey), out); err != nil {
+					log.Printf("[ExecutePlan] Failed to record idempotency key %q: %v", actionKey, err)
+				}
+			}
+			results = append(results, mcp.ActionResult{Action: actionType, Result: out})
 		} else {
-			response.Error = mcp.NewError(-32601, fmt.Sprintf("unknown action: %s", actionType))
-			*reply = response
-			return nil
+			response.Error = mcp.NewError(-32601, s.unknownToolMessage("action", actionType))
+			return response
+		}
+	}
+	if doc.Project != "" {
+		if err := recordAppliedPlan(doc, created); err != nil {
+			log.Printf("[ExecutePlan] Failed to record state for project %s: %v", doc.Project, err)
 		}
 	}
-	result, err := json.Marshal(map[string]string{
-		"status":  "success",
-		"message": "Plan executed successfully",
+	if err := state.DeleteCheckpoint(checkpoint.PlanHash); err != nil {
+		log.Printf("[ExecutePlan] Failed to clear checkpoint: %v", err)
+	}
+	result, err := json.Marshal(mcp.PlanResult{
+		Status:           mcp.StatusSuccess,
+		Message:          "Plan executed successfully",
+		Actions:          results,
+		CreatedResources: created,
 	})
 	if err != nil {
 		response.Error = mcp.NewError(-32000, fmt.Sprintf("failed to marshal result: %v", err))
 	} else {
 		response.Result = json.RawMessage(result)
+		if doc.IdempotencyKey != "" {
+			if err := storeIdempotentResult(planIdempotencyKey(doc.IdempotencyKey), response.Result); err != nil {
+				log.Printf("[ExecutePlan] Failed to record idempotency key %q: %v", doc.IdempotencyKey, err)
+			}
+		}
 	}
-	*reply = response
-	return nil
+	return response
+}
+
+// Plan and action idempotency keys live in separate namespaces, so a client reusing one
+// string for both does not replay the wrong kind of result.
+func planIdempotencyKey(key string) string   { return "plan:" + key }
+func actionIdempotencyKey(key string) string { return "action:" + key }
+
+// storeIdempotentResult records result under key for state.DefaultIdempotencyTTL.
+func storeIdempotentResult(key string, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return state.StoreIdempotencyKey(key, data, state.DefaultIdempotencyTTL)
+}
+
+// createdResourceTypes maps the actions that create resources to the resource type recorded
+// in project state.
+var createdResourceTypes = map[string]string{
+	"create_network":   "network",
+	"create_volume":    "volume",
+	"create_container": "container",
+}
+
+// createdResource returns the resource an action created, if it created one. Resources a
+// handler found already existing are not claimed as created by the plan.
+func createdResource(actionType string, parameters, out map[string]interface{}) (state.ResourceRef, bool) {
+	resourceType, ok := createdResourceTypes[actionType]
+	if !ok || out == nil {
+		return state.ResourceRef{}, false
+	}
+	if existing, _ := out["existing"].(bool); existing {
+		return state.ResourceRef{}, false
+	}
+	if service, _ := out["service"].(bool); service {
+		resourceType = "service"
+	}
+	id, _ := out["id"].(string)
+	name, _ := parameters["name"].(string)
+	if id == "" {
+		return state.ResourceRef{}, false
+	}
+	return state.ResourceRef{Type: resourceType, Name: name, ID: id}, true
+}
+
+// recordAppliedPlan appends a successfully executed plan and the resources it created to
+// the project's persisted state.
+func recordAppliedPlan(doc mcp.PlanDocument, created []state.ResourceRef) error {
+	return state.UpdateProjectState(doc.Project, func(st *state.ProjectState) error {
+		st.Plans = append(st.Plans, state.AppliedPlan{
+			Hash:      doc.Hash(),
+			Plan:      doc.Plan,
+			AppliedAt: time.Now().UTC(),
+			Created:   created,
+		})
+		st.Resources = append(st.Resources, created...)
+		return nil
+	})
 }
 
 // CallTool allows direct invocation of an individual tool.
-func (s *Server) CallTool(args *mcp.ToolCallArgs, reply *mcp.RPCResponse) error {
+func (s *Server) CallTool(ctx context.Context, args *mcp.ToolCallArgs, reply *mcp.RPCResponse) error {
+	defer trackInflight("CallTool")()
 	response := mcp.RPCResponse{Version: mcp.JSONRPCVersion}
 	tool, exists := s.tools[args.ToolName]
 	if !exists {
-		response.Error = mcp.NewError(-32601, fmt.Sprintf("unknown tool: %s", args.ToolName))
+		response.Error = mcp.NewError(-32601, s.unknownToolMessage("tool", args.ToolName))
+		*reply = response
+		return nil
+	}
+	if err := s.checkPolicy(args.ToolName, args.Parameters); err != nil {
+		response.Error = toolError(err, err.Error())
 		*reply = response
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.PlanDeadline(false))
 	defer cancel()
-	if err := tool.Handler(ctx, s, args.Parameters); err != nil {
-		response.Error = mcp.NewError(-32000, fmt.Sprintf("failed to execute tool %s: %v", args.ToolName, err))
+	out, err := s.runTool(ctx, tool, args.Parameters)
+	if err != nil {
+		response.Error = toolError(err, fmt.Sprintf("failed to execute tool %s: %v", args.ToolName, err))
 		*reply = response
 		return nil
 	}
-	result, err := json.Marshal(map[string]string{
-		"status":  "success",
-		"message": fmt.Sprintf("Tool %s executed successfully", args.ToolName),
+	created := []mcp.ResourceRef{}
+	if ref, ok := createdResource(args.ToolName, args.Parameters, out); ok {
+		created = append(created, ref)
+	}
+	result, err := json.Marshal(mcp.ToolResult{
+		Status:           mcp.StatusSuccess,
+		Message:          fmt.Sprintf("Tool %s executed successfully", args.ToolName),
+		Result:           out,
+		CreatedResources: created,
 	})
 	if err != nil {
 		response.Error = mcp.NewError(-32000, fmt.Sprintf("failed to marshal result: %v", err))
@@ -283,6 +1319,140 @@ func (s *Server) CallTool(args *mcp.ToolCallArgs, reply *mcp.RPCResponse) error
 	return nil
 }
 
+// ListPrompts lists the registered prompt templates with their arguments, so clients of the
+// legacy transport can discover prompts as prompts/list clients do.
+func (s *Server) ListPrompts(_ *struct{}, reply *mcp.RPCResponse) error {
+	response := mcp.RPCResponse{Version: mcp.JSONRPCVersion}
+	result, err := json.Marshal(mcp.ListPromptsResult{Prompts: mcp.ListPrompts()})
+	if err != nil {
+		response.Error = mcp.NewError(-32000, fmt.Sprintf("failed to marshal result: %v", err))
+	} else {
+		response.Result = json.RawMessage(result)
+	}
+	*reply = response
+	return nil
+}
+
+// rpcService exposes the Server's methods over net/rpc. A new one is bound to each HTTP
+// request's context, so a client disconnect cancels the work that request started.
+type rpcService struct {
+	ctx context.Context
+	s   *Server
+}
+
+// CallLLM forwards to Server.CallLLM with the request context.
+func (r *rpcService) CallLLM(args *CallLLMArgs, reply *string) error {
+	return r.s.CallLLM(r.ctx, args, reply)
+}
+
+// ExecutePlan forwards to Server.ExecutePlan with the request context.
+func (r *rpcService) ExecutePlan(args *string, reply *mcp.RPCResponse) error {
+	return r.s.ExecutePlan(r.ctx, args, reply)
+}
+
+// CallTool forwards to Server.CallTool with the request context.
+func (r *rpcService) CallTool(args *mcp.ToolCallArgs, reply *mcp.RPCResponse) error {
+	return r.s.CallTool(r.ctx, args, reply)
+}
+
+// ProjectWorkflow forwards to Server.ProjectWorkflow.
+func (r *rpcService) ProjectWorkflow(args *string, reply *state.Workflow) error {
+	return r.s.ProjectWorkflow(args, reply)
+}
+
+// AdvanceWorkflow forwards to Server.AdvanceWorkflow.
+func (r *rpcService) AdvanceWorkflow(args *WorkflowArgs, reply *state.Workflow) error {
+	return r.s.AdvanceWorkflow(args, reply)
+}
+
+// ToolCallTraces forwards to Server.ToolCallTraces.
+func (r *rpcService) ToolCallTraces(args *int, reply *[]ToolCallTrace) error {
+	return r.s.ToolCallTraces(args, reply)
+}
+
+// WatchConverge forwards to Server.WatchConverge.
+func (r *rpcService) WatchConverge(args *ConvergeArgs, reply *ConvergeStatus) error {
+	return r.s.WatchConverge(args, reply)
+}
+
+// StopConverge forwards to Server.StopConverge.
+func (r *rpcService) StopConverge(args *string, reply *ConvergeStatus) error {
+	return r.s.StopConverge(args, reply)
+}
+
+// ConvergeStatus forwards to Server.ConvergeStatus.
+func (r *rpcService) ConvergeStatus(args *string, reply *ConvergeStatus) error {
+	return r.s.ConvergeStatus(args, reply)
+}
+
+// ReloadConfig forwards to Server.ReloadConfig.
+func (r *rpcService) ReloadConfig(args *struct{}, reply *ReloadResult) error {
+	return r.s.ReloadConfig(args, reply)
+}
+
+// StartSession forwards to Server.StartSession.
+func (r *rpcService) StartSession(args *StartSessionArgs, reply *SessionReply) error {
+	return r.s.StartSession(args, reply)
+}
+
+// SendCommand forwards to Server.SendCommand with the request context.
+func (r *rpcService) SendCommand(args *SessionCommandArgs, reply *SessionReply) error {
+	return r.s.SendCommand(r.ctx, args, reply)
+}
+
+// EndSession forwards to Server.EndSession.
+func (r *rpcService) EndSession(args *string, reply *SessionReply) error {
+	return r.s.EndSession(args, reply)
+}
+
+// TokenUsage forwards to Server.TokenUsage.
+func (r *rpcService) TokenUsage(args *struct{}, reply *TokenUsage) error {
+	return r.s.TokenUsage(args, reply)
+}
+
+// ListPrompts forwards to Server.ListPrompts.
+func (r *rpcService) ListPrompts(args *struct{}, reply *mcp.RPCResponse) error {
+	return r.s.ListPrompts(args, reply)
+}
+
+// maxRPCBody bounds the size of a JSON-RPC request body.
+const maxRPCBody = 16 << 20
+
+// handleRPC serves one JSON-RPC request. Legacy "Server.*" methods go through net/rpc as
+// before; any other method (the standard MCP names) is answered by dispatch with a
+// JSON-RPC 2.0 response.
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRPCBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req jsonrpcRequest
+	if err := json.Unmarshal(body, &req); err == nil && req.Method != "" && !strings.HasPrefix(req.Method, "Server.") {
+		result, rpcErr := s.dispatch(r.Context(), req.Method, req.Params)
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(newJSONRPCResponse(req.ID, result, rpcErr))
+		return
+	}
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("Server", &rpcService{ctx: r.Context(), s: s}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to register RPC service: %v", err), http.StatusInternalServerError)
+		return
+	}
+	rpcServer.ServeCodec(jsonrpc.NewServerCodec(&httpReadWriteCloser{
+		r: io.NopCloser(bytes.NewReader(body)),
+		w: w,
+	}))
+}
+
 // HTTP adapter for net/rpc/jsonrpc.
 type httpReadWriteCloser struct {
 	r io.ReadCloser
@@ -295,33 +1465,174 @@ func (hrwc *httpReadWriteCloser) Write(p []byte) (int, error) { return hrwc.w.Wr
 
 func (hrwc *httpReadWriteCloser) Close() error { return hrwc.r.Close() }
 
-// StartRPCServer starts the JSON-RPC server on port 1234.
-func StartRPCServer() {
-	srv, err := NewServer()
-	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+// maxUploadMemory is the portion of a multipart plan upload kept in memory; larger
+// build contexts are spooled to temporary files.
+const maxUploadMemory = 32 << 20
+
+// ndjsonContentType is the media type clients send in Accept to stream plan progress.
+const ndjsonContentType = "application/x-ndjson"
+
+// uploadEvent is one line of a streamed plan upload response: either a progress line from a
+// running tool ("log") or the final RPC response ("result").
+type uploadEvent struct {
+	Type     string           `json:"type"`
+	Line     string           `json:"line,omitempty"`
+	Response *mcp.RPCResponse `json:"response,omitempty"`
+}
+
+// handlePlanUpload executes a plan sent as a multipart form. The "plan" field carries the
+// plan JSON and every file part is a tarred build context, keyed by its form field name,
+// that build_image actions reference through their "context" parameter. Clients that send
+// "Accept: application/x-ndjson" receive build output as it happens, one JSON event per
+// line, followed by the final result; others receive the RPC response once the plan ends.
+func (s *Server) handlePlanUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "plan upload requires POST", http.StatusMethodNotAllowed)
+		return
 	}
-	rpcServer := rpc.NewServer()
-	if err := rpcServer.RegisterName("Server", srv); err != nil {
-		log.Fatalf("Failed to register RPC service: %v", err)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		http.Error(w, fmt.Sprintf("invalid multipart upload: %v", err), http.StatusBadRequest)
+		return
 	}
-	http.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "JSON-RPC requires POST", http.StatusMethodNotAllowed)
+	defer r.MultipartForm.RemoveAll()
+
+	contexts := make(map[string]*multipart.FileHeader)
+	for name, files := range r.MultipartForm.File {
+		if len(files) > 0 {
+			contexts[name] = files[0]
+		}
+	}
+	plan := r.FormValue("plan")
+	ctx, cancel := context.WithTimeout(withBuildContexts(r.Context(), contexts), s.timeouts.PlanDeadline(len(contexts) > 0))
+	defer cancel()
+
+	if !strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		reply := s.executePlan(ctx, &plan)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			log.Printf("[PlanUpload] Failed to write response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	send := func(event uploadEvent) {
+		if err := enc.Encode(event); err != nil {
+			log.Printf("[PlanUpload] Failed to stream event: %v", err)
 			return
 		}
-		rpcServer.ServeCodec(jsonrpc.NewServerCodec(&httpReadWriteCloser{
-			r: r.Body,
-			w: w,
-		}))
-	})
-	log.Println("JSON-RPC server listening on port 1234 (POST /rpc)...")
-	log.Fatal(http.ListenAndServe(":1234", nil))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	reply := s.executePlan(withProgress(ctx, func(line string) {
+		send(uploadEvent{Type: "log", Line: line})
+	}), &plan)
+	send(uploadEvent{Type: "result", Response: &reply})
+}
+
+// shutdownGracePeriod is how long in-flight requests get to finish after a shutdown signal
+// before their contexts are cancelled.
+const shutdownGracePeriod = 15 * time.Second
+
+// StartRPCServer serves the JSON-RPC server on port 1234 until ctx is cancelled, then shuts
+// down gracefully: in-flight requests get shutdownGracePeriod to finish before the server's
+// context is cancelled and the Docker client is closed. When MCP_API_TOKEN is set, every
+// endpoint except the health checks requires it as a bearer token.
+func StartRPCServer(ctx context.Context) error {
+	srv, err := NewServer()
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+	if err := state.PruneIdempotencyKeys(); err != nil {
+		log.Printf("Failed to prune expired idempotency keys: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", srv.handleRPC)
+	mux.HandleFunc("/plan/upload", srv.handlePlanUpload)
+	mux.HandleFunc("/user-input/stream", srv.handleStreamPlan)
+	mux.HandleFunc("/events", srv.handleEvents)
+	if metricsEnabled() {
+		mux.Handle("/metrics", metricsHandler())
+	}
+	mux.HandleFunc("/healthz", srv.handleHealthz)
+	mux.HandleFunc("/readyz", srv.handleReadyz)
+
+	httpServer := &http.Server{
+		Addr:    ":1234",
+		Handler: requireToken(os.Getenv(config.EnvAPIToken), mux),
+		// Request contexts derive from the server's, so Close aborts in-flight work.
+		BaseContext: func(net.Listener) context.Context { return srv.ctx },
+	}
+	go srv.reloadOnSIGHUP(ctx)
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Println("JSON-RPC server listening on port 1234 (POST /rpc, POST /plan/upload, POST /user-input/stream, GET /events, GET /healthz, GET /readyz, GET /metrics when ENABLE_METRICS is set)...")
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		srv.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests...", shutdownGracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	shutdownErr := httpServer.Shutdown(shutdownCtx)
+	if err := srv.Close(); err != nil {
+		log.Printf("Failed to close Docker client: %v", err)
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("graceful shutdown did not complete: %w", shutdownErr)
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// StartStdioServer serves MCP over stdin and stdout until stdin is closed or ctx is
+// cancelled.
+func StartStdioServer(ctx context.Context) error {
+	srv, err := NewServer()
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+	defer srv.Close()
+	go srv.reloadOnSIGHUP(ctx)
+	log.Println("Serving MCP over stdio")
+	return srv.serveStdio(ctx, os.Stdin, os.Stdout)
 }
 
 func main() {
+	transport := flag.String("transport", "http", "Transport to serve: http (JSON-RPC on port 1234) or stdio (MCP over stdin/stdout)")
+	flag.Parse()
+	log.SetOutput(redactingWriter{w: log.Writer()})
+
 	// Optionally, generate and log a system prompt here using pkg/mcp/prompt.go.
-	StartRPCServer()
-	// Keep the main goroutine alive.
-	select {}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Printf("Tracing disabled: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+	switch *transport {
+	case "http":
+		err = StartRPCServer(ctx)
+	case "stdio":
+		err = StartStdioServer(ctx)
+	default:
+		err = fmt.Errorf("unknown transport %q: use http or stdio", *transport)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
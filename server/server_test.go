@@ -0,0 +1,962 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"santoshkal/mcp-godocker/pkg/config"
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/profiles"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// newTestServer returns a Server with all tools registered, talking to a fake Docker daemon
+// started by useFakeDaemon.
+func newTestServer(t *testing.T, daemon http.HandlerFunc) *Server {
+	t.Helper()
+	useFakeDaemon(t, daemon)
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// useFakeDaemon points the environment NewServer reads at a fakeDaemon serving daemon.
+func useFakeDaemon(t *testing.T, daemon http.HandlerFunc) {
+	t.Helper()
+	fake := fakeDaemon(t, daemon)
+
+	t.Setenv("DOCKER_HOST", "tcp://"+fake.Listener.Addr().String())
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	t.Setenv("DOCKER_CERT_PATH", "")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv(state.StateDirEnv, t.TempDir())
+	t.Setenv(profiles.ProfilesFileEnv, "")
+	t.Setenv(config.EnvConfigFile, filepath.Join(t.TempDir(), "mcp.yaml"))
+	for _, env := range []string{config.EnvLLMProvider, config.EnvLLMModel, config.EnvLLMAPIKeyEnv, config.EnvDefaultProject, config.EnvEndpoint, config.EnvSystemPromptFile} {
+		t.Setenv(env, "")
+	}
+}
+
+// fakeDaemon starts a fake Docker daemon that answers pings itself and passes every other
+// request, with the API version prefix stripped from its path, to daemon. A nil daemon
+// answers 404 to everything else.
+func fakeDaemon(t *testing.T, daemon http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1.") {
+			if i := strings.Index(r.URL.Path[1:], "/"); i > 0 {
+				r.URL.Path = r.URL.Path[i+1:]
+			}
+		}
+		if r.URL.Path == "/_ping" {
+			w.Header().Set("API-Version", "1.47")
+			io.WriteString(w, "OK")
+			return
+		}
+		if daemon == nil {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		daemon(w, r)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// useFakeLLM points the OpenAI client NewServer creates at a fake chat completions API that
+// answers every request with the concatenation of chunks, streamed one chunk per event when
+// the client asks for a stream. Complete answers report a usage of 12 prompt and 5
+// completion tokens. It returns the decoded request bodies it received.
+func useFakeLLM(t *testing.T, chunks ...string) *[]map[string]interface{} {
+	t.Helper()
+	var mu sync.Mutex
+	requests := &[]map[string]interface{}{}
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		*requests = append(*requests, req)
+		mu.Unlock()
+		if stream, _ := req["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range chunks {
+				data, _ := json.Marshal(map[string]interface{}{
+					"choices": []interface{}{map[string]interface{}{"index": 0, "delta": map[string]interface{}{"content": chunk}}},
+				})
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"index":         0,
+				"message":       map[string]interface{}{"role": "assistant", "content": strings.Join(chunks, "")},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]interface{}{"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17},
+		})
+	}))
+	t.Cleanup(fake.Close)
+	t.Setenv("OPENAI_BASE_URL", fake.URL)
+	return requests
+}
+
+// writeDaemonError answers like the Docker API does for a failed request.
+func writeDaemonError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// countingTool registers a tool named "count" that counts its calls and fails while *fail
+// is set.
+func countingTool(s *Server, calls *int, fail *bool) {
+	s.RegisterTool("count", "Count calls", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			if *fail {
+				return nil, errors.New("count failed")
+			}
+			*calls++
+			return map[string]interface{}{"calls": *calls}, nil
+		})
+}
+
+// tarFile returns a tar archive holding a single file.
+func tarFile(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// planUpload builds a multipart plan upload with the given build contexts.
+func planUpload(t *testing.T, plan string, contexts map[string][]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("plan", plan); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range contexts {
+		part, err := mw.CreateFormFile(name, name+".tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/plan/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// buildDaemon answers image builds with a short build log ending in buildError, if set,
+// recording the tag and the Dockerfile found in each build context.
+func buildDaemon(tags, dockerfiles *[]string, buildError string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/build" {
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+			return
+		}
+		*tags = append(*tags, r.URL.Query().Get("t"))
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			if hdr.Name == "Dockerfile" {
+				data, _ := io.ReadAll(tr)
+				*dockerfiles = append(*dockerfiles, string(data))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]string{"stream": "Step 1/1 : FROM scratch\n"})
+		if buildError != "" {
+			enc.Encode(map[string]string{"error": buildError})
+			return
+		}
+		enc.Encode(map[string]string{"stream": "Successfully built 0123456789ab\n"})
+	}
+}
+
+func TestHandlePlanUpload(t *testing.T) {
+	const buildPlan = `[{"action": "build_image", "parameters": {"tag": "app:test", "context": "app"}}]`
+	dockerfile := tarFile(t, "Dockerfile", "FROM scratch\n")
+	tests := []struct {
+		name       string
+		plan       string
+		contexts   map[string][]byte
+		buildError string
+		wantError  string
+		wantTags   []string
+	}{
+		{
+			name:     "builds the uploaded context",
+			plan:     buildPlan,
+			contexts: map[string][]byte{"app": dockerfile},
+			wantTags: []string{"app:test"},
+		},
+		{
+			name:      "context not uploaded",
+			plan:      buildPlan,
+			contexts:  map[string][]byte{"other": dockerfile},
+			wantError: `references build context "app" which was not uploaded`,
+		},
+		{
+			name:      "context missing from the action",
+			plan:      `[{"action": "build_image", "parameters": {"tag": "app:test"}}]`,
+			contexts:  map[string][]byte{"app": dockerfile},
+			wantError: "action 0 (build_image) is missing a build context",
+		},
+		{
+			name:       "build error in the output stream",
+			plan:       buildPlan,
+			contexts:   map[string][]byte{"app": dockerfile},
+			buildError: "unknown instruction: FORM",
+			wantError:  "build failed: unknown instruction: FORM",
+			wantTags:   []string{"app:test"},
+		},
+		{
+			name:      "malformed plan",
+			plan:      `{"plan": "build"}`,
+			wantError: "failed to parse plan JSON",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tags, dockerfiles []string
+			s := newTestServer(t, buildDaemon(&tags, &dockerfiles, tt.buildError))
+			rec := httptest.NewRecorder()
+			s.handlePlanUpload(rec, planUpload(t, tt.plan, tt.contexts))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			var reply mcp.RPCResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("decoding reply %s: %v", rec.Body, err)
+			}
+			if strings.Join(tags, ",") != strings.Join(tt.wantTags, ",") {
+				t.Errorf("built tags %v, want %v", tags, tt.wantTags)
+			}
+			if tt.wantError != "" {
+				if reply.Error == nil || !strings.Contains(reply.Error.Message, tt.wantError) {
+					t.Fatalf("error = %v, want one containing %q", reply.Error, tt.wantError)
+				}
+				return
+			}
+			if reply.Error != nil {
+				t.Fatalf("unexpected error: %v", reply.Error)
+			}
+			if len(dockerfiles) != 1 || dockerfiles[0] != "FROM scratch\n" {
+				t.Errorf("daemon received Dockerfiles %q, want the uploaded one", dockerfiles)
+			}
+		})
+	}
+}
+
+func TestHandlePlanUploadStreamsBuildOutput(t *testing.T) {
+	var tags, dockerfiles []string
+	s := newTestServer(t, buildDaemon(&tags, &dockerfiles, ""))
+	req := planUpload(t, `[{"action": "build_image", "parameters": {"tag": "app:test", "context": "app"}}]`,
+		map[string][]byte{"app": tarFile(t, "Dockerfile", "FROM scratch\n")})
+	req.Header.Set("Accept", ndjsonContentType)
+	rec := httptest.NewRecorder()
+	s.handlePlanUpload(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ndjsonContentType)
+	}
+	var events []uploadEvent
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var event uploadEvent
+		if err := json.Unmarshal(sc.Bytes(), &event); err != nil {
+			t.Fatalf("decoding event %s: %v", sc.Bytes(), err)
+		}
+		events = append(events, event)
+	}
+	want := []string{"Step 1/1 : FROM scratch", "[1/1] Successfully built 0123456789ab"}
+	if len(events) != len(want)+1 {
+		t.Fatalf("got %d events, want %d log lines and a result", len(events), len(want))
+	}
+	for i, line := range want {
+		if events[i].Type != "log" || events[i].Line != line {
+			t.Errorf("event %d = %+v, want log line %q", i, events[i], line)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Type != "result" || last.Response == nil || last.Response.Error != nil {
+		t.Errorf("last event %+v, want a successful result", last)
+	}
+}
+
+func TestHandlePlanUploadRequiresPost(t *testing.T) {
+	s := newTestServer(t, nil)
+	rec := httptest.NewRecorder()
+	s.handlePlanUpload(rec, httptest.NewRequest(http.MethodGet, "/plan/upload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlePlanUploadRejectsNonMultipart(t *testing.T) {
+	s := newTestServer(t, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/plan/upload", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	s.handlePlanUpload(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStartRPCServerShutsDownGracefully(t *testing.T) {
+	probe, err := net.Listen("tcp", ":1234")
+	if err != nil {
+		t.Skipf("port 1234 is not available: %v", err)
+	}
+	probe.Close()
+	created := make(chan struct{})
+	useFakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeDaemonError(w, http.StatusNotFound, "network not found")
+			return
+		}
+		// Hold the request long enough for the shutdown to start while it is in flight.
+		close(created)
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id": "n1"}`)
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	exited := make(chan error, 1)
+	go func() { exited <- StartRPCServer(ctx) }()
+
+	// Without keep-alives the transport never leaves a spare, unused connection open, which
+	// Shutdown would wait on as if it were about to send a request.
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get("http://127.0.0.1:1234/metrics")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	replies := make(chan string, 1)
+	go func() {
+		body := `{"method": "Server.CallTool", "params": [{"tool_name": "create_network", "parameters": {"name": "shop-net"}}], "id": 1}`
+		resp, err := client.Post("http://127.0.0.1:1234/rpc", "application/json", strings.NewReader(body))
+		if err != nil {
+			replies <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		replies <- string(data)
+	}()
+	<-created
+	stop()
+
+	select {
+	case reply := <-replies:
+		if !strings.Contains(reply, `"error":null`) || strings.Contains(reply, `"error":{`) {
+			t.Errorf("in-flight request got %s, want it to complete", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight request did not complete")
+	}
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("StartRPCServer() = %v, want a clean exit", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit after shutdown")
+	}
+	if _, err := client.Get("http://127.0.0.1:1234/metrics"); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+}
+
+func TestExecutePlanStopsWhenCancelled(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.RegisterTool("slow", "Block until the caller goes away", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			// The client disconnects while this action is running.
+			cancel()
+			<-ctx.Done()
+			return nil, nil
+		})
+	plan := `[{"action": "count", "parameters": {}}, {"action": "slow", "parameters": {}}, {"action": "count", "parameters": {}}, {"action": "count", "parameters": {}}]`
+	var reply mcp.RPCResponse
+	if err := s.ExecutePlan(ctx, &plan, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == nil || !strings.Contains(reply.Error.Message, "plan aborted before action 2: context canceled") {
+		t.Fatalf("error = %v, want the plan aborted before action 2", reply.Error)
+	}
+	if calls != 1 {
+		t.Errorf("count ran %d times, want only the action before the cancellation", calls)
+	}
+}
+
+func TestCallToolUsesCallerContext(t *testing.T) {
+	s := newTestServer(t, nil)
+	var seen error
+	s.RegisterTool("probe", "Report the context state", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			seen = ctx.Err()
+			return nil, ctx.Err()
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var reply mcp.RPCResponse
+	if err := s.CallTool(ctx, &mcp.ToolCallArgs{ToolName: "probe"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(seen, context.Canceled) || reply.Error == nil {
+		t.Errorf("tool saw %v and replied %v, want the caller's cancellation", seen, reply.Error)
+	}
+}
+
+func TestExecutePlanResumesAfterFailure(t *testing.T) {
+	s := newTestServer(t, nil)
+	var ran []int
+	failAt := 2
+	s.RegisterTool("step", "Record the step", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			n := int(params["n"].(float64))
+			if n == failAt {
+				return nil, errors.New("daemon went away")
+			}
+			ran = append(ran, n)
+			return map[string]interface{}{"n": n}, nil
+		})
+	actions := `[{"action": "step", "parameters": {"n": 0}}, {"action": "step", "parameters": {"n": 1}}, {"action": "step", "parameters": {"n": 2}}, {"action": "step", "parameters": {"n": 3}}]`
+	plan := `{"project": "shop", "plan": ` + actions + `}`
+	var reply mcp.RPCResponse
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == nil || !strings.Contains(reply.Error.Message, "daemon went away") {
+		t.Fatalf("error = %v, want the third action to fail", reply.Error)
+	}
+	doc, _ := mcp.ParsePlan([]byte(plan))
+	cp, err := state.LoadCheckpoint(doc.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Completed) != 2 || !cp.Completed[0] || !cp.Completed[1] {
+		t.Fatalf("checkpoint = %v, want actions 0 and 1 completed", cp.Completed)
+	}
+
+	failAt = -1
+	ran = nil
+	resume := `{"project": "shop", "resume": true, "plan": ` + actions + `}`
+	reply = mcp.RPCResponse{}
+	if err := s.ExecutePlan(context.Background(), &resume, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error != nil {
+		t.Fatalf("resume error = %v", reply.Error)
+	}
+	if len(ran) != 2 || ran[0] != 2 || ran[1] != 3 {
+		t.Errorf("resume ran steps %v, want only 2 and 3", ran)
+	}
+	var result struct {
+		Actions []struct {
+			Skipped bool `json:"skipped"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal(reply.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Actions) != 4 || !result.Actions[0].Skipped || !result.Actions[1].Skipped || result.Actions[2].Skipped {
+		t.Errorf("actions = %+v, want the first two skipped", result.Actions)
+	}
+	if cp, _ := state.LoadCheckpoint(doc.Hash()); len(cp.Completed) != 0 {
+		t.Errorf("checkpoint = %v, want it cleared after the plan completed", cp.Completed)
+	}
+}
+
+func TestExecutePlanAppliesProfile(t *testing.T) {
+	tests := []struct {
+		profile    string
+		wantMemory int64
+		wantErr    string
+	}{
+		{profile: "dev", wantMemory: 256 << 20},
+		{profile: "prod", wantMemory: 1024 << 20},
+		{profile: "", wantMemory: 128 << 20},
+		{profile: "staging", wantErr: "unknown profile: staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			var created container.CreateRequest
+			s := newTestServer(t, createDaemon(t, &created))
+			plan := `{"profile": "` + tt.profile + `", "plan": [{"action": "create_container", "parameters": {"name": "web", "image": "nginx", "memory_mb": 128}}]}`
+			var reply mcp.RPCResponse
+			if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" {
+				if reply.Error == nil || !strings.Contains(reply.Error.Message, tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", reply.Error, tt.wantErr)
+				}
+				return
+			}
+			if reply.Error != nil {
+				t.Fatalf("ExecutePlan() error = %v", reply.Error)
+			}
+			if created.HostConfig == nil || created.HostConfig.Memory != tt.wantMemory {
+				t.Errorf("host config = %+v, want memory %d", created.HostConfig, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestNewServerDockerHost(t *testing.T) {
+	// volumeDaemon records which daemon received the volume creation.
+	volumeDaemon := func(name string, got *string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/volumes/create" {
+				*got = name
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"Name": "data"}`)
+				return
+			}
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+	tests := []struct {
+		name       string
+		configured bool
+		option     bool
+		want       string
+	}{
+		{name: "environment", want: "env"},
+		{name: "configuration over environment", configured: true, want: "config"},
+		{name: "option over configuration", configured: true, option: true, want: "option"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			useFakeDaemon(t, volumeDaemon("env", &got))
+			if tt.configured {
+				configured := fakeDaemon(t, volumeDaemon("config", &got))
+				writeConfig(t, "docker_host: tcp://"+configured.Listener.Addr().String()+"\n")
+				t.Setenv("DOCKER_HOST", "")
+			}
+			var opts []ServerOption
+			if tt.option {
+				opts = append(opts, WithDockerHost("tcp://"+fakeDaemon(t, volumeDaemon("option", &got)).Listener.Addr().String()))
+			}
+			s, err := NewServer(opts...)
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			defer s.Close()
+			if _, err := s.tools["create_volume"].Handler(context.Background(), s, map[string]interface{}{"name": "data", "idempotent": false}); err != nil {
+				t.Fatalf("create_volume: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("volume created on the %s daemon, want the %s one", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServerDockerTLS(t *testing.T) {
+	useFakeDaemon(t, nil)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	_, err := NewServer(WithDockerTLS(&docker.TLSConfig{CACert: missing, Cert: missing, Key: missing}))
+	if err == nil || !strings.Contains(err.Error(), "failed to create Docker client") {
+		t.Errorf("NewServer() error = %v, want the unreadable TLS files reported", err)
+	}
+}
+
+// planActions decodes the per-action results of a successful plan response.
+func planActions(t *testing.T, reply mcp.RPCResponse) []mcp.ActionResult {
+	t.Helper()
+	if reply.Error != nil {
+		t.Fatalf("plan failed: %v", reply.Error)
+	}
+	var result mcp.PlanResult
+	if err := json.Unmarshal(reply.Result, &result); err != nil {
+		t.Fatalf("decoding result %s: %v", reply.Result, err)
+	}
+	return result.Actions
+}
+
+func TestExecutePlanReportsCreatedResources(t *testing.T) {
+	var created int
+	s := newTestServer(t, sessionDaemon(&created))
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	plan := `[{"action": "create_network", "parameters": {"name": "shop-net"}}, {"action": "count", "parameters": {}}]`
+	reply := s.executePlan(context.Background(), &plan)
+	if reply.Error != nil {
+		t.Fatalf("plan failed: %v", reply.Error)
+	}
+	var result mcp.PlanResult
+	if err := json.Unmarshal(reply.Result, &result); err != nil {
+		t.Fatalf("decoding result %s: %v", reply.Result, err)
+	}
+	if result.Status != mcp.StatusSuccess || len(result.Actions) != 2 || result.Actions[0].Result["id"] != "n1" || result.Actions[1].Result["calls"] != float64(1) {
+		t.Errorf("result = %+v, want both actions with their results", result)
+	}
+	if want := []mcp.ResourceRef{{Type: "network", Name: "shop-net", ID: "n1"}}; !reflect.DeepEqual(result.CreatedResources, want) {
+		t.Errorf("created resources = %+v, want %+v", result.CreatedResources, want)
+	}
+
+	tests := []struct {
+		tool   string
+		params map[string]interface{}
+		want   []mcp.ResourceRef
+	}{
+		{tool: "create_network", params: map[string]interface{}{"name": "web-net"}, want: []mcp.ResourceRef{{Type: "network", Name: "web-net", ID: "n1"}}},
+		{tool: "count", params: map[string]interface{}{}, want: []mcp.ResourceRef{}},
+	}
+	for _, tt := range tests {
+		var reply mcp.RPCResponse
+		if err := s.CallTool(context.Background(), &mcp.ToolCallArgs{ToolName: tt.tool, Parameters: tt.params}, &reply); err != nil || reply.Error != nil {
+			t.Fatalf("CallTool(%s) = %v, %v", tt.tool, reply.Error, err)
+		}
+		var result mcp.ToolResult
+		if err := json.Unmarshal(reply.Result, &result); err != nil {
+			t.Fatalf("decoding result %s: %v", reply.Result, err)
+		}
+		if result.Status != mcp.StatusSuccess || !reflect.DeepEqual(result.CreatedResources, tt.want) {
+			t.Errorf("CallTool(%s) = %+v, want created resources %+v", tt.tool, result, tt.want)
+		}
+	}
+}
+
+func TestExecutePlanReplaysIdempotencyKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     string
+		second    string
+		wantCalls int
+		cached    []bool
+	}{
+		{
+			name:      "plan key replays the whole result",
+			first:     `{"idempotency_key": "k", "plan": [{"action": "count", "parameters": {}}]}`,
+			second:    `{"idempotency_key": "k", "plan": [{"action": "count", "parameters": {}}]}`,
+			wantCalls: 1,
+			cached:    []bool{false},
+		},
+		{
+			name:      "different plan keys both run",
+			first:     `{"idempotency_key": "k1", "plan": [{"action": "count", "parameters": {}}]}`,
+			second:    `{"idempotency_key": "k2", "plan": [{"action": "count", "parameters": {}}]}`,
+			wantCalls: 2,
+			cached:    []bool{false},
+		},
+		{
+			name:      "action key replays the action in another plan",
+			first:     `[{"action": "count", "parameters": {}, "idempotency_key": "a"}]`,
+			second:    `[{"action": "count", "parameters": {"n": 1}, "idempotency_key": "a"}, {"action": "count", "parameters": {}}]`,
+			wantCalls: 2,
+			cached:    []bool{true, false},
+		},
+		{
+			name:      "plan and action keys do not collide",
+			first:     `[{"action": "count", "parameters": {}, "idempotency_key": "k"}]`,
+			second:    `{"idempotency_key": "k", "plan": [{"action": "count", "parameters": {}}]}`,
+			wantCalls: 2,
+			cached:    []bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			var calls int
+			var fail bool
+			countingTool(s, &calls, &fail)
+			first := s.executePlan(context.Background(), &tt.first)
+			planActions(t, first)
+			second := s.executePlan(context.Background(), &tt.second)
+			actions := planActions(t, second)
+			if calls != tt.wantCalls {
+				t.Errorf("tool ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if len(actions) != len(tt.cached) {
+				t.Fatalf("second plan reported %d actions, want %d", len(actions), len(tt.cached))
+			}
+			for i, action := range actions {
+				if action.Cached != tt.cached[i] {
+					t.Errorf("action %d cached = %v, want %v", i, action.Cached, tt.cached[i])
+				}
+			}
+			if tt.first == tt.second && string(first.Result) != string(second.Result) {
+				t.Errorf("replayed result %s, want %s", second.Result, first.Result)
+			}
+		})
+	}
+}
+
+func TestExecutePlanRefusesIdempotencyKeyInUse(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	release, err := state.ClaimIdempotencyKey(planIdempotencyKey("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := `{"idempotency_key": "k", "plan": [{"action": "count", "parameters": {}}]}`
+	reply := s.executePlan(context.Background(), &plan)
+	if reply.Error == nil || !strings.Contains(reply.Error.Message, "still running") {
+		t.Fatalf("error = %v, want the key reported as in use", reply.Error)
+	}
+	if calls != 0 {
+		t.Errorf("tool ran %d times while the key was claimed", calls)
+	}
+	release()
+	planActions(t, s.executePlan(context.Background(), &plan))
+	if calls != 1 {
+		t.Errorf("tool ran %d times after the claim was released, want 1", calls)
+	}
+}
+
+func TestExecutePlanCheckpointsReplayedActions(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	if err := storeIdempotentResult(actionIdempotencyKey("a"), map[string]interface{}{"calls": 0}); err != nil {
+		t.Fatal(err)
+	}
+	plan := `{"plan": [{"action": "count", "parameters": {}, "idempotency_key": "a"}, {"action": "count", "parameters": {}}]}`
+	fail = true
+	if reply := s.executePlan(context.Background(), &plan); reply.Error == nil {
+		t.Fatal("plan succeeded although its second action failed")
+	}
+	fail = false
+	// Forget the recorded result: the resumed plan must skip the first action because it
+	// was checkpointed when replayed, not because it is replayed again.
+	if err := state.StoreIdempotencyKey(actionIdempotencyKey("a"), nil, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	resume := `{"resume": true, "plan": [{"action": "count", "parameters": {}, "idempotency_key": "a"}, {"action": "count", "parameters": {}}]}`
+	actions := planActions(t, s.executePlan(context.Background(), &resume))
+	if len(actions) != 2 || !actions[0].Skipped || actions[1].Skipped {
+		t.Errorf("resumed actions = %+v, want the first skipped and the second run", actions)
+	}
+	if calls != 1 {
+		t.Errorf("tool ran %d times, want 1", calls)
+	}
+}
+
+func TestPlanPromptPutsSystemFirst(t *testing.T) {
+	requests := useFakeLLM(t, `{"plan": [{"action": "count", "parameters": {}}]}`)
+	s := newTestServer(t, nil)
+	writeConfig(t, "llm:\n  plan_format: document\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	var plan string
+	if err := s.CallLLM(context.Background(), &CallLLMArgs{Input: "count once"}, &plan); err != nil {
+		t.Fatalf("CallLLM() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.handleStreamPlan(rec, httptest.NewRequest(http.MethodPost, "/plan/stream", strings.NewReader(`{"input": "count once"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stream plan status = %d: %s", rec.Code, rec.Body)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("LLM received %d requests, want one per implementation", len(*requests))
+	}
+	for i, req := range *requests {
+		messages := requestMessages(t, req)
+		if len(messages) != 2 || messages[0][0] != "system" || messages[1] != [2]string{"user", "count once"} {
+			t.Errorf("request %d messages = %q, want the system prompt then the instruction", i, messages)
+			continue
+		}
+		if !strings.Contains(messages[0][1], `{"plan": [...]}`) {
+			t.Errorf("request %d system prompt does not ask for a plan document:\n%s", i, messages[0][1])
+		}
+	}
+
+	// Whatever shape the model answers in, CallLLM returns the bare array, and ExecutePlan
+	// runs it as is.
+	if plan != `[{"action":"count","parameters":{}}]` {
+		t.Errorf("CallLLM() plan = %s, want the bare array of actions", plan)
+	}
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	var reply mcp.RPCResponse
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil || reply.Error != nil {
+		t.Fatalf("ExecutePlan(CallLLM plan) = %v, %v", reply.Error, err)
+	}
+	if calls != 1 {
+		t.Errorf("ExecutePlan ran count %d times, want 1", calls)
+	}
+}
+
+func TestSystemPrompt(t *testing.T) {
+	requests := useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop"}}]`)
+	s := newTestServer(t, nil)
+	promptFile := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(promptFile, []byte("Reply with JSON only. Actions: {{.AllowedActions}}\n{{.PlanShape}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		config  string
+		want    []string
+		notWant []string
+		changed bool
+	}{
+		{
+			name:    "built-in prompt lists the tools",
+			config:  "",
+			want:    []string{"4. Use only these actions: ", "create_network, create_volume", "remove_image", "Always return a valid JSON array of actions."},
+			notWant: []string{"{{"},
+		},
+		{
+			name:    "policy narrows the actions",
+			config:  "policy:\n  denied_actions: [remove_image, remove_volume]\n",
+			want:    []string{"4. Use only these actions: ", "create_network"},
+			notWant: []string{"remove_image", "remove_volume"},
+		},
+		{
+			name:    "file override",
+			config:  "llm:\n  system_prompt_file: " + promptFile + "\n  plan_format: document\npolicy:\n  allowed_actions: [create_network, pull_image]\n",
+			want:    []string{"Reply with JSON only. Actions: create_network, pull_image\n", `{"plan": [...]}`},
+			notWant: []string{"4. Use only these actions"},
+			changed: true,
+		},
+		{
+			name:    "inline override",
+			config:  "llm:\n  system_prompt: \"Answer in JSON using {{.AllowedActions}}\"\npolicy:\n  allowed_actions: [create_volume]\n",
+			want:    []string{"Answer in JSON using create_volume"},
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, tt.config)
+			var result ReloadResult
+			if err := s.ReloadConfig(nil, &result); err != nil {
+				t.Fatalf("ReloadConfig() error = %v", err)
+			}
+			if changed := slices.Contains(result.Changed, "llm.system_prompt"); changed != tt.changed {
+				t.Errorf("reload changed %v, want llm.system_prompt reported: %v", result.Changed, tt.changed)
+			}
+			*requests = nil
+			var plan string
+			if err := s.CallLLM(context.Background(), &CallLLMArgs{Input: "create a network named shop"}, &plan); err != nil {
+				t.Fatalf("CallLLM() error = %v", err)
+			}
+			messages := requestMessages(t, (*requests)[0])
+			if len(messages) == 0 || messages[0][0] != "system" {
+				t.Fatalf("messages = %q, want the system prompt first", messages)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(messages[0][1], want) {
+					t.Errorf("system prompt does not contain %q:\n%s", want, messages[0][1])
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(messages[0][1], notWant) {
+					t.Errorf("system prompt contains %q:\n%s", notWant, messages[0][1])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizePlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "array", content: `[{"action": "count", "parameters": {}}]`, want: `[{"action":"count","parameters":{}}]`},
+		{name: "document", content: `{"plan": [{"action": "count", "parameters": {}}]}`, want: `[{"action":"count","parameters":{}}]`},
+		{name: "object without plan", content: `{"actions": []}`, wantErr: "CallLLM returned a malformed plan: plan is malformed at /: missing properties: 'plan'"},
+		{name: "action without parameters", content: `[{"action": "count"}]`, wantErr: "CallLLM returned a malformed plan: plan is malformed at /0"},
+		{name: "parameters not an object", content: `{"plan": [{"action": "count", "parameters": "all"}]}`, wantErr: "plan is malformed at /plan/0/parameters"},
+		{name: "prose", content: `Sure! Here is your plan.`, wantErr: "CallLLM returned invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizePlan(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("normalizePlan() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("normalizePlan() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
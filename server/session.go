@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+const (
+	// sessionIdleTimeout is how long a session may go without a command before it is ended.
+	sessionIdleTimeout = 30 * time.Minute
+	// sessionSweepInterval is how often idle sessions are looked for.
+	sessionSweepInterval = time.Minute
+)
+
+// sessionHelp lists the commands SendCommand understands, mirroring the plan+apply prompt.
+const sessionHelp = `Commands:
+- 'help': print this list of commands
+- 'apply': apply the current plan
+- 'down': stop containers in the project
+- 'ps': list containers in the project
+- 'quiet': turn on quiet mode (default)
+- 'verbose': turn on verbose mode
+- 'destroy': produce a plan to destroy all resources in the project
+Anything else is an instruction to plan changes to the project.`
+
+// SessionTurn is one message of a session's conversation.
+type SessionTurn struct {
+	// Role is "user" for instructions and "assistant" for the plans generated for them.
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Session is a plan+apply conversation about one project. It remembers the last plan so
+// that "apply" executes exactly what was shown.
+type Session struct {
+	ID      string        `json:"id"`
+	Project string        `json:"project"`
+	Plan    string        `json:"plan,omitempty"`
+	Verbose bool          `json:"verbose"`
+	History []SessionTurn `json:"history,omitempty"`
+	// LastUsed is when the session last received a command; idle sessions are ended.
+	LastUsed time.Time `json:"last_used"`
+}
+
+// StartSessionArgs are the arguments of StartSession.
+type StartSessionArgs struct {
+	// Project defaults to default_project from the configuration.
+	Project string `json:"project"`
+}
+
+// SessionCommandArgs are the arguments of SendCommand.
+type SessionCommandArgs struct {
+	SessionID string `json:"session_id"`
+	Command   string `json:"command"`
+}
+
+// SessionReply is the outcome of a session command.
+type SessionReply struct {
+	SessionID string `json:"session_id"`
+	Message   string `json:"message"`
+	// Plan is the plan held by the session after the command, if any.
+	Plan string `json:"plan,omitempty"`
+	// Result is the response of the plan or tool a command ran ("apply", "ps", "down").
+	Result *mcp.RPCResponse `json:"result,omitempty"`
+	// Phase is where the project is in the plan → approve → apply loop after the command.
+	// It is stored with the project, not the session, so it survives the session ending.
+	Phase state.Phase `json:"phase,omitempty"`
+}
+
+// sessions holds the open sessions, keyed by ID.
+type sessions struct {
+	mu        sync.Mutex
+	byID      map[string]*Session
+	sweepOnce sync.Once
+}
+
+// newSessionID returns a random session identifier.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// StartSession opens a session for a project.
+func (s *Server) StartSession(args *StartSessionArgs, reply *SessionReply) error {
+	project := ""
+	if args != nil {
+		project = args.Project
+	}
+	if project == "" {
+		project = s.config().DefaultProject
+	}
+	if project == "" {
+		return errors.New("StartSession requires a project name (or default_project in the configuration)")
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return fmt.Errorf("failed to create session id: %w", err)
+	}
+	s.sessions.sweepOnce.Do(func() { go s.sweepSessions(s.ctx) })
+
+	s.sessions.mu.Lock()
+	if s.sessions.byID == nil {
+		s.sessions.byID = map[string]*Session{}
+	}
+	s.sessions.byID[id] = &Session{ID: id, Project: project, LastUsed: time.Now()}
+	s.sessions.mu.Unlock()
+
+	phase := workflowPhase(project)
+	*reply = SessionReply{SessionID: id, Message: fmt.Sprintf("Started session for project %s (phase %s).\n%s", project, phase, sessionHelp), Phase: phase}
+	return nil
+}
+
+// EndSession closes a session.
+func (s *Server) EndSession(args *string, reply *SessionReply) error {
+	if args == nil || *args == "" {
+		return errors.New("EndSession requires a session id")
+	}
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	if _, ok := s.sessions.byID[*args]; !ok {
+		return fmt.Errorf("unknown session %s", *args)
+	}
+	delete(s.sessions.byID, *args)
+	*reply = SessionReply{SessionID: *args, Message: "Session ended."}
+	return nil
+}
+
+// SendCommand runs one command in a session. An instruction that is not a command asks the
+// LLM for a new plan, which "apply" executes.
+func (s *Server) SendCommand(ctx context.Context, args *SessionCommandArgs, reply *SessionReply) error {
+	if args == nil || args.SessionID == "" {
+		return errors.New("SendCommand requires a session_id")
+	}
+	command := strings.TrimSpace(args.Command)
+	if command == "" {
+		return errors.New("SendCommand requires a command")
+	}
+	// The session is copied out so commands never hold the lock while talking to Docker or
+	// the LLM. A command that changes the session sets update, which is applied to the live
+	// session afterwards so that changes made meanwhile (such as a plan recorded by CallLLM)
+	// are kept.
+	s.sessions.mu.Lock()
+	sess, ok := s.sessions.byID[args.SessionID]
+	var current Session
+	if ok {
+		sess.LastUsed = time.Now()
+		current = *sess
+		current.History = append([]SessionTurn(nil), sess.History...)
+	}
+	s.sessions.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown session %s (it may have expired)", args.SessionID)
+	}
+
+	out := SessionReply{SessionID: current.ID}
+	var update func(*Session)
+	switch strings.ToLower(command) {
+	case "help":
+		out.Message = sessionHelp
+	case "verbose", "quiet":
+		verbose := strings.EqualFold(command, "verbose")
+		current.Verbose = verbose
+		update = func(sess *Session) { sess.Verbose = verbose }
+		out.Message = fmt.Sprintf("%s mode on.", strings.ToLower(command))
+	case "ps":
+		out.Result = s.sessionTool(ctx, "project_ps", map[string]interface{}{"project": current.Project})
+		out.Message = fmt.Sprintf("Containers of project %s.", current.Project)
+	case "down":
+		out.Result = s.sessionTool(ctx, "stop_by_label", map[string]interface{}{
+			"labels": map[string]interface{}{docker.ProjectLabel: current.Project},
+		})
+		if out.Result.Error != nil {
+			out.Message = fmt.Sprintf("Stopping the containers of project %s failed: %s", current.Project, out.Result.Error.Message)
+		} else {
+			out.Message = fmt.Sprintf("Stopped the containers of project %s.", current.Project)
+		}
+	case "destroy":
+		plan, _ := json.Marshal([]map[string]interface{}{{
+			"action":     "destroy_project",
+			"parameters": map[string]interface{}{"project": current.Project},
+		}})
+		current.Plan = string(plan)
+		update = func(sess *Session) { sess.Plan = current.Plan }
+		s.proposePlan(current.Project, current.Plan)
+		out.Message = "Planned destroying all resources of the project; send 'apply' to run it."
+	case "apply":
+		if current.Plan == "" {
+			return errors.New("there is no plan to apply: describe the changes you want first")
+		}
+		planDoc, err := sessionPlanDocument(current.Project, current.Plan)
+		if err != nil {
+			return err
+		}
+		doc, err := json.Marshal(planDoc)
+		if err != nil {
+			return err
+		}
+		planJSON := string(doc)
+		var result mcp.RPCResponse
+		if err := s.ExecutePlan(ctx, &planJSON, &result); err != nil {
+			return err
+		}
+		out.Result = &result
+		if result.Error != nil {
+			out.Message = fmt.Sprintf("Applying the plan failed: %s", result.Error.Message)
+		} else {
+			out.Message = "Plan applied."
+		}
+	default:
+		plan, err := s.generatePlan(ctx, command, current.History)
+		if err != nil {
+			return err
+		}
+		turns := []SessionTurn{{Role: "user", Content: command}, {Role: "assistant", Content: plan}}
+		current.Plan = plan
+		current.History = append(current.History, turns...)
+		update = func(sess *Session) {
+			sess.Plan = plan
+			sess.History = append(append([]SessionTurn(nil), sess.History...), turns...)
+		}
+		s.proposePlan(current.Project, plan)
+		out.Message = "Review the plan; send 'apply' to run it or describe further changes."
+	}
+	if current.Verbose && current.Plan != "" {
+		out.Message += "\n" + describePlan(current.Plan)
+	}
+	out.Plan = current.Plan
+	out.Phase = workflowPhase(current.Project)
+
+	if update != nil {
+		s.sessions.mu.Lock()
+		if sess, ok := s.sessions.byID[current.ID]; ok {
+			updated := *sess
+			update(&updated)
+			s.sessions.byID[current.ID] = &updated
+		}
+		s.sessions.mu.Unlock()
+	}
+	*reply = out
+	return nil
+}
+
+// sessionHistory returns a copy of the conversation of session id.
+func (s *Server) sessionHistory(id string) ([]SessionTurn, error) {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	sess, ok := s.sessions.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown session %s (it may have expired)", id)
+	}
+	sess.LastUsed = time.Now()
+	return append([]SessionTurn(nil), sess.History...), nil
+}
+
+// recordSessionPlan appends an instruction and the plan generated for it to session id,
+// makes the plan the one "apply" runs and moves the project to awaiting_approval.
+func (s *Server) recordSessionPlan(id, input, plan string) {
+	s.sessions.mu.Lock()
+	sess, ok := s.sessions.byID[id]
+	if !ok {
+		s.sessions.mu.Unlock()
+		return
+	}
+	updated := *sess
+	updated.Plan = plan
+	updated.History = append(append([]SessionTurn(nil), sess.History...),
+		SessionTurn{Role: "user", Content: input},
+		SessionTurn{Role: "assistant", Content: plan})
+	s.sessions.byID[id] = &updated
+	s.sessions.mu.Unlock()
+	s.proposePlan(updated.Project, plan)
+}
+
+// sessionTool runs a tool for a session command through CallTool.
+func (s *Server) sessionTool(ctx context.Context, name string, params map[string]interface{}) *mcp.RPCResponse {
+	var result mcp.RPCResponse
+	_ = s.CallTool(ctx, &mcp.ToolCallArgs{ToolName: name, Parameters: params}, &result)
+	return &result
+}
+
+// describePlan renders a plan as a numbered list of actions for verbose mode.
+func describePlan(plan string) string {
+	var actions []map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &actions); err != nil {
+		return plan
+	}
+	var b strings.Builder
+	b.WriteString("Plan:")
+	for i, action := range actions {
+		params, _ := json.Marshal(action["parameters"])
+		fmt.Fprintf(&b, "\n%d. %v %s", i+1, action["action"], params)
+	}
+	return b.String()
+}
+
+// sweepSessions ends sessions that have been idle for longer than sessionIdleTimeout,
+// until ctx is cancelled.
+func (s *Server) sweepSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sessions.mu.Lock()
+			for id, sess := range s.sessions.byID {
+				if now.Sub(sess.LastUsed) > sessionIdleTimeout {
+					delete(s.sessions.byID, id)
+				}
+			}
+			s.sessions.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sessionDaemon creates networks, counting them in created, and lists the container
+// shop-web as the only member of the project.
+func sessionDaemon(created *int) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/networks/create":
+			mu.Lock()
+			*created++
+			mu.Unlock()
+			io.WriteString(w, `{"Id": "n1"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/json":
+			io.WriteString(w, `[{"Id": "c1", "Names": ["/shop-web"], "Image": "nginx", "State": "running", "Status": "Up 1 minute"}]`)
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/c1/json":
+			io.WriteString(w, `{"Id": "c1", "Name": "/shop-web", "RestartCount": 0, "State": {"Status": "running", "Running": true}, "HostConfig": {}}`)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestSessionPlanApplyPs(t *testing.T) {
+	useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop-net"}}]`)
+	plan := `[{"action":"create_network","parameters":{"name":"shop-net"}}]`
+	var created int
+	s := newTestServer(t, sessionDaemon(&created))
+	ctx := context.Background()
+
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatalf("StartSession() error = %v", err)
+	}
+	if started.SessionID == "" || !strings.Contains(started.Message, "Started session for project shop") {
+		t.Fatalf("StartSession() = %+v", started)
+	}
+	send := func(command string) SessionReply {
+		t.Helper()
+		var reply SessionReply
+		if err := s.SendCommand(ctx, &SessionCommandArgs{SessionID: started.SessionID, Command: command}, &reply); err != nil {
+			t.Fatalf("SendCommand(%q) error = %v", command, err)
+		}
+		return reply
+	}
+
+	planned := send("create a network named shop-net")
+	if planned.Plan != plan {
+		t.Errorf("plan = %s, want %s", planned.Plan, plan)
+	}
+	if created != 0 {
+		t.Fatalf("planning created %d networks, want none before apply", created)
+	}
+
+	applied := send("apply")
+	if applied.Message != "Plan applied." || applied.Result == nil || applied.Result.Error != nil {
+		t.Fatalf("apply = %+v, want the plan applied", applied)
+	}
+	if created != 1 {
+		t.Errorf("apply created %d networks, want 1", created)
+	}
+
+	ps := send("ps")
+	if ps.Result == nil || ps.Result.Error != nil {
+		t.Fatalf("ps = %+v", ps)
+	}
+	var listed struct {
+		Result struct {
+			Project    string `json:"project"`
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(ps.Result.Result, &listed); err != nil {
+		t.Fatalf("decoding ps result %s: %v", ps.Result.Result, err)
+	}
+	if listed.Result.Project != "shop" || len(listed.Result.Containers) != 1 || listed.Result.Containers[0].Name != "shop-web" {
+		t.Errorf("ps result = %s, want shop-web in project shop", ps.Result.Result)
+	}
+	if ps.Plan != plan {
+		t.Errorf("ps dropped the session plan: %q", ps.Plan)
+	}
+
+	var ended SessionReply
+	if err := s.EndSession(&started.SessionID, &ended); err != nil {
+		t.Fatalf("EndSession() error = %v", err)
+	}
+	var reply SessionReply
+	err := s.SendCommand(ctx, &SessionCommandArgs{SessionID: started.SessionID, Command: "ps"}, &reply)
+	if err == nil || !strings.Contains(err.Error(), "unknown session") {
+		t.Errorf("SendCommand() after EndSession error = %v, want unknown session", err)
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	s := newTestServer(t, nil)
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatal(err)
+	}
+	send := func(command string) (SessionReply, error) {
+		var reply SessionReply
+		err := s.SendCommand(context.Background(), &SessionCommandArgs{SessionID: started.SessionID, Command: command}, &reply)
+		return reply, err
+	}
+
+	if _, err := send("apply"); err == nil || !strings.Contains(err.Error(), "there is no plan to apply") {
+		t.Errorf("apply without a plan error = %v", err)
+	}
+	if reply, err := send("verbose"); err != nil || reply.Message != "verbose mode on." {
+		t.Errorf("verbose = %+v, %v", reply, err)
+	}
+	reply, err := send("destroy")
+	if err != nil {
+		t.Fatalf("destroy error = %v", err)
+	}
+	if !strings.Contains(reply.Plan, `"destroy_project"`) || !strings.Contains(reply.Message, `1. destroy_project {"project":"shop"}`) {
+		t.Errorf("destroy = %+v, want a destroy_project plan described in verbose mode", reply)
+	}
+	if _, err := send("  "); err == nil || !strings.Contains(err.Error(), "SendCommand requires a command") {
+		t.Errorf("blank command error = %v", err)
+	}
+
+	if err := s.StartSession(&StartSessionArgs{}, &SessionReply{}); err == nil || !strings.Contains(err.Error(), "requires a project name") {
+		t.Errorf("StartSession() without a project error = %v", err)
+	}
+}
+
+// requestMessages returns the role and content of each message of an LLM request.
+func requestMessages(t *testing.T, req map[string]interface{}) [][2]string {
+	t.Helper()
+	raw, _ := req["messages"].([]interface{})
+	var out [][2]string
+	for _, m := range raw {
+		msg, _ := m.(map[string]interface{})
+		role, _ := msg["role"].(string)
+		content, _ := msg["content"].(string)
+		out = append(out, [2]string{role, content})
+	}
+	return out
+}
+
+func TestSessionDownReportsFailure(t *testing.T) {
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonError(w, http.StatusInternalServerError, "daemon is shutting down")
+	})
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatal(err)
+	}
+	var reply SessionReply
+	if err := s.SendCommand(context.Background(), &SessionCommandArgs{SessionID: started.SessionID, Command: "down"}, &reply); err != nil {
+		t.Fatalf("down error = %v", err)
+	}
+	if reply.Result == nil || reply.Result.Error == nil {
+		t.Fatalf("down = %+v, want the tool's error in the result", reply)
+	}
+	if !strings.HasPrefix(reply.Message, "Stopping the containers of project shop failed: ") || !strings.Contains(reply.Message, reply.Result.Error.Message) {
+		t.Errorf("down message = %q, want the failure reported", reply.Message)
+	}
+}
+
+func TestSessionCommandKeepsConcurrentPlan(t *testing.T) {
+	s := newTestServer(t, nil)
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatal(err)
+	}
+	plan := `[{"action":"create_network","parameters":{"name":"shop-net"}}]`
+	// A CallLLM for the same session records its plan while "ps" is still running.
+	s.RegisterTool("project_ps", "List the project's containers", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			s.recordSessionPlan(started.SessionID, "create a network", plan)
+			return map[string]interface{}{"containers": []interface{}{}}, nil
+		})
+	for _, command := range []string{"ps", "verbose"} {
+		var reply SessionReply
+		if err := s.SendCommand(context.Background(), &SessionCommandArgs{SessionID: started.SessionID, Command: command}, &reply); err != nil {
+			t.Fatalf("%s error = %v", command, err)
+		}
+	}
+	s.sessions.mu.Lock()
+	sess := *s.sessions.byID[started.SessionID]
+	s.sessions.mu.Unlock()
+	if sess.Plan != plan || len(sess.History) != 2 || !sess.Verbose {
+		t.Errorf("session = %+v, want the recorded plan kept and verbose mode on", sess)
+	}
+}
+
+func TestFollowUpPromptIncludesPreviousPlan(t *testing.T) {
+	requests := useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop-net"}}]`)
+	s := newTestServer(t, nil)
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatal(err)
+	}
+	var first SessionReply
+	if err := s.SendCommand(context.Background(), &SessionCommandArgs{SessionID: started.SessionID, Command: "create a network named shop-net"}, &first); err != nil {
+		t.Fatalf("SendCommand() error = %v", err)
+	}
+	var plan string
+	if err := s.CallLLM(context.Background(), &CallLLMArgs{Input: "also add a volume", SessionID: started.SessionID}, &plan); err != nil {
+		t.Fatalf("CallLLM() error = %v", err)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("LLM received %d requests, want 2", len(*requests))
+	}
+	messages := requestMessages(t, (*requests)[1])
+	if len(messages) != 4 {
+		t.Fatalf("follow-up messages = %q, want system, user, assistant, user", messages)
+	}
+	if messages[0][0] != "system" {
+		t.Errorf("first message role = %s, want the system prompt first", messages[0][0])
+	}
+	want := [][2]string{
+		{"user", "create a network named shop-net"},
+		{"assistant", first.Plan},
+		{"user", "also add a volume"},
+	}
+	for i, w := range want {
+		if messages[i+1] != w {
+			t.Errorf("message %d = %q, want %q", i+1, messages[i+1], w)
+		}
+	}
+
+	history, err := s.sessionHistory(started.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[2].Content != "also add a volume" {
+		t.Errorf("session history = %+v, want the CallLLM turn recorded", history)
+	}
+}
+
+func TestCallLLMArgsAcceptsBareString(t *testing.T) {
+	var args CallLLMArgs
+	if err := json.Unmarshal([]byte(`"create a network"`), &args); err != nil || args.Input != "create a network" {
+		t.Errorf("Unmarshal(string) = %+v, %v", args, err)
+	}
+	if err := json.Unmarshal([]byte(`{"input": "pull redis", "history": [{"role": "user", "content": "hi"}]}`), &args); err != nil || args.Input != "pull redis" || len(args.History) != 1 {
+		t.Errorf("Unmarshal(object) = %+v, %v", args, err)
+	}
+}
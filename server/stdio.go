@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+)
+
+// maxStdioMessage bounds one newline-delimited request; plans can be large.
+const maxStdioMessage = 16 << 20
+
+// jsonrpcRequest is a JSON-RPC 2.0 request, as read by the stdio transport and by the HTTP
+// endpoint for MCP methods. A request without an id is a notification and gets no response.
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response. IDs are echoed verbatim, since MCP clients use
+// both numbers and strings.
+type jsonrpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *mcp.RPCError   `json:"error,omitempty"`
+}
+
+// newJSONRPCResponse builds the response to request id. A successful call always carries a
+// result, an empty object when the method returned nothing.
+func newJSONRPCResponse(id json.RawMessage, result interface{}, rpcErr *mcp.RPCError) jsonrpcResponse {
+	resp := jsonrpcResponse{Version: mcp.JSONRPCVersion, ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result = result
+		if resp.Result == nil {
+			resp.Result = struct{}{}
+		}
+	}
+	return resp
+}
+
+// serveStdio runs the MCP stdio transport: one JSON-RPC request per line on r, one response
+// per line on w. Requests are handled concurrently and responses written as they complete.
+// It returns when r reaches EOF (after in-flight requests finish) or ctx is cancelled.
+// Logs go to stderr, so w carries nothing but responses.
+func (s *Server) serveStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStdioMessage)
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+	enc := json.NewEncoder(w)
+	write := func(resp jsonrpcResponse) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := enc.Encode(resp); err != nil {
+			log.Printf("[stdio] Failed to write response: %v", err)
+		}
+	}
+
+	lines := make(chan []byte)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	defer wg.Wait()
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case l, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-scanErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			line = l
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var req jsonrpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			write(jsonrpcResponse{Version: mcp.JSONRPCVersion, ID: json.RawMessage("null"), Error: mcp.NewError(-32700, "parse error: "+err.Error())})
+			continue
+		}
+		wg.Add(1)
+		go func(req jsonrpcRequest) {
+			defer wg.Done()
+			result, rpcErr := s.dispatch(ctx, req.Method, req.Params)
+			if len(req.ID) == 0 {
+				return
+			}
+			write(newJSONRPCResponse(req.ID, result, rpcErr))
+		}(req)
+	}
+}
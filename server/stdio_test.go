@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stdioSession runs serveStdio on a pipe and returns a function sending one request line and
+// reading one response.
+func stdioSession(t *testing.T, s *Server) func(line string) map[string]interface{} {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serveStdio(ctx, inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("serveStdio() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("serveStdio did not return after stdin was closed")
+		}
+		cancel()
+	})
+	responses := bufio.NewScanner(outR)
+	return func(line string) map[string]interface{} {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if !responses.Scan() {
+			t.Fatalf("no response to %s: %v", line, responses.Err())
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(responses.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response %s: %v", responses.Bytes(), err)
+		}
+		return resp
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	send := stdioSession(t, s)
+
+	resp := send(`{"jsonrpc": "2.0", "id": "a1", "method": "Server.CallTool", "params": [{"tool_name": "count", "parameters": {}}]}`)
+	if resp["id"] != "a1" || resp["error"] != nil {
+		t.Fatalf("response = %v, want a result for id a1", resp)
+	}
+	reply, _ := resp["result"].(map[string]interface{})
+	result, _ := reply["result"].(map[string]interface{})
+	if output, _ := result["result"].(map[string]interface{}); output["calls"] != float64(1) {
+		t.Errorf("result = %v, want the tool's output", resp["result"])
+	}
+
+	// A notification is executed but gets no response, so the next line read answers the
+	// request after it.
+	notified := make(chan struct{})
+	s.RegisterTool("notify", "Signal a notification", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			close(notified)
+			return map[string]interface{}{}, nil
+		})
+	resp = send(`{"jsonrpc": "2.0", "method": "Server.CallTool", "params": {"tool_name": "notify", "parameters": {}}}` + "\n" +
+		`{"jsonrpc": "2.0", "id": 2, "method": "Server.Nope"}`)
+	if resp["id"] != float64(2) {
+		t.Errorf("response = %v, want the one for id 2", resp)
+	}
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Error("the notification was not executed")
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		wantID   interface{}
+		wantCode float64
+	}{
+		{name: "unknown method", line: `{"jsonrpc": "2.0", "id": 3, "method": "Server.Nope"}`, wantID: float64(3), wantCode: -32601},
+		{name: "unqualified method", line: `{"jsonrpc": "2.0", "id": 4, "method": "CallTool"}`, wantID: float64(4), wantCode: -32601},
+		{name: "invalid params", line: `{"jsonrpc": "2.0", "id": 5, "method": "Server.CallTool", "params": [1, 2]}`, wantID: float64(5), wantCode: -32602},
+		{name: "parse error", line: `{not json`, wantID: nil, wantCode: -32700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(tt.line)
+			rpcErr, _ := resp["error"].(map[string]interface{})
+			if resp["id"] != tt.wantID || rpcErr == nil || rpcErr["code"] != tt.wantCode {
+				t.Errorf("response = %v, want error %v for id %v", resp, tt.wantCode, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		want    string
+		wantErr string
+	}{
+		{name: "bare argument", params: `"shop"`, want: "shop"},
+		{name: "positional", params: `["shop"]`, want: "shop"},
+		{name: "empty", params: ``, want: ""},
+		{name: "null", params: `null`, want: ""},
+		{name: "too many", params: `["a", "b"]`, wantErr: "expected a single parameter, got 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			err := decodeParams(json.RawMessage(tt.params), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("decodeParams() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("decodeParams() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
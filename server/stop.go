@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// maxConcurrentStops bounds how many containers stop_by_label stops at once.
+const maxConcurrentStops = 8
+
+// stopByLabelHandler stops every running container carrying all the given labels, a few at
+// a time, and reports the outcome for each. Every container is attempted; if any could not
+// be stopped the error names each of them.
+func stopByLabelHandler(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+	rawLabels, _ := params["labels"].(map[string]interface{})
+	if len(rawLabels) == 0 {
+		return nil, errors.New("stop_by_label requires at least one label")
+	}
+	labels := make(map[string]string, len(rawLabels))
+	for k, v := range rawLabels {
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("label %s must be a string, got %T", k, v)
+		}
+		labels[k] = value
+	}
+	var timeout *int
+	if n, ok, err := numberParam(params, "timeout_seconds"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 0 {
+			return nil, fmt.Errorf("timeout_seconds must not be negative, got %v", n)
+		}
+		t := int(n)
+		timeout = &t
+	}
+
+	containers, err := docker.ListContainersByLabels(ctx, s.dockerClient, labels, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	outcomes := make([]map[string]interface{}, len(containers))
+	sem := make(chan struct{}, maxConcurrentStops)
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, id, name string) {
+			defer wg.Done()
+			outcome := map[string]interface{}{"id": id, "name": name}
+			defer func() { outcomes[i] = outcome }()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				outcome["stopped"] = false
+				outcome["error"] = ctx.Err().Error()
+				return
+			}
+			start := time.Now()
+			if err := docker.StopContainer(ctx, s.dockerClient, id, timeout); err != nil {
+				outcome["stopped"] = false
+				outcome["error"] = err.Error()
+				return
+			}
+			outcome["stopped"] = true
+			outcome["duration_ms"] = time.Since(start).Milliseconds()
+		}(i, c.ID, strings.TrimPrefix(firstContainerName(c.Names), "/"))
+	}
+	wg.Wait()
+
+	var failures []string
+	for _, outcome := range outcomes {
+		if stopped, _ := outcome["stopped"].(bool); !stopped {
+			failures = append(failures, fmt.Sprintf("%s (%s)", outcome["name"], outcome["error"]))
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("failed to stop %d of %d containers: %s", len(failures), len(containers), strings.Join(failures, "; "))
+	}
+	return map[string]interface{}{
+		"matched":    len(containers),
+		"containers": outcomes,
+	}, nil
+}
+
+// firstContainerName returns the primary name of a container, or "" if it has none.
+func firstContainerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// labelDaemon lists the containers whose labels match the request's label filter and stops
+// them on request, recording the stops and the timeout each asked for. Stopping a container
+// named in fail returns a server error.
+func labelDaemon(t *testing.T, labels map[string]map[string]string, fail string, stopped *[]string, timeouts *[]string) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/json":
+			var f map[string]map[string]bool
+			if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &f); err != nil {
+				t.Errorf("decoding filters: %v", err)
+			}
+			list := []map[string]interface{}{}
+			for name, have := range labels {
+				match := true
+				for want := range f["label"] {
+					k, v, hasValue := strings.Cut(want, "=")
+					if got, ok := have[k]; !ok || (hasValue && got != v) {
+						match = false
+					}
+				}
+				if match {
+					list = append(list, map[string]interface{}{"Id": name + "-id", "Names": []string{"/" + name}})
+				}
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i]["Id"].(string) < list[j]["Id"].(string) })
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/stop"):
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/stop")
+			if id == fail+"-id" {
+				writeDaemonError(w, http.StatusInternalServerError, "cannot kill container: permission denied")
+				return
+			}
+			mu.Lock()
+			*stopped = append(*stopped, id)
+			*timeouts = append(*timeouts, r.URL.Query().Get("t"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeDaemonError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+		}
+	}
+}
+
+func TestStopByLabel(t *testing.T) {
+	labels := map[string]map[string]string{
+		"web1": {"tier": "web", "project": "shop"},
+		"web2": {"tier": "web", "project": "shop"},
+		"web3": {"tier": "web", "project": "blog"},
+		"db":   {"tier": "db", "project": "shop"},
+	}
+	var stopped, timeouts []string
+	s := newTestServer(t, labelDaemon(t, labels, "", &stopped, &timeouts))
+	params := map[string]interface{}{"labels": map[string]interface{}{"tier": "web"}, "timeout_seconds": float64(5)}
+	got, err := s.tools["stop_by_label"].Handler(context.Background(), s, params)
+	if err != nil {
+		t.Fatalf("stop_by_label: %v", err)
+	}
+	if got["matched"] != 3 {
+		t.Errorf("matched = %v, want 3", got["matched"])
+	}
+	sort.Strings(stopped)
+	if strings.Join(stopped, ",") != "web1-id,web2-id,web3-id" {
+		t.Errorf("stopped %v, want the three web containers and not db", stopped)
+	}
+	for _, timeout := range timeouts {
+		if timeout != "5" {
+			t.Errorf("stop timeout = %q, want 5", timeout)
+		}
+	}
+	outcomes := got["containers"].([]map[string]interface{})
+	for i, outcome := range outcomes {
+		if want := fmt.Sprintf("web%d", i+1); outcome["name"] != want || outcome["stopped"] != true {
+			t.Errorf("outcome %d = %v, want %s stopped", i, outcome, want)
+		}
+	}
+}
+
+func TestStopByLabelReportsFailures(t *testing.T) {
+	labels := map[string]map[string]string{
+		"web1": {"tier": "web"},
+		"web2": {"tier": "web"},
+		"db":   {"tier": "db"},
+	}
+	var stopped, timeouts []string
+	s := newTestServer(t, labelDaemon(t, labels, "web2", &stopped, &timeouts))
+
+	_, err := s.tools["stop_by_label"].Handler(context.Background(), s, map[string]interface{}{"labels": map[string]interface{}{"tier": "web"}})
+	if err == nil || !strings.Contains(err.Error(), "failed to stop 1 of 2 containers: web2 (") || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("stop_by_label error = %v, want web2's failure", err)
+	}
+	if strings.Join(stopped, ",") != "web1-id" {
+		t.Errorf("stopped %v, want web1 attempted despite web2 failing", stopped)
+	}
+	if len(timeouts) != 1 || timeouts[0] != "" {
+		t.Errorf("stop timeouts = %q, want the container's own", timeouts)
+	}
+
+	for _, params := range []map[string]interface{}{
+		{},
+		{"labels": map[string]interface{}{"tier": float64(1)}},
+		{"labels": map[string]interface{}{"tier": "web"}, "timeout_seconds": float64(-1)},
+	} {
+		if _, err := s.tools["stop_by_label"].Handler(context.Background(), s, params); err == nil {
+			t.Errorf("stop_by_label(%v) succeeded, want an error", params)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// streamPlanRequest is the body accepted by the streaming plan endpoint.
+type streamPlanRequest struct {
+	Input string `json:"input"`
+	// History holds earlier turns of the conversation, as for CallLLM.
+	History []SessionTurn `json:"history,omitempty"`
+}
+
+// handleStreamPlan generates a plan like CallLLM but streams it as Server-Sent Events: every
+// chunk of model output is sent as a "data:" frame holding a JSON string, followed by an
+// "event: done" frame carrying the validated plan, or an "event: error" frame on failure.
+// A client disconnect cancels the request context and with it the LLM call.
+func (s *Server) handleStreamPlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "streaming plan generation requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req streamPlanRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || strings.TrimSpace(req.Input) == "" {
+		http.Error(w, `request body must be JSON of the form {"input": "..."}`, http.StatusBadRequest)
+		return
+	}
+	if err := s.checkTokenBudget(); err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, payload interface{}) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if event != "" {
+			if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	log.Printf("[StreamPlan] Received user input: %s", req.Input)
+	prompt, tools := s.planPrompt(req.Input, req.History)
+	response, err := s.llm().GeneratePlanStream(r.Context(), prompt, tools, func(_ context.Context, chunk []byte) error {
+		return send("", string(chunk))
+	})
+	recordLLMCall(response, err)
+	s.tokens.add(response)
+	s.traceToolCalls(req.Input, response, err)
+	if err != nil {
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			log.Printf("[StreamPlan] Client disconnected, generation cancelled")
+			return
+		}
+		log.Printf("[StreamPlan] OpenAI error: %v", err)
+		_ = send("error", map[string]string{"message": fmt.Sprintf("OpenAI API error: %v", err)})
+		return
+	}
+	if len(response.Choices) == 0 {
+		_ = send("error", map[string]string{"message": "received an empty response from OpenAI"})
+		return
+	}
+	plan, err := normalizePlan(response.Choices[0].Content)
+	if err != nil {
+		_ = send("error", map[string]string{"message": err.Error()})
+		return
+	}
+	_ = send("done", json.RawMessage(plan))
+}
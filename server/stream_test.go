@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseEvent is one Server-Sent Events frame.
+type sseEvent struct {
+	event string
+	data  string
+}
+
+// readEvents splits an event stream into its frames.
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if current.data != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		default:
+			t.Errorf("unexpected line %q in event stream", line)
+		}
+	}
+	return events
+}
+
+func TestHandleStreamPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunks    []string
+		wantEvent string
+		wantData  string
+	}{
+		{
+			name:      "plan",
+			chunks:    []string{`[{"action": "create_`, `network", "parameters": `, `{"name": "shop"}}]`},
+			wantEvent: "done",
+			wantData:  `[{"action":"create_network","parameters":{"name":"shop"}}]`,
+		},
+		{
+			name:      "not a plan",
+			chunks:    []string{"I cannot ", "help with that."},
+			wantEvent: "error",
+			wantData:  `{"message":"CallLLM returned invalid JSON: invalid character 'I' looking for beginning of value"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeLLM(t, tt.chunks...)
+			s := newTestServer(t, nil)
+			srv := httptest.NewServer(http.HandlerFunc(s.handleStreamPlan))
+			defer srv.Close()
+			resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"input": "create a network named shop"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", ct)
+			}
+			var body strings.Builder
+			if _, err := bufio.NewReader(resp.Body).WriteTo(&body); err != nil {
+				t.Fatal(err)
+			}
+			events := readEvents(t, body.String())
+			if len(events) != len(tt.chunks)+1 {
+				t.Fatalf("got %d events, want %d chunks and a final event:\n%s", len(events), len(tt.chunks), body.String())
+			}
+			var reconstructed strings.Builder
+			for _, e := range events[:len(tt.chunks)] {
+				var chunk string
+				if e.event != "" || json.Unmarshal([]byte(e.data), &chunk) != nil {
+					t.Fatalf("event %+v, want an unnamed frame holding a JSON string", e)
+				}
+				reconstructed.WriteString(chunk)
+			}
+			if got, want := reconstructed.String(), strings.Join(tt.chunks, ""); got != want {
+				t.Errorf("reconstructed %q from the chunks, want %q", got, want)
+			}
+			last := events[len(events)-1]
+			if last.event != tt.wantEvent || last.data != tt.wantData {
+				t.Errorf("final event = %+v, want %s with %s", last, tt.wantEvent, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestHandleStreamPlanRejectsBadRequests(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{name: "GET", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "not JSON", method: http.MethodPost, body: "create a network", wantStatus: http.StatusBadRequest},
+		{name: "empty input", method: http.MethodPost, body: `{"input": "  "}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleStreamPlan(rec, httptest.NewRequest(tt.method, "/user-input/stream", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
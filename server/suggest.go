@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// verbSynonyms maps verbs models commonly use to the verb of the equivalent registered tool.
+var verbSynonyms = map[string]string{
+	"start":    "run",
+	"launch":   "run",
+	"make":     "create",
+	"add":      "create",
+	"new":      "create",
+	"fetch":    "pull",
+	"download": "pull",
+	"delete":   "remove",
+	"rm":       "remove",
+}
+
+// unknownToolMessage describes an unknown action or tool name, suggesting the closest
+// registered tool when there is a plausible match.
+func (s *Server) unknownToolMessage(kind, name string) string {
+	if suggestion := s.suggestTool(name); suggestion != "" {
+		return fmt.Sprintf("unknown %s: %s (did you mean %s?)", kind, name, suggestion)
+	}
+	return fmt.Sprintf("unknown %s: %s", kind, name)
+}
+
+// suggestTool returns the registered tool name closest to name, or "" if none is close
+// enough. Verbs are first mapped through verbSynonyms so that e.g. "start_container"
+// matches "run_container".
+func (s *Server) suggestTool(name string) string {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	normalized = strings.NewReplacer("-", "_", " ", "_").Replace(normalized)
+	if verb, rest, ok := strings.Cut(normalized, "_"); ok {
+		if synonym, found := verbSynonyms[verb]; found {
+			normalized = synonym + "_" + rest
+		}
+	}
+
+	names := make([]string, 0, len(s.tools))
+	for toolName := range s.tools {
+		names = append(names, toolName)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", -1
+	for _, toolName := range names {
+		d := levenshtein(normalized, toolName)
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = toolName, d
+		}
+	}
+	// Accept edits touching at most a third of the name.
+	if best == "" || bestDistance > len(best)/3 {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestUnknownToolMessage(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		kind, name string
+		want       string
+	}{
+		{"action", "start_container", "unknown action: start_container (did you mean run_container?)"},
+		{"action", "create-network", "unknown action: create-network (did you mean create_network?)"},
+		{"tool", "pul_image", "unknown tool: pul_image (did you mean pull_image?)"},
+		{"tool", "Download_Image", "unknown tool: Download_Image (did you mean pull_image?)"},
+		{"action", "deploy_kubernetes", "unknown action: deploy_kubernetes"},
+		{"tool", "", "unknown tool: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.unknownToolMessage(tt.kind, tt.name); got != tt.want {
+				t.Errorf("unknownToolMessage(%q, %q) = %q, want %q", tt.kind, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"run_container", "run_container", 0},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
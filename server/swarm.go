@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+
+	"santoshkal/mcp-godocker/pkg/docker"
+)
+
+// createServiceHandler is create_container in swarm mode: the container settings are
+// translated into a replicated Swarm service of the same name.
+func createServiceHandler(ctx context.Context, s *Server, name, image string, params map[string]interface{}) (map[string]interface{}, error) {
+	if err := docker.RequireSwarmManager(ctx, s.dockerClient); err != nil {
+		return nil, err
+	}
+	if idempotent(params) {
+		existing, err := docker.FindService(ctx, s.dockerClient, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if err := checkProjectLabel(ctx, "service", name, existing.Spec.Labels); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"id": existing.ID, "existing": true, "service": true}, nil
+		}
+	}
+	hostConfig, err := parseHostConfig(s.withProjectDefaults(ctx, params))
+	if err != nil {
+		return nil, err
+	}
+	env, err := parseEnvironment(params, &s.config().Policy)
+	if err != nil {
+		return nil, err
+	}
+	if _, hostConfig.PortBindings, err = parsePorts(params); err != nil {
+		return nil, err
+	}
+	replicas := uint64(1)
+	if n, ok, err := numberParam(params, "replicas"); err != nil {
+		return nil, err
+	} else if ok {
+		if n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("replicas must be a non-negative integer, got %v", n)
+		}
+		replicas = uint64(n)
+	}
+	var networks []string
+	if raw, ok := params["networks"].([]interface{}); ok {
+		for _, item := range raw {
+			if n, ok := item.(string); ok && n != "" {
+				networks = append(networks, n)
+			}
+		}
+	}
+	config := &container.Config{Image: image, Env: env, Labels: projectLabels(ctx)}
+	if err := parseProcessConfig(params, config); err != nil {
+		return nil, err
+	}
+	spec := docker.ServiceSpec(name, config, hostConfig, replicas, networks)
+	id, err := docker.CreateService(ctx, s.dockerClient, spec)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id, "service": true, "replicas": replicas}, nil
+}
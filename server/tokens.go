@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+
+	"santoshkal/mcp-godocker/pkg/llm"
+)
+
+// TokenUsage reports the LLM tokens the server has spent on plans since it started.
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// Budget is llm.token_budget, or zero when there is no cap.
+	Budget int `json:"budget,omitempty"`
+	// Remaining is the budget left, when there is one.
+	Remaining int `json:"remaining,omitempty"`
+}
+
+// tokenCounter accumulates the token usage reported by the LLM.
+type tokenCounter struct {
+	mu         sync.Mutex
+	prompt     int
+	completion int
+}
+
+// add adds the usage reported for response.
+func (c *tokenCounter) add(response *llms.ContentResponse) {
+	prompt, completion := llm.Usage(response)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompt += prompt
+	c.completion += completion
+}
+
+// usage returns the totals so far against budget.
+func (c *tokenCounter) usage(budget int) TokenUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := TokenUsage{
+		PromptTokens:     c.prompt,
+		CompletionTokens: c.completion,
+		TotalTokens:      c.prompt + c.completion,
+		Budget:           budget,
+	}
+	if budget > 0 && u.TotalTokens < budget {
+		u.Remaining = budget - u.TotalTokens
+	}
+	return u
+}
+
+// checkTokenBudget returns an error once the configured token budget has been spent.
+func (s *Server) checkTokenBudget() error {
+	budget := s.config().LLM.TokenBudget
+	if budget <= 0 {
+		return nil
+	}
+	if u := s.tokens.usage(budget); u.TotalTokens >= budget {
+		return fmt.Errorf("LLM token budget exhausted: %d of %d tokens used (raise llm.token_budget and reload, or restart the server)", u.TotalTokens, budget)
+	}
+	return nil
+}
+
+// TokenUsage reports the cumulative LLM token usage.
+func (s *Server) TokenUsage(_ *struct{}, reply *TokenUsage) error {
+	*reply = s.tokens.usage(s.config().LLM.TokenBudget)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokenBudget(t *testing.T) {
+	requests := useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop"}}]`)
+	s := newTestServer(t, nil)
+	writeConfig(t, "llm:\n  max_tokens: 64\n  token_budget: 30\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	input := &CallLLMArgs{Input: "create a network named shop"}
+	var plan string
+	for i := 0; i < 2; i++ {
+		if err := s.CallLLM(context.Background(), input, &plan); err != nil {
+			t.Fatalf("CallLLM() #%d error = %v, want it allowed while under budget", i+1, err)
+		}
+	}
+	if got := (*requests)[0]["max_tokens"]; got != float64(64) {
+		t.Errorf("request max_tokens = %v, want 64", got)
+	}
+
+	var usage TokenUsage
+	if err := s.TokenUsage(nil, &usage); err != nil {
+		t.Fatal(err)
+	}
+	want := TokenUsage{PromptTokens: 24, CompletionTokens: 10, TotalTokens: 34, Budget: 30}
+	if usage != want {
+		t.Errorf("TokenUsage() = %+v, want %+v", usage, want)
+	}
+
+	err := s.CallLLM(context.Background(), input, &plan)
+	if err == nil || !strings.Contains(err.Error(), "LLM token budget exhausted: 34 of 30 tokens used") {
+		t.Errorf("CallLLM() error = %v, want the budget to be exhausted", err)
+	}
+	rec := httptest.NewRecorder()
+	s.handleStreamPlan(rec, httptest.NewRequest(http.MethodPost, "/plan/stream", strings.NewReader(`{"input": "`+input.Input+`"}`)))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("stream plan status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("LLM received %d requests, want none past the budget", len(*requests))
+	}
+}
+
+func TestTokenUsageWithoutBudget(t *testing.T) {
+	useFakeLLM(t, `[{"action": "list_containers", "parameters": {}}]`)
+	s := newTestServer(t, nil)
+	input := &CallLLMArgs{Input: "list the containers"}
+	var plan string
+	if err := s.CallLLM(context.Background(), input, &plan); err != nil {
+		t.Fatalf("CallLLM() error = %v", err)
+	}
+	var usage TokenUsage
+	s.TokenUsage(nil, &usage)
+	if usage != (TokenUsage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}) {
+		t.Errorf("TokenUsage() = %+v, want the reported usage and no budget", usage)
+	}
+}
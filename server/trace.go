@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// maxTraces bounds the tool-call traces kept in memory.
+const maxTraces = 100
+
+// TracedToolCall is one tool call the model made while generating a plan.
+type TracedToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	// RawArguments holds the arguments as sent by the model when they are not a JSON object.
+	RawArguments string `json:"raw_arguments,omitempty"`
+}
+
+// ToolCallTrace records what the model did for one instruction, to help diagnose why it
+// produced a bad plan.
+type ToolCallTrace struct {
+	Input     string           `json:"input"`
+	Model     string           `json:"model"`
+	At        time.Time        `json:"at"`
+	ToolCalls []TracedToolCall `json:"tool_calls"`
+	Content   string           `json:"content,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// traceToolCalls records the model's tool calls for input when trace_tool_calls is enabled
+// in the configuration. Traces are kept in memory for the ToolCallTraces RPC and written as
+// JSON files to the traces directory under the state directory.
+func (s *Server) traceToolCalls(input string, response *llms.ContentResponse, genErr error) {
+	cfg := s.config()
+	if !cfg.TraceToolCalls {
+		return
+	}
+	trace := ToolCallTrace{Input: input, Model: cfg.LLM.Model, At: time.Now().UTC(), ToolCalls: []TracedToolCall{}}
+	if genErr != nil {
+		trace.Error = genErr.Error()
+	}
+	if response != nil && len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		trace.Content = choice.Content
+		for _, call := range choice.ToolCalls {
+			if call.FunctionCall == nil {
+				continue
+			}
+			traced := TracedToolCall{ID: call.ID, Name: call.FunctionCall.Name}
+			if err := json.Unmarshal([]byte(call.FunctionCall.Arguments), &traced.Arguments); err != nil {
+				traced.RawArguments = call.FunctionCall.Arguments
+			}
+			trace.ToolCalls = append(trace.ToolCalls, traced)
+		}
+	}
+
+	s.traceMu.Lock()
+	s.traces = append(s.traces, trace)
+	if len(s.traces) > maxTraces {
+		s.traces = s.traces[len(s.traces)-maxTraces:]
+	}
+	s.traceMu.Unlock()
+
+	if err := writeTrace(trace); err != nil {
+		log.Printf("[Trace] Failed to write tool-call trace: %v", err)
+	}
+}
+
+func writeTrace(trace ToolCallTrace) error {
+	dir := filepath.Join(state.Dir(), "traces")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s.json", trace.At.Format("20060102T150405.000000000Z"))
+	return os.WriteFile(filepath.Join(dir, name), data, 0o600)
+}
+
+// ToolCallTraces returns the most recent tool-call traces, oldest first. args limits how
+// many are returned; zero or less returns all that are kept.
+func (s *Server) ToolCallTraces(args *int, reply *[]ToolCallTrace) error {
+	s.traceMu.Lock()
+	defer s.traceMu.Unlock()
+	traces := s.traces
+	if args != nil && *args > 0 && *args < len(traces) {
+		traces = traces[len(traces)-*args:]
+	}
+	*reply = append([]ToolCallTrace{}, traces...)
+	return nil
+}
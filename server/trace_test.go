@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// useToolCallingLLM points the OpenAI client at a fake model that answers with a plan and
+// the given tool calls, each a function name and its raw arguments.
+func useToolCallingLLM(t *testing.T, plan string, calls [][2]string) {
+	t.Helper()
+	toolCalls := []interface{}{}
+	for i, call := range calls {
+		toolCalls = append(toolCalls, map[string]interface{}{
+			"id":       "call_" + string(rune('a'+i)),
+			"type":     "function",
+			"function": map[string]interface{}{"name": call[0], "arguments": call[1]},
+		})
+	}
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"index":         0,
+				"message":       map[string]interface{}{"role": "assistant", "content": plan, "tool_calls": toolCalls},
+				"finish_reason": "tool_calls",
+			}},
+		})
+	}))
+	t.Cleanup(fake.Close)
+	t.Setenv("OPENAI_BASE_URL", fake.URL)
+}
+
+func TestTraceToolCalls(t *testing.T) {
+	plan := `[{"action": "create_network", "parameters": {"name": "shop"}}]`
+	useToolCallingLLM(t, plan, [][2]string{
+		{"create_network", `{"name": "shop"}`},
+		{"pull_image", `not json`},
+	})
+	s := newTestServer(t, nil)
+	writeConfig(t, "trace_tool_calls: true\n")
+	var result ReloadResult
+	if err := s.ReloadConfig(nil, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{"create a network named shop", "and pull redis"} {
+		var reply string
+		if err := s.CallLLM(context.Background(), &CallLLMArgs{Input: input}, &reply); err != nil {
+			t.Fatalf("CallLLM() error = %v", err)
+		}
+	}
+
+	var traces []ToolCallTrace
+	limit := 1
+	if err := s.ToolCallTraces(&limit, &traces); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("got %d traces, want the limit of 1", len(traces))
+	}
+	got := traces[0]
+	if got.Input != "and pull redis" || got.Model != "gpt-4o" || got.Content != plan || got.Error != "" {
+		t.Errorf("trace = %+v, want the last instruction and the model's reply", got)
+	}
+	want := []TracedToolCall{
+		{ID: "call_a", Name: "create_network", Arguments: map[string]interface{}{"name": "shop"}},
+		{ID: "call_b", Name: "pull_image", RawArguments: "not json"},
+	}
+	if !reflect.DeepEqual(got.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", got.ToolCalls, want)
+	}
+
+	if err := s.ToolCallTraces(nil, &traces); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 2 {
+		t.Errorf("got %d traces, want both", len(traces))
+	}
+	files, err := os.ReadDir(filepath.Join(state.Dir(), "traces"))
+	if err != nil || len(files) != 2 {
+		t.Errorf("trace files = %v (%v), want one per plan", files, err)
+	}
+}
+
+func TestTraceToolCallsDisabled(t *testing.T) {
+	useToolCallingLLM(t, `[]`, [][2]string{{"create_network", `{}`}})
+	s := newTestServer(t, nil)
+	input := &CallLLMArgs{Input: "create a network"}
+	var reply string
+	s.CallLLM(context.Background(), input, &reply)
+	var traces []ToolCallTrace
+	if err := s.ToolCallTraces(nil, &traces); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 0 {
+		t.Errorf("got %d traces with tracing disabled, want none", len(traces))
+	}
+	if _, err := os.Stat(filepath.Join(state.Dir(), "traces")); !os.IsNotExist(err) {
+		t.Errorf("traces directory exists with tracing disabled: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanRecorder     *tracetest.SpanRecorder
+	spanRecorderOnce sync.Once
+)
+
+// recordSpans returns a function listing the spans ended since the call. The server's tracer
+// binds to the first provider installed globally, so every test shares one recorder.
+func recordSpans(t *testing.T) func() []sdktrace.ReadOnlySpan {
+	t.Helper()
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+	seen := len(spanRecorder.Ended())
+	return func() []sdktrace.ReadOnlySpan { return spanRecorder.Ended()[seen:] }
+}
+
+// spanAttribute returns the value of the named attribute of span, or an invalid value.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestExecutePlanTracesEachAction(t *testing.T) {
+	ended := recordSpans(t)
+	var created int
+	s := newTestServer(t, sessionDaemon(&created))
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	plan := `[{"action": "create_network", "parameters": {"name": "shop-net"}}, {"action": "count", "parameters": {}}]`
+	if reply := s.executePlan(context.Background(), &plan); reply.Error != nil {
+		t.Fatalf("plan failed: %v", reply.Error)
+	}
+
+	var root sdktrace.ReadOnlySpan
+	var tools, requests []sdktrace.ReadOnlySpan
+	for _, span := range ended() {
+		switch span.Name() {
+		case "plan.execute":
+			root = span
+		case "tool.call":
+			tools = append(tools, span)
+		default:
+			requests = append(requests, span)
+		}
+	}
+	if root == nil {
+		t.Fatal("no plan.execute span recorded")
+	}
+	if root.Parent().IsValid() {
+		t.Errorf("plan.execute has parent %v, want a root span", root.Parent().SpanID())
+	}
+	if got := spanAttribute(root, "plan.actions").AsInt64(); got != 2 {
+		t.Errorf("plan.actions = %d, want 2", got)
+	}
+	if got := spanAttribute(root, "plan.completed").AsInt64(); got != 2 {
+		t.Errorf("plan.completed = %d, want 2", got)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("recorded %d tool.call spans, want 2", len(tools))
+	}
+	for i, want := range []string{"create_network", "count"} {
+		span := tools[i]
+		if span.Parent().SpanID() != root.SpanContext().SpanID() || span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("tool.call %s is not a child of plan.execute", want)
+		}
+		if got := spanAttribute(span, "tool.name").AsString(); got != want {
+			t.Errorf("tool.call %d has tool.name %q, want %q", i, got, want)
+		}
+		if got := spanAttribute(span, "tool.outcome").AsString(); got != "success" {
+			t.Errorf("tool.call %s has outcome %q, want success", want, got)
+		}
+	}
+	// create_network's request to the daemon is traced by the Docker client under its tool.call.
+	var nested bool
+	for _, span := range requests {
+		if strings.HasPrefix(span.Name(), "POST ") && strings.HasSuffix(span.Name(), "/networks/create") {
+			nested = span.Parent().SpanID() == tools[0].SpanContext().SpanID()
+		}
+	}
+	if !nested {
+		t.Errorf("no network create request span under create_network's tool.call among %d Docker request spans", len(requests))
+	}
+}
+
+func TestToolCallSpanRecordsFailure(t *testing.T) {
+	ended := recordSpans(t)
+	s := newTestServer(t, nil)
+	var calls int
+	fail := true
+	countingTool(s, &calls, &fail)
+	if _, err := s.runTool(context.Background(), s.tools["count"], map[string]interface{}{}); err == nil {
+		t.Fatal("runTool succeeded, want the tool's error")
+	}
+	var call sdktrace.ReadOnlySpan
+	for _, span := range ended() {
+		if span.Name() == "tool.call" {
+			call = span
+		}
+	}
+	if call == nil {
+		t.Fatal("no tool.call span recorded")
+	}
+	if got := spanAttribute(call, "tool.outcome").AsString(); got != "error" {
+		t.Errorf("tool.outcome = %q, want error", got)
+	}
+	if call.Status().Code.String() != "Error" || len(call.Events()) == 0 {
+		t.Errorf("span status %v with %d events, want the error recorded", call.Status(), len(call.Events()))
+	}
+}
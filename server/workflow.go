@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+// WorkflowArgs are the parameters of the AdvanceWorkflow RPC.
+type WorkflowArgs struct {
+	Project  string `json:"project"`
+	Phase    string `json:"phase"`
+	PlanHash string `json:"plan_hash,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ProjectWorkflow returns where a project is in the plan → approve → apply → observe loop.
+func (s *Server) ProjectWorkflow(args *string, reply *state.Workflow) error {
+	if args == nil || *args == "" {
+		return fmt.Errorf("ProjectWorkflow requires a project name")
+	}
+	wf, err := state.LoadWorkflow(*args)
+	if err != nil {
+		return err
+	}
+	*reply = wf
+	return nil
+}
+
+// AdvanceWorkflow moves a project to another phase, for the steps of the loop that happen
+// outside the server: a client moves to awaiting_approval once it has shown the user a plan,
+// and back to planning when the plan is rejected. ExecutePlan records the applying,
+// converged and failed phases itself.
+func (s *Server) AdvanceWorkflow(args *WorkflowArgs, reply *state.Workflow) error {
+	if args == nil || args.Project == "" {
+		return fmt.Errorf("AdvanceWorkflow requires a project name")
+	}
+	phase, err := state.ParsePhase(args.Phase)
+	if err != nil {
+		return err
+	}
+	wf, err := state.AdvanceWorkflow(args.Project, phase, args.PlanHash, args.Detail)
+	if err != nil {
+		return err
+	}
+	log.Printf("[AdvanceWorkflow] Project %s is now %s", args.Project, wf.Phase)
+	*reply = wf
+	return nil
+}
+
+// sessionPlanDocument wraps a session's plan, a JSON array of actions, in the document
+// ExecutePlan runs for the session's project.
+func sessionPlanDocument(project, plan string) (mcp.PlanDocument, error) {
+	var actions []map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &actions); err != nil {
+		return mcp.PlanDocument{}, fmt.Errorf("session plan is not valid JSON: %w", err)
+	}
+	return mcp.PlanDocument{Project: project, Plan: actions}, nil
+}
+
+// proposePlan moves a session's project to awaiting_approval for plan, so that the phase
+// outlives the session and the next session picks up where it left off. A plan replacing
+// one still awaiting approval goes back through planning first. Failures are logged rather
+// than returned: the plan itself is still shown to the user.
+func (s *Server) proposePlan(project, plan string) {
+	doc, err := sessionPlanDocument(project, plan)
+	if err != nil {
+		log.Printf("[Session] Not recording workflow phase for project %s: %v", project, err)
+		return
+	}
+	wf, err := state.LoadWorkflow(project)
+	if err == nil && wf.Phase == state.PhaseAwaitingApproval {
+		_, err = state.AdvanceWorkflow(project, state.PhasePlanning, wf.PlanHash, "plan revised")
+	}
+	if err == nil {
+		_, err = state.AdvanceWorkflow(project, state.PhaseAwaitingApproval, doc.Hash(), "")
+	}
+	if err != nil {
+		log.Printf("[Session] Failed to record workflow phase for project %s: %v", project, err)
+	}
+}
+
+// workflowPhase returns the recorded phase of project, or "" if it cannot be read.
+func workflowPhase(project string) state.Phase {
+	wf, err := state.LoadWorkflow(project)
+	if err != nil {
+		log.Printf("[Session] Failed to load workflow of project %s: %v", project, err)
+		return ""
+	}
+	return wf.Phase
+}
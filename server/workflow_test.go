@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"santoshkal/mcp-godocker/pkg/mcp"
+	"santoshkal/mcp-godocker/pkg/state"
+)
+
+func TestExecutePlanRecordsWorkflow(t *testing.T) {
+	s := newTestServer(t, nil)
+	fail := false
+	s.RegisterTool("step", "Do a step", map[string]interface{}{"type": "object"},
+		func(ctx context.Context, s *Server, params map[string]interface{}) (map[string]interface{}, error) {
+			if fail {
+				return nil, errors.New("step failed")
+			}
+			return nil, nil
+		})
+	plan := `{"project": "shop", "plan": [{"action": "step", "parameters": {}}]}`
+	project := "shop"
+
+	var wf state.Workflow
+	if err := s.AdvanceWorkflow(&WorkflowArgs{Project: project, Phase: "awaiting_approval"}, &wf); err != nil {
+		t.Fatalf("AdvanceWorkflow() error = %v", err)
+	}
+	var reply mcp.RPCResponse
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil || reply.Error != nil {
+		t.Fatalf("ExecutePlan() = %v, %v", reply.Error, err)
+	}
+	if err := s.ProjectWorkflow(&project, &wf); err != nil {
+		t.Fatalf("ProjectWorkflow() error = %v", err)
+	}
+	if wf.Phase != state.PhaseConverged {
+		t.Errorf("phase after a successful apply = %s, want converged", wf.Phase)
+	}
+
+	fail = true
+	reply = mcp.RPCResponse{}
+	if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil || reply.Error == nil {
+		t.Fatalf("ExecutePlan() = %v, %v; want the step to fail", reply.Error, err)
+	}
+	if err := s.ProjectWorkflow(&project, &wf); err != nil {
+		t.Fatalf("ProjectWorkflow() error = %v", err)
+	}
+	if wf.Phase != state.PhaseFailed || !strings.Contains(wf.Detail, "step failed") {
+		t.Errorf("workflow after a failed apply = %+v, want failed with the error", wf)
+	}
+	var phases []state.Phase
+	for _, tr := range wf.History {
+		phases = append(phases, tr.To)
+	}
+	want := []state.Phase{state.PhaseAwaitingApproval, state.PhaseApplying, state.PhaseConverged, state.PhaseApplying, state.PhaseFailed}
+	if len(phases) != len(want) {
+		t.Fatalf("history = %v, want %v", phases, want)
+	}
+	for i := range want {
+		if phases[i] != want[i] {
+			t.Errorf("history = %v, want %v", phases, want)
+			break
+		}
+	}
+}
+
+func TestAdvanceWorkflowRejections(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name    string
+		args    *WorkflowArgs
+		wantErr string
+	}{
+		{name: "missing project", args: &WorkflowArgs{Phase: "planning"}, wantErr: "AdvanceWorkflow requires a project name"},
+		{name: "unknown phase", args: &WorkflowArgs{Project: "shop", Phase: "done"}, wantErr: `unknown phase "done"`},
+		{name: "not allowed", args: &WorkflowArgs{Project: "shop", Phase: "converged"}, wantErr: "project shop cannot move from planning to converged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wf state.Workflow
+			err := s.AdvanceWorkflow(tt.args, &wf)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("AdvanceWorkflow() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecutePlanRejectsInvalidProjectNames(t *testing.T) {
+	s := newTestServer(t, nil)
+	var calls int
+	var fail bool
+	countingTool(s, &calls, &fail)
+	for _, project := range []string{"../shop", "shop/web", "-shop", ".shop"} {
+		plan := `{"project": "` + project + `", "plan": [{"action": "count", "parameters": {}}]}`
+		var reply mcp.RPCResponse
+		if err := s.ExecutePlan(context.Background(), &plan, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Error == nil || !strings.Contains(reply.Error.Message, "invalid project name") {
+			t.Errorf("ExecutePlan() for project %q = %v, want an invalid project name error", project, reply.Error)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("tool ran %d times for plans with invalid project names", calls)
+	}
+}
+
+func TestSessionRecordsWorkflow(t *testing.T) {
+	useFakeLLM(t, `[{"action": "create_network", "parameters": {"name": "shop-net"}}]`)
+	var created int
+	s := newTestServer(t, sessionDaemon(&created))
+	ctx := context.Background()
+	var started SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &started); err != nil {
+		t.Fatal(err)
+	}
+	if started.Phase != state.PhasePlanning {
+		t.Errorf("new project starts in %q, want planning", started.Phase)
+	}
+	send := func(id, command string) SessionReply {
+		t.Helper()
+		var reply SessionReply
+		if err := s.SendCommand(ctx, &SessionCommandArgs{SessionID: id, Command: command}, &reply); err != nil {
+			t.Fatalf("SendCommand(%q) error = %v", command, err)
+		}
+		return reply
+	}
+
+	planned := send(started.SessionID, "create a network named shop-net")
+	revised := send(started.SessionID, "actually, the same again")
+	if planned.Phase != state.PhaseAwaitingApproval || revised.Phase != state.PhaseAwaitingApproval {
+		t.Errorf("phases after planning = %q and %q, want awaiting_approval", planned.Phase, revised.Phase)
+	}
+	if ps := send(started.SessionID, "ps"); ps.Phase != state.PhaseAwaitingApproval {
+		t.Errorf("ps moved the project to %q", ps.Phase)
+	}
+	if applied := send(started.SessionID, "apply"); applied.Phase != state.PhaseConverged {
+		t.Errorf("phase after apply = %q, want converged", applied.Phase)
+	}
+
+	// The phase belongs to the project, so a later session resumes from it.
+	var ended SessionReply
+	if err := s.EndSession(&started.SessionID, &ended); err != nil {
+		t.Fatal(err)
+	}
+	var again SessionReply
+	if err := s.StartSession(&StartSessionArgs{Project: "shop"}, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Phase != state.PhaseConverged || !strings.Contains(again.Message, "(phase converged)") {
+		t.Errorf("second session started with %q (%q), want converged", again.Phase, again.Message)
+	}
+
+	wf, err := state.LoadWorkflow("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phases []state.Phase
+	for _, tr := range wf.History {
+		phases = append(phases, tr.To)
+	}
+	want := []state.Phase{state.PhaseAwaitingApproval, state.PhasePlanning, state.PhaseAwaitingApproval, state.PhaseApplying, state.PhaseConverged}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("history = %v, want %v", phases, want)
+	}
+	// The approved plan is the one that was applied.
+	if hash := wf.History[2].PlanHash; hash == "" || hash != wf.History[3].PlanHash {
+		t.Errorf("awaiting_approval recorded plan %q but applying %q", hash, wf.History[3].PlanHash)
+	}
+}
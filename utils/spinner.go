@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Spinner shows an animated progress indicator next to a message until stopped.
+type Spinner struct {
+	out  io.Writer
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// StartSpinner starts a spinner with the given message on stderr. When stderr is not a
+// terminal the message is printed once without animation.
+func StartSpinner(message string) *Spinner {
+	s := &Spinner{
+		out:  os.Stderr,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if !isatty.IsTerminal(os.Stderr.Fd()) {
+		fmt.Fprintln(s.out, message)
+		close(s.done)
+		return s
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(s.out, "\r%s %s", spinnerFrames[i%len(spinnerFrames)], message)
+			select {
+			case <-s.stop:
+				// Clear the spinner line.
+				fmt.Fprint(s.out, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop halts the spinner and clears its line. It is safe to call more than once.
+func (s *Spinner) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -1,14 +1,40 @@
 package utils
 
-func GetSystemPrompt() string {
-	promptTemplate := `
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Plan formats the system prompt can ask the model for. Either way CallLLM returns the bare
+// array of actions.
+const (
+	// PlanFormatArray asks for a bare JSON array of actions.
+	PlanFormatArray = "array"
+	// PlanFormatDocument asks for a JSON object whose "plan" field holds the actions.
+	PlanFormatDocument = "document"
+)
+
+// PromptData holds the values a system prompt template can refer to, e.g.
+// {{.AllowedActions}}.
+type PromptData struct {
+	// PlanShape is the sentence asking for the configured plan format.
+	PlanShape string
+	// AllowedActions lists the actions plans may use, comma separated. When empty, the
+	// default prompt only gives examples.
+	AllowedActions string
+}
+
+// DefaultSystemPrompt is the built-in system prompt template.
+const DefaultSystemPrompt = `
 You are an AI that generates structured JSON plans for Docker automation.
-Always return a valid JSON array of actions.
+{{.PlanShape}}
 	Follow these guidelines:
 1. Use the MCP protocol to manage Docker resources.
 2. Provide a step-by-step plan in JSON version 2 format as an array of actions.
-3. Always pull iage tagged as latest if no specific tagis specified.
-4. Include only valid Docker actions (e.g., create_container, run_container).
+3. Always pull the image tagged latest if no specific tag is specified.
+{{if .AllowedActions}}4. Use only these actions: {{.AllowedActions}}.{{else}}4. Include only valid Docker actions (e.g., create_container, run_container).{{end}}
+5. Never write passwords or other secrets into a plan: reference them as ${MCP_PASS_NAME}, which the server fills in from its own environment, or read them from a dotenv file with env_from_file.
 
 ---
 Example Response for creating an mysql container:
@@ -39,10 +65,10 @@ Example Response for creating an mysql container:
             "name": "mysql_container",
             "image": "mysql:latest",
             "environment": {
-                "MYSQL_ROOT_PASSWORD": "rootpassword",
+                "MYSQL_ROOT_PASSWORD": "${MCP_PASS_MYSQL_ROOT_PASSWORD}",
                 "MYSQL_DATABASE": "exampledb",
                 "MYSQL_USER": "exampleuser",
-                "MYSQL_PASSWORD": "examplepass"
+                "MYSQL_PASSWORD": "${MCP_PASS_MYSQL_PASSWORD}"
             },
             "volumes": [
                 {
@@ -71,5 +97,40 @@ Example Response for creating an mysql container:
 ---
 Do not include explanations. Do not return Markdown. Just return JSON.
 `
-	return promptTemplate
+
+// GetSystemPrompt returns the system prompt asking for a bare JSON array of actions.
+func GetSystemPrompt() string {
+	return SystemPrompt(PlanFormatArray)
+}
+
+// SystemPrompt returns the built-in system prompt asking for plans in the given format.
+func SystemPrompt(format string) string {
+	prompt, _ := RenderSystemPrompt(DefaultSystemPrompt, PromptData{PlanShape: PlanShape(format)})
+	return prompt
+}
+
+// PlanShape returns the sentence asking the model for plans in the given format.
+func PlanShape(format string) string {
+	if format == PlanFormatDocument {
+		return `Always return a valid JSON object of the form {"plan": [...]}, where "plan" is the array of actions.`
+	}
+	return "Always return a valid JSON array of actions."
+}
+
+// RenderSystemPrompt executes the system prompt template text (Go text/template syntax) with
+// data. The result must still ask for JSON output, since anything else cannot be parsed as a
+// plan.
+func RenderSystemPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("system_prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	if !strings.Contains(strings.ToLower(b.String()), "json") {
+		return "", fmt.Errorf("system prompt does not ask for JSON output: plans are parsed as JSON, so the prompt must instruct the model to reply with JSON only")
+	}
+	return b.String(), nil
 }